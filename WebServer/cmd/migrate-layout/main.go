@@ -0,0 +1,39 @@
+// Command migrate-layout moves images stored flat in IMAGE_DIR (or the S3 bucket) to the
+// camera/YYYY/MM/DD/ layout and rewrites their database rows. Stop the server first.
+package main
+
+import (
+	"log"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/storage"
+
+	"github.com/joho/godotenv"
+)
+
+func main() {
+	_ = godotenv.Load()
+
+	cfg := config.Load()
+	logger := logger.NewLogger(cfg)
+
+	db, err := sqlite.New(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to open database %s: %v", cfg.DatabasePath, err)
+	}
+	defer db.Close()
+
+	stores, err := blob.Open(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+
+	buffer := storage.NewBufferService(cfg, logger, stores, sqlite.NewImageRepository(db), sqlite.NewImageVariantRepository(db))
+	moved, err := buffer.MigrateLayout()
+	if err != nil {
+		log.Fatalf("Migration failed after moving %d images: %v", moved, err)
+	}
+	log.Printf("Moved %d images", moved)
+}
@@ -0,0 +1,102 @@
+// Command migrate shows and changes the schema version of the database at DATABASE_PATH.
+// The server applies pending migrations at startup; this command lists them and reverts
+// them, e.g. before going back to an older server. Stop the server first.
+//
+//	migrate status          list the migrations and whether they are applied
+//	migrate up [version]    apply the pending migrations up to version (default: all)
+//	migrate down [version]  revert the migrations above version (default: the newest one)
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"webserver/internal/config"
+	"webserver/internal/repository/sqlite"
+
+	"github.com/joho/godotenv"
+)
+
+const usage = "usage: migrate status | up [version] | down [version]"
+
+func main() {
+	_ = godotenv.Load()
+
+	if len(os.Args) < 2 || len(os.Args) > 3 {
+		log.Fatal(usage)
+	}
+
+	cfg := config.Load()
+	db, err := sqlite.Open(cfg.DatabasePath)
+	if err != nil {
+		log.Fatalf("Failed to open database %s: %v", cfg.DatabasePath, err)
+	}
+	defer db.Close()
+
+	current, err := db.Version()
+	if err != nil {
+		log.Fatalf("Failed to read schema version: %v", err)
+	}
+
+	switch os.Args[1] {
+	case "status":
+		printStatus(db, current)
+
+	case "up":
+		target := versionArg(sqlite.LatestVersion())
+		applied, err := db.MigrateUp(target)
+		if err != nil {
+			log.Fatalf("Migration failed after applying %d migrations: %v", applied, err)
+		}
+		log.Printf("Applied %d migrations", applied)
+
+	case "down":
+		target := versionArg(current - 1)
+		reverted, err := db.MigrateDown(target)
+		if err != nil {
+			log.Fatalf("Migration failed after reverting %d migrations: %v", reverted, err)
+		}
+		log.Printf("Reverted %d migrations", reverted)
+
+	default:
+		log.Fatal(usage)
+	}
+}
+
+// versionArg returns the version given on the command line, or def when there is none.
+func versionArg(def int) int {
+	if len(os.Args) < 3 {
+		return def
+	}
+	version, err := strconv.Atoi(os.Args[2])
+	if err != nil || version < 0 {
+		log.Fatalf("Invalid version %q", os.Args[2])
+	}
+	return version
+}
+
+// printStatus prints every migration with the time it was applied.
+func printStatus(db *sqlite.DB, current int) {
+	statuses, err := db.MigrationStatus()
+	if err != nil {
+		log.Fatalf("Failed to read migrations: %v", err)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED")
+	for _, status := range statuses {
+		applied := "pending"
+		if status.Applied {
+			applied = status.AppliedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		if status.Unknown {
+			applied += " (unknown to this version)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Description, applied)
+	}
+	w.Flush()
+
+	fmt.Printf("\nSchema version %d, latest %d\n", current, sqlite.LatestVersion())
+}
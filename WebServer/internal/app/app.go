@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"webserver/internal/config"
 	"webserver/internal/logger"
 	"webserver/internal/repository"
@@ -12,7 +13,20 @@ import (
 	"webserver/internal/route"
 	"webserver/internal/service"
 	"webserver/internal/service/ai"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/clip"
+	"webserver/internal/service/event"
+	"webserver/internal/service/health"
+	"webserver/internal/service/motion"
+	"webserver/internal/service/reconcile"
+	"webserver/internal/service/recording"
+	"webserver/internal/service/registry"
+	"webserver/internal/service/render"
+	"webserver/internal/service/retention"
+	"webserver/internal/service/rule"
 	"webserver/internal/service/storage"
+	"webserver/internal/service/stream"
+	"webserver/internal/service/tracking"
 	"webserver/internal/service/websocket"
 )
 
@@ -23,10 +37,32 @@ type App struct {
 	detectorServices []*ai.DetectorService
 	bufferService    *storage.BufferService
 	hubService       *websocket.HubService
+	streamService    *stream.ReassemblerService
+	identityService  *stream.IdentityService
+	healthService    *health.HealthService
+	recordingService *recording.RecordingService
+	clipService      *clip.ClipService
+	eventService     *event.EventService
+	zoneService      *motion.ZoneService
+	trackingService  *tracking.TrackingService
+	ruleService      *rule.RuleService
+	renderService    *render.RenderService
+	retentionService *retention.RetentionService
+	reconcileService *reconcile.ReconcileService
 	manager          *service.Manager
 	db               *sqlite.DB
 	imageRepo        repository.ImageRepository
 	detectionRepo    repository.DetectionRepository
+	variantRepo      repository.ImageVariantRepository
+	cameraRepo       repository.CameraRepository
+	cameraEventRepo  repository.CameraEventRepository
+	recordingRepo    repository.RecordingRepository
+	clipRepo         repository.ClipRepository
+	eventRepo        repository.EventRepository
+	motionZoneRepo   repository.MotionZoneRepository
+	trackRepo        repository.TrackRepository
+	ruleRepo         repository.RuleRepository
+	ruleTriggerRepo  repository.RuleTriggerRepository
 }
 
 // NewApp constructs the application, initializing all services and dependencies.
@@ -45,6 +81,16 @@ func NewApp() *App {
 	var db *sqlite.DB
 	var imageRepo repository.ImageRepository
 	var detectionRepo repository.DetectionRepository
+	var variantRepo repository.ImageVariantRepository
+	var cameraRepo repository.CameraRepository
+	var cameraEventRepo repository.CameraEventRepository
+	var recordingRepo repository.RecordingRepository
+	var clipRepo repository.ClipRepository
+	var eventRepo repository.EventRepository
+	var motionZoneRepo repository.MotionZoneRepository
+	var trackRepo repository.TrackRepository
+	var ruleRepo repository.RuleRepository
+	var ruleTriggerRepo repository.RuleTriggerRepository
 
 	db, err := sqlite.New(cfg.DatabasePath)
 	if err != nil {
@@ -54,28 +100,85 @@ func NewApp() *App {
 		logger.Info("📦 Database initialized at %s", cfg.DatabasePath)
 		imageRepo = sqlite.NewImageRepository(db)
 		detectionRepo = sqlite.NewDetectionRepository(db)
+		variantRepo = sqlite.NewImageVariantRepository(db)
+		cameraRepo = sqlite.NewCameraRepository(db)
+		cameraEventRepo = sqlite.NewCameraEventRepository(db)
+		recordingRepo = sqlite.NewRecordingRepository(db)
+		clipRepo = sqlite.NewClipRepository(db)
+		eventRepo = sqlite.NewEventRepository(db)
+		motionZoneRepo = sqlite.NewMotionZoneRepository(db)
+		trackRepo = sqlite.NewTrackRepository(db)
+		ruleRepo = sqlite.NewRuleRepository(db)
+		ruleTriggerRepo = sqlite.NewRuleTriggerRepository(db)
 	}
 
+	zones := motion.NewZoneService(cfg, logger, motionZoneRepo)
 	detectors := make([]*ai.DetectorService, 0, cfg.ProcessingWorkers)
 	for i := 0; i < cfg.ProcessingWorkers; i++ {
-		ds := ai.NewDetectorService(cfg, logger)
+		ds := ai.NewDetectorService(cfg, logger, zones)
 		detectors = append(detectors, ds)
 	}
-	buffer := storage.NewBufferService(cfg, logger, imageRepo, detectionRepo)
+	stores, err := blob.Open(cfg)
+	if err != nil {
+		logger.Error("Failed to open %s storage, falling back to %s: %v", cfg.StorageBackend, cfg.ImageDirectory, err)
+		stores = blob.NewStores(blob.NewLocalStore(cfg.ImageDirectory))
+	}
+	logger.Info("🗄️ Storing new images in %s", stores.Default().Location(""))
+	buffer := storage.NewBufferService(cfg, logger, stores, imageRepo, variantRepo)
 	hub := websocket.NewHubService(cfg, logger)
+	reassembler := stream.NewReassemblerService(cfg, logger)
+	cameras, err := registry.NewRegistryService(cfg, logger, cameraRepo)
+	if err != nil {
+		logger.Error("Refusing to start, rename the camera in the environment: %v", err)
+		os.Exit(1)
+	}
+	warnUnknownCameras(cfg, logger, cameras)
+	identity := stream.NewIdentityService(cfg, logger, cameras)
+	healthService := health.NewHealthService(cfg, logger, cameras, cameraEventRepo, hub)
+	recorder := recording.NewRecordingService(cfg, logger, recordingRepo)
+	clips := clip.NewClipService(cfg, logger, clipRepo)
+	events := event.NewEventService(cfg, logger, eventRepo, buffer)
+	tracker := tracking.NewTrackingService(cfg, logger, trackRepo)
+	rules := rule.NewRuleService(cfg, logger, ruleRepo, ruleTriggerRepo)
+	renderer := render.NewRenderService(cfg, logger, buffer, imageRepo, detectionRepo, zones, ai.DrawOverlay)
+	retainer := retention.NewRetentionService(cfg, logger, buffer, imageRepo)
+	reconciler := reconcile.NewReconcileService(cfg, logger, stores, imageRepo)
 
-	mng := service.NewManager(detectors, buffer, hub, cfg, logger)
+	mng := service.NewManager(detectors, buffer, hub, reassembler, identity, cameras, healthService, recorder, clips, events, zones,
+		tracker, rules, renderer, reconciler, retainer, cfg, logger)
 
 	return &App{
 		config:           cfg,
 		detectorServices: detectors,
 		bufferService:    buffer,
 		hubService:       hub,
+		streamService:    reassembler,
+		identityService:  identity,
+		healthService:    healthService,
+		recordingService: recorder,
+		clipService:      clips,
+		eventService:     events,
+		zoneService:      zones,
+		trackingService:  tracker,
+		ruleService:      rules,
+		renderService:    renderer,
+		retentionService: retainer,
+		reconcileService: reconciler,
 		manager:          mng,
 		logger:           logger,
 		db:               db,
 		imageRepo:        imageRepo,
 		detectionRepo:    detectionRepo,
+		variantRepo:      variantRepo,
+		cameraRepo:       cameraRepo,
+		cameraEventRepo:  cameraEventRepo,
+		recordingRepo:    recordingRepo,
+		clipRepo:         clipRepo,
+		eventRepo:        eventRepo,
+		motionZoneRepo:   motionZoneRepo,
+		trackRepo:        trackRepo,
+		ruleRepo:         ruleRepo,
+		ruleTriggerRepo:  ruleTriggerRepo,
 	}
 }
 
@@ -90,16 +193,36 @@ func (a *App) Run() error {
 	// Start background services
 	go a.bufferService.Run()
 	go a.hubService.Run()
+	go a.streamService.Run()
+	go a.healthService.Run()
+	go a.recordingService.Run()
+	go a.clipService.Run()
+	go a.bufferService.BackfillVariants()
+	go a.retentionService.Run()
+	go a.reconcileService.Run()
 
 	// Setup routes
-	router := route.SetupRoutes(a.manager, a.config, a.logger, a.imageRepo, a.detectionRepo)
+	router := route.SetupRoutes(a.manager, a.config, a.logger, a.imageRepo, a.detectionRepo, a.cameraEventRepo, a.recordingRepo, a.clipRepo, a.eventRepo,
+		a.trackRepo, a.variantRepo)
 
 	a.logger.Info("🚀 Security Camera Server\n")
 	a.logger.Info("📍 URL: http://localhost:%d\n", a.config.Port)
 	a.logger.Info("🔑 Password: %s\n", a.config.Password)
 	a.logger.Info("📁 Images: %s\n", a.config.ImageDirectory)
+	a.logger.Info("🎥 Recordings: %s\n", a.config.RecordingDirectory)
 	a.logger.Info("🤖 AI Model: %s\n", a.config.ModelPath)
 	a.logger.Info("📦 Database: %s\n", a.config.DatabasePath)
 
 	return http.ListenAndServe(fmt.Sprintf(":%d", a.config.Port), router)
 }
+
+// warnUnknownCameras reports per-camera settings naming cameras that are not registered,
+// for example after a camera was renamed through the API.
+func warnUnknownCameras(cfg *config.Config, logger *logger.Logger, cameras *registry.RegistryService) {
+	for camera, variables := range cfg.CameraSettings() {
+		if _, exists := cameras.GetByName(camera); exists || strings.EqualFold(camera, recording.AllCameras) {
+			continue
+		}
+		logger.Warning("⚠️  %s name camera %s, which is not registered", strings.Join(variables, ", "), camera)
+	}
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -12,35 +13,215 @@ import (
 
 type CameraID string
 
+const (
+	// CameraAuthToken accepts only datagrams signed with a registered camera secret.
+	CameraAuthToken = "token"
+	// CameraAuthIP additionally accepts unsigned datagrams from registered camera IPs.
+	CameraAuthIP = "ip"
+)
+
+// CameraToken identifies a camera by the ID in its datagram header and the shared HMAC secret.
+type CameraToken struct {
+	Name   string
+	Secret string
+}
+
 type Config struct {
-	Port              int
-	Password          string
-	ModelPath         string
-	ConfigPath        string
-	ImageDirectory    string
-	ProcessingWorkers int
-	LogDirectory      string
-	DatabasePath      string
-	CamerasPort       int
-	CameraNames       map[string]string
+	Port                int
+	Password            string
+	ModelPath           string
+	ConfigPath          string
+	ModelDescriptor     string // JSON file describing the model input and output, optional
+	DetectorBackend     string // opencv, http or fake
+	DetectorURL         string
+	DetectorTimeoutMs   int
+	LabelMap            string // JSON file with class names and their translations, optional
+	LabelLanguage       string
+	DetectAllow         map[string][]string // camera name -> classes recorded, all when absent
+	DetectDeny          map[string][]string // camera name -> classes never recorded
+	DetectThresholds    map[string]float64  // class -> minimum confidence
+	DetectZoneRule      string              // how detections are matched to detect zones: center, overlap or iou
+	DetectZoneMinPct    float64             // default minimum overlap, in percent, for the overlap and iou rules
+	ImageDirectory      string
+	StorageBackend      string // blob store new images are written to: local or s3
+	S3Endpoint          string
+	S3Bucket            string
+	S3Region            string
+	S3AccessKey         string
+	S3SecretKey         string
+	S3Prefix            string
+	AnnotateCacheSize   int                // annotated images kept in memory for /api/pictures/view
+	ThumbnailWidth      int                // width in pixels of the gallery thumbnails
+	PreviewWidth        int                // width in pixels of the medium previews
+	StorageQuotaGB      float64            // total size of stored images, 0 for no limit
+	CameraQuotasGB      map[string]float64 // camera name -> size of its stored images
+	RetentionDays       int                // maximum image age, 0 keeps images forever
+	RetentionCameraDays map[string]float64 // camera name -> maximum image age in days
+	RetentionPriority   []string           // labels whose images quotas delete last
+	RetentionIntervalS  int
+	ReconcileIntervalS  int     // 0 reconciles only on request
+	ReconcileRepair     bool    // scheduled passes fix problems instead of only reporting them
+	ReconcileMaxMissing float64 // percent of a store's images that may lack a file before repair is refused
+	ProcessingWorkers   int
+	LogDirectory        string
+	DatabasePath        string
+	SpoolDir            string // durable journal of detection frames waiting to be stored
+	SpoolMaxMB          int    // 0 for no limit
+	SpoolSegmentMB      int
+	CamerasPort         int
+	CameraNames         map[string]string      // seeds the camera registry on first start
+	CameraTokens        map[uint16]CameraToken // seeds the camera registry on first start
+	CameraAuthMode      string
+	FrameTimeoutMs      int
+	CameraOfflineAfterS int
+	CameraOnlineFrames  int
+	RecordCameras       []string // camera names recorded continuously, "all" for every camera
+	RecordingDirectory  string
+	RecordingSegmentS   int
+	RecordingMaxDays    int     // maximum segment age, 0 keeps segments forever
+	RecordingQuotaGB    float64 // total size of recordings, 0 for no limit
+	ClipDirectory       string
+	ClipMaxDays         int     // maximum clip age, 0 keeps clips forever
+	ClipQuotaGB         float64 // total size of clips, 0 for no limit
+	ClipPreRollS        int
+	ClipPostRollS       int
+	ClipMaxDurationS    int
+	EventGapS           int
+	TrackIoU            float64           // minimum box overlap for a detection to continue a track
+	TrackMaxAgeS        int               // seconds an unseen object keeps its track
+	StationaryIoU       float64           // minimum box overlap for a detection to count as the same stationary object
+	StationaryRefreshS  int               // seconds before an unchanged stationary object is saved again, 0 disables suppression
+	MotionThresholdPct  float64           // default share of a zone (or the frame) in percent that has to change
+	MotionDetector      string            // default motion detection strategy
+	MotionDetectors     map[string]string // camera name -> motion detection strategy
+	MotionBlurSize      int
+	MotionMinAreaPct    float64 // smallest moving blob, in percent of the frame, that is kept
+	SceneLumaJump       float64 // mean brightness change (0-255) treated as a scene change
+	SceneChangePct      float64 // share of changed pixels, in percent, treated as a scene change
 }
 
 // Load reads configuration from environment variables and returns a Config instance.
 func Load() *Config {
 	return &Config{
-		Port:              getEnvAsInt("PORT", 80),
-		Password:          getEnv("PASSWORD", ""),
-		ModelPath:         getEnv("MODEL_PATH", filepath.Join(".", "internal", "service", "ai", "frozen_inference_graph.pb")),
-		ConfigPath:        getEnv("CONFIG_PATH", filepath.Join(".", "internal", "service", "ai", "ssd_mobilenet_v1_coco_2017_11_17.pbtxt")),
-		ImageDirectory:    getEnv("IMAGE_DIR", filepath.Join(".", "static", "images")),
-		LogDirectory:      getEnv("LOG_DIR", filepath.Join(".", "logs")),
-		DatabasePath:      getEnv("DATABASE_PATH", filepath.Join(".", "data", "images.db")),
-		ProcessingWorkers: getEnvAsInt("PROCESSING_WORKERS", 4), // 4 worker threads of ai processing
-		CamerasPort:       getEnvAsInt("CAMERAS_PORT", 81),
-		CameraNames:       parseCameraEnv(getEnv("CAMERAS", "")),
+		Port:                getEnvAsInt("PORT", 80),
+		Password:            getEnv("PASSWORD", ""),
+		ModelPath:           getEnv("MODEL_PATH", filepath.Join(".", "internal", "service", "ai", "frozen_inference_graph.pb")),
+		ConfigPath:          getEnv("CONFIG_PATH", filepath.Join(".", "internal", "service", "ai", "ssd_mobilenet_v1_coco_2017_11_17.pbtxt")),
+		ModelDescriptor:     getEnv("MODEL_DESCRIPTOR", ""),
+		DetectorBackend:     getEnv("DETECTOR_BACKEND", "opencv"),
+		DetectorURL:         getEnv("DETECTOR_URL", ""),
+		DetectorTimeoutMs:   getEnvAsInt("DETECTOR_TIMEOUT_MS", 5000),
+		LabelMap:            getEnv("LABEL_MAP", ""),
+		LabelLanguage:       getEnv("LABEL_LANGUAGE", "pl"),
+		DetectAllow:         parseCameraListEnv(getEnv("DETECT_ALLOW", "")),      // "name:class|class" pairs
+		DetectDeny:          parseCameraListEnv(getEnv("DETECT_DENY", "")),       // "name:class|class" pairs
+		DetectThresholds:    parseThresholdsEnv(getEnv("DETECT_THRESHOLDS", "")), // "class:confidence" pairs
+		DetectZoneRule:      getEnv("DETECT_ZONE_RULE", "center"),
+		DetectZoneMinPct:    getEnvAsFloat("DETECT_ZONE_MIN_PERCENT", 25),
+		ImageDirectory:      getEnv("IMAGE_DIR", filepath.Join(".", "static", "images")),
+		StorageBackend:      getEnv("STORAGE_BACKEND", "local"),
+		S3Endpoint:          getEnv("S3_ENDPOINT", ""),
+		S3Bucket:            getEnv("S3_BUCKET", ""),
+		S3Region:            getEnv("S3_REGION", "us-east-1"),
+		S3AccessKey:         getEnv("S3_ACCESS_KEY", ""),
+		S3SecretKey:         getEnv("S3_SECRET_KEY", ""),
+		S3Prefix:            getEnv("S3_PREFIX", ""),
+		AnnotateCacheSize:   getEnvAsInt("ANNOTATE_CACHE_SIZE", 64),
+		ThumbnailWidth:      getEnvAsInt("THUMBNAIL_WIDTH", 320),
+		PreviewWidth:        getEnvAsInt("PREVIEW_WIDTH", 1024),
+		StorageQuotaGB:      getEnvAsFloat("STORAGE_QUOTA_GB", 0),
+		CameraQuotasGB:      parseThresholdsEnv(getEnv("STORAGE_CAMERA_QUOTAS", "")), // "name:GB" pairs
+		RetentionDays:       getEnvAsInt("RETENTION_DAYS", 0),
+		RetentionCameraDays: parseThresholdsEnv(getEnv("RETENTION_CAMERA_DAYS", "")), // "name:days" pairs
+		RetentionPriority:   parseListEnv(getEnv("RETENTION_PRIORITY", "")),
+		RetentionIntervalS:  getEnvAsInt("RETENTION_INTERVAL", 600),   // enforce policies every 10 minutes
+		ReconcileIntervalS:  getEnvAsInt("RECONCILE_INTERVAL", 86400), // compare files and database daily
+		ReconcileRepair:     getEnvAsBool("RECONCILE_REPAIR", false),
+		ReconcileMaxMissing: getEnvAsFloat("RECONCILE_MAX_MISSING_PERCENT", 10),
+		LogDirectory:        getEnv("LOG_DIR", filepath.Join(".", "logs")),
+		DatabasePath:        getEnv("DATABASE_PATH", filepath.Join(".", "data", "images.db")),
+		SpoolDir:            getEnv("SPOOL_DIR", filepath.Join(".", "data", "spool")),
+		SpoolMaxMB:          getEnvAsInt("SPOOL_MAX_MB", 512),
+		SpoolSegmentMB:      getEnvAsInt("SPOOL_SEGMENT_MB", 16),
+		ProcessingWorkers:   getEnvAsInt("PROCESSING_WORKERS", 4), // 4 worker threads of ai processing
+		CamerasPort:         getEnvAsInt("CAMERAS_PORT", 81),
+		CameraNames:         parseCameraEnv(getEnv("CAMERAS", "")),
+		CameraTokens:        parseCameraTokensEnv(getEnv("CAMERA_TOKENS", "")),
+		CameraAuthMode:      getEnv("CAMERA_AUTH_MODE", CameraAuthToken),
+		FrameTimeoutMs:      getEnvAsInt("FRAME_TIMEOUT_MS", 2000),   // drop partially received frames after 2s
+		CameraOfflineAfterS: getEnvAsInt("CAMERA_OFFLINE_AFTER", 10), // seconds without frames before a camera is offline
+		CameraOnlineFrames:  getEnvAsInt("CAMERA_ONLINE_FRAMES", 3),  // frames needed to mark a camera online again
+		RecordCameras:       parseListEnv(getEnv("RECORD_CAMERAS", "")),
+		RecordingDirectory:  getEnv("RECORDING_DIR", filepath.Join(".", "recordings")),
+		RecordingSegmentS:   getEnvAsInt("RECORDING_SEGMENT", 300), // 5-minute segments
+		RecordingMaxDays:    getEnvAsInt("RECORDING_RETENTION_DAYS", 7),
+		RecordingQuotaGB:    getEnvAsFloat("RECORDING_QUOTA_GB", 0),
+		ClipDirectory:       getEnv("CLIP_DIR", filepath.Join(".", "clips")),
+		ClipMaxDays:         getEnvAsInt("CLIP_RETENTION_DAYS", 0),
+		ClipQuotaGB:         getEnvAsFloat("CLIP_QUOTA_GB", 0),
+		ClipPreRollS:        getEnvAsInt("CLIP_PRE_ROLL", 5),      // seconds before a detection kept in event clips
+		ClipPostRollS:       getEnvAsInt("CLIP_POST_ROLL", 5),     // seconds after the last detection kept in event clips
+		ClipMaxDurationS:    getEnvAsInt("CLIP_MAX_DURATION", 60), // upper bound for clips extended by repeated detections
+		EventGapS:           getEnvAsInt("EVENT_GAP", 30),         // detections closer than this are merged into one event
+		TrackIoU:            getEnvAsFloat("TRACK_IOU", 0.3),
+		TrackMaxAgeS:        getEnvAsInt("TRACK_MAX_AGE", 5),
+		StationaryIoU:       getEnvAsFloat("STATIONARY_IOU", 0.8),
+		StationaryRefreshS:  getEnvAsInt("STATIONARY_REFRESH", 600), // re-save parked objects every 10 minutes
+		MotionThresholdPct:  getEnvAsFloat("MOTION_THRESHOLD_PERCENT", 0.2),
+		MotionDetector:      getEnv("MOTION_DETECTOR", "diff"),
+		MotionDetectors:     parseCameraEnv(getEnv("MOTION_DETECTORS", "")), // "name:strategy" pairs
+		MotionBlurSize:      getEnvAsInt("MOTION_BLUR", 5),                  // Gaussian kernel size, 0 disables blurring
+		MotionMinAreaPct:    getEnvAsFloat("MOTION_MIN_AREA_PERCENT", 0.02),
+		SceneLumaJump:       getEnvAsFloat("SCENE_LUMA_JUMP", 25),
+		SceneChangePct:      getEnvAsFloat("SCENE_CHANGE_PERCENT", 60),
+	}
+}
+
+// CameraSettings returns, for every camera name mentioned in a per-camera environment
+// variable, the names of those variables.
+func (c *Config) CameraSettings() map[string][]string {
+	settings := make(map[string][]string)
+	add := func(variable string, cameras []string) {
+		sort.Strings(cameras)
+		for _, camera := range cameras {
+			settings[camera] = append(settings[camera], variable)
+		}
+	}
+
+	add("DETECT_ALLOW", keys(c.DetectAllow))
+	add("DETECT_DENY", keys(c.DetectDeny))
+	add("MOTION_DETECTORS", keys(c.MotionDetectors))
+	add("RECORD_CAMERAS", append([]string(nil), c.RecordCameras...))
+	add("STORAGE_CAMERA_QUOTAS", keys(c.CameraQuotasGB))
+	add("RETENTION_CAMERA_DAYS", keys(c.RetentionCameraDays))
+	return settings
+}
+
+// RenameCameraKey moves the entry of a renamed camera in a map keyed by camera name.
+// The new name takes over the old name's entry, or loses its own when there is none,
+// so settings and state follow the camera.
+func RenameCameraKey[V any](entries map[string]V, oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+	value, exists := entries[oldName]
+	delete(entries, oldName)
+	if exists {
+		entries[newName] = value
+	} else {
+		delete(entries, newName)
 	}
 }
 
+// keys returns the keys of a map keyed by camera name.
+func keys[V any](entries map[string]V) []string {
+	names := make([]string, 0, len(entries))
+	for name := range entries {
+		names = append(names, name)
+	}
+	return names
+}
+
 // getEnv returns the environment variable value or a default if empty.
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -59,14 +240,43 @@ func getEnvAsInt(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getEnvAsFloat returns the float value of an environment variable or a default value.
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
+// getEnvAsBool returns the boolean value of an environment variable or a default value.
+func getEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+// parseListEnv parses a comma-separated list, skipping empty entries.
+func parseListEnv(envValue string) []string {
+	var items []string
+	for _, item := range strings.Split(envValue, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseCameraEnv parses "ip:name" pairs separated by commas.
 func parseCameraEnv(envValue string) map[string]string {
 	cameras := make(map[string]string)
 
 	if envValue == "" {
-		return map[string]string{
-			"192.168.1.32": "drzwi",
-			"192.168.1.29": "brama",
-		}
+		return cameras
 	}
 
 	pairs := strings.Split(envValue, ",")
@@ -80,3 +290,50 @@ func parseCameraEnv(envValue string) map[string]string {
 	}
 	return cameras
 }
+
+// parseCameraListEnv parses "name:item|item" pairs separated by commas.
+func parseCameraListEnv(envValue string) map[string][]string {
+	lists := make(map[string][]string)
+
+	for camera, items := range parseCameraEnv(envValue) {
+		for _, item := range strings.Split(items, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				lists[camera] = append(lists[camera], item)
+			}
+		}
+	}
+	return lists
+}
+
+// parseThresholdsEnv parses "name:value" pairs separated by commas, skipping invalid values.
+func parseThresholdsEnv(envValue string) map[string]float64 {
+	thresholds := make(map[string]float64)
+
+	for name, value := range parseCameraEnv(envValue) {
+		if threshold, err := strconv.ParseFloat(value, 64); err == nil {
+			thresholds[name] = threshold
+		}
+	}
+	return thresholds
+}
+
+// parseCameraTokensEnv parses "id:name:secret" triples separated by commas.
+func parseCameraTokensEnv(envValue string) map[uint16]CameraToken {
+	tokens := make(map[uint16]CameraToken)
+
+	for _, entry := range strings.Split(envValue, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		id, err := strconv.ParseUint(strings.TrimSpace(parts[0]), 10, 16)
+		if err != nil {
+			continue
+		}
+		tokens[uint16(id)] = CameraToken{
+			Name:   strings.TrimSpace(parts[1]),
+			Secret: strings.TrimSpace(parts[2]),
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,24 @@
+package dto
+
+import "time"
+
+// CameraHealth describes the live state of a camera as seen by the server.
+type CameraHealth struct {
+	Camera         string    `json:"camera"`
+	Status         string    `json:"status"`
+	Since          time.Time `json:"since"`
+	LastFrame      time.Time `json:"lastFrame"`
+	FPS            float64   `json:"fps"`
+	LastFrameSize  int       `json:"lastFrameSize"`
+	AvgFrameSize   int       `json:"avgFrameSize"`
+	Frames         uint64    `json:"frames"`
+	DecodeFailures uint64    `json:"decodeFailures"`
+	SceneChanges   uint64    `json:"sceneChanges"`
+	Suppressed     uint64    `json:"suppressed"` // frames not saved because they only showed unchanged stationary objects
+}
+
+// StatusMessage is broadcast to viewers over the websocket when a camera changes state.
+type StatusMessage struct {
+	Type string `json:"type"`
+	CameraHealth
+}
@@ -0,0 +1,20 @@
+package dto
+
+import "webserver/internal/model"
+
+// CameraRequest is the body for creating or updating a camera. Omitted fields keep
+// their current value on update.
+type CameraRequest struct {
+	Name      *string `json:"name"`
+	DeviceID  *int    `json:"device_id"`
+	Secret    *string `json:"secret"`
+	IPAddress *string `json:"ip_address"`
+	Enabled   *bool   `json:"enabled"`
+}
+
+// CameraResponse returns a camera together with its secret. It is only used when a
+// camera is created so the secret can be copied into the firmware once.
+type CameraResponse struct {
+	model.Camera
+	Secret string `json:"secret,omitempty"`
+}
@@ -0,0 +1,9 @@
+package dto
+
+// ClipInfo describes the event clip recorded around a gallery image.
+type ClipInfo struct {
+	ID       int64   `json:"id"`
+	URL      string  `json:"url"`
+	Duration float64 `json:"duration"` // seconds
+	Frames   int     `json:"frames"`
+}
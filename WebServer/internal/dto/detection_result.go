@@ -7,4 +7,6 @@ type DetectionResult struct {
 	Y          int
 	Width      int
 	Height     int
+	Zone       string // detect zone the object was found in
+	TrackID    int64  // object track across frames
 }
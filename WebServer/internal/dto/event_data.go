@@ -0,0 +1,19 @@
+package dto
+
+import "webserver/internal/model"
+
+// EventsData is a paginated response payload for the events list.
+type EventsData struct {
+	Events      []model.Event `json:"events"`
+	Length      int           `json:"length"`
+	TotalPages  int           `json:"totalPages"`
+	CurrentPage int           `json:"currentPage"`
+	Limit       int           `json:"pageSize"`
+	Cameras     []string      `json:"cameras"`
+}
+
+// EventDetail is an event together with the images grouped into it.
+type EventDetail struct {
+	Event  model.Event `json:"event"`
+	Images []ImageInfo `json:"images"`
+}
@@ -3,6 +3,7 @@ package dto
 // BufferedImage holds image data and detection results before flushing to disk.
 
 type BufferedImage struct {
+	Filename   string
 	Timestamp  string
 	Camera     string
 	Detections []DetectionResult
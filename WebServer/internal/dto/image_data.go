@@ -5,9 +5,10 @@ type ImagesData struct {
 	Images      []ImageInfo `json:"images"`
 	ImagesDir   string      `json:"imagesDir"`
 	Size        int64       `json:"size"`
-	MaxSize     int64       `json:"maxSize"`
+	MaxSize     float64     `json:"maxSize"` // storage quota in GB, 0 without a limit
 	Length      int         `json:"length"`
 	TotalPages  int         `json:"totalPages"`
 	CurrentPage int         `json:"currentPage"`
 	Limit       int         `json:"pageSize"`
+	Cameras     []string    `json:"cameras"` // registered camera names for the filter list
 }
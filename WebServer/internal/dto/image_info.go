@@ -12,6 +12,9 @@ type ImageInfo struct {
 	TimeOfDay time.Time `json:"timeOfDay"`
 	Camera    string    `json:"camera"`
 	Objects   []string  `json:"objects"` // Multiple detected objects
+	Clip      *ClipInfo `json:"clip,omitempty"`
+	Thumbnail string    `json:"thumbnail,omitempty"` // URL of the thumbnail, when one was generated
+	Preview   string    `json:"preview,omitempty"`   // URL of the medium preview, when one was generated
 }
 
 // MarshalJSON customizes JSON output for ImageInfo to format date and time-of-day.
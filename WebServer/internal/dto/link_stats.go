@@ -0,0 +1,21 @@
+package dto
+
+import "time"
+
+// LinkStats holds per-camera UDP link quality counters reported by the frame reassembler.
+type LinkStats struct {
+	Camera       string    `json:"camera"`
+	Packets      uint64    `json:"packets"`
+	Bytes        uint64    `json:"bytes"`
+	Frames       uint64    `json:"frames"`
+	LegacyFrames uint64    `json:"legacyFrames"`
+	Lost         uint64    `json:"lost"`       // frames whose chunks never arrived
+	Incomplete   uint64    `json:"incomplete"` // frames dropped with missing chunks
+	Reordered    uint64    `json:"reordered"`  // frames whose chunks arrived out of order
+	Late         uint64    `json:"late"`       // chunks of frames older than the last delivered one
+	Duplicates   uint64    `json:"duplicates"`
+	Corrupted    uint64    `json:"corrupted"` // checksum or header errors
+	Replayed     uint64    `json:"replayed"`  // signed chunks from an earlier camera boot
+	Pending      int       `json:"pending"`
+	LastPacket   time.Time `json:"lastPacket"`
+}
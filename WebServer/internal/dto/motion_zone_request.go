@@ -0,0 +1,14 @@
+package dto
+
+import "webserver/internal/model"
+
+// MotionZoneRequest is the body for creating or updating a motion zone. Omitted
+// fields keep their current value on update.
+type MotionZoneRequest struct {
+	Camera           *string       `json:"camera"`
+	Name             *string       `json:"name"`
+	Type             *string       `json:"type"`
+	Points           []model.Point `json:"points"`
+	ThresholdPercent *float64      `json:"threshold_percent"`
+	Enabled          *bool         `json:"enabled"`
+}
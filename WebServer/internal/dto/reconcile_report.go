@@ -0,0 +1,26 @@
+package dto
+
+import "time"
+
+// ReconcileReport is the response payload for the storage reconciliation endpoint.
+type ReconcileReport struct {
+	StartedAt   time.Time       `json:"startedAt"`
+	DryRun      bool            `json:"dryRun"`      // nothing was changed
+	Files       int             `json:"files"`       // files listed in the stores
+	Rows        int             `json:"rows"`        // image rows checked
+	Relinked    int             `json:"relinked"`    // rows pointed to their file found under another key
+	Flagged     int             `json:"flagged"`     // rows whose file is missing, flagged in the database
+	Quarantined int             `json:"quarantined"` // files without a row moved to quarantine/
+	Skipped     int             `json:"skipped"`     // recent files without a row, possibly still being stored
+	Failed      int             `json:"failed"`
+	Items       []ReconcileItem `json:"items"` // the first problems found
+}
+
+// ReconcileItem describes a problem found by reconciliation and what was done about it.
+type ReconcileItem struct {
+	Action   string `json:"action"` // relinked, flagged, quarantined or failed
+	Storage  string `json:"storage"`
+	Key      string `json:"key"`
+	Filename string `json:"filename,omitempty"` // image row, if any
+	Reason   string `json:"reason"`
+}
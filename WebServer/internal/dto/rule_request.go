@@ -0,0 +1,16 @@
+package dto
+
+import "webserver/internal/model"
+
+// RuleRequest is the body for creating or updating a rule. Omitted fields keep
+// their current value on update.
+type RuleRequest struct {
+	Camera       *string       `json:"camera"`
+	Name         *string       `json:"name"`
+	Type         *string       `json:"type"`
+	Labels       []string      `json:"labels"`
+	Points       []model.Point `json:"points"`
+	Direction    *string       `json:"direction"`
+	DwellSeconds *float64      `json:"dwell_seconds"`
+	Enabled      *bool         `json:"enabled"`
+}
@@ -0,0 +1,15 @@
+package dto
+
+// SpoolStats is the response payload for the image spool statistics endpoint.
+type SpoolStats struct {
+	Accepted        uint64            `json:"accepted"`        // images written to the spool
+	Stored          uint64            `json:"stored"`          // images drained into the store and database
+	Dropped         uint64            `json:"dropped"`         // images rejected because the spool was full or failing
+	DroppedByCamera map[string]uint64 `json:"droppedByCamera"` // camera name -> dropped images
+	Backlog         int               `json:"backlog"`         // images waiting in the spool
+	BacklogBytes    int64             `json:"backlogBytes"`
+	Failing         bool              `json:"failing"`     // storing the oldest spooled image is failing
+	DeadLetters     int               `json:"deadLetters"` // images given up on, kept in the spool dead-letter file
+	DeadLetterBytes int64             `json:"deadLetterBytes"`
+	LastError       string            `json:"lastError,omitempty"`
+}
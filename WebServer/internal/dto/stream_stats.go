@@ -0,0 +1,17 @@
+package dto
+
+import "time"
+
+// RejectedSource summarizes UDP traffic dropped because it could not be attributed to a camera.
+type RejectedSource struct {
+	Source     string    `json:"source"`
+	Count      uint64    `json:"count"`
+	LastReason string    `json:"lastReason"`
+	LastSeen   time.Time `json:"lastSeen"`
+}
+
+// StreamStats is the response payload for the UDP stream statistics endpoint.
+type StreamStats struct {
+	Cameras  []LinkStats      `json:"cameras"`
+	Rejected []RejectedSource `json:"rejected"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "webserver/internal/model"
+
+// TracksData is a paginated response payload for the object track list.
+type TracksData struct {
+	Tracks      []model.Track `json:"tracks"`
+	Length      int           `json:"length"`
+	TotalPages  int           `json:"totalPages"`
+	CurrentPage int           `json:"currentPage"`
+	Limit       int           `json:"pageSize"`
+}
@@ -1,22 +1,22 @@
 package handler
 
 import (
-	"bytes"
+	"encoding/json"
+	"errors"
 	"net"
+	"net/http"
 	"strconv"
-	"strings"
 	"webserver/internal/config"
+	"webserver/internal/dto"
 	"webserver/internal/logger"
 	"webserver/internal/service"
-)
-
-var (
-	jpegHeader = []byte{0xFF, 0xD8}
-	jpegFooter = []byte{0xFF, 0xD9}
+	"webserver/internal/service/stream"
 )
 
 // UDPCameraHandler listens for UDP packets from cameras, reconstructs JPEG frames,
-// and forwards complete frames to the Manager for processing.
+// and forwards complete frames to the Manager for processing. Signed framed datagrams
+// identify the camera by ID; unsigned and legacy headerless datagrams are only accepted
+// from known IPs when the IP fallback mode is enabled.
 func UDPCameraHandler(manager *service.Manager, logger *logger.Logger, config *config.Config) {
 	port := strconv.Itoa(config.CamerasPort)
 
@@ -37,7 +37,8 @@ func UDPCameraHandler(manager *service.Manager, logger *logger.Logger, config *c
 	logger.Info("UDP Camera handler started on port %s", port)
 	buffer := make([]byte, 2048)
 
-	cameraBuffers := make(map[string]*bytes.Buffer)
+	reassembler := manager.GetStreamService()
+	identity := manager.GetIdentityService()
 
 	for {
 		n, remoteAddr, err := conn.ReadFromUDP(buffer)
@@ -46,28 +47,55 @@ func UDPCameraHandler(manager *service.Manager, logger *logger.Logger, config *c
 			continue
 		}
 
-		ip := strings.Split(remoteAddr.String(), ":")[0]
-		cameraName, exists := config.CameraNames[ip]
-		if !exists {
-			cameraName = "unknown_" + ip
+		ip := remoteAddr.IP.String()
+		data := buffer[:n]
+
+		var frame []byte
+		var cameraName string
+		pkt, err := stream.ParsePacket(data)
+		switch {
+		case err == nil:
+			if cameraName, err = identity.Identify(pkt, ip); err != nil {
+				if name, ok := identity.LookupName(pkt.CameraID); ok && errors.Is(err, stream.ErrReplayed) {
+					reassembler.RecordReplayed(name)
+				}
+				identity.Reject(ip, err)
+				continue
+			}
+			frame = reassembler.AddChunk(cameraName, pkt)
+		case errors.Is(err, stream.ErrNotFramed):
+			if cameraName, err = identity.IdentifyByIP(ip); err != nil {
+				identity.Reject(ip, err)
+				continue
+			}
+			frame = reassembler.AddLegacy(cameraName, data)
+		default:
+			// Corrupted payloads cannot be verified; count them against the claimed camera
+			if pkt != nil {
+				if name, ok := identity.LookupName(pkt.CameraID); ok {
+					reassembler.RecordCorrupted(name)
+				}
+			}
+			identity.Reject(ip, err)
 		}
 
-		data := buffer[:n]
-		if _, ok := cameraBuffers[cameraName]; !ok {
-			cameraBuffers[cameraName] = new(bytes.Buffer)
+		if frame != nil {
+			manager.HandleCameraImage(frame, cameraName)
 		}
-		imgBuffer := cameraBuffers[cameraName]
+	}
+}
 
-		if bytes.HasPrefix(data, jpegHeader) {
-			imgBuffer.Reset()
+// StreamStatsHandler returns per-camera UDP link quality counters and rejected traffic as JSON.
+func StreamStatsHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := dto.StreamStats{
+			Cameras:  manager.GetStreamService().GetStats(),
+			Rejected: manager.GetIdentityService().GetRejections(),
 		}
-		imgBuffer.Write(data)
 
-		if bytes.HasSuffix(data, jpegFooter) {
-			fullFrame := make([]byte, imgBuffer.Len())
-			copy(fullFrame, imgBuffer.Bytes())
-			manager.HandleCameraImage(fullFrame, cameraName)
-			imgBuffer.Reset()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(data); err != nil {
+			logger.Error("Error encoding JSON response: %v", err)
 		}
 	}
 }
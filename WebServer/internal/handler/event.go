@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service"
+	"webserver/internal/service/event"
+)
+
+// EventsHandler serves detection events:
+// GET lists events with the gallery filters (camera, object, dateAfter, dateBefore,
+// timeAfter, timeBefore, page, limit), GET ?id= returns one event with its images
+// and DELETE ?id= removes an event together with its images.
+func EventsHandler(manager *service.Manager, logger *logger.Logger, eventRepo repository.EventRepository,
+	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if eventRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("id") == "" {
+				listEvents(w, r, manager, logger, eventRepo)
+				return
+			}
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			getEvent(w, id, logger, eventRepo, imageRepo, detectionRepo)
+
+		case http.MethodDelete:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			if err := manager.GetEventService().Delete(id); err != nil {
+				if errors.Is(err, event.ErrNotFound) {
+					http.Error(w, "Event not found", http.StatusNotFound)
+					return
+				}
+				logger.Error("Error deleting event %d: %v", id, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// listEvents writes a filtered, paginated page of events.
+func listEvents(w http.ResponseWriter, r *http.Request, manager *service.Manager, logger *logger.Logger,
+	eventRepo repository.EventRepository) {
+	filter := parseImageFilters(r)
+
+	events, err := eventRepo.GetAll(filter)
+	if err != nil {
+		logger.Error("Error querying events: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if events == nil {
+		events = []model.Event{}
+	}
+
+	totalCount, err := eventRepo.GetTotalCount(filter)
+	if err != nil {
+		logger.Error("Error counting events: %v", err)
+		totalCount = len(events)
+	}
+
+	writeJSON(w, logger, http.StatusOK, dto.EventsData{
+		Events:      events,
+		Length:      totalCount,
+		TotalPages:  (totalCount + filter.Limit - 1) / filter.Limit,
+		CurrentPage: filter.Page,
+		Limit:       filter.Limit,
+		Cameras:     manager.GetRegistryService().GetNames(),
+	})
+}
+
+// getEvent writes one event with the stored images that belong to it.
+func getEvent(w http.ResponseWriter, id int64, logger *logger.Logger, eventRepo repository.EventRepository,
+	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository) {
+	ev, err := eventRepo.GetByID(id)
+	if err != nil {
+		logger.Error("Error getting event %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if ev == nil {
+		http.Error(w, "Event not found", http.StatusNotFound)
+		return
+	}
+
+	filenames, err := eventRepo.GetImageFilenames(id)
+	if err != nil {
+		logger.Error("Error getting images for event %d: %v", id, err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	detail := dto.EventDetail{Event: *ev, Images: []dto.ImageInfo{}}
+	for _, filename := range filenames {
+		if imageRepo == nil {
+			break
+		}
+		img, err := imageRepo.GetByFilename(filename)
+		if err != nil {
+			logger.Error("Error getting image %s: %v", filename, err)
+			continue
+		}
+		if img == nil {
+			// Not flushed to disk yet or deleted from the gallery
+			continue
+		}
+
+		objects := []string{}
+		if detectionRepo != nil {
+			if objects, err = detectionRepo.GetObjectNamesByImageID(img.ID); err != nil {
+				logger.Error("Error getting objects for image %d: %v", img.ID, err)
+				objects = []string{}
+			}
+		}
+
+		detail.Images = append(detail.Images, dto.ImageInfo{
+			Name:      img.Filename,
+			Date:      img.Timestamp,
+			TimeOfDay: img.Timestamp,
+			Camera:    img.Camera,
+			Objects:   objects,
+		})
+	}
+
+	writeJSON(w, logger, http.StatusOK, detail)
+}
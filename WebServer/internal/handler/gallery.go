@@ -1,10 +1,14 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/url"
 	"strconv"
 	"time"
 	"webserver/internal/config"
@@ -12,32 +16,20 @@ import (
 	"webserver/internal/logger"
 	"webserver/internal/repository"
 	"webserver/internal/service"
+	"webserver/internal/service/reconcile"
+	"webserver/internal/service/storage"
 )
 
-const (
-	// MaxImageDirectorySize defines the maximum size of the image directory in GB.
-	MaxImageDirectorySize = 2
-)
-
-// GetPicturesFromDBHandler returns filtered list of images from database.
+// GetPicturesFromDBHandler returns filtered list of images from database, with the
+// event clip recorded around each image and the URLs of its thumbnail and preview
+// when they exist.
 func GetPicturesFromDBHandler(manager *service.Manager, cfg *config.Config, logger *logger.Logger,
-	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository) http.HandlerFunc {
+	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository,
+	clipRepo repository.ClipRepository, variantRepo repository.ImageVariantRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 
-		q := r.URL.Query()
-		page := atoiDefault(q.Get("page"), 1)
-		limit := atoiDefault(q.Get("limit"), 24)
-
-		filter := &dto.ImageFilters{
-			Camera:     q.Get("camera"),
-			Object:     q.Get("object"),
-			DateAfter:  parseDate(q.Get("dateAfter")),
-			DateBefore: parseDate(q.Get("dateBefore")),
-			TimeAfter:  parseTimeOfDay(q.Get("timeAfter")),
-			TimeBefore: parseTimeOfDay(q.Get("timeBefore")),
-			Limit:      limit,
-			Page:       page,
-		}
+		filter := parseImageFilters(r)
+		page, limit := filter.Page, filter.Limit
 
 		images, err := imageRepo.GetAll(filter)
 		if err != nil {
@@ -71,24 +63,58 @@ func GetPicturesFromDBHandler(manager *service.Manager, cfg *config.Config, logg
 				}
 			}
 
-			pictures = append(pictures, dto.ImageInfo{
+			var clipInfo *dto.ClipInfo
+			if clipRepo != nil {
+				clip, err := clipRepo.GetByImage(img.Filename)
+				if err != nil {
+					logger.Error("Error getting clip for image %d: %v", img.ID, err)
+				} else if clip != nil && clip.Complete && clip.Frames > 0 {
+					clipInfo = &dto.ClipInfo{
+						ID:       clip.ID,
+						URL:      fmt.Sprintf("/api/clips/stream?id=%d", clip.ID),
+						Duration: clip.EndTime.Sub(clip.StartTime).Seconds(),
+						Frames:   clip.Frames,
+					}
+				}
+			}
+
+			info := dto.ImageInfo{
 				Name:      img.Filename,
 				Date:      img.Timestamp,
 				TimeOfDay: img.Timestamp,
 				Camera:    img.Camera,
 				Objects:   objects,
-			})
+				Clip:      clipInfo,
+			}
+			if variantRepo != nil {
+				variants, err := variantRepo.GetByImageID(img.ID)
+				if err != nil {
+					logger.Error("Error getting variants for image %d: %v", img.ID, err)
+				}
+				for _, v := range variants {
+					link := fmt.Sprintf("/api/pictures/view?image=%s&size=%s", url.QueryEscape(img.Filename), v.Size)
+					switch v.Size {
+					case storage.VariantThumb:
+						info.Thumbnail = link
+					case storage.VariantMedium:
+						info.Preview = link
+					}
+				}
+			}
+
+			pictures = append(pictures, info)
 		}
 
 		data := dto.ImagesData{
 			Images:      pictures,
 			ImagesDir:   cfg.ImageDirectory,
 			Size:        totalSize,
-			MaxSize:     MaxImageDirectorySize,
+			MaxSize:     cfg.StorageQuotaGB,
 			Length:      totalCount,
 			TotalPages:  (totalCount + limit - 1) / limit,
 			CurrentPage: page,
 			Limit:       limit,
+			Cameras:     manager.GetRegistryService().GetNames(),
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -98,9 +124,9 @@ func GetPicturesFromDBHandler(manager *service.Manager, cfg *config.Config, logg
 	}
 }
 
-// DeletePictureHandler removes an image from disk and database.
-func DeletePictureHandler(manager *service.Manager, cfg *config.Config, logger *logger.Logger,
-	imageRepo repository.ImageRepository) http.HandlerFunc {
+// DeletePictureHandler removes an image, its variants and its database rows from the
+// store the image was saved to.
+func DeletePictureHandler(manager *service.Manager, cfg *config.Config, logger *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		filename := r.URL.Query().Get("filename")
 		if filename == "" {
@@ -108,17 +134,10 @@ func DeletePictureHandler(manager *service.Manager, cfg *config.Config, logger *
 			return
 		}
 
-		// Delete file from disk
-		filePath := filepath.Join(cfg.ImageDirectory, filename)
-		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
-			logger.Error("Failed to delete file %s: %v", filePath, err)
-		}
-
-		// Delete from database if available
-		if imageRepo != nil {
-			if err := imageRepo.DeleteByFilename(filename); err != nil {
-				logger.Error("Failed to delete from database: %v", err)
-			}
+		if err := manager.GetBufferService().DeleteImage(filename); err != nil {
+			logger.Error("Failed to delete picture %s: %v", filename, err)
+			http.Error(w, "Failed to delete picture", http.StatusInternalServerError)
+			return
 		}
 
 		logger.Info("Deleted picture: %s", filename)
@@ -127,48 +146,154 @@ func DeletePictureHandler(manager *service.Manager, cfg *config.Config, logger *
 	}
 }
 
-// ClearPicturesWithDBHandler deletes all files from the image directory and clears the database.
-func ClearPicturesWithDBHandler(manager *service.Manager, cfg *config.Config, logger *logger.Logger,
-	imageRepo repository.ImageRepository) http.HandlerFunc {
+// ClearPicturesWithDBHandler deletes all images from every store and clears the database.
+func ClearPicturesWithDBHandler(manager *service.Manager, cfg *config.Config, logger *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		files, err := os.ReadDir(cfg.ImageDirectory)
-		if err != nil {
-			logger.Error("Error reading pictures directory: %v", err)
-			http.Error(w, "Unable to read pictures directory", http.StatusInternalServerError)
+		if err := manager.GetBufferService().ClearImages(); err != nil {
+			logger.Error("Error clearing pictures: %v", err)
+			http.Error(w, "Unable to clear pictures", http.StatusInternalServerError)
 			return
 		}
 
-		for _, file := range files {
-			if !file.IsDir() {
-				filePath := filepath.Join(cfg.ImageDirectory, file.Name())
-				err := os.Remove(filePath)
-				if err != nil {
-					logger.Error("Error deleting file %s: %v", file.Name(), err)
-				}
-			}
+		logger.Info("All pictures cleared")
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ViewPictureHandler serves a single image specified via the "image" query parameter
+// from the store it was saved to. The "size" parameter selects the thumb or medium
+// variant, falling back to the original until the variant exists. With annotate=true
+// the stored detections, track IDs and camera zones are drawn onto the original.
+// Responses carry an ETag for revalidation.
+func ViewPictureHandler(manager *service.Manager, config *config.Config, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		image := r.URL.Query().Get("image")
+		if image == "" {
+			http.Error(w, "Image parameter is required", http.StatusBadRequest)
+			return
 		}
 
-		if imageRepo != nil {
-			if err := imageRepo.DeleteAll(); err != nil {
-				logger.Error("Error clearing database: %v", err)
+		if annotate, _ := strconv.ParseBool(r.URL.Query().Get("annotate")); annotate {
+			rendered, err := manager.GetRenderService().Annotated(image)
+			if errors.Is(err, fs.ErrNotExist) {
+				http.Error(w, "Image not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to annotate image %s: %v", image, err)
+				http.Error(w, "Failed to annotate image", http.StatusInternalServerError)
+				return
 			}
+
+			// Zones and detections can change, so the browser has to revalidate
+			sum := sha256.Sum256(rendered)
+			w.Header().Set("ETag", fmt.Sprintf(`"%x"`, sum[:16]))
+			w.Header().Set("Cache-Control", "private, no-cache")
+			http.ServeContent(w, r, image, time.Time{}, bytes.NewReader(rendered))
+			return
 		}
 
-		logger.Info("All pictures cleared from directory: %s", cfg.ImageDirectory)
-		w.WriteHeader(http.StatusNoContent)
+		size := r.URL.Query().Get("size")
+		switch size {
+		case "", "original":
+			size = ""
+		case storage.VariantThumb, storage.VariantMedium:
+		default:
+			http.Error(w, "Size must be thumb, medium or original", http.StatusBadRequest)
+			return
+		}
+
+		data, info, served, err := manager.GetBufferService().ReadImage(image, size)
+		if errors.Is(err, fs.ErrNotExist) {
+			http.Error(w, "Image not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to read image %s: %v", image, err)
+			http.Error(w, "Failed to read image", http.StatusInternalServerError)
+			return
+		}
+
+		// The original stands in for a variant that is not created yet, so the browser
+		// must not keep it under the variant's URL
+		if served != size {
+			w.Header().Set("Cache-Control", "private, no-cache")
+			http.ServeContent(w, r, image, time.Time{}, bytes.NewReader(data))
+			return
+		}
+
+		// Stored images never change, only get deleted
+		w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, info.ModTime.UnixNano(), info.Size))
+		w.Header().Set("Cache-Control", "private, max-age=86400")
+		http.ServeContent(w, r, image, info.ModTime, bytes.NewReader(data))
 	}
 }
 
-// ViewPictureHandler serves a single image file specified via the "image" query parameter.
-func ViewPictureHandler(config *config.Config) http.HandlerFunc {
+// SpoolStatsHandler returns the image spool counters and backlog as JSON.
+func SpoolStatsHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		image := r.URL.Query().Get("image")
-		if image == "" {
-			http.Error(w, "Image parameter is required", http.StatusBadRequest)
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(manager.GetBufferService().Stats()); err != nil {
+			logger.Error("Error encoding JSON response: %v", err)
+		}
+	}
+}
+
+// ReconcileHandler compares the stored files with the database: GET returns the report
+// of the last pass and POST runs a pass now, which only reports the problems unless
+// ?repair=true is given.
+func ReconcileHandler(manager *service.Manager, logger *logger.Logger, imageRepo repository.ImageRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if imageRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		var report *dto.ReconcileReport
+		switch r.Method {
+		case http.MethodGet:
+			if report = manager.GetReconcileService().LastReport(); report == nil {
+				http.Error(w, "No reconciliation has run yet", http.StatusNotFound)
+				return
+			}
+
+		case http.MethodPost:
+			repair, _ := strconv.ParseBool(r.URL.Query().Get("repair"))
+			var err error
+			if report, err = manager.GetReconcileService().Reconcile(!repair, time.Now()); err != nil {
+				if errors.Is(err, reconcile.ErrTooManyMissing) {
+					http.Error(w, err.Error(), http.StatusConflict)
+					return
+				}
+				logger.Error("Failed to reconcile stored images: %v", err)
+				http.Error(w, "Failed to reconcile stored images", http.StatusInternalServerError)
+				return
+			}
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		filePath := filepath.Join(config.ImageDirectory, image)
-		http.ServeFile(w, r, filePath)
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(report); err != nil {
+			logger.Error("Error encoding JSON response: %v", err)
+		}
+	}
+}
+
+// parseImageFilters reads the gallery filter and pagination query parameters.
+func parseImageFilters(r *http.Request) *dto.ImageFilters {
+	q := r.URL.Query()
+	return &dto.ImageFilters{
+		Camera:     q.Get("camera"),
+		Object:     q.Get("object"),
+		DateAfter:  parseDate(q.Get("dateAfter")),
+		DateBefore: parseDate(q.Get("dateBefore")),
+		TimeAfter:  parseTimeOfDay(q.Get("timeAfter")),
+		TimeBefore: parseTimeOfDay(q.Get("timeBefore")),
+		Limit:      atoiDefault(q.Get("limit"), 24),
+		Page:       atoiDefault(q.Get("page"), 1),
 	}
 }
 
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service"
+)
+
+// CameraStatusHandler returns the server-side health (online/offline, FPS, frame size,
+// decode failures) of every registered camera, or of one camera with ?camera=.
+func CameraStatusHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		healthService := manager.GetHealthService()
+
+		camera := r.URL.Query().Get("camera")
+		if camera == "" {
+			writeJSON(w, logger, http.StatusOK, healthService.GetStatus())
+			return
+		}
+
+		status, exists := healthService.GetStatusByName(camera)
+		if !exists {
+			http.Error(w, "Camera not found", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, logger, http.StatusOK, status)
+	}
+}
+
+// CameraEventsHandler returns recent camera state transitions and scene changes,
+// optionally filtered by ?camera= and ?type= (online, offline, scene_change).
+func CameraEventsHandler(logger *logger.Logger, cameraEventRepo repository.CameraEventRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cameraEventRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		q := r.URL.Query()
+		events, err := cameraEventRepo.GetRecent(q.Get("camera"), q.Get("type"), atoiDefault(q.Get("limit"), 50))
+		if err != nil {
+			logger.Error("Error querying camera events: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if events == nil {
+			events = []model.CameraEvent{}
+		}
+		writeJSON(w, logger, http.StatusOK, events)
+	}
+}
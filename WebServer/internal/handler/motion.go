@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/service"
+	"webserver/internal/service/motion"
+)
+
+// MotionZonesHandler manages motion detection zones:
+// GET lists zones (optionally ?camera=, or one with ?id=), POST creates a zone,
+// PUT ?id= updates it and DELETE ?id= removes it.
+func MotionZonesHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		zones := manager.GetMotionZoneService()
+
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("id") == "" {
+				writeJSON(w, logger, http.StatusOK, zones.GetAll(r.URL.Query().Get("camera")))
+				return
+			}
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			zone, exists := zones.GetByID(id)
+			if !exists {
+				http.Error(w, "Motion zone not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, logger, http.StatusOK, zone)
+
+		case http.MethodPost:
+			var req dto.MotionZoneRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			zone := model.MotionZone{Type: model.ZoneInclude, Enabled: true}
+			applyMotionZoneRequest(&zone, &req)
+
+			created, err := zones.Create(zone)
+			if err != nil {
+				writeMotionZoneError(w, logger, err)
+				return
+			}
+			writeJSON(w, logger, http.StatusCreated, created)
+
+		case http.MethodPut:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			zone, exists := zones.GetByID(id)
+			if !exists {
+				http.Error(w, "Motion zone not found", http.StatusNotFound)
+				return
+			}
+			var req dto.MotionZoneRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			applyMotionZoneRequest(&zone, &req)
+
+			updated, err := zones.Update(zone)
+			if err != nil {
+				writeMotionZoneError(w, logger, err)
+				return
+			}
+			writeJSON(w, logger, http.StatusOK, updated)
+
+		case http.MethodDelete:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			if err := zones.Delete(id); err != nil {
+				writeMotionZoneError(w, logger, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// applyMotionZoneRequest copies the fields present in the request onto the zone.
+func applyMotionZoneRequest(zone *model.MotionZone, req *dto.MotionZoneRequest) {
+	if req.Camera != nil {
+		zone.Camera = *req.Camera
+	}
+	if req.Name != nil {
+		zone.Name = *req.Name
+	}
+	if req.Type != nil {
+		zone.Type = *req.Type
+	}
+	if req.Points != nil {
+		zone.Points = req.Points
+	}
+	if req.ThresholdPercent != nil {
+		zone.ThresholdPercent = *req.ThresholdPercent
+	}
+	if req.Enabled != nil {
+		zone.Enabled = *req.Enabled
+	}
+}
+
+// writeMotionZoneError maps motion zone errors to HTTP status codes.
+func writeMotionZoneError(w http.ResponseWriter, logger *logger.Logger, err error) {
+	switch {
+	case errors.Is(err, motion.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, motion.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, motion.ErrReadOnly):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		logger.Error("Motion zone error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
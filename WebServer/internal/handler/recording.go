@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"net/http"
+	"os"
+	"time"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+)
+
+// RecordingsHandler lists continuous recording segments overlapping a time range.
+// Query params: camera, from, to (RFC 3339 or the HTML "2006-01-02T15:04" format).
+func RecordingsHandler(logger *logger.Logger, recordingRepo repository.RecordingRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if recordingRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		q := r.URL.Query()
+		from, ok := parseDateTime(q.Get("from"))
+		if !ok {
+			http.Error(w, "Invalid from parameter", http.StatusBadRequest)
+			return
+		}
+		to, ok := parseDateTime(q.Get("to"))
+		if !ok {
+			http.Error(w, "Invalid to parameter", http.StatusBadRequest)
+			return
+		}
+
+		recordings, err := recordingRepo.GetByRange(q.Get("camera"), from, to)
+		if err != nil {
+			logger.Error("Error querying recordings: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if recordings == nil {
+			recordings = []model.Recording{}
+		}
+		writeJSON(w, logger, http.StatusOK, recordings)
+	}
+}
+
+// RecordingStreamHandler streams a finished recording segment (?id=) as an AVI file.
+func RecordingStreamHandler(logger *logger.Logger, recordingRepo repository.RecordingRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if recordingRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		id, ok := parseID(w, r)
+		if !ok {
+			return
+		}
+
+		rec, err := recordingRepo.GetByID(id)
+		if err != nil {
+			logger.Error("Error getting recording: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if rec == nil {
+			http.Error(w, "Recording not found", http.StatusNotFound)
+			return
+		}
+		if !rec.Complete {
+			http.Error(w, "Recording is still in progress", http.StatusConflict)
+			return
+		}
+
+		serveAVI(w, r, logger, rec.Filename, rec.FilePath, rec.EndTime)
+	}
+}
+
+// ClipStreamHandler streams a finished event clip (?id=) as an AVI file.
+func ClipStreamHandler(logger *logger.Logger, clipRepo repository.ClipRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if clipRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		id, ok := parseID(w, r)
+		if !ok {
+			return
+		}
+
+		clip, err := clipRepo.GetByID(id)
+		if err != nil {
+			logger.Error("Error getting clip: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if clip == nil {
+			http.Error(w, "Clip not found", http.StatusNotFound)
+			return
+		}
+		if !clip.Complete {
+			http.Error(w, "Clip is still being recorded", http.StatusConflict)
+			return
+		}
+
+		serveAVI(w, r, logger, clip.Filename, clip.FilePath, clip.EndTime)
+	}
+}
+
+// serveAVI writes an AVI file as the response, supporting Range requests so players can seek.
+func serveAVI(w http.ResponseWriter, r *http.Request, logger *logger.Logger, filename, path string, modTime time.Time) {
+	file, err := os.Open(path)
+	if err != nil {
+		logger.Error("Error opening video %s: %v", filename, err)
+		http.Error(w, "Video file not found", http.StatusNotFound)
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "video/x-msvideo")
+	w.Header().Set("Content-Disposition", `inline; filename="`+filename+`"`)
+	http.ServeContent(w, r, filename, modTime, file)
+}
+
+// parseDateTime parses an optional timestamp in local time. An empty value yields the zero time.
+func parseDateTime(v string) (time.Time, bool) {
+	if v == "" {
+		return time.Time{}, true
+	}
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t.Local(), true
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", v, time.Local); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
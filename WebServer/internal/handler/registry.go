@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/service"
+	"webserver/internal/service/registry"
+)
+
+// CamerasHandler manages the camera registry:
+// GET lists cameras (or one with ?id=), POST creates a camera,
+// PUT ?id= updates (rename, disable, rotate secret) and DELETE ?id= removes it.
+func CamerasHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cameras := manager.GetRegistryService()
+
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("id") == "" {
+				writeJSON(w, logger, http.StatusOK, cameras.GetAll())
+				return
+			}
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			cam, exists := cameras.GetByID(id)
+			if !exists {
+				http.Error(w, "Camera not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, logger, http.StatusOK, cam)
+
+		case http.MethodPost:
+			var req dto.CameraRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			cam := model.Camera{Enabled: true}
+			applyCameraRequest(&cam, &req)
+
+			created, err := cameras.Create(cam)
+			if err != nil {
+				writeRegistryError(w, logger, err)
+				return
+			}
+			writeJSON(w, logger, http.StatusCreated, dto.CameraResponse{Camera: created, Secret: created.Secret})
+
+		case http.MethodPut:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			cam, exists := cameras.GetByID(id)
+			if !exists {
+				http.Error(w, "Camera not found", http.StatusNotFound)
+				return
+			}
+			var req dto.CameraRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			oldName := cam.Name
+			applyCameraRequest(&cam, &req)
+
+			updated, err := cameras.Update(cam)
+			if err != nil {
+				writeRegistryError(w, logger, err)
+				return
+			}
+			manager.RenameCamera(oldName, updated.Name)
+			writeJSON(w, logger, http.StatusOK, updated)
+
+		case http.MethodDelete:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			if err := cameras.Delete(id); err != nil {
+				writeRegistryError(w, logger, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// applyCameraRequest copies the fields present in the request onto the camera.
+func applyCameraRequest(cam *model.Camera, req *dto.CameraRequest) {
+	if req.Name != nil {
+		cam.Name = *req.Name
+	}
+	if req.DeviceID != nil {
+		cam.DeviceID = *req.DeviceID
+	}
+	if req.Secret != nil {
+		cam.Secret = *req.Secret
+	}
+	if req.IPAddress != nil {
+		cam.IPAddress = *req.IPAddress
+	}
+	if req.Enabled != nil {
+		cam.Enabled = *req.Enabled
+	}
+}
+
+// writeRegistryError maps registry errors to HTTP status codes.
+func writeRegistryError(w http.ResponseWriter, logger *logger.Logger, err error) {
+	switch {
+	case errors.Is(err, registry.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, registry.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, registry.ErrConflict):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, registry.ErrReadOnly):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		logger.Error("Camera registry error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// parseID reads the required "id" query parameter, writing a 400 response when it is invalid.
+func parseID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(r.URL.Query().Get("id"), 10, 64)
+	if err != nil || id <= 0 {
+		http.Error(w, "Valid id parameter is required", http.StatusBadRequest)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeJSON encodes v as the JSON response body with the given status code.
+func writeJSON(w http.ResponseWriter, logger *logger.Logger, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Error("Error encoding JSON response: %v", err)
+	}
+}
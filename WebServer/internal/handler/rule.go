@@ -0,0 +1,159 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/service"
+	"webserver/internal/service/rule"
+)
+
+// RulesHandler manages camera rules:
+// GET lists rules (optionally ?camera=, or one with ?id=), POST creates a rule,
+// PUT ?id= updates it and DELETE ?id= removes it.
+func RulesHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rules := manager.GetRuleService()
+
+		switch r.Method {
+		case http.MethodGet:
+			if r.URL.Query().Get("id") == "" {
+				writeJSON(w, logger, http.StatusOK, rules.GetAll(r.URL.Query().Get("camera")))
+				return
+			}
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			found, exists := rules.GetByID(id)
+			if !exists {
+				http.Error(w, "Rule not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, logger, http.StatusOK, found)
+
+		case http.MethodPost:
+			var req dto.RuleRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			newRule := model.Rule{Enabled: true}
+			applyRuleRequest(&newRule, &req)
+
+			created, err := rules.Create(newRule)
+			if err != nil {
+				writeRuleError(w, logger, err)
+				return
+			}
+			writeJSON(w, logger, http.StatusCreated, created)
+
+		case http.MethodPut:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			existing, exists := rules.GetByID(id)
+			if !exists {
+				http.Error(w, "Rule not found", http.StatusNotFound)
+				return
+			}
+			var req dto.RuleRequest
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, "Invalid request body", http.StatusBadRequest)
+				return
+			}
+			applyRuleRequest(&existing, &req)
+
+			updated, err := rules.Update(existing)
+			if err != nil {
+				writeRuleError(w, logger, err)
+				return
+			}
+			writeJSON(w, logger, http.StatusOK, updated)
+
+		case http.MethodDelete:
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			if err := rules.Delete(id); err != nil {
+				writeRuleError(w, logger, err)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+
+		default:
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// RuleTriggersHandler returns the newest rule triggers as JSON, optionally filtered
+// by ?camera= and ?rule= (rule ID) and limited by ?limit= (default 50).
+func RuleTriggersHandler(manager *service.Manager, logger *logger.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		ruleID, _ := strconv.ParseInt(query.Get("rule"), 10, 64)
+
+		triggers, err := manager.GetRuleService().GetTriggers(query.Get("camera"), ruleID, atoiDefault(query.Get("limit"), 50))
+		if err != nil {
+			logger.Error("Error querying rule triggers: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		writeJSON(w, logger, http.StatusOK, triggers)
+	}
+}
+
+// applyRuleRequest copies the fields present in the request onto the rule.
+func applyRuleRequest(r *model.Rule, req *dto.RuleRequest) {
+	if req.Camera != nil {
+		r.Camera = *req.Camera
+	}
+	if req.Name != nil {
+		r.Name = *req.Name
+	}
+	if req.Type != nil {
+		r.Type = *req.Type
+	}
+	if req.Labels != nil {
+		r.Labels = req.Labels
+	}
+	if req.Points != nil {
+		r.Points = req.Points
+	}
+	if req.Direction != nil {
+		r.Direction = *req.Direction
+	}
+	if req.DwellSeconds != nil {
+		r.DwellSeconds = *req.DwellSeconds
+	}
+	if req.Enabled != nil {
+		r.Enabled = *req.Enabled
+	}
+}
+
+// writeRuleError maps rule errors to HTTP status codes.
+func writeRuleError(w http.ResponseWriter, logger *logger.Logger, err error) {
+	switch {
+	case errors.Is(err, rule.ErrNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, rule.ErrInvalid):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, rule.ErrReadOnly):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		logger.Error("Rule error: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
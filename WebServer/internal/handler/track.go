@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"net/http"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service"
+)
+
+// TracksHandler serves object tracks:
+// GET lists stored tracks with the gallery filters (camera, object, dateAfter, dateBefore,
+// timeAfter, timeBefore, page, limit), GET ?id= returns one track with its path and
+// GET ?active=true returns the tracks currently followed (optionally for ?camera=).
+func TracksHandler(manager *service.Manager, logger *logger.Logger, trackRepo repository.TrackRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		query := r.URL.Query()
+		if query.Get("active") == "true" {
+			writeJSON(w, logger, http.StatusOK, manager.GetTrackingService().Active(query.Get("camera")))
+			return
+		}
+
+		if trackRepo == nil {
+			http.Error(w, "Database not available", http.StatusServiceUnavailable)
+			return
+		}
+
+		if query.Get("id") != "" {
+			id, ok := parseID(w, r)
+			if !ok {
+				return
+			}
+			track, err := trackRepo.GetByID(id)
+			if err != nil {
+				logger.Error("Error getting track %d: %v", id, err)
+				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+				return
+			}
+			if track == nil {
+				http.Error(w, "Track not found", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, logger, http.StatusOK, track)
+			return
+		}
+
+		filter := parseImageFilters(r)
+		tracks, err := trackRepo.GetAll(filter)
+		if err != nil {
+			logger.Error("Error querying tracks: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+		if tracks == nil {
+			tracks = []model.Track{}
+		}
+
+		totalCount, err := trackRepo.GetTotalCount(filter)
+		if err != nil {
+			logger.Error("Error counting tracks: %v", err)
+			totalCount = len(tracks)
+		}
+
+		writeJSON(w, logger, http.StatusOK, dto.TracksData{
+			Tracks:      tracks,
+			Length:      totalCount,
+			TotalPages:  (totalCount + filter.Limit - 1) / filter.Limit,
+			CurrentPage: filter.Page,
+			Limit:       filter.Limit,
+		})
+	}
+}
@@ -14,7 +14,6 @@ func AuthMiddleware(next http.Handler) http.Handler {
 		publicPaths := []string{ //endpoints that do not require authentication
 			"/login",
 			"/auth/login",
-			"/api/camera",
 			"/static/css/login.css",
 		}
 
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Camera represents a registered camera. DeviceID is the ID sent in the datagram
+// header (0 when the camera is only identified by IP address). Boot is the highest boot
+// counter accepted from its signed datagrams.
+type Camera struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	DeviceID  int       `json:"device_id"`
+	Secret    string    `json:"-"`
+	IPAddress string    `json:"ip_address"`
+	Enabled   bool      `json:"enabled"`
+	Boot      int64     `json:"boot"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,12 @@
+package model
+
+import "time"
+
+// CameraEvent represents a recorded change in a camera's state (e.g. going offline).
+type CameraEvent struct {
+	ID        int64     `json:"id"`
+	Camera    string    `json:"camera"`
+	Type      string    `json:"type"`
+	Message   string    `json:"message"`
+	Timestamp time.Time `json:"timestamp"`
+}
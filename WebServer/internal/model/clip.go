@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// Clip represents a short MJPEG AVI event clip recorded around a detection, including
+// frames from before (pre-roll) and after (post-roll) the triggering image.
+type Clip struct {
+	ID        int64     `json:"id"`
+	Camera    string    `json:"camera"`
+	Filename  string    `json:"filename"`
+	FilePath  string    `json:"filepath"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Frames    int       `json:"frames"`
+	FileSize  int64     `json:"filesize"`
+	Complete  bool      `json:"complete"`
+}
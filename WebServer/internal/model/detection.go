@@ -10,4 +10,6 @@ type Detection struct {
 	Width      int     `json:"width"`
 	Height     int     `json:"height"`
 	Confidence float64 `json:"confidence"`
+	Zone       string  `json:"zone"`     // detect zone the object was found in, "" without zones
+	TrackID    int64   `json:"track_id"` // object track across frames, 0 when not tracked
 }
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// Event groups consecutive detections from one camera into a single incident.
+// Thumbnail is the filename of the image with the highest detection confidence.
+type Event struct {
+	ID             int64     `json:"id"`
+	Camera         string    `json:"camera"`
+	StartTime      time.Time `json:"start_time"`
+	EndTime        time.Time `json:"end_time"`
+	PeakConfidence float64   `json:"peak_confidence"`
+	Objects        []string  `json:"objects"`
+	Thumbnail      string    `json:"thumbnail"`
+	ImageCount     int       `json:"image_count"`
+}
@@ -10,4 +10,5 @@ type Image struct {
 	Timestamp time.Time `json:"timestamp"`
 	FilePath  string    `json:"filepath"`
 	FileSize  int64     `json:"filesize"`
+	Storage   string    `json:"storage"` // blob store backend holding the file: local or s3
 }
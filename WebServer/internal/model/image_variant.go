@@ -0,0 +1,13 @@
+package model
+
+// ImageVariant is a downscaled copy of a stored image (thumbnail or preview). Filename
+// is relative to the image directory.
+type ImageVariant struct {
+	ID       int64  `json:"id"`
+	ImageID  int64  `json:"image_id"`
+	Size     string `json:"size"`
+	Filename string `json:"filename"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int64  `json:"filesize"`
+}
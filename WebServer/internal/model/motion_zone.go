@@ -0,0 +1,34 @@
+package model
+
+import "time"
+
+const (
+	// ZoneInclude limits motion detection to the area inside the polygon.
+	ZoneInclude = "include"
+	// ZoneExclude ignores changes inside the polygon (trees, street, timestamps).
+	ZoneExclude = "exclude"
+	// ZoneDetect keeps only object detections inside the polygon (driveway, door).
+	// It does not affect motion detection.
+	ZoneDetect = "detect"
+)
+
+// Point is a polygon vertex relative to the frame size, from 0 to 1 on both axes,
+// so zones keep working when the camera resolution changes.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// MotionZone is a polygon applied to a camera's motion mask. ThresholdPercent is the
+// share of the zone area that has to change to count as motion (0 uses the default);
+// for detect zones it is the minimum overlap of a detection with the zone.
+type MotionZone struct {
+	ID               int64     `json:"id"`
+	Camera           string    `json:"camera"`
+	Name             string    `json:"name"`
+	Type             string    `json:"type"`
+	Points           []Point   `json:"points"`
+	ThresholdPercent float64   `json:"threshold_percent"`
+	Enabled          bool      `json:"enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// Recording represents one time segment of continuous recording stored as an MJPEG AVI file.
+// Complete is false while the segment is still being written.
+type Recording struct {
+	ID        int64     `json:"id"`
+	Camera    string    `json:"camera"`
+	Filename  string    `json:"filename"`
+	FilePath  string    `json:"filepath"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Frames    int       `json:"frames"`
+	Width     int       `json:"width"`
+	Height    int       `json:"height"`
+	FileSize  int64     `json:"filesize"`
+	Complete  bool      `json:"complete"`
+}
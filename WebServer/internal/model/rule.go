@@ -0,0 +1,49 @@
+package model
+
+import "time"
+
+const (
+	// RuleLineCrossing triggers when a tracked object crosses the line from Points[0] to Points[1].
+	RuleLineCrossing = "line_crossing"
+	// RuleZoneEnter triggers when a tracked object moves into the polygon.
+	RuleZoneEnter = "zone_enter"
+	// RuleZoneLeave triggers when a tracked object moves out of the polygon or is lost inside it.
+	RuleZoneLeave = "zone_leave"
+	// RuleLoiter triggers once when a tracked object stays in the polygon for DwellSeconds.
+	RuleLoiter = "loiter"
+
+	// DirectionLeft and DirectionRight limit line crossings to objects moving to that side
+	// of the line, looking from its first point towards the second.
+	DirectionLeft  = "left"
+	DirectionRight = "right"
+)
+
+// Rule describes a camera rule evaluated against tracked objects. Points are relative to
+// the frame size like motion zone points: two points for a line, a polygon for zone rules.
+// Labels limit the rule to some classes (all classes when empty).
+type Rule struct {
+	ID           int64     `json:"id"`
+	Camera       string    `json:"camera"`
+	Name         string    `json:"name"`
+	Type         string    `json:"type"`
+	Labels       []string  `json:"labels"`
+	Points       []Point   `json:"points"`
+	Direction    string    `json:"direction"`     // line crossings only, "" for both directions
+	DwellSeconds float64   `json:"dwell_seconds"` // loiter rules only
+	Enabled      bool      `json:"enabled"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// RuleTrigger records a rule firing for a tracked object.
+type RuleTrigger struct {
+	ID        int64     `json:"id"`
+	RuleID    int64     `json:"rule_id"`
+	RuleName  string    `json:"rule_name"`
+	Type      string    `json:"type"`
+	Camera    string    `json:"camera"`
+	TrackID   int64     `json:"track_id"`
+	Label     string    `json:"label"`
+	Detail    string    `json:"detail"` // crossing direction, loiter time or why the object left
+	Image     string    `json:"image"`  // filename of the image that triggered the rule, "" when lost
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// TrackPoint is the centre of a tracked object's bounding box in frame pixels at one moment.
+type TrackPoint struct {
+	X         int       `json:"x"`
+	Y         int       `json:"y"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Track follows one object across the frames of a camera. EndTime is the last time
+// the object was seen, so DwellSeconds is how long it stayed in view.
+type Track struct {
+	ID           int64        `json:"id"`
+	Camera       string       `json:"camera"`
+	Label        string       `json:"label"`
+	StartTime    time.Time    `json:"start_time"`
+	EndTime      time.Time    `json:"end_time"`
+	DwellSeconds float64      `json:"dwell_seconds"`
+	Path         []TrackPoint `json:"path"`
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"time"
+
 	"webserver/internal/dto"
 	"webserver/internal/model"
 )
@@ -9,6 +11,7 @@ import (
 type ImageRepository interface {
 	// Create operations
 	Insert(img *model.Image) (int64, error)
+	InsertWithDetections(img *model.Image, detections []model.Detection) (int64, error)
 
 	// Read operations
 	GetByID(id int64) (*model.Image, error)
@@ -16,6 +19,13 @@ type ImageRepository interface {
 	GetAll(filter *dto.ImageFilters) ([]model.Image, error)
 	GetTotalCount(filter *dto.ImageFilters) (int, error)
 	GetDirectorySize() (int64, error)
+	GetUsageByCamera() (map[string]int64, error)
+	GetRetentionCandidates(camera string, before time.Time, priority []string, limit int) ([]model.Image, error)
+	GetBatch(afterID int64, limit int) ([]model.Image, error)
+
+	// Update operations
+	UpdateFilePath(id int64, filePath string) error
+	SetMissing(ids []int64) error
 
 	// Delete operations
 	Delete(id int64) error
@@ -37,3 +47,153 @@ type DetectionRepository interface {
 	// Delete operations
 	DeleteByImageID(imageID int64) error
 }
+
+// ImageVariantRepository defines the interface for image thumbnail and preview operations.
+type ImageVariantRepository interface {
+	// Create operations
+	Upsert(variant *model.ImageVariant) (bool, error)
+
+	// Read operations
+	GetByImageID(imageID int64) ([]model.ImageVariant, error)
+	GetMissing(afterID int64, variants, limit int) ([]model.Image, error)
+}
+
+// CameraRepository defines the interface for camera registry operations.
+type CameraRepository interface {
+	// Create operations
+	Insert(cam *model.Camera) (int64, error)
+
+	// Read operations
+	GetByID(id int64) (*model.Camera, error)
+	GetAll() ([]model.Camera, error)
+
+	// Update operations
+	Update(cam *model.Camera) error
+	SetBoot(id int64, boot int64) error
+
+	// Delete operations
+	Delete(id int64) error
+}
+
+// CameraEventRepository defines the interface for camera event operations.
+type CameraEventRepository interface {
+	// Create operations
+	Insert(event *model.CameraEvent) (int64, error)
+
+	// Read operations
+	GetRecent(camera, eventType string, limit int) ([]model.CameraEvent, error)
+}
+
+// RecordingRepository defines the interface for continuous recording segment operations.
+type RecordingRepository interface {
+	// Create operations
+	Insert(rec *model.Recording) (int64, error)
+
+	// Read operations
+	GetByID(id int64) (*model.Recording, error)
+	GetByRange(camera string, from, to time.Time) ([]model.Recording, error)
+	GetIncomplete() ([]model.Recording, error)
+	GetOldest(before time.Time, limit int) ([]model.Recording, error)
+	GetTotalSize() (int64, error)
+
+	// Update operations
+	Update(rec *model.Recording) error
+
+	// Delete operations
+	Delete(id int64) error
+}
+
+// ClipRepository defines the interface for event clip operations.
+type ClipRepository interface {
+	// Create operations
+	Insert(clip *model.Clip) (int64, error)
+	AddImage(clipID int64, imageFilename string) error
+
+	// Read operations
+	GetByID(id int64) (*model.Clip, error)
+	GetByImage(imageFilename string) (*model.Clip, error)
+	GetIncomplete() ([]model.Clip, error)
+	GetOldest(before time.Time, limit int) ([]model.Clip, error)
+	GetTotalSize() (int64, error)
+
+	// Update operations
+	Update(clip *model.Clip) error
+
+	// Delete operations
+	Delete(id int64) error
+}
+
+// EventRepository defines the interface for detection event operations.
+type EventRepository interface {
+	// Create operations
+	Insert(event *model.Event) (int64, error)
+	AddImage(eventID int64, imageFilename string) error
+
+	// Read operations
+	GetByID(id int64) (*model.Event, error)
+	GetLatest(camera string) (*model.Event, error)
+	GetAll(filter *dto.ImageFilters) ([]model.Event, error)
+	GetTotalCount(filter *dto.ImageFilters) (int, error)
+	GetImageFilenames(eventID int64) ([]string, error)
+
+	// Update operations
+	Update(event *model.Event) error
+
+	// Delete operations
+	Delete(id int64) error
+}
+
+// TrackRepository defines the interface for object track operations.
+type TrackRepository interface {
+	// Create operations
+	Insert(track *model.Track) (int64, error)
+
+	// Read operations
+	GetByID(id int64) (*model.Track, error)
+	GetAll(filter *dto.ImageFilters) ([]model.Track, error)
+	GetTotalCount(filter *dto.ImageFilters) (int, error)
+
+	// Update operations
+	Update(track *model.Track) error
+}
+
+// RuleRepository defines the interface for camera rule operations.
+type RuleRepository interface {
+	// Create operations
+	Insert(rule *model.Rule) (int64, error)
+
+	// Read operations
+	GetByID(id int64) (*model.Rule, error)
+	GetAll() ([]model.Rule, error)
+
+	// Update operations
+	Update(rule *model.Rule) error
+
+	// Delete operations
+	Delete(id int64) error
+}
+
+// RuleTriggerRepository defines the interface for rule trigger operations.
+type RuleTriggerRepository interface {
+	// Create operations
+	Insert(trigger *model.RuleTrigger) (int64, error)
+
+	// Read operations
+	GetRecent(camera string, ruleID int64, limit int) ([]model.RuleTrigger, error)
+}
+
+// MotionZoneRepository defines the interface for motion zone operations.
+type MotionZoneRepository interface {
+	// Create operations
+	Insert(zone *model.MotionZone) (int64, error)
+
+	// Read operations
+	GetByID(id int64) (*model.MotionZone, error)
+	GetAll() ([]model.MotionZone, error)
+
+	// Update operations
+	Update(zone *model.MotionZone) error
+
+	// Delete operations
+	Delete(id int64) error
+}
@@ -0,0 +1,75 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"webserver/internal/model"
+)
+
+// CameraEventRepository implements repository.CameraEventRepository for SQLite.
+type CameraEventRepository struct {
+	db *DB
+}
+
+// NewCameraEventRepository creates a new SQLite camera event repository.
+func NewCameraEventRepository(db *DB) *CameraEventRepository {
+	return &CameraEventRepository{db: db}
+}
+
+// Insert adds a new camera event to the database.
+func (r *CameraEventRepository) Insert(event *model.CameraEvent) (int64, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO camera_events (camera, type, message, timestamp)
+		VALUES (?, ?, ?, ?)
+	`, event.Camera, event.Type, event.Message, event.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert camera event: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetRecent returns the newest events, optionally limited to one camera and event type.
+func (r *CameraEventRepository) GetRecent(camera, eventType string, limit int) ([]model.CameraEvent, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	query := `SELECT id, camera, type, message, timestamp FROM camera_events WHERE 1=1`
+	args := []interface{}{}
+
+	if camera != "" {
+		query += " AND camera = ?"
+		args = append(args, camera)
+	}
+
+	if eventType != "" {
+		query += " AND type = ?"
+		args = append(args, eventType)
+	}
+
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " ORDER BY timestamp DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query camera events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.CameraEvent
+	for rows.Next() {
+		var event model.CameraEvent
+		if err := rows.Scan(&event.ID, &event.Camera, &event.Type, &event.Message, &event.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan camera event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
@@ -0,0 +1,194 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+
+	"webserver/internal/model"
+)
+
+// CameraRepository implements repository.CameraRepository for SQLite.
+type CameraRepository struct {
+	db *DB
+}
+
+// NewCameraRepository creates a new SQLite camera repository.
+func NewCameraRepository(db *DB) *CameraRepository {
+	return &CameraRepository{db: db}
+}
+
+// Insert adds a new camera record to the database.
+func (r *CameraRepository) Insert(cam *model.Camera) (int64, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO cameras (name, device_id, secret, ip_address, enabled)
+		VALUES (?, ?, ?, ?, ?)
+	`, cam.Name, nullableInt(cam.DeviceID), cam.Secret, nullableString(cam.IPAddress), cam.Enabled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert camera: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetByID retrieves a camera by its ID.
+func (r *CameraRepository) GetByID(id int64) (*model.Camera, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	cam, err := scanCamera(r.db.Conn().QueryRow(`
+		SELECT id, name, device_id, secret, ip_address, enabled, boot, created_at
+		FROM cameras WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get camera: %w", err)
+	}
+	return cam, nil
+}
+
+// GetAll retrieves all cameras ordered by name.
+func (r *CameraRepository) GetAll() ([]model.Camera, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, name, device_id, secret, ip_address, enabled, boot, created_at
+		FROM cameras ORDER BY name
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query cameras: %w", err)
+	}
+	defer rows.Close()
+
+	var cameras []model.Camera
+	for rows.Next() {
+		cam, err := scanCamera(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan camera: %w", err)
+		}
+		cameras = append(cameras, *cam)
+	}
+
+	return cameras, nil
+}
+
+// Update saves camera changes. When the name changes, images, health events, recordings, clips, events,
+// motion zones, tracks and rules stored under the old name are moved to the new one in the same
+// transaction so history is kept.
+func (r *CameraRepository) Update(cam *model.Camera) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var oldName string
+	if err := tx.QueryRow(`SELECT name FROM cameras WHERE id = ?`, cam.ID).Scan(&oldName); err != nil {
+		return fmt.Errorf("failed to get camera: %w", err)
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE cameras SET name = ?, device_id = ?, secret = ?, ip_address = ?, enabled = ?
+		WHERE id = ?
+	`, cam.Name, nullableInt(cam.DeviceID), cam.Secret, nullableString(cam.IPAddress), cam.Enabled, cam.ID); err != nil {
+		return fmt.Errorf("failed to update camera: %w", err)
+	}
+
+	if oldName != cam.Name {
+		if _, err := tx.Exec(`UPDATE images SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera images: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE camera_events SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera health events: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE recordings SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera recordings: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE clips SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera clips: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE events SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera events: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE motion_zones SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera motion zones: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE tracks SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera tracks: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE rules SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera rules: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE rule_triggers SET camera = ? WHERE camera = ?`, cam.Name, oldName); err != nil {
+			return fmt.Errorf("failed to rename camera rule triggers: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// SetBoot raises the boot counter of a camera. Lower values are ignored so the counter
+// never goes back.
+func (r *CameraRepository) SetBoot(id int64, boot int64) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`UPDATE cameras SET boot = ? WHERE id = ? AND boot < ?`, boot, id, boot); err != nil {
+		return fmt.Errorf("failed to update camera boot: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a camera from the registry. Its images are kept.
+func (r *CameraRepository) Delete(id int64) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`DELETE FROM cameras WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete camera: %w", err)
+	}
+	return nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanCamera reads a camera row, mapping NULL device IDs and addresses to zero values.
+func scanCamera(row rowScanner) (*model.Camera, error) {
+	var cam model.Camera
+	var deviceID sql.NullInt64
+	var ip sql.NullString
+	if err := row.Scan(&cam.ID, &cam.Name, &deviceID, &cam.Secret, &ip, &cam.Enabled, &cam.Boot, &cam.CreatedAt); err != nil {
+		return nil, err
+	}
+	cam.DeviceID = int(deviceID.Int64)
+	cam.IPAddress = ip.String
+	return &cam, nil
+}
+
+// nullableInt stores 0 as NULL so UNIQUE columns allow several unset values.
+func nullableInt(v int) interface{} {
+	if v == 0 {
+		return nil
+	}
+	return v
+}
+
+// nullableString stores "" as NULL so UNIQUE columns allow several unset values.
+func nullableString(v string) interface{} {
+	if v == "" {
+		return nil
+	}
+	return v
+}
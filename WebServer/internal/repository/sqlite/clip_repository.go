@@ -0,0 +1,200 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"webserver/internal/model"
+)
+
+// ClipRepository implements repository.ClipRepository for SQLite.
+type ClipRepository struct {
+	db *DB
+}
+
+// NewClipRepository creates a new SQLite clip repository.
+func NewClipRepository(db *DB) *ClipRepository {
+	return &ClipRepository{db: db}
+}
+
+// Insert adds a new event clip to the database.
+func (r *ClipRepository) Insert(clip *model.Clip) (int64, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO clips (camera, filename, filepath, start_time, end_time, frames, filesize, complete)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, clip.Camera, clip.Filename, clip.FilePath, clip.StartTime, clip.EndTime, clip.Frames, clip.FileSize, clip.Complete)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert clip: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// AddImage links a stored image (and through it, its detections) to a clip.
+func (r *ClipRepository) AddImage(clipID int64, imageFilename string) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		INSERT OR IGNORE INTO clip_images (clip_id, image_filename) VALUES (?, ?)
+	`, clipID, imageFilename); err != nil {
+		return fmt.Errorf("failed to link clip image: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves a clip by its ID.
+func (r *ClipRepository) GetByID(id int64) (*model.Clip, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	clip, err := scanClip(r.db.Conn().QueryRow(`
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, filesize, complete
+		FROM clips WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clip: %w", err)
+	}
+	return clip, nil
+}
+
+// GetByImage retrieves the clip recorded around the given image.
+func (r *ClipRepository) GetByImage(imageFilename string) (*model.Clip, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	clip, err := scanClip(r.db.Conn().QueryRow(`
+		SELECT c.id, c.camera, c.filename, c.filepath, c.start_time, c.end_time, c.frames, c.filesize, c.complete
+		FROM clips c
+		INNER JOIN clip_images ci ON ci.clip_id = c.id
+		WHERE ci.image_filename = ?
+		ORDER BY c.id DESC LIMIT 1
+	`, imageFilename))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get clip for image: %w", err)
+	}
+	return clip, nil
+}
+
+// GetIncomplete returns clips that were never finalized, e.g. after a crash.
+func (r *ClipRepository) GetIncomplete() ([]model.Clip, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, filesize, complete
+		FROM clips WHERE complete = 0 ORDER BY start_time, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clips: %w", err)
+	}
+	defer rows.Close()
+
+	var clips []model.Clip
+	for rows.Next() {
+		clip, err := scanClip(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan clip: %w", err)
+		}
+		clips = append(clips, *clip)
+	}
+
+	return clips, nil
+}
+
+// GetOldest returns finished clips that ended before the given time (any time when
+// zero), oldest first.
+func (r *ClipRepository) GetOldest(before time.Time, limit int) ([]model.Clip, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	query := `
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, filesize, complete
+		FROM clips WHERE complete = 1`
+	args := []interface{}{}
+
+	if !before.IsZero() {
+		query += " AND end_time < ?"
+		args = append(args, before)
+	}
+	query += " ORDER BY start_time, id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query clips: %w", err)
+	}
+	defer rows.Close()
+
+	var clips []model.Clip
+	for rows.Next() {
+		clip, err := scanClip(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan clip: %w", err)
+		}
+		clips = append(clips, *clip)
+	}
+
+	return clips, nil
+}
+
+// GetTotalSize returns the total size in bytes of all clips.
+func (r *ClipRepository) GetTotalSize() (int64, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	var size int64
+	if err := r.db.Conn().QueryRow(`SELECT COALESCE(SUM(filesize), 0) FROM clips`).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get clip size: %w", err)
+	}
+	return size, nil
+}
+
+// Update saves the time range, frame count, size and completion state of a clip.
+func (r *ClipRepository) Update(clip *model.Clip) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		UPDATE clips SET start_time = ?, end_time = ?, frames = ?, filesize = ?, complete = ?
+		WHERE id = ?
+	`, clip.StartTime, clip.EndTime, clip.Frames, clip.FileSize, clip.Complete, clip.ID); err != nil {
+		return fmt.Errorf("failed to update clip: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a clip and its image links. The images themselves are kept.
+func (r *ClipRepository) Delete(id int64) error {
+	return r.db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM clip_images WHERE clip_id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete clip images: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM clips WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("failed to delete clip: %w", err)
+		}
+		return nil
+	})
+}
+
+// scanClip reads a clips row.
+func scanClip(row rowScanner) (*model.Clip, error) {
+	var clip model.Clip
+	if err := row.Scan(&clip.ID, &clip.Camera, &clip.Filename, &clip.FilePath, &clip.StartTime, &clip.EndTime,
+		&clip.Frames, &clip.FileSize, &clip.Complete); err != nil {
+		return nil, err
+	}
+	return &clip, nil
+}
@@ -21,9 +21,9 @@ func (r *DetectionRepository) Insert(det *model.Detection) (int64, error) {
 	defer r.db.Unlock()
 
 	result, err := r.db.Conn().Exec(`
-		INSERT INTO detections (image_id, object_name, x, y, width, height, confidence)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, det.ImageID, det.ObjectName, det.X, det.Y, det.Width, det.Height, det.Confidence)
+		INSERT INTO detections (image_id, object_name, x, y, width, height, confidence, zone, track_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, det.ImageID, det.ObjectName, det.X, det.Y, det.Width, det.Height, det.Confidence, det.Zone, det.TrackID)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert detection: %w", err)
 	}
@@ -43,8 +43,8 @@ func (r *DetectionRepository) InsertBatch(detections []model.Detection) error {
 	defer tx.Rollback()
 
 	stmt, err := tx.Prepare(`
-		INSERT INTO detections (image_id, object_name, x, y, width, height, confidence)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
+		INSERT INTO detections (image_id, object_name, x, y, width, height, confidence, zone, track_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 	if err != nil {
 		return fmt.Errorf("failed to prepare statement: %w", err)
@@ -52,7 +52,7 @@ func (r *DetectionRepository) InsertBatch(detections []model.Detection) error {
 	defer stmt.Close()
 
 	for _, det := range detections {
-		if _, err := stmt.Exec(det.ImageID, det.ObjectName, det.X, det.Y, det.Width, det.Height, det.Confidence); err != nil {
+		if _, err := stmt.Exec(det.ImageID, det.ObjectName, det.X, det.Y, det.Width, det.Height, det.Confidence, det.Zone, det.TrackID); err != nil {
 			return fmt.Errorf("failed to insert detection: %w", err)
 		}
 	}
@@ -66,7 +66,7 @@ func (r *DetectionRepository) GetByImageID(imageID int64) ([]model.Detection, er
 	defer r.db.RUnlock()
 
 	rows, err := r.db.Conn().Query(`
-		SELECT id, image_id, object_name, x, y, width, height, confidence 
+		SELECT id, image_id, object_name, x, y, width, height, confidence, zone, track_id
 		FROM detections WHERE image_id = ?
 	`, imageID)
 	if err != nil {
@@ -77,7 +77,7 @@ func (r *DetectionRepository) GetByImageID(imageID int64) ([]model.Detection, er
 	var detections []model.Detection
 	for rows.Next() {
 		var det model.Detection
-		if err := rows.Scan(&det.ID, &det.ImageID, &det.ObjectName, &det.X, &det.Y, &det.Width, &det.Height, &det.Confidence); err != nil {
+		if err := rows.Scan(&det.ID, &det.ImageID, &det.ObjectName, &det.X, &det.Y, &det.Width, &det.Height, &det.Confidence, &det.Zone, &det.TrackID); err != nil {
 			return nil, fmt.Errorf("failed to scan detection: %w", err)
 		}
 		detections = append(detections, det)
@@ -0,0 +1,260 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"webserver/internal/dto"
+	"webserver/internal/model"
+)
+
+// EventRepository implements repository.EventRepository for SQLite.
+type EventRepository struct {
+	db *DB
+}
+
+// NewEventRepository creates a new SQLite event repository.
+func NewEventRepository(db *DB) *EventRepository {
+	return &EventRepository{db: db}
+}
+
+// Insert adds a new event to the database.
+func (r *EventRepository) Insert(event *model.Event) (int64, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO events (camera, start_time, end_time, peak_confidence, objects, thumbnail, image_count)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, event.Camera, event.StartTime, event.EndTime, event.PeakConfidence,
+		strings.Join(event.Objects, ","), event.Thumbnail, event.ImageCount)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert event: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// AddImage links a stored image to an event.
+func (r *EventRepository) AddImage(eventID int64, imageFilename string) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		INSERT OR IGNORE INTO event_images (event_id, image_filename) VALUES (?, ?)
+	`, eventID, imageFilename); err != nil {
+		return fmt.Errorf("failed to link event image: %w", err)
+	}
+	return nil
+}
+
+// GetByID retrieves an event by its ID.
+func (r *EventRepository) GetByID(id int64) (*model.Event, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	event, err := scanEvent(r.db.Conn().QueryRow(`
+		SELECT id, camera, start_time, end_time, peak_confidence, objects, thumbnail, image_count
+		FROM events WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get event: %w", err)
+	}
+	return event, nil
+}
+
+// GetLatest retrieves the most recent event of a camera.
+func (r *EventRepository) GetLatest(camera string) (*model.Event, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	event, err := scanEvent(r.db.Conn().QueryRow(`
+		SELECT id, camera, start_time, end_time, peak_confidence, objects, thumbnail, image_count
+		FROM events WHERE camera = ? ORDER BY end_time DESC, id DESC LIMIT 1
+	`, camera))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest event: %w", err)
+	}
+	return event, nil
+}
+
+// GetAll retrieves events based on the same filter criteria as images, newest first.
+func (r *EventRepository) GetAll(filter *dto.ImageFilters) ([]model.Event, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	where, args := eventFilter(filter)
+	query := `
+		SELECT id, camera, start_time, end_time, peak_confidence, objects, thumbnail, image_count
+		FROM events WHERE 1=1` + where + " ORDER BY start_time DESC, id DESC"
+
+	limit := filter.Limit
+	page := filter.Page
+	if limit <= 0 {
+		limit = 24
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	offset := (page - 1) * limit
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []model.Event
+	for rows.Next() {
+		event, err := scanEvent(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		events = append(events, *event)
+	}
+
+	return events, nil
+}
+
+// GetTotalCount returns the total number of events matching the filter criteria.
+func (r *EventRepository) GetTotalCount(filter *dto.ImageFilters) (int, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	where, args := eventFilter(filter)
+
+	var count int
+	err := r.db.Conn().QueryRow(`SELECT COUNT(*) FROM events WHERE 1=1`+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count events: %w", err)
+	}
+	return count, nil
+}
+
+// GetImageFilenames returns the filenames of the images linked to an event.
+func (r *EventRepository) GetImageFilenames(eventID int64) ([]string, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT image_filename FROM event_images WHERE event_id = ? ORDER BY image_filename
+	`, eventID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query event images: %w", err)
+	}
+	defer rows.Close()
+
+	var filenames []string
+	for rows.Next() {
+		var filename string
+		if err := rows.Scan(&filename); err != nil {
+			return nil, fmt.Errorf("failed to scan event image: %w", err)
+		}
+		filenames = append(filenames, filename)
+	}
+
+	return filenames, nil
+}
+
+// Update saves the time range, confidence, objects, thumbnail and image count of an event.
+func (r *EventRepository) Update(event *model.Event) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		UPDATE events SET start_time = ?, end_time = ?, peak_confidence = ?, objects = ?, thumbnail = ?, image_count = ?
+		WHERE id = ?
+	`, event.StartTime, event.EndTime, event.PeakConfidence, strings.Join(event.Objects, ","),
+		event.Thumbnail, event.ImageCount, event.ID); err != nil {
+		return fmt.Errorf("failed to update event: %w", err)
+	}
+	return nil
+}
+
+// Delete removes an event and its image links. The images themselves are not touched.
+func (r *EventRepository) Delete(id int64) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	tx, err := r.db.Conn().Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM event_images WHERE event_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete event images: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM events WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete event: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// eventFilter builds the WHERE conditions for image filters applied to events.
+// Dates and times are compared on the stored wall-clock text so the timezone
+// offset does not shift events into another day.
+func eventFilter(filter *dto.ImageFilters) (string, []interface{}) {
+	where := ""
+	args := []interface{}{}
+
+	if filter.Camera != "" {
+		where += " AND camera = ?"
+		args = append(args, filter.Camera)
+	}
+
+	if filter.Object != "" {
+		where += " AND (',' || objects || ',') LIKE ?"
+		args = append(args, "%,"+filter.Object+",%")
+	}
+
+	if !filter.DateAfter.IsZero() {
+		where += " AND substr(start_time, 1, 10) >= ?"
+		args = append(args, filter.DateAfter.Format("2006-01-02"))
+	}
+
+	if !filter.DateBefore.IsZero() {
+		where += " AND substr(start_time, 1, 10) <= ?"
+		args = append(args, filter.DateBefore.Format("2006-01-02"))
+	}
+
+	if !filter.TimeAfter.IsZero() {
+		where += " AND substr(start_time, 12, 8) >= ?"
+		args = append(args, filter.TimeAfter.Format("15:04:05"))
+	}
+
+	if !filter.TimeBefore.IsZero() {
+		where += " AND substr(start_time, 12, 8) <= ?"
+		args = append(args, filter.TimeBefore.Format("15:04:05"))
+	}
+
+	return where, args
+}
+
+// scanEvent reads an events row, splitting the stored object list.
+func scanEvent(row rowScanner) (*model.Event, error) {
+	var event model.Event
+	var objects string
+	if err := row.Scan(&event.ID, &event.Camera, &event.StartTime, &event.EndTime, &event.PeakConfidence,
+		&objects, &event.Thumbnail, &event.ImageCount); err != nil {
+		return nil, err
+	}
+	event.Objects = []string{}
+	if objects != "" {
+		event.Objects = strings.Split(objects, ",")
+	}
+	return &event, nil
+}
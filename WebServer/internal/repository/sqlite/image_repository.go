@@ -3,6 +3,8 @@ package sqlite
 import (
 	"database/sql"
 	"fmt"
+	"strings"
+	"time"
 
 	"webserver/internal/dto"
 	"webserver/internal/model"
@@ -20,13 +22,18 @@ func NewImageRepository(db *DB) *ImageRepository {
 
 // Insert adds a new image record to the database.
 func (r *ImageRepository) Insert(img *model.Image) (int64, error) {
+	storage := img.Storage
+	if storage == "" {
+		storage = "local"
+	}
+
 	r.db.Lock()
 	defer r.db.Unlock()
 
 	result, err := r.db.Conn().Exec(`
-		INSERT INTO images (filename, camera, timestamp, filepath, filesize)
-		VALUES (?, ?, ?, ?, ?)
-	`, img.Filename, img.Camera, img.Timestamp, img.FilePath, img.FileSize)
+		INSERT INTO images (filename, camera, timestamp, filepath, filesize, storage)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, img.Filename, img.Camera, img.Timestamp, img.FilePath, img.FileSize, storage)
 	if err != nil {
 		return 0, fmt.Errorf("failed to insert image: %w", err)
 	}
@@ -34,6 +41,46 @@ func (r *ImageRepository) Insert(img *model.Image) (int64, error) {
 	return result.LastInsertId()
 }
 
+// InsertWithDetections adds an image record together with its detections in a single
+// transaction, so an image is never stored without its detections. The detections'
+// ImageID is set to the new image's ID.
+func (r *ImageRepository) InsertWithDetections(img *model.Image, detections []model.Detection) (int64, error) {
+	storage := img.Storage
+	if storage == "" {
+		storage = "local"
+	}
+
+	var id int64
+	err := r.db.Transaction(func(tx *sql.Tx) error {
+		result, err := tx.Exec(`
+			INSERT INTO images (filename, camera, timestamp, filepath, filesize, storage)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, img.Filename, img.Camera, img.Timestamp, img.FilePath, img.FileSize, storage)
+		if err != nil {
+			return fmt.Errorf("failed to insert image: %w", err)
+		}
+		if id, err = result.LastInsertId(); err != nil {
+			return fmt.Errorf("failed to insert image: %w", err)
+		}
+
+		for i := range detections {
+			det := &detections[i]
+			det.ImageID = id
+			if _, err := tx.Exec(`
+				INSERT INTO detections (image_id, object_name, x, y, width, height, confidence, zone, track_id)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			`, det.ImageID, det.ObjectName, det.X, det.Y, det.Width, det.Height, det.Confidence, det.Zone, det.TrackID); err != nil {
+				return fmt.Errorf("failed to insert detection: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
 // GetByID retrieves an image by its ID.
 func (r *ImageRepository) GetByID(id int64) (*model.Image, error) {
 	r.db.RLock()
@@ -41,9 +88,9 @@ func (r *ImageRepository) GetByID(id int64) (*model.Image, error) {
 
 	var img model.Image
 	err := r.db.Conn().QueryRow(`
-		SELECT id, filename, camera, timestamp, filepath, filesize 
+		SELECT id, filename, camera, timestamp, filepath, filesize, storage
 		FROM images WHERE id = ?
-	`, id).Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize)
+	`, id).Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize, &img.Storage)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -61,9 +108,9 @@ func (r *ImageRepository) GetByFilename(filename string) (*model.Image, error) {
 
 	var img model.Image
 	err := r.db.Conn().QueryRow(`
-		SELECT id, filename, camera, timestamp, filepath, filesize 
+		SELECT id, filename, camera, timestamp, filepath, filesize, storage
 		FROM images WHERE filename = ?
-	`, filename).Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize)
+	`, filename).Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize, &img.Storage)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -80,7 +127,7 @@ func (r *ImageRepository) GetAll(filter *dto.ImageFilters) ([]model.Image, error
 	defer r.db.RUnlock()
 
 	query := `
-		SELECT DISTINCT i.id, i.filename, i.camera, i.timestamp, i.filepath, i.filesize
+		SELECT DISTINCT i.id, i.filename, i.camera, i.timestamp, i.filepath, i.filesize, i.storage
 		FROM images i
 		LEFT JOIN detections d ON i.id = d.image_id
 		WHERE 1=1
@@ -141,7 +188,7 @@ func (r *ImageRepository) GetAll(filter *dto.ImageFilters) ([]model.Image, error
 	var images []model.Image
 	for rows.Next() {
 		var img model.Image
-		if err := rows.Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize); err != nil {
+		if err := rows.Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize, &img.Storage); err != nil {
 			return nil, fmt.Errorf("failed to scan image: %w", err)
 		}
 		images = append(images, img)
@@ -207,67 +254,279 @@ func (r *ImageRepository) GetDirectorySize() (int64, error) {
 	defer r.db.RUnlock()
 
 	var totalSize int64
-	err := r.db.Conn().QueryRow(`SELECT COALESCE(SUM(filesize), 0) FROM images`).Scan(&totalSize)
+	err := r.db.Conn().QueryRow(`
+		SELECT (SELECT COALESCE(SUM(filesize), 0) FROM images) + (SELECT COALESCE(SUM(filesize), 0) FROM image_variants)
+	`).Scan(&totalSize)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get directory size: %w", err)
 	}
 	return totalSize, nil
 }
 
-// Delete removes an image by its ID.
+// GetUsageByCamera returns the bytes used by each camera's images and their variants.
+func (r *ImageRepository) GetUsageByCamera() (map[string]int64, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT i.camera, SUM(i.filesize + COALESCE((SELECT SUM(v.filesize) FROM image_variants v WHERE v.image_id = i.id), 0))
+		FROM images i GROUP BY i.camera
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query storage usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make(map[string]int64)
+	for rows.Next() {
+		var camera string
+		var size int64
+		if err := rows.Scan(&camera, &size); err != nil {
+			return nil, fmt.Errorf("failed to scan storage usage: %w", err)
+		}
+		usage[camera] = size
+	}
+	return usage, nil
+}
+
+// GetRetentionCandidates returns up to limit images in the order retention deletes
+// them: images without any of the priority labels first, then oldest first. The
+// camera and before filters are skipped when empty. FileSize of the returned images
+// includes their variants, the space freed by deleting them.
+func (r *ImageRepository) GetRetentionCandidates(camera string, before time.Time, priority []string,
+	limit int) ([]model.Image, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	query := `
+		SELECT i.id, i.filename, i.camera, i.timestamp, i.filepath,
+			i.filesize + COALESCE((SELECT SUM(v.filesize) FROM image_variants v WHERE v.image_id = i.id), 0),
+			i.storage
+		FROM images i WHERE 1=1`
+	args := []interface{}{}
+
+	if camera != "" {
+		query += " AND i.camera = ?"
+		args = append(args, camera)
+	}
+	if !before.IsZero() {
+		query += " AND julianday(i.timestamp) < julianday(?)"
+		args = append(args, before.UTC())
+	}
+
+	query += " ORDER BY "
+	if len(priority) > 0 {
+		query += "EXISTS (SELECT 1 FROM detections d WHERE d.image_id = i.id AND d.object_name IN (?" +
+			strings.Repeat(", ?", len(priority)-1) + ")), "
+		for _, label := range priority {
+			args = append(args, label)
+		}
+	}
+	query += "i.timestamp, i.id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query retention candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var images []model.Image
+	for rows.Next() {
+		var img model.Image
+		if err := rows.Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize, &img.Storage); err != nil {
+			return nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// Delete removes an image by its ID, together with its detections and variants.
 func (r *ImageRepository) Delete(id int64) error {
-	r.db.Lock()
-	defer r.db.Unlock()
+	return r.db.Transaction(func(tx *sql.Tx) error {
+		return deleteImage(tx, id)
+	})
+}
 
-	// First delete related detections
-	if _, err := r.db.Conn().Exec(`DELETE FROM detections WHERE image_id = ?`, id); err != nil {
-		return fmt.Errorf("failed to delete detections: %w", err)
+// GetBatch returns up to limit images with an ID above afterID, in ID order.
+func (r *ImageRepository) GetBatch(afterID int64, limit int) ([]model.Image, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, filename, camera, timestamp, filepath, filesize, storage
+		FROM images WHERE id > ? ORDER BY id LIMIT ?
+	`, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images: %w", err)
 	}
+	defer rows.Close()
 
-	if _, err := r.db.Conn().Exec(`DELETE FROM images WHERE id = ?`, id); err != nil {
-		return fmt.Errorf("failed to delete image: %w", err)
+	var images []model.Image
+	for rows.Next() {
+		var img model.Image
+		if err := rows.Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize, &img.Storage); err != nil {
+			return nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
+
+// UpdateFilePath changes where an image's file is stored.
+func (r *ImageRepository) UpdateFilePath(id int64, filePath string) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`UPDATE images SET filepath = ? WHERE id = ?`, filePath, id); err != nil {
+		return fmt.Errorf("failed to update image path: %w", err)
 	}
 	return nil
 }
 
+// SetMissing flags exactly the given images as having no file, clearing the flag of
+// every other image.
+func (r *ImageRepository) SetMissing(ids []int64) error {
+	return r.db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`UPDATE images SET missing = 0 WHERE missing != 0`); err != nil {
+			return fmt.Errorf("failed to clear missing images: %w", err)
+		}
+		for _, id := range ids {
+			if _, err := tx.Exec(`UPDATE images SET missing = 1 WHERE id = ?`, id); err != nil {
+				return fmt.Errorf("failed to flag missing image: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
 // DeleteByFilename removes an image by its filename.
 func (r *ImageRepository) DeleteByFilename(filename string) error {
-	r.db.Lock()
-	defer r.db.Unlock()
+	return r.db.Transaction(func(tx *sql.Tx) error {
+		var imageID int64
+		err := tx.QueryRow(`SELECT id FROM images WHERE filename = ?`, filename).Scan(&imageID)
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to get image id: %w", err)
+		}
+		return deleteImage(tx, imageID)
+	})
+}
 
-	// Get image ID first
-	var imageID int64
-	err := r.db.Conn().QueryRow(`SELECT id FROM images WHERE filename = ?`, filename).Scan(&imageID)
-	if err == sql.ErrNoRows {
+// DeleteAll removes all images with their detections and variants. Like deleteImage it
+// drops their clip links, keeps rule triggers without the image and leaves events
+// without images or thumbnails.
+func (r *ImageRepository) DeleteAll() error {
+	return r.db.Transaction(func(tx *sql.Tx) error {
+		if _, err := tx.Exec(`DELETE FROM clip_images WHERE image_filename IN (SELECT filename FROM images)`); err != nil {
+			return fmt.Errorf("failed to delete clip images: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE rule_triggers SET image = '' WHERE image IN (SELECT filename FROM images)`); err != nil {
+			return fmt.Errorf("failed to clear rule trigger images: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM event_images WHERE image_filename IN (SELECT filename FROM images)`); err != nil {
+			return fmt.Errorf("failed to delete event images: %w", err)
+		}
+		if _, err := tx.Exec(`
+			UPDATE events SET image_count = (SELECT COUNT(*) FROM event_images WHERE event_id = events.id),
+				thumbnail = CASE WHEN thumbnail IN (SELECT filename FROM images) THEN '' ELSE thumbnail END
+		`); err != nil {
+			return fmt.Errorf("failed to update events: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM detections`); err != nil {
+			return fmt.Errorf("failed to delete detections: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM image_variants`); err != nil {
+			return fmt.Errorf("failed to delete image variants: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM images`); err != nil {
+			return fmt.Errorf("failed to delete images: %w", err)
+		}
 		return nil
+	})
+}
+
+// deleteImage removes an image row with its detections and variants inside a transaction.
+// Clip links are dropped and rule triggers keep their history without the image. Events
+// lose the image, and those that used it as their thumbnail get the remaining image with
+// the highest confidence instead.
+func deleteImage(tx *sql.Tx, id int64) error {
+	var filename string
+	err := tx.QueryRow(`SELECT filename FROM images WHERE id = ?`, id).Scan(&filename)
+	if err != nil && err != sql.ErrNoRows {
+		return fmt.Errorf("failed to get image filename: %w", err)
 	}
-	if err != nil {
-		return fmt.Errorf("failed to get image id: %w", err)
+
+	if filename != "" {
+		if _, err := tx.Exec(`DELETE FROM clip_images WHERE image_filename = ?`, filename); err != nil {
+			return fmt.Errorf("failed to delete clip images: %w", err)
+		}
+		if _, err := tx.Exec(`UPDATE rule_triggers SET image = '' WHERE image = ?`, filename); err != nil {
+			return fmt.Errorf("failed to clear rule trigger images: %w", err)
+		}
+		if err := unlinkEventImage(tx, id, filename); err != nil {
+			return err
+		}
 	}
 
-	// Delete related detections
-	if _, err := r.db.Conn().Exec(`DELETE FROM detections WHERE image_id = ?`, imageID); err != nil {
+	if _, err := tx.Exec(`DELETE FROM detections WHERE image_id = ?`, id); err != nil {
 		return fmt.Errorf("failed to delete detections: %w", err)
 	}
-
-	if _, err := r.db.Conn().Exec(`DELETE FROM images WHERE id = ?`, imageID); err != nil {
+	if _, err := tx.Exec(`DELETE FROM image_variants WHERE image_id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete image variants: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM images WHERE id = ?`, id); err != nil {
 		return fmt.Errorf("failed to delete image: %w", err)
 	}
 	return nil
 }
 
-// DeleteAll removes all images and their detections.
-func (r *ImageRepository) DeleteAll() error {
-	r.db.Lock()
-	defer r.db.Unlock()
-
-	if _, err := r.db.Conn().Exec(`DELETE FROM detections`); err != nil {
-		return fmt.Errorf("failed to delete detections: %w", err)
+// unlinkEventImage removes an image from its events, recounting their images and
+// picking a new thumbnail where the image was the thumbnail.
+func unlinkEventImage(tx *sql.Tx, id int64, filename string) error {
+	rows, err := tx.Query(`SELECT event_id FROM event_images WHERE image_filename = ?`, filename)
+	if err != nil {
+		return fmt.Errorf("failed to query event images: %w", err)
+	}
+	var eventIDs []int64
+	for rows.Next() {
+		var eventID int64
+		if err := rows.Scan(&eventID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan event image: %w", err)
+		}
+		eventIDs = append(eventIDs, eventID)
 	}
+	rows.Close()
 
-	if _, err := r.db.Conn().Exec(`DELETE FROM images`); err != nil {
-		return fmt.Errorf("failed to delete images: %w", err)
+	if _, err := tx.Exec(`DELETE FROM event_images WHERE image_filename = ?`, filename); err != nil {
+		return fmt.Errorf("failed to delete event images: %w", err)
 	}
 
+	for _, eventID := range eventIDs {
+		if _, err := tx.Exec(`
+			UPDATE events SET image_count = (SELECT COUNT(*) FROM event_images WHERE event_id = ?)
+			WHERE id = ?
+		`, eventID, eventID); err != nil {
+			return fmt.Errorf("failed to update event image count: %w", err)
+		}
+		if _, err := tx.Exec(`
+			UPDATE events SET thumbnail = COALESCE((
+				SELECT ei.image_filename FROM event_images ei
+				JOIN images i ON i.filename = ei.image_filename AND i.id != ?
+				LEFT JOIN detections d ON d.image_id = i.id
+				WHERE ei.event_id = ?
+				GROUP BY ei.image_filename
+				ORDER BY MAX(COALESCE(d.confidence, 0)) DESC, MIN(i.timestamp)
+				LIMIT 1
+			), '')
+			WHERE id = ? AND thumbnail = ?
+		`, id, eventID, eventID, filename); err != nil {
+			return fmt.Errorf("failed to update event thumbnail: %w", err)
+		}
+	}
 	return nil
 }
@@ -0,0 +1,93 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"webserver/internal/model"
+)
+
+// ImageVariantRepository implements repository.ImageVariantRepository for SQLite.
+type ImageVariantRepository struct {
+	db *DB
+}
+
+// NewImageVariantRepository creates a new SQLite image variant repository.
+func NewImageVariantRepository(db *DB) *ImageVariantRepository {
+	return &ImageVariantRepository{db: db}
+}
+
+// Upsert adds a variant of an image or replaces the existing variant of the same size.
+// It reports false without saving anything when the image no longer exists.
+func (r *ImageVariantRepository) Upsert(variant *model.ImageVariant) (bool, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO image_variants (image_id, size, filename, width, height, filesize)
+		SELECT ?, ?, ?, ?, ?, ? WHERE EXISTS (SELECT 1 FROM images WHERE id = ?)
+		ON CONFLICT (image_id, size) DO UPDATE SET
+			filename = excluded.filename, width = excluded.width, height = excluded.height,
+			filesize = excluded.filesize
+	`, variant.ImageID, variant.Size, variant.Filename, variant.Width, variant.Height, variant.FileSize, variant.ImageID)
+	if err != nil {
+		return false, fmt.Errorf("failed to save image variant: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to save image variant: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// GetByImageID retrieves all variants of an image.
+func (r *ImageVariantRepository) GetByImageID(imageID int64) ([]model.ImageVariant, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, image_id, size, filename, width, height, filesize
+		FROM image_variants WHERE image_id = ? ORDER BY width
+	`, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query image variants: %w", err)
+	}
+	defer rows.Close()
+
+	var variants []model.ImageVariant
+	for rows.Next() {
+		var v model.ImageVariant
+		if err := rows.Scan(&v.ID, &v.ImageID, &v.Size, &v.Filename, &v.Width, &v.Height, &v.FileSize); err != nil {
+			return nil, fmt.Errorf("failed to scan image variant: %w", err)
+		}
+		variants = append(variants, v)
+	}
+	return variants, nil
+}
+
+// GetMissing returns up to limit images with an ID above afterID that have fewer than
+// the given number of variants, in ID order.
+func (r *ImageVariantRepository) GetMissing(afterID int64, variants, limit int) ([]model.Image, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, filename, camera, timestamp, filepath, filesize, storage
+		FROM images
+		WHERE id > ? AND (SELECT COUNT(*) FROM image_variants WHERE image_id = images.id) < ?
+		ORDER BY id LIMIT ?
+	`, afterID, variants, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query images without variants: %w", err)
+	}
+	defer rows.Close()
+
+	var images []model.Image
+	for rows.Next() {
+		var img model.Image
+		if err := rows.Scan(&img.ID, &img.Filename, &img.Camera, &img.Timestamp, &img.FilePath, &img.FileSize, &img.Storage); err != nil {
+			return nil, fmt.Errorf("failed to scan image: %w", err)
+		}
+		images = append(images, img)
+	}
+	return images, nil
+}
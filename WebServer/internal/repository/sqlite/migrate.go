@@ -0,0 +1,266 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Migration is a versioned schema change. Up applies it and Down reverts it; each runs
+// in its own transaction together with the change to the schema_migrations table, so a
+// failing step leaves the database at the previous version.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(tx *sql.Tx) error
+	Down        func(tx *sql.Tx) error
+}
+
+// MigrationStatus describes a migration and whether it has been applied.
+type MigrationStatus struct {
+	Version     int
+	Description string
+	Applied     bool
+	AppliedAt   time.Time
+	Unknown     bool // applied by a newer version of the server
+}
+
+// LatestVersion returns the schema version of the newest migration.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// Version returns the newest applied migration, 0 when none has been applied.
+func (db *DB) Version() (int, error) {
+	applied, err := db.applied()
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range applied {
+		version = max(version, v)
+	}
+	return version, nil
+}
+
+// MigrationStatus lists the migrations by version, including applied migrations that
+// this version of the server does not know.
+func (db *DB) MigrationStatus() ([]MigrationStatus, error) {
+	applied, err := db.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []MigrationStatus
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Description: m.Description}
+		if row, exists := applied[m.Version]; exists {
+			status.Applied = true
+			status.AppliedAt = row.AppliedAt
+			delete(applied, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range applied {
+		row.Unknown = true
+		statuses = append(statuses, row)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// MigrateUp applies the pending migrations up to and including target, oldest first,
+// and returns how many were applied. Databases with migrations unknown to this version
+// of the server are left alone.
+func (db *DB) MigrateUp(target int) (int, error) {
+	applied, err := db.applied()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkKnown(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if m.Version > target {
+			break
+		}
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+
+		err := db.Transaction(func(tx *sql.Tx) error {
+			if _, err := tx.Exec(migrationsSchema); err != nil {
+				return err
+			}
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, ?)`,
+				m.Version, m.Description, time.Now())
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to apply migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateDown reverts the applied migrations above target, newest first, and returns
+// how many were reverted. Reverting drops the tables and columns a migration added,
+// together with their data.
+func (db *DB) MigrateDown(target int) (int, error) {
+	applied, err := db.applied()
+	if err != nil {
+		return 0, err
+	}
+	if err := checkKnown(applied); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= target {
+			break
+		}
+		if _, exists := applied[m.Version]; !exists {
+			continue
+		}
+
+		err := db.Transaction(func(tx *sql.Tx) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			_, err := tx.Exec(`DELETE FROM schema_migrations WHERE version = ?`, m.Version)
+			return err
+		})
+		if err != nil {
+			return count, fmt.Errorf("failed to revert migration %d (%s): %w", m.Version, m.Description, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// migrationsSchema creates the table recording the applied migrations.
+const migrationsSchema = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		description TEXT NOT NULL,
+		applied_at DATETIME NOT NULL
+	)`
+
+// applied returns the applied migrations by version.
+func (db *DB) applied() (map[int]MigrationStatus, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	applied := make(map[int]MigrationStatus)
+	var name string
+	err := db.conn.QueryRow(`SELECT name FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'`).Scan(&name)
+	if err == sql.ErrNoRows {
+		return applied, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	rows, err := db.conn.Query(`SELECT version, description, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		status := MigrationStatus{Applied: true}
+		if err := rows.Scan(&status.Version, &status.Description, &status.AppliedAt); err != nil {
+			return nil, fmt.Errorf("failed to read schema version: %w", err)
+		}
+		applied[status.Version] = status
+	}
+	return applied, rows.Err()
+}
+
+// checkKnown returns an error when a migration was applied that this version of the
+// server does not know, such as after downgrading the server.
+func checkKnown(applied map[int]MigrationStatus) error {
+	for version := range applied {
+		if version > LatestVersion() {
+			return fmt.Errorf("database schema version %d is newer than this server supports (%d)", version, LatestVersion())
+		}
+	}
+	return nil
+}
+
+// exec returns a migration step running SQL statements.
+func exec(statements string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(statements)
+		return err
+	}
+}
+
+// steps returns a migration step running the given steps in order.
+func steps(fns ...func(tx *sql.Tx) error) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		for _, fn := range fns {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// addColumn returns a migration step adding a column unless the table already has it.
+func addColumn(table, column, definition string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		exists, err := hasColumn(tx, table, column)
+		if err != nil || exists {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+		return err
+	}
+}
+
+// dropColumn returns a migration step removing a column if the table has it.
+func dropColumn(table, column string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		exists, err := hasColumn(tx, table, column)
+		if err != nil || !exists {
+			return err
+		}
+		_, err = tx.Exec(fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", table, column))
+		return err
+	}
+}
+
+// hasColumn reports whether a table has a column.
+func hasColumn(tx *sql.Tx, table, column string) (bool, error) {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid, notNull, pk int
+			name, colType    string
+			defaultValue     sql.NullString
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return false, err
+		}
+		if name == column {
+			return true, nil
+		}
+	}
+	return false, rows.Err()
+}
@@ -0,0 +1,280 @@
+package sqlite
+
+// migrations lists the schema changes in the order they are applied. Never edit or
+// renumber a released migration; add a new one with the next version instead.
+//
+// Databases created before migrations were tracked have some of these tables and
+// columns without a schema_migrations row, so every Up only creates what is missing
+// (IF NOT EXISTS, addColumn) and such databases are brought up to date in place.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "images and detections",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS images (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				filename TEXT NOT NULL UNIQUE,
+				camera TEXT NOT NULL,
+				timestamp DATETIME NOT NULL,
+				filepath TEXT NOT NULL,
+				filesize INTEGER DEFAULT 0,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS detections (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				image_id INTEGER NOT NULL,
+				object_name TEXT NOT NULL,
+				x INTEGER DEFAULT 0,
+				y INTEGER DEFAULT 0,
+				width INTEGER DEFAULT 0,
+				height INTEGER DEFAULT 0,
+				confidence REAL DEFAULT 0,
+				FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_images_camera ON images(camera);
+			CREATE INDEX IF NOT EXISTS idx_images_timestamp ON images(timestamp);
+			CREATE INDEX IF NOT EXISTS idx_detections_object_name ON detections(object_name);
+			CREATE INDEX IF NOT EXISTS idx_detections_image_id ON detections(image_id);
+		`),
+		Down: exec(`
+			DROP TABLE IF EXISTS detections;
+			DROP TABLE IF EXISTS images;
+		`),
+	},
+	{
+		Version:     2,
+		Description: "camera registry",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS cameras (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				name TEXT NOT NULL UNIQUE,
+				device_id INTEGER UNIQUE,
+				secret TEXT NOT NULL DEFAULT '',
+				ip_address TEXT UNIQUE,
+				enabled INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+		`),
+		Down: exec(`DROP TABLE IF EXISTS cameras;`),
+	},
+	{
+		Version:     3,
+		Description: "camera health events",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS camera_events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				camera TEXT NOT NULL,
+				type TEXT NOT NULL,
+				message TEXT NOT NULL DEFAULT '',
+				timestamp DATETIME NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_camera_events_camera_timestamp ON camera_events(camera, timestamp);
+		`),
+		Down: exec(`DROP TABLE IF EXISTS camera_events;`),
+	},
+	{
+		Version:     4,
+		Description: "continuous recordings",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS recordings (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				camera TEXT NOT NULL,
+				filename TEXT NOT NULL UNIQUE,
+				filepath TEXT NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				frames INTEGER DEFAULT 0,
+				width INTEGER DEFAULT 0,
+				height INTEGER DEFAULT 0,
+				filesize INTEGER DEFAULT 0,
+				complete INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_recordings_camera_start ON recordings(camera, start_time);
+		`),
+		Down: exec(`DROP TABLE IF EXISTS recordings;`),
+	},
+	{
+		Version:     5,
+		Description: "event clips",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS clips (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				camera TEXT NOT NULL,
+				filename TEXT NOT NULL UNIQUE,
+				filepath TEXT NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				frames INTEGER DEFAULT 0,
+				filesize INTEGER DEFAULT 0,
+				complete INTEGER NOT NULL DEFAULT 0
+			);
+
+			CREATE TABLE IF NOT EXISTS clip_images (
+				clip_id INTEGER NOT NULL,
+				image_filename TEXT NOT NULL,
+				PRIMARY KEY (clip_id, image_filename),
+				FOREIGN KEY (clip_id) REFERENCES clips(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_clip_images_image_filename ON clip_images(image_filename);
+		`),
+		Down: exec(`
+			DROP TABLE IF EXISTS clip_images;
+			DROP TABLE IF EXISTS clips;
+		`),
+	},
+	{
+		Version:     6,
+		Description: "detection events",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS events (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				camera TEXT NOT NULL,
+				start_time DATETIME NOT NULL,
+				end_time DATETIME NOT NULL,
+				peak_confidence REAL DEFAULT 0,
+				objects TEXT NOT NULL DEFAULT '',
+				thumbnail TEXT NOT NULL DEFAULT '',
+				image_count INTEGER DEFAULT 0
+			);
+
+			CREATE TABLE IF NOT EXISTS event_images (
+				event_id INTEGER NOT NULL,
+				image_filename TEXT NOT NULL,
+				PRIMARY KEY (event_id, image_filename),
+				FOREIGN KEY (event_id) REFERENCES events(id) ON DELETE CASCADE
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_events_camera_start ON events(camera, start_time);
+		`),
+		Down: exec(`
+			DROP TABLE IF EXISTS event_images;
+			DROP TABLE IF EXISTS events;
+		`),
+	},
+	{
+		Version:     7,
+		Description: "motion zones",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS motion_zones (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				camera TEXT NOT NULL,
+				name TEXT NOT NULL DEFAULT '',
+				type TEXT NOT NULL,
+				points TEXT NOT NULL,
+				threshold_percent REAL DEFAULT 0,
+				enabled INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_motion_zones_camera ON motion_zones(camera);
+		`),
+		Down: exec(`DROP TABLE IF EXISTS motion_zones;`),
+	},
+	{
+		Version:     8,
+		Description: "detection zones",
+		Up:          addColumn("detections", "zone", "TEXT NOT NULL DEFAULT ''"),
+		Down:        dropColumn("detections", "zone"),
+	},
+	{
+		Version:     9,
+		Description: "object tracks",
+		Up: steps(
+			exec(`
+				CREATE TABLE IF NOT EXISTS tracks (
+					id INTEGER PRIMARY KEY AUTOINCREMENT,
+					camera TEXT NOT NULL,
+					label TEXT NOT NULL,
+					start_time DATETIME NOT NULL,
+					end_time DATETIME NOT NULL,
+					path TEXT NOT NULL DEFAULT '[]'
+				);
+
+				CREATE INDEX IF NOT EXISTS idx_tracks_camera_start ON tracks(camera, start_time);
+			`),
+			addColumn("detections", "track_id", "INTEGER NOT NULL DEFAULT 0"),
+		),
+		Down: steps(
+			dropColumn("detections", "track_id"),
+			exec(`DROP TABLE IF EXISTS tracks;`),
+		),
+	},
+	{
+		Version:     10,
+		Description: "rules and rule triggers",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS rules (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				camera TEXT NOT NULL,
+				name TEXT NOT NULL DEFAULT '',
+				type TEXT NOT NULL,
+				labels TEXT NOT NULL DEFAULT '',
+				points TEXT NOT NULL,
+				direction TEXT NOT NULL DEFAULT '',
+				dwell_seconds REAL DEFAULT 0,
+				enabled INTEGER NOT NULL DEFAULT 1,
+				created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+			);
+
+			CREATE TABLE IF NOT EXISTS rule_triggers (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				rule_id INTEGER NOT NULL,
+				rule_name TEXT NOT NULL DEFAULT '',
+				type TEXT NOT NULL,
+				camera TEXT NOT NULL,
+				track_id INTEGER NOT NULL DEFAULT 0,
+				label TEXT NOT NULL DEFAULT '',
+				detail TEXT NOT NULL DEFAULT '',
+				image TEXT NOT NULL DEFAULT '',
+				timestamp DATETIME NOT NULL
+			);
+
+			CREATE INDEX IF NOT EXISTS idx_rule_triggers_camera_timestamp ON rule_triggers(camera, timestamp);
+		`),
+		Down: exec(`
+			DROP TABLE IF EXISTS rule_triggers;
+			DROP TABLE IF EXISTS rules;
+		`),
+	},
+	{
+		Version:     11,
+		Description: "image thumbnails and previews",
+		Up: exec(`
+			CREATE TABLE IF NOT EXISTS image_variants (
+				id INTEGER PRIMARY KEY AUTOINCREMENT,
+				image_id INTEGER NOT NULL,
+				size TEXT NOT NULL,
+				filename TEXT NOT NULL,
+				width INTEGER DEFAULT 0,
+				height INTEGER DEFAULT 0,
+				filesize INTEGER DEFAULT 0,
+				UNIQUE (image_id, size),
+				FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+			);
+		`),
+		Down: exec(`DROP TABLE IF EXISTS image_variants;`),
+	},
+	{
+		Version:     12,
+		Description: "image storage backends",
+		Up:          addColumn("images", "storage", "TEXT NOT NULL DEFAULT 'local'"),
+		Down:        dropColumn("images", "storage"),
+	},
+	{
+		Version:     13,
+		Description: "images missing their file",
+		Up:          addColumn("images", "missing", "INTEGER NOT NULL DEFAULT 0"),
+		Down:        dropColumn("images", "missing"),
+	},
+	{
+		Version:     14,
+		Description: "camera boot counters",
+		Up:          addColumn("cameras", "boot", "INTEGER NOT NULL DEFAULT 0"),
+		Down:        dropColumn("cameras", "boot"),
+	},
+}
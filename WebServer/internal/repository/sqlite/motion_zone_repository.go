@@ -0,0 +1,129 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"webserver/internal/model"
+)
+
+// MotionZoneRepository implements repository.MotionZoneRepository for SQLite.
+type MotionZoneRepository struct {
+	db *DB
+}
+
+// NewMotionZoneRepository creates a new SQLite motion zone repository.
+func NewMotionZoneRepository(db *DB) *MotionZoneRepository {
+	return &MotionZoneRepository{db: db}
+}
+
+// Insert adds a new motion zone to the database.
+func (r *MotionZoneRepository) Insert(zone *model.MotionZone) (int64, error) {
+	points, err := json.Marshal(zone.Points)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode zone points: %w", err)
+	}
+
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO motion_zones (camera, name, type, points, threshold_percent, enabled)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, zone.Camera, zone.Name, zone.Type, string(points), zone.ThresholdPercent, zone.Enabled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert motion zone: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetByID retrieves a motion zone by its ID.
+func (r *MotionZoneRepository) GetByID(id int64) (*model.MotionZone, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	zone, err := scanMotionZone(r.db.Conn().QueryRow(`
+		SELECT id, camera, name, type, points, threshold_percent, enabled, created_at
+		FROM motion_zones WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get motion zone: %w", err)
+	}
+	return zone, nil
+}
+
+// GetAll retrieves all motion zones ordered by camera and ID.
+func (r *MotionZoneRepository) GetAll() ([]model.MotionZone, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, camera, name, type, points, threshold_percent, enabled, created_at
+		FROM motion_zones ORDER BY camera, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query motion zones: %w", err)
+	}
+	defer rows.Close()
+
+	var zones []model.MotionZone
+	for rows.Next() {
+		zone, err := scanMotionZone(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan motion zone: %w", err)
+		}
+		zones = append(zones, *zone)
+	}
+
+	return zones, nil
+}
+
+// Update saves motion zone changes.
+func (r *MotionZoneRepository) Update(zone *model.MotionZone) error {
+	points, err := json.Marshal(zone.Points)
+	if err != nil {
+		return fmt.Errorf("failed to encode zone points: %w", err)
+	}
+
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		UPDATE motion_zones SET camera = ?, name = ?, type = ?, points = ?, threshold_percent = ?, enabled = ?
+		WHERE id = ?
+	`, zone.Camera, zone.Name, zone.Type, string(points), zone.ThresholdPercent, zone.Enabled, zone.ID); err != nil {
+		return fmt.Errorf("failed to update motion zone: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a motion zone.
+func (r *MotionZoneRepository) Delete(id int64) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`DELETE FROM motion_zones WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete motion zone: %w", err)
+	}
+	return nil
+}
+
+// scanMotionZone reads a motion_zones row, decoding the stored polygon.
+func scanMotionZone(row rowScanner) (*model.MotionZone, error) {
+	var zone model.MotionZone
+	var points string
+	if err := row.Scan(&zone.ID, &zone.Camera, &zone.Name, &zone.Type, &points, &zone.ThresholdPercent,
+		&zone.Enabled, &zone.CreatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(points), &zone.Points); err != nil {
+		return nil, fmt.Errorf("failed to decode zone points: %w", err)
+	}
+	return &zone, nil
+}
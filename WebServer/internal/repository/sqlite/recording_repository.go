@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"webserver/internal/model"
+)
+
+// RecordingRepository implements repository.RecordingRepository for SQLite.
+type RecordingRepository struct {
+	db *DB
+}
+
+// NewRecordingRepository creates a new SQLite recording repository.
+func NewRecordingRepository(db *DB) *RecordingRepository {
+	return &RecordingRepository{db: db}
+}
+
+// Insert adds a new recording segment to the database.
+func (r *RecordingRepository) Insert(rec *model.Recording) (int64, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO recordings (camera, filename, filepath, start_time, end_time, frames, width, height, filesize, complete)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rec.Camera, rec.Filename, rec.FilePath, rec.StartTime, rec.EndTime,
+		rec.Frames, rec.Width, rec.Height, rec.FileSize, rec.Complete)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert recording: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetByID retrieves a recording segment by its ID.
+func (r *RecordingRepository) GetByID(id int64) (*model.Recording, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rec, err := scanRecording(r.db.Conn().QueryRow(`
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, width, height, filesize, complete
+		FROM recordings WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recording: %w", err)
+	}
+	return rec, nil
+}
+
+// GetByRange returns segments overlapping the given time range, oldest first.
+// An empty camera matches all cameras; zero times leave the range open.
+// Segments still being recorded overlap any range after their start.
+func (r *RecordingRepository) GetByRange(camera string, from, to time.Time) ([]model.Recording, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	query := `
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, width, height, filesize, complete
+		FROM recordings WHERE 1=1`
+	args := []interface{}{}
+
+	if camera != "" {
+		query += " AND camera = ?"
+		args = append(args, camera)
+	}
+	if !from.IsZero() {
+		query += " AND (end_time >= ? OR complete = 0)"
+		args = append(args, from)
+	}
+	if !to.IsZero() {
+		query += " AND start_time <= ?"
+		args = append(args, to)
+	}
+	query += " ORDER BY start_time, id"
+
+	return r.query(query, args...)
+}
+
+// GetIncomplete returns segments that were never finalized, e.g. after a crash.
+func (r *RecordingRepository) GetIncomplete() ([]model.Recording, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	return r.query(`
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, width, height, filesize, complete
+		FROM recordings WHERE complete = 0 ORDER BY start_time, id
+	`)
+}
+
+// GetOldest returns finished segments that ended before the given time (any time when
+// zero), oldest first.
+func (r *RecordingRepository) GetOldest(before time.Time, limit int) ([]model.Recording, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	query := `
+		SELECT id, camera, filename, filepath, start_time, end_time, frames, width, height, filesize, complete
+		FROM recordings WHERE complete = 1`
+	args := []interface{}{}
+
+	if !before.IsZero() {
+		query += " AND end_time < ?"
+		args = append(args, before)
+	}
+	query += " ORDER BY start_time, id LIMIT ?"
+	args = append(args, limit)
+
+	return r.query(query, args...)
+}
+
+// GetTotalSize returns the total size in bytes of all segments.
+func (r *RecordingRepository) GetTotalSize() (int64, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	var size int64
+	if err := r.db.Conn().QueryRow(`SELECT COALESCE(SUM(filesize), 0) FROM recordings`).Scan(&size); err != nil {
+		return 0, fmt.Errorf("failed to get recording size: %w", err)
+	}
+	return size, nil
+}
+
+// Update saves the end time, frame count, size and completion state of a segment.
+func (r *RecordingRepository) Update(rec *model.Recording) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		UPDATE recordings SET end_time = ?, frames = ?, width = ?, height = ?, filesize = ?, complete = ?
+		WHERE id = ?
+	`, rec.EndTime, rec.Frames, rec.Width, rec.Height, rec.FileSize, rec.Complete, rec.ID); err != nil {
+		return fmt.Errorf("failed to update recording: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a recording segment from the database.
+func (r *RecordingRepository) Delete(id int64) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`DELETE FROM recordings WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete recording: %w", err)
+	}
+	return nil
+}
+
+// query runs a recordings SELECT and scans all rows. Caller must hold the read lock.
+func (r *RecordingRepository) query(query string, args ...interface{}) ([]model.Recording, error) {
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query recordings: %w", err)
+	}
+	defer rows.Close()
+
+	var recordings []model.Recording
+	for rows.Next() {
+		rec, err := scanRecording(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan recording: %w", err)
+		}
+		recordings = append(recordings, *rec)
+	}
+
+	return recordings, nil
+}
+
+// scanRecording reads a recordings row.
+func scanRecording(row rowScanner) (*model.Recording, error) {
+	var rec model.Recording
+	if err := row.Scan(&rec.ID, &rec.Camera, &rec.Filename, &rec.FilePath, &rec.StartTime, &rec.EndTime,
+		&rec.Frames, &rec.Width, &rec.Height, &rec.FileSize, &rec.Complete); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
@@ -0,0 +1,136 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"webserver/internal/model"
+)
+
+// RuleRepository implements repository.RuleRepository for SQLite.
+type RuleRepository struct {
+	db *DB
+}
+
+// NewRuleRepository creates a new SQLite rule repository.
+func NewRuleRepository(db *DB) *RuleRepository {
+	return &RuleRepository{db: db}
+}
+
+// Insert adds a new rule to the database.
+func (r *RuleRepository) Insert(rule *model.Rule) (int64, error) {
+	points, err := json.Marshal(rule.Points)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode rule points: %w", err)
+	}
+
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO rules (camera, name, type, labels, points, direction, dwell_seconds, enabled)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.Camera, rule.Name, rule.Type, strings.Join(rule.Labels, ","), string(points), rule.Direction,
+		rule.DwellSeconds, rule.Enabled)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert rule: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetByID retrieves a rule by its ID.
+func (r *RuleRepository) GetByID(id int64) (*model.Rule, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rule, err := scanRule(r.db.Conn().QueryRow(`
+		SELECT id, camera, name, type, labels, points, direction, dwell_seconds, enabled, created_at
+		FROM rules WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rule: %w", err)
+	}
+	return rule, nil
+}
+
+// GetAll retrieves all rules ordered by camera and ID.
+func (r *RuleRepository) GetAll() ([]model.Rule, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	rows, err := r.db.Conn().Query(`
+		SELECT id, camera, name, type, labels, points, direction, dwell_seconds, enabled, created_at
+		FROM rules ORDER BY camera, id
+	`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rules: %w", err)
+	}
+	defer rows.Close()
+
+	var rules []model.Rule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, nil
+}
+
+// Update saves rule changes.
+func (r *RuleRepository) Update(rule *model.Rule) error {
+	points, err := json.Marshal(rule.Points)
+	if err != nil {
+		return fmt.Errorf("failed to encode rule points: %w", err)
+	}
+
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		UPDATE rules SET camera = ?, name = ?, type = ?, labels = ?, points = ?, direction = ?, dwell_seconds = ?, enabled = ?
+		WHERE id = ?
+	`, rule.Camera, rule.Name, rule.Type, strings.Join(rule.Labels, ","), string(points), rule.Direction,
+		rule.DwellSeconds, rule.Enabled, rule.ID); err != nil {
+		return fmt.Errorf("failed to update rule: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a rule. Its triggers are kept as history.
+func (r *RuleRepository) Delete(id int64) error {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`DELETE FROM rules WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete rule: %w", err)
+	}
+	return nil
+}
+
+// scanRule reads a rules row, decoding the stored labels and points.
+func scanRule(row rowScanner) (*model.Rule, error) {
+	var rule model.Rule
+	var labels, points string
+	if err := row.Scan(&rule.ID, &rule.Camera, &rule.Name, &rule.Type, &labels, &points, &rule.Direction,
+		&rule.DwellSeconds, &rule.Enabled, &rule.CreatedAt); err != nil {
+		return nil, err
+	}
+	rule.Labels = []string{}
+	if labels != "" {
+		rule.Labels = strings.Split(labels, ",")
+	}
+	if err := json.Unmarshal([]byte(points), &rule.Points); err != nil {
+		return nil, fmt.Errorf("failed to decode rule points: %w", err)
+	}
+	return &rule, nil
+}
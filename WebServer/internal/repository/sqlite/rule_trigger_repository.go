@@ -0,0 +1,79 @@
+package sqlite
+
+import (
+	"fmt"
+
+	"webserver/internal/model"
+)
+
+// RuleTriggerRepository implements repository.RuleTriggerRepository for SQLite.
+type RuleTriggerRepository struct {
+	db *DB
+}
+
+// NewRuleTriggerRepository creates a new SQLite rule trigger repository.
+func NewRuleTriggerRepository(db *DB) *RuleTriggerRepository {
+	return &RuleTriggerRepository{db: db}
+}
+
+// Insert adds a new rule trigger to the database.
+func (r *RuleTriggerRepository) Insert(trigger *model.RuleTrigger) (int64, error) {
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO rule_triggers (rule_id, rule_name, type, camera, track_id, label, detail, image, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trigger.RuleID, trigger.RuleName, trigger.Type, trigger.Camera, trigger.TrackID, trigger.Label,
+		trigger.Detail, trigger.Image, trigger.Timestamp)
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert rule trigger: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetRecent returns the newest triggers, optionally limited to one camera and rule.
+func (r *RuleTriggerRepository) GetRecent(camera string, ruleID int64, limit int) ([]model.RuleTrigger, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	query := `
+		SELECT id, rule_id, rule_name, type, camera, track_id, label, detail, image, timestamp
+		FROM rule_triggers WHERE 1=1`
+	args := []interface{}{}
+
+	if camera != "" {
+		query += " AND camera = ?"
+		args = append(args, camera)
+	}
+
+	if ruleID > 0 {
+		query += " AND rule_id = ?"
+		args = append(args, ruleID)
+	}
+
+	if limit <= 0 {
+		limit = 50
+	}
+	query += " ORDER BY timestamp DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query rule triggers: %w", err)
+	}
+	defer rows.Close()
+
+	var triggers []model.RuleTrigger
+	for rows.Next() {
+		var t model.RuleTrigger
+		if err := rows.Scan(&t.ID, &t.RuleID, &t.RuleName, &t.Type, &t.Camera, &t.TrackID, &t.Label,
+			&t.Detail, &t.Image, &t.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan rule trigger: %w", err)
+		}
+		triggers = append(triggers, t)
+	}
+
+	return triggers, nil
+}
@@ -14,59 +14,34 @@ type DB struct {
 	mu   sync.RWMutex
 }
 
-// New creates and initializes a new SQLite database connection.
+// New opens the SQLite database and applies its pending migrations.
 func New(dbPath string) (*DB, error) {
-	conn, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	db, err := Open(dbPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %w", err)
+		return nil, err
 	}
 
-	conn.SetMaxOpenConns(1)
-	conn.SetMaxIdleConns(1)
-	conn.SetConnMaxLifetime(0)
-
-	db := &DB{conn: conn}
-
-	if err := db.migrate(); err != nil {
+	if _, err := db.MigrateUp(LatestVersion()); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
 	return db, nil
 }
 
-// migrate creates the necessary tables if they don't exist.
-func (db *DB) migrate() error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS images (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		filename TEXT NOT NULL UNIQUE,
-		camera TEXT NOT NULL,
-		timestamp DATETIME NOT NULL,
-		filepath TEXT NOT NULL,
-		filesize INTEGER DEFAULT 0,
-		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
-	);
-
-	CREATE TABLE IF NOT EXISTS detections (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		image_id INTEGER NOT NULL,
-		object_name TEXT NOT NULL,
-		x INTEGER DEFAULT 0,
-		y INTEGER DEFAULT 0,
-		width INTEGER DEFAULT 0,
-		height INTEGER DEFAULT 0,
-		confidence REAL DEFAULT 0,
-		FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
-	);
-
-	CREATE INDEX IF NOT EXISTS idx_images_camera ON images(camera);
-	CREATE INDEX IF NOT EXISTS idx_images_timestamp ON images(timestamp);
-	CREATE INDEX IF NOT EXISTS idx_detections_object_name ON detections(object_name);
-	CREATE INDEX IF NOT EXISTS idx_detections_image_id ON detections(image_id);
-	`
-
-	_, err := db.conn.Exec(schema)
-	return err
+// Open opens the SQLite database without changing its schema, for inspecting and
+// migrating it.
+func Open(dbPath string) (*DB, error) {
+	conn, err := sql.Open("sqlite3", dbPath+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	conn.SetMaxOpenConns(1)
+	conn.SetMaxIdleConns(1)
+	conn.SetConnMaxLifetime(0)
+
+	return &DB{conn: conn}, nil
 }
 
 // Close closes the database connection.
@@ -98,3 +73,25 @@ func (db *DB) RLock() {
 func (db *DB) RUnlock() {
 	db.mu.RUnlock()
 }
+
+// Transaction runs fn in a transaction while holding the write lock. The transaction
+// is committed when fn returns nil and rolled back otherwise, so the statements of fn
+// are applied together or not at all.
+func (db *DB) Transaction(fn func(tx *sql.Tx) error) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	tx, err := db.conn.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
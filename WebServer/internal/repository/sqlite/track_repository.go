@@ -0,0 +1,167 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"webserver/internal/dto"
+	"webserver/internal/model"
+)
+
+// TrackRepository implements repository.TrackRepository for SQLite.
+type TrackRepository struct {
+	db *DB
+}
+
+// NewTrackRepository creates a new SQLite track repository.
+func NewTrackRepository(db *DB) *TrackRepository {
+	return &TrackRepository{db: db}
+}
+
+// Insert adds a new track to the database.
+func (r *TrackRepository) Insert(track *model.Track) (int64, error) {
+	path, err := json.Marshal(track.Path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode track path: %w", err)
+	}
+
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	result, err := r.db.Conn().Exec(`
+		INSERT INTO tracks (camera, label, start_time, end_time, path)
+		VALUES (?, ?, ?, ?, ?)
+	`, track.Camera, track.Label, track.StartTime, track.EndTime, string(path))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert track: %w", err)
+	}
+
+	return result.LastInsertId()
+}
+
+// GetByID retrieves a track by its ID.
+func (r *TrackRepository) GetByID(id int64) (*model.Track, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	track, err := scanTrack(r.db.Conn().QueryRow(`
+		SELECT id, camera, label, start_time, end_time, path
+		FROM tracks WHERE id = ?
+	`, id))
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get track: %w", err)
+	}
+	return track, nil
+}
+
+// GetAll retrieves tracks based on the same filter criteria as images, newest first.
+// The object filter matches the track label.
+func (r *TrackRepository) GetAll(filter *dto.ImageFilters) ([]model.Track, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	where, args := trackFilter(filter)
+	query := `
+		SELECT id, camera, label, start_time, end_time, path
+		FROM tracks WHERE 1=1` + where + " ORDER BY start_time DESC, id DESC"
+
+	limit := filter.Limit
+	page := filter.Page
+	if limit <= 0 {
+		limit = 24
+	}
+	if page < 1 {
+		page = 1
+	}
+
+	offset := (page - 1) * limit
+	query += " LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.db.Conn().Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query tracks: %w", err)
+	}
+	defer rows.Close()
+
+	var tracks []model.Track
+	for rows.Next() {
+		track, err := scanTrack(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan track: %w", err)
+		}
+		tracks = append(tracks, *track)
+	}
+
+	return tracks, nil
+}
+
+// GetTotalCount returns the total number of tracks matching the filter criteria.
+func (r *TrackRepository) GetTotalCount(filter *dto.ImageFilters) (int, error) {
+	r.db.RLock()
+	defer r.db.RUnlock()
+
+	where, args := trackFilter(filter)
+
+	var count int
+	err := r.db.Conn().QueryRow(`SELECT COUNT(*) FROM tracks WHERE 1=1`+where, args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count tracks: %w", err)
+	}
+	return count, nil
+}
+
+// Update saves the end time and path of a track.
+func (r *TrackRepository) Update(track *model.Track) error {
+	path, err := json.Marshal(track.Path)
+	if err != nil {
+		return fmt.Errorf("failed to encode track path: %w", err)
+	}
+
+	r.db.Lock()
+	defer r.db.Unlock()
+
+	if _, err := r.db.Conn().Exec(`
+		UPDATE tracks SET start_time = ?, end_time = ?, path = ? WHERE id = ?
+	`, track.StartTime, track.EndTime, string(path), track.ID); err != nil {
+		return fmt.Errorf("failed to update track: %w", err)
+	}
+	return nil
+}
+
+// trackFilter builds the WHERE conditions for image filters applied to tracks.
+func trackFilter(filter *dto.ImageFilters) (string, []interface{}) {
+	where, args := eventFilter(&dto.ImageFilters{
+		Camera:     filter.Camera,
+		DateAfter:  filter.DateAfter,
+		DateBefore: filter.DateBefore,
+		TimeAfter:  filter.TimeAfter,
+		TimeBefore: filter.TimeBefore,
+	})
+
+	if filter.Object != "" {
+		where += " AND label = ?"
+		args = append(args, filter.Object)
+	}
+
+	return where, args
+}
+
+// scanTrack reads a tracks row, decoding the stored path.
+func scanTrack(row rowScanner) (*model.Track, error) {
+	var track model.Track
+	var path string
+	if err := row.Scan(&track.ID, &track.Camera, &track.Label, &track.StartTime, &track.EndTime, &path); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(path), &track.Path); err != nil {
+		return nil, fmt.Errorf("failed to decode track path: %w", err)
+	}
+	track.DwellSeconds = track.EndTime.Sub(track.StartTime).Seconds()
+	return &track, nil
+}
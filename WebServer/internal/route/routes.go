@@ -33,7 +33,10 @@ func dynamicHTMLHandler(w http.ResponseWriter, r *http.Request) {
 // SetupRoutes registers HTTP routes, static file serving, API endpoints,
 // and wraps the mux with the authentication middleware.
 func SetupRoutes(manager *service.Manager, cfg *config.Config, logger *logger.Logger,
-	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository) http.Handler {
+	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository,
+	cameraEventRepo repository.CameraEventRepository, recordingRepo repository.RecordingRepository,
+	clipRepo repository.ClipRepository, eventRepo repository.EventRepository, trackRepo repository.TrackRepository,
+	variantRepo repository.ImageVariantRepository) http.Handler {
 	mux := http.NewServeMux()
 
 	// Static files
@@ -44,10 +47,24 @@ func SetupRoutes(manager *service.Manager, cfg *config.Config, logger *logger.Lo
 
 	// API endpoints
 	mux.HandleFunc("/api/view", handler.ViewWebsocketHandler(manager, logger))
-	mux.HandleFunc("/api/pictures", handler.GetPicturesFromDBHandler(manager, cfg, logger, imageRepo, detectionRepo))
-	mux.HandleFunc("/api/pictures/view", handler.ViewPictureHandler(cfg))
-	mux.HandleFunc("/api/pictures/clear", handler.ClearPicturesWithDBHandler(manager, cfg, logger, imageRepo))
-	mux.HandleFunc("/api/pictures/delete", handler.DeletePictureHandler(manager, cfg, logger, imageRepo))
+	mux.HandleFunc("/api/cameras", handler.CamerasHandler(manager, logger))
+	mux.HandleFunc("/api/cameras/status", handler.CameraStatusHandler(manager, logger))
+	mux.HandleFunc("/api/cameras/events", handler.CameraEventsHandler(logger, cameraEventRepo))
+	mux.HandleFunc("/api/motion-zones", handler.MotionZonesHandler(manager, logger))
+	mux.HandleFunc("/api/recordings", handler.RecordingsHandler(logger, recordingRepo))
+	mux.HandleFunc("/api/recordings/stream", handler.RecordingStreamHandler(logger, recordingRepo))
+	mux.HandleFunc("/api/clips/stream", handler.ClipStreamHandler(logger, clipRepo))
+	mux.HandleFunc("/api/stream/stats", handler.StreamStatsHandler(manager, logger))
+	mux.HandleFunc("/api/events", handler.EventsHandler(manager, logger, eventRepo, imageRepo, detectionRepo))
+	mux.HandleFunc("/api/rules", handler.RulesHandler(manager, logger))
+	mux.HandleFunc("/api/rules/triggers", handler.RuleTriggersHandler(manager, logger))
+	mux.HandleFunc("/api/tracks", handler.TracksHandler(manager, logger, trackRepo))
+	mux.HandleFunc("/api/pictures", handler.GetPicturesFromDBHandler(manager, cfg, logger, imageRepo, detectionRepo, clipRepo, variantRepo))
+	mux.HandleFunc("/api/pictures/view", handler.ViewPictureHandler(manager, cfg, logger))
+	mux.HandleFunc("/api/pictures/clear", handler.ClearPicturesWithDBHandler(manager, cfg, logger))
+	mux.HandleFunc("/api/pictures/delete", handler.DeletePictureHandler(manager, cfg, logger))
+	mux.HandleFunc("/api/storage/spool", handler.SpoolStatsHandler(manager, logger))
+	mux.HandleFunc("/api/storage/reconcile", handler.ReconcileHandler(manager, logger, imageRepo))
 
 	// Log endpoints
 	mux.HandleFunc("/logs/info", handler.ShowInfoLogsHandler(cfg))
@@ -0,0 +1,84 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"webserver/internal/model"
+	"webserver/internal/service/render"
+
+	"gocv.io/x/gocv"
+)
+
+// zoneColors are the outline colours of the zone types.
+var zoneColors = map[string]color.RGBA{
+	model.ZoneInclude: {R: 0, G: 200, B: 0, A: 0},
+	model.ZoneExclude: {R: 128, G: 128, B: 128, A: 0},
+	model.ZoneDetect:  {R: 0, G: 128, B: 255, A: 0},
+}
+
+// DrawOverlay draws zones and detection boxes with their captions onto a JPEG frame
+// and returns the re-encoded JPEG buffer.
+func DrawOverlay(frame []byte, overlay render.Overlay) ([]byte, error) {
+	red := color.RGBA{R: 255, G: 0, B: 0, A: 0}
+
+	mat, err := gocv.IMDecode(frame, gocv.IMReadColor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	defer mat.Close()
+	if mat.Empty() {
+		return nil, fmt.Errorf("failed to decode image: empty frame")
+	}
+	width, height := mat.Cols(), mat.Rows()
+
+	for _, zone := range overlay.Zones {
+		if len(zone.Points) == 0 {
+			continue
+		}
+		points := make([]image.Point, len(zone.Points))
+		for i, p := range zone.Points {
+			points[i] = image.Pt(int(p.X*float64(width)), int(p.Y*float64(height)))
+		}
+
+		polygon := gocv.NewPointsVectorFromPoints([][]image.Point{points})
+		err = gocv.Polylines(&mat, polygon, true, zoneColors[zone.Type], 2)
+		polygon.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw zone: %v", err)
+		}
+		err = gocv.PutText(&mat, zone.Name, points[0].Add(image.Pt(4, 16)), gocv.FontHersheySimplex, 0.5,
+			zoneColors[zone.Type], 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw text: %v", err)
+		}
+	}
+
+	for _, box := range overlay.Boxes {
+		rect := image.Rect(box.X, box.Y, box.X+box.Width, box.Y+box.Height)
+		err = gocv.Rectangle(&mat, rect, red, 2)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw rectangle: %v", err)
+		}
+
+		// Captions of boxes touching the top edge go inside the box
+		pt := image.Pt(box.X, box.Y-5)
+		if box.Y < 15 {
+			pt.Y = box.Y + 15
+		}
+		err = gocv.PutText(&mat, box.Caption, pt, gocv.FontHersheySimplex, 0.5, red, 1)
+		if err != nil {
+			return nil, fmt.Errorf("failed to draw text: %v", err)
+		}
+	}
+
+	buf, err := gocv.IMEncode(".jpg", mat)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode image: %v", err)
+	}
+	defer buf.Close()
+	annotated := make([]byte, len(buf.GetBytes()))
+	copy(annotated, buf.GetBytes())
+
+	return annotated, nil
+}
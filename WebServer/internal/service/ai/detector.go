@@ -1,260 +1,186 @@
 package ai
 
 import (
+	"bytes"
 	"fmt"
 	"image"
-	"image/color"
-	"os"
+	_ "image/jpeg"
 	"sync"
 	"webserver/internal/config"
 	"webserver/internal/dto"
 	"webserver/internal/logger"
+	"webserver/internal/service/detection"
+	"webserver/internal/service/motion"
 
 	"gocv.io/x/gocv"
 )
 
-const (
-	// MotionThreshold is the default pixel threshold for motion detection.
-	MotionThreshold = 500
-	// DetectionThreshold is the minimum confidence for object detections.
-	DetectionThreshold = 0.6
-)
-
 // CameraState holds motion detection state for a single camera.
 type CameraState struct {
-	previousMat gocv.Mat
-	hasPrevious bool
-	mutex       sync.Mutex
+	detector MotionDetector
+	luma     float64 // mean brightness of the previous frame
+	hasLuma  bool
+	mutex    sync.Mutex
 }
 
 type DetectorService struct {
 	cameraStates map[string]*CameraState
-	statesMutex  sync.RWMutex
-	net          gocv.Net
-	modelPath    string
-	configPath   string
+	strategies   map[string]string // camera name -> motion strategy from MOTION_DETECTORS
+	statesMutex  sync.RWMutex      // guards cameraStates and strategies
+	zones        *motion.ZoneService
+	illumination *motion.IlluminationCheck
+	config       *config.Config
+	blurSize     int
+	minAreaPct   float64
+	objects      detection.ObjectDetector
+	filter       *detection.Filter
 	logger       *logger.Logger
 }
 
-// NewDetectorService creates a detector with model/config paths and a logger.
-// It attempts to initialize the underlying DNN network.
-func NewDetectorService(config *config.Config, logger *logger.Logger) *DetectorService {
+// NewDetectorService creates a detector with the object detection backend selected
+// by DETECTOR_BACKEND, the shared motion zones and a logger. Motion detection keeps
+// working when the object detector cannot be initialized.
+func NewDetectorService(config *config.Config, logger *logger.Logger, zones *motion.ZoneService) *DetectorService {
 	service := &DetectorService{
 		cameraStates: make(map[string]*CameraState),
-		modelPath:    config.ModelPath,
-		configPath:   config.ConfigPath,
+		strategies:   make(map[string]string),
+		zones:        zones,
+		illumination: motion.NewIlluminationCheck(config),
+		config:       config,
+		blurSize:     config.MotionBlurSize,
+		minAreaPct:   config.MotionMinAreaPct,
 		logger:       logger,
 	}
 
-	if err := service.initializeNet(); err != nil {
-		service.logger.Warning("Could not initialize detection network: %v", err)
-		return service
-	}
-
-	return service
-}
-
-// initializeNet loads the DNN network and sets backend/target preferences.
-func (s *DetectorService) initializeNet() error {
-	if _, err := os.Stat(s.modelPath); os.IsNotExist(err) {
-		return fmt.Errorf("model file not found: %s", s.modelPath)
+	for camera, name := range config.MotionDetectors {
+		service.strategies[camera] = motion.ParseStrategy(name)
 	}
 
-	if _, err := os.Stat(s.configPath); os.IsNotExist(err) {
-		return fmt.Errorf("config file not found: %s", s.configPath)
+	descriptor, err := detection.LoadDescriptor(config)
+	if err != nil {
+		service.logger.Warning("Could not load model descriptor, using defaults: %v", err)
 	}
 
-	net := gocv.ReadNet(s.modelPath, s.configPath)
-
-	if net.Empty() {
-		return fmt.Errorf("failed to load network")
-	}
-	errBackend := net.SetPreferableBackend(gocv.NetBackendDefault)
-	errTarget := net.SetPreferableTarget(gocv.NetTargetCPU)
+	// Backends drop everything below the lowest per-class threshold; the filter applies the rest
+	service.filter = detection.NewFilter(config, descriptor)
+	descriptor.ConfidenceThreshold = service.filter.MinThreshold()
 
-	if errBackend != nil || errTarget != nil {
-		return fmt.Errorf("failed to set preferable backend or target")
+	objects, err := newObjectDetector(config, descriptor)
+	if err != nil {
+		service.logger.Warning("Could not initialize %s object detector: %v", config.DetectorBackend, err)
+		return service
 	}
+	service.objects = objects
+	service.logger.Info("Object detector %s (%s) initialized successfully", descriptor.Name, config.DetectorBackend)
 
-	s.net = net
-	s.logger.Info("Detection network initialized successfully")
-	return nil
+	return service
 }
 
-// DetectMotion computes frame differences to detect movement above MotionThreshold.
-func (s *DetectorService) DetectMotion(imageBytes []byte, cameraID string) (bool, error) {
-	// Convert bytes to Mat
+// DetectMotion runs the camera's motion detector on the frame and reports motion when
+// the changed share of one of the camera's motion zones (or of the whole frame) exceeds
+// its threshold. Frames are blurred before detection and blobs smaller than the
+// configured minimum area are ignored. A frame-wide brightness shift is reported as a
+// scene change instead and becomes the new motion reference.
+func (s *DetectorService) DetectMotion(imageBytes []byte, cameraID string) (motion.Result, error) {
 	state := s.getCameraState(cameraID)
 	state.mutex.Lock()
 	defer state.mutex.Unlock()
 
+	// Convert bytes to Mat
 	mat, err := gocv.IMDecode(imageBytes, gocv.IMReadColor)
 	if err != nil {
-		return false, fmt.Errorf("failed to decode image: %v", err)
+		return motion.Result{}, fmt.Errorf("failed to decode image: %v", err)
 	}
 	defer mat.Close()
 
 	if mat.Empty() {
-		return false, fmt.Errorf("decoded image is empty")
-	}
-
-	if !state.hasPrevious {
-		state.previousMat = mat.Clone()
-		state.hasPrevious = true
-		s.logger.Info("Initialized motion detection for camera: %s", cameraID)
-		return false, nil
+		return motion.Result{}, fmt.Errorf("decoded image is empty")
 	}
 
-	// Calculate difference
-	diff := gocv.NewMat()
-	defer diff.Close()
-	err = gocv.AbsDiff(state.previousMat, mat, &diff)
-	if err != nil {
-		return false, fmt.Errorf("failed to compute absolute difference: %v", err)
-	}
-	// Convert to grayscale
 	gray := gocv.NewMat()
 	defer gray.Close()
-	err = gocv.CvtColor(diff, &gray, gocv.ColorBGRToGray)
-	if err != nil {
-		return false, fmt.Errorf("failed to convert image to grayscale: %v", err)
+	if err := preprocess(mat, &gray, s.blurSize); err != nil {
+		return motion.Result{}, err
 	}
-	// Apply threshold
-	thresh := gocv.NewMat()
-	defer thresh.Close()
-	gocv.Threshold(gray, &thresh, 30, 255, gocv.ThresholdBinary)
 
-	// Count non-zero pixels
-	nonZeroPixels := gocv.CountNonZero(thresh)
+	luma := gray.Mean().Val1
+	previousLuma, hadLuma := state.luma, state.hasLuma
+	state.luma, state.hasLuma = luma, true
 
-	// Update previous frame
-	state.previousMat.Close()
-	if mat.Empty() {
-		return false, fmt.Errorf("decoded image is empty")
-	}
-	state.previousMat = mat.Clone()
-
-	// Motion detected if more than motionThreshold pixels changed
-	motionDetected := nonZeroPixels > MotionThreshold
-
-	if motionDetected {
-		s.logger.Info("Motion detected: %d pixels changed", nonZeroPixels)
+	mask := gocv.NewMat()
+	defer mask.Close()
+	ready, err := state.detector.Apply(gray, &mask)
+	if err != nil {
+		return motion.Result{}, err
 	}
-
-	return motionDetected, nil
-}
-
-// DetectObjects runs the DNN on the image and returns array of DetectionResults that were above the confidence threshold.
-func (s *DetectorService) DetectObjects(imageBytes []byte) ([]dto.DetectionResult, error) {
-	if s.net.Empty() {
-		return []dto.DetectionResult{}, fmt.Errorf("detection network not initialized")
+	if !ready || !hadLuma {
+		return motion.Result{}, nil
 	}
 
-	//Convert image to mat
-	mat, err := gocv.IMDecode(imageBytes, gocv.IMReadColor)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %v", err)
+	// Check for global illumination changes before any filtering
+	pixels := mask.Rows() * mask.Cols()
+	changedPercent := float64(gocv.CountNonZero(mask)) * 100 / float64(pixels)
+	if reason, sceneChange := s.illumination.Check(previousLuma, luma, changedPercent); sceneChange {
+		if err := state.detector.Reset(gray); err != nil {
+			return motion.Result{}, err
+		}
+		return motion.Result{SceneChange: true, Reason: reason, ChangedPercent: changedPercent}, nil
 	}
-	defer mat.Close()
 
-	if mat.Empty() {
-		return nil, fmt.Errorf("decoded image is empty")
+	minArea := s.minAreaPct / 100 * float64(pixels)
+	if err := cleanMask(&mask, minArea); err != nil {
+		return motion.Result{}, err
 	}
-	//Create blob with parameters that fit ssd coco net input
-	blob := gocv.BlobFromImage(mat, 1.0/127.5, image.Pt(300, 300), gocv.NewScalar(127.5, 127.5, 127.5, 0), true, false)
-	defer blob.Close()
 
-	s.net.SetInput(blob, "")
+	// Apply motion zones to the changed pixels
+	result := s.zones.Evaluate(cameraID, mask.ToBytes(), mask.Cols(), mask.Rows())
 
-	output := s.net.Forward("")
-	defer output.Close()
-
-	var results []dto.DetectionResult
-
-	// Process detections with output: [ batch_id, class_id, confidence, x1, y1, x2, y2 ]
-	outputReshaped := output.Reshape(1, output.Total()/7)
-	for i := 0; i < outputReshaped.Rows(); i++ {
-		confidence := outputReshaped.GetFloatAt(i, 2)
-		if confidence > DetectionThreshold {
-			classID := int(outputReshaped.GetFloatAt(i, 1))
-			x := int(outputReshaped.GetFloatAt(i, 3) * float32(mat.Cols()))
-			y := int(outputReshaped.GetFloatAt(i, 4) * float32(mat.Rows()))
-			width := int(outputReshaped.GetFloatAt(i, 5)*float32(mat.Cols())) - x
-			height := int(outputReshaped.GetFloatAt(i, 6)*float32(mat.Rows())) - y
-
-			results = append(results, dto.DetectionResult{
-				Label:      getClassLabel(classID),
-				Confidence: float64(confidence),
-				X:          x,
-				Y:          y,
-				Width:      width,
-				Height:     height,
-			})
-			for _, object := range results {
-				s.logger.Info("Detected %s", object.Label)
-			}
+	if result.Motion {
+		if result.Zone != "" {
+			s.logger.Info("Motion detected: %.2f%% of zone %s changed", result.ChangedPercent, result.Zone)
+		} else {
+			s.logger.Info("Motion detected: %.2f%% of frame changed", result.ChangedPercent)
 		}
 	}
 
-	return results, nil
+	return result, nil
 }
 
-// DrawRectangle draws detection results on the image and returns a re-encoded JPEG buffer.
-func (s *DetectorService) DrawRectangle(detections []dto.DetectionResult, img []byte) ([]byte, error) {
-	red := color.RGBA{R: 255, G: 0, B: 0, A: 0}
+// DetectObjects runs the configured object detector on the image and returns the
+// detections the camera's class allow/deny lists and confidence thresholds keep
+// that lie inside one of its detect zones (if it has any).
+func (s *DetectorService) DetectObjects(imageBytes []byte, cameraID string) ([]dto.DetectionResult, error) {
+	if s.objects == nil {
+		return []dto.DetectionResult{}, fmt.Errorf("object detector not initialized")
+	}
 
-	mat, err := gocv.IMDecode(img, gocv.IMReadColor)
+	results, err := s.objects.Detect(imageBytes)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode image: %v", err)
+		return nil, err
 	}
-	defer mat.Close()
-
-	for _, detection := range detections {
-		rect := image.Rect(detection.X, detection.Y, detection.X+detection.Width, detection.Y+detection.Height)
-		err = gocv.Rectangle(&mat, rect, red, 2)
-		if err != nil {
-			return nil, fmt.Errorf("failed to draw rectangle: %v", err)
-		}
-
-		label := fmt.Sprintf("%s (%.2f)", detection.Label, detection.Confidence)
-		pt := image.Pt(detection.X, detection.Y-5)
-		err = gocv.PutText(&mat, label, pt, gocv.FontHersheySimplex, 0.5, red, 1)
+	results = s.filter.Apply(cameraID, results)
+	if len(results) > 0 {
+		frame, _, err := image.DecodeConfig(bytes.NewReader(imageBytes))
 		if err != nil {
-			return nil, fmt.Errorf("failed to draw text: %v", err)
+			return nil, fmt.Errorf("failed to decode image size: %v", err)
 		}
+		results = s.zones.FilterDetections(cameraID, results, frame.Width, frame.Height)
 	}
-
-	buf, err := gocv.IMEncode(".jpg", mat)
-	if err != nil {
-		s.logger.Error("Failed to encode image: %v", err)
-		return nil, err
+	for _, object := range results {
+		s.logger.Info("Detected %s", object.Label)
 	}
-	defer buf.Close()
-	finalImage := make([]byte, len(buf.GetBytes()))
-	copy(finalImage, buf.GetBytes())
-
-	return finalImage, nil
+	return results, nil
 }
 
-// getClassLabel maps model class IDs to human-readable labels.
-func getClassLabel(classID int) string {
-	labels := map[int]string{
-		1:  "osoba",
-		2:  "rower",
-		3:  "samochod",
-		4:  "motocykl",
-		5:  "samolot",
-		6:  "autobus",
-		8:  "ciezarowka",
-		16: "ptak",
-		17: "kot",
-		18: "pies",
-	}
+// RenameCamera moves the motion detection state, the motion strategy and the detection
+// filters of a renamed camera.
+func (s *DetectorService) RenameCamera(oldName, newName string) {
+	s.statesMutex.Lock()
+	config.RenameCameraKey(s.cameraStates, oldName, newName)
+	config.RenameCameraKey(s.strategies, oldName, newName)
+	s.statesMutex.Unlock()
 
-	if label, exists := labels[classID]; exists {
-		return label
-	}
-	return fmt.Sprintf("nieznany%d", classID)
+	s.filter.RenameCamera(oldName, newName)
 }
 
 // getCameraState returns the per-camera state, creating it when absent.
@@ -274,11 +200,15 @@ func (s *DetectorService) getCameraState(cameraID string) *CameraState {
 		return state
 	}
 
+	strategy, exists := s.strategies[cameraID]
+	if !exists {
+		strategy = motion.ParseStrategy(s.config.MotionDetector)
+	}
 	state = &CameraState{
-		hasPrevious: false,
+		detector: newMotionDetector(strategy),
 	}
 	s.cameraStates[cameraID] = state
-	s.logger.Info("Created motion detection state for camera: %s", cameraID)
+	s.logger.Info("Created %s motion detection state for camera: %s", strategy, cameraID)
 
 	return state
 }
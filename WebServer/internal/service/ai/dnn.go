@@ -0,0 +1,168 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/service/detection"
+
+	"gocv.io/x/gocv"
+)
+
+// newObjectDetector creates the object detection backend selected by DETECTOR_BACKEND.
+func newObjectDetector(config *config.Config, descriptor detection.ModelDescriptor) (detection.ObjectDetector, error) {
+	switch config.DetectorBackend {
+	case detection.BackendOpenCV, "":
+		return newDNNDetector(descriptor)
+	case detection.BackendHTTP:
+		timeout := time.Duration(config.DetectorTimeoutMs) * time.Millisecond
+		return detection.NewHTTPDetector(config.DetectorURL, timeout, descriptor)
+	case detection.BackendFake:
+		return detection.NewFakeDetector(descriptor), nil
+	default:
+		return nil, fmt.Errorf("unknown detector backend %q", config.DetectorBackend)
+	}
+}
+
+// dnnDetector runs a model with the OpenCV DNN module.
+type dnnDetector struct {
+	net        gocv.Net
+	descriptor detection.ModelDescriptor
+}
+
+// newDNNDetector loads the descriptor's network and sets backend/target preferences.
+func newDNNDetector(descriptor detection.ModelDescriptor) (*dnnDetector, error) {
+	if _, err := os.Stat(descriptor.ModelPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("model file not found: %s", descriptor.ModelPath)
+	}
+
+	if descriptor.ConfigPath != "" {
+		if _, err := os.Stat(descriptor.ConfigPath); os.IsNotExist(err) {
+			return nil, fmt.Errorf("config file not found: %s", descriptor.ConfigPath)
+		}
+	}
+
+	net := gocv.ReadNet(descriptor.ModelPath, descriptor.ConfigPath)
+
+	if net.Empty() {
+		return nil, fmt.Errorf("failed to load network")
+	}
+	errBackend := net.SetPreferableBackend(gocv.NetBackendDefault)
+	errTarget := net.SetPreferableTarget(gocv.NetTargetCPU)
+
+	if errBackend != nil || errTarget != nil {
+		net.Close()
+		return nil, fmt.Errorf("failed to set preferable backend or target")
+	}
+
+	return &dnnDetector{net: net, descriptor: descriptor}, nil
+}
+
+// Detect runs the network on the image and parses the output according to the descriptor's layout.
+func (d *dnnDetector) Detect(imageBytes []byte) ([]dto.DetectionResult, error) {
+	//Convert image to mat
+	mat, err := gocv.IMDecode(imageBytes, gocv.IMReadColor)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	defer mat.Close()
+
+	if mat.Empty() {
+		return nil, fmt.Errorf("decoded image is empty")
+	}
+
+	if d.descriptor.IsYOLO() {
+		return d.detectYOLO(mat)
+	}
+
+	//Create blob with the input parameters of the model
+	desc := d.descriptor
+	mean := gocv.NewScalar(desc.Mean[0], desc.Mean[1], desc.Mean[2], 0)
+	blob := gocv.BlobFromImage(mat, desc.Scale, image.Pt(desc.InputWidth, desc.InputHeight), mean, desc.SwapRB, false)
+	defer blob.Close()
+
+	d.net.SetInput(blob, "")
+
+	output := d.net.Forward("")
+	defer output.Close()
+
+	return d.parseSSD(output, mat.Cols(), mat.Rows()), nil
+}
+
+// detectYOLO letterboxes the frame to the model input, runs the network and
+// decodes the output with non-maximum suppression.
+func (d *dnnDetector) detectYOLO(mat gocv.Mat) ([]dto.DetectionResult, error) {
+	desc := d.descriptor
+	letterbox := detection.NewLetterbox(mat.Cols(), mat.Rows(), desc.InputWidth, desc.InputHeight)
+
+	resized := gocv.NewMat()
+	defer resized.Close()
+	if err := gocv.Resize(mat, &resized, image.Pt(letterbox.ResizedWidth, letterbox.ResizedHeight), 0, 0, gocv.InterpolationLinear); err != nil {
+		return nil, fmt.Errorf("failed to resize image: %v", err)
+	}
+
+	padded := gocv.NewMat()
+	defer padded.Close()
+	grey := color.RGBA{R: detection.LetterboxColor, G: detection.LetterboxColor, B: detection.LetterboxColor, A: 0}
+	if err := gocv.CopyMakeBorder(resized, &padded, letterbox.Top, letterbox.Bottom, letterbox.Left, letterbox.Right,
+		gocv.BorderConstant, grey); err != nil {
+		return nil, fmt.Errorf("failed to pad image: %v", err)
+	}
+
+	mean := gocv.NewScalar(desc.Mean[0], desc.Mean[1], desc.Mean[2], 0)
+	blob := gocv.BlobFromImage(padded, desc.Scale, image.Pt(desc.InputWidth, desc.InputHeight), mean, desc.SwapRB, false)
+	defer blob.Close()
+
+	d.net.SetInput(blob, "")
+
+	output := d.net.Forward("")
+	defer output.Close()
+
+	data, err := output.DataPtrFloat32()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read network output: %v", err)
+	}
+	return detection.DecodeYOLO(desc, output.Size(), data, letterbox)
+}
+
+// parseSSD reads detections with output: [ batch_id, class_id, confidence, x1, y1, x2, y2 ].
+func (d *dnnDetector) parseSSD(output gocv.Mat, cols, rows int) []dto.DetectionResult {
+	var results []dto.DetectionResult
+
+	outputReshaped := output.Reshape(1, output.Total()/7)
+	defer outputReshaped.Close()
+	for i := 0; i < outputReshaped.Rows(); i++ {
+		confidence := outputReshaped.GetFloatAt(i, 2)
+		if float64(confidence) <= d.descriptor.ConfidenceThreshold {
+			continue
+		}
+		label, known := d.descriptor.Label(int(outputReshaped.GetFloatAt(i, 1)))
+		if !known {
+			continue
+		}
+		x := int(outputReshaped.GetFloatAt(i, 3) * float32(cols))
+		y := int(outputReshaped.GetFloatAt(i, 4) * float32(rows))
+		width := int(outputReshaped.GetFloatAt(i, 5)*float32(cols)) - x
+		height := int(outputReshaped.GetFloatAt(i, 6)*float32(rows)) - y
+
+		results = append(results, dto.DetectionResult{
+			Label:      label,
+			Confidence: float64(confidence),
+			X:          x,
+			Y:          y,
+			Width:      width,
+			Height:     height,
+		})
+	}
+
+	return results
+}
+
+// Close releases the network.
+func (d *dnnDetector) Close() error {
+	return d.net.Close()
+}
@@ -0,0 +1,224 @@
+package ai
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"webserver/internal/service/motion"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// DiffThreshold is the grey-level change above which a pixel counts as changed.
+	DiffThreshold = 30
+	// AverageLearningRate is the weight of a new frame in the running-average background.
+	AverageLearningRate = 0.05
+	// MOG2History is the number of frames the MOG2 background model learns from.
+	MOG2History = 500
+	// MOG2VarThreshold is the squared Mahalanobis distance for MOG2 foreground pixels.
+	MOG2VarThreshold = 16
+	// mog2ShadowValue is the mask value MOG2 uses for shadows, which are not motion.
+	mog2ShadowValue = 127
+)
+
+// MotionDetector turns consecutive greyscale frames of one camera into a binary
+// mask (CV_8U, 255 for changed pixels).
+type MotionDetector interface {
+	// Apply updates the model with the frame and writes the foreground mask to mask.
+	// It returns false while the detector has no reference for the scene yet.
+	Apply(gray gocv.Mat, mask *gocv.Mat) (bool, error)
+	// Reset makes the frame the new reference, discarding what was learned so far.
+	Reset(gray gocv.Mat) error
+	// Close releases the detector's native resources.
+	Close()
+}
+
+// newMotionDetector creates the detector for a motion.Strategy* name.
+func newMotionDetector(strategy string) MotionDetector {
+	switch strategy {
+	case motion.StrategyAverage:
+		return &averageDetector{}
+	case motion.StrategyMOG2:
+		return &mog2Detector{subtractor: gocv.NewBackgroundSubtractorMOG2WithParams(MOG2History, MOG2VarThreshold, true)}
+	default:
+		return &diffDetector{}
+	}
+}
+
+// diffDetector compares every frame with the previous one.
+type diffDetector struct {
+	previous    gocv.Mat
+	hasPrevious bool
+}
+
+func (d *diffDetector) Apply(gray gocv.Mat, mask *gocv.Mat) (bool, error) {
+	if !d.hasPrevious || d.previous.Rows() != gray.Rows() || d.previous.Cols() != gray.Cols() {
+		return false, d.Reset(gray)
+	}
+
+	diff := gocv.NewMat()
+	defer diff.Close()
+	if err := gocv.AbsDiff(d.previous, gray, &diff); err != nil {
+		return false, fmt.Errorf("failed to compute absolute difference: %v", err)
+	}
+	gocv.Threshold(diff, mask, DiffThreshold, 255, gocv.ThresholdBinary)
+
+	d.previous.Close()
+	d.previous = gray.Clone()
+	return true, nil
+}
+
+func (d *diffDetector) Reset(gray gocv.Mat) error {
+	d.Close()
+	d.previous = gray.Clone()
+	d.hasPrevious = true
+	return nil
+}
+
+func (d *diffDetector) Close() {
+	if d.hasPrevious {
+		d.previous.Close()
+		d.hasPrevious = false
+	}
+}
+
+// averageDetector compares every frame with an exponentially weighted running
+// average, so slow changes (clouds, dusk) are absorbed into the background.
+type averageDetector struct {
+	background    gocv.Mat // CV_32F
+	hasBackground bool
+}
+
+func (d *averageDetector) Apply(gray gocv.Mat, mask *gocv.Mat) (bool, error) {
+	if !d.hasBackground || d.background.Rows() != gray.Rows() || d.background.Cols() != gray.Cols() {
+		return false, d.Reset(gray)
+	}
+
+	reference := gocv.NewMat()
+	defer reference.Close()
+	if err := gocv.ConvertScaleAbs(d.background, &reference, 1, 0); err != nil {
+		return false, fmt.Errorf("failed to convert background: %v", err)
+	}
+
+	diff := gocv.NewMat()
+	defer diff.Close()
+	if err := gocv.AbsDiff(reference, gray, &diff); err != nil {
+		return false, fmt.Errorf("failed to compute absolute difference: %v", err)
+	}
+	gocv.Threshold(diff, mask, DiffThreshold, 255, gocv.ThresholdBinary)
+
+	if err := gocv.AccumulatedWeighted(gray, &d.background, AverageLearningRate); err != nil {
+		return false, fmt.Errorf("failed to update background: %v", err)
+	}
+	return true, nil
+}
+
+func (d *averageDetector) Reset(gray gocv.Mat) error {
+	d.Close()
+	d.background = gocv.NewMat()
+	if err := gray.ConvertTo(&d.background, gocv.MatTypeCV32F); err != nil {
+		d.background.Close()
+		return fmt.Errorf("failed to reset background: %v", err)
+	}
+	d.hasBackground = true
+	return nil
+}
+
+func (d *averageDetector) Close() {
+	if d.hasBackground {
+		d.background.Close()
+		d.hasBackground = false
+	}
+}
+
+// mog2Detector uses OpenCV's Gaussian mixture background subtractor, which adapts
+// to repetitive movement such as leaves and handles sensor noise per pixel.
+type mog2Detector struct {
+	subtractor gocv.BackgroundSubtractorMOG2
+	frames     int
+}
+
+func (d *mog2Detector) Apply(gray gocv.Mat, mask *gocv.Mat) (bool, error) {
+	foreground := gocv.NewMat()
+	defer foreground.Close()
+	if err := d.subtractor.Apply(gray, &foreground); err != nil {
+		return false, fmt.Errorf("failed to apply background subtractor: %v", err)
+	}
+	// Drop shadows, keep definite foreground only
+	gocv.Threshold(foreground, mask, mog2ShadowValue, 255, gocv.ThresholdBinary)
+
+	d.frames++
+	return d.frames > 1, nil
+}
+
+// Reset relearns the background from the frame alone (learning rate 1).
+func (d *mog2Detector) Reset(gray gocv.Mat) error {
+	foreground := gocv.NewMat()
+	defer foreground.Close()
+	if err := d.subtractor.ApplyWithLearningRate(gray, &foreground, 1); err != nil {
+		return fmt.Errorf("failed to reset background subtractor: %v", err)
+	}
+	d.frames = 1
+	return nil
+}
+
+func (d *mog2Detector) Close() {
+	d.subtractor.Close()
+}
+
+// preprocess converts a decoded frame to greyscale and blurs it to suppress sensor noise.
+func preprocess(frame gocv.Mat, gray *gocv.Mat, blurSize int) error {
+	if err := gocv.CvtColor(frame, gray, gocv.ColorBGRToGray); err != nil {
+		return fmt.Errorf("failed to convert image to grayscale: %v", err)
+	}
+	if blurSize <= 1 {
+		return nil
+	}
+	if blurSize%2 == 0 {
+		blurSize++ // Gaussian kernels must be odd
+	}
+	if err := gocv.GaussianBlur(*gray, gray, image.Pt(blurSize, blurSize), 0, 0, gocv.BorderDefault); err != nil {
+		return fmt.Errorf("failed to blur image: %v", err)
+	}
+	return nil
+}
+
+// cleanMask removes speckle noise with a morphological opening, closes small gaps,
+// and drops connected blobs smaller than minArea pixels.
+func cleanMask(mask *gocv.Mat, minArea float64) error {
+	kernel := gocv.GetStructuringElement(gocv.MorphEllipse, image.Pt(3, 3))
+	defer kernel.Close()
+
+	if err := gocv.MorphologyEx(*mask, mask, gocv.MorphOpen, kernel); err != nil {
+		return fmt.Errorf("failed to open mask: %v", err)
+	}
+	if err := gocv.MorphologyEx(*mask, mask, gocv.MorphClose, kernel); err != nil {
+		return fmt.Errorf("failed to close mask: %v", err)
+	}
+
+	if minArea <= 0 {
+		return nil
+	}
+
+	contours := gocv.FindContours(*mask, gocv.RetrievalExternal, gocv.ChainApproxSimple)
+	defer contours.Close()
+
+	kept := gocv.Zeros(mask.Rows(), mask.Cols(), gocv.MatTypeCV8U)
+	defer kept.Close()
+	white := color.RGBA{R: 255, G: 255, B: 255, A: 0}
+	for i := 0; i < contours.Size(); i++ {
+		if gocv.ContourArea(contours.At(i)) < minArea {
+			continue
+		}
+		if err := gocv.DrawContours(&kept, contours, i, white, -1); err != nil {
+			return fmt.Errorf("failed to draw contour: %v", err)
+		}
+	}
+
+	// Keep only the changed pixels of large blobs
+	if err := gocv.BitwiseAnd(*mask, kept, mask); err != nil {
+		return fmt.Errorf("failed to filter mask: %v", err)
+	}
+	return nil
+}
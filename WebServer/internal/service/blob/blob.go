@@ -0,0 +1,160 @@
+package blob
+
+import (
+	"fmt"
+	"io/fs"
+	"sort"
+	"time"
+	"webserver/internal/config"
+)
+
+const (
+	// BackendLocal stores blobs as files below a directory.
+	BackendLocal = "local"
+	// BackendS3 stores blobs as objects in an S3-compatible bucket (AWS S3, MinIO, NAS gateways).
+	BackendS3 = "s3"
+)
+
+// ErrInvalidKey is returned for keys that would leave the store, such as "../x".
+// It matches fs.ErrNotExist, so such keys are treated like missing blobs.
+var ErrInvalidKey = fmt.Errorf("invalid blob key: %w", fs.ErrNotExist)
+
+// Info describes a stored blob.
+type Info struct {
+	Size    int64
+	ModTime time.Time
+}
+
+// BlobStore stores binary objects under slash-separated keys such as "thumb/a.jpg".
+// Get returns an error matching fs.ErrNotExist for missing keys; Delete ignores them.
+type BlobStore interface {
+	Backend() string
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, Info, error)
+	Delete(key string) error
+	// Location returns where the blob lives, recorded as the image's file path.
+	Location(key string) string
+	// Key returns the key of a location returned by Location, false for locations
+	// outside the store.
+	Key(location string) (string, bool)
+}
+
+// Mover is implemented by stores that can move a blob without copying its data.
+type Mover interface {
+	Move(from, to string) error
+}
+
+// Lister is implemented by stores that can enumerate their blobs.
+type Lister interface {
+	// List calls fn for every blob whose key starts with prefix, stopping at the
+	// first error returned by fn.
+	List(prefix string, fn func(key string, info Info) error) error
+}
+
+// Move moves a blob to another key of the same store, copying and deleting it when
+// the store cannot move blobs itself.
+func Move(store BlobStore, from, to string) error {
+	if mover, ok := store.(Mover); ok {
+		return mover.Move(from, to)
+	}
+
+	data, _, err := store.Get(from)
+	if err != nil {
+		return err
+	}
+	if err := store.Put(to, data); err != nil {
+		return err
+	}
+	return store.Delete(from)
+}
+
+// Stores holds the configured blob stores by backend. New images go to the default
+// store; existing images are read from the store recorded for them, so backends can
+// be mixed after switching.
+type Stores struct {
+	stores   map[string]BlobStore
+	fallback BlobStore
+}
+
+// NewStores creates Stores from the given stores; the first one is the default.
+func NewStores(fallback BlobStore, others ...BlobStore) *Stores {
+	stores := &Stores{stores: map[string]BlobStore{fallback.Backend(): fallback}, fallback: fallback}
+	for _, store := range others {
+		stores.stores[store.Backend()] = store
+	}
+	return stores
+}
+
+// Open creates the stores selected by STORAGE_BACKEND. The local store in IMAGE_DIR
+// is always available for images stored before switching; the S3 store is added when
+// S3_BUCKET is set.
+func Open(config *config.Config) (*Stores, error) {
+	local := NewLocalStore(config.ImageDirectory)
+	var s3 BlobStore
+	if config.S3Bucket != "" {
+		store, err := NewS3Store(S3Config{
+			Endpoint:  config.S3Endpoint,
+			Bucket:    config.S3Bucket,
+			Region:    config.S3Region,
+			AccessKey: config.S3AccessKey,
+			SecretKey: config.S3SecretKey,
+			Prefix:    config.S3Prefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		s3 = store
+	}
+
+	switch config.StorageBackend {
+	case "", BackendLocal:
+		if s3 == nil {
+			return NewStores(local), nil
+		}
+		return NewStores(local, s3), nil
+	case BackendS3:
+		if s3 == nil {
+			return nil, fmt.Errorf("storage backend %q needs S3_BUCKET", BackendS3)
+		}
+		return NewStores(s3, local), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expected %q or %q", config.StorageBackend, BackendLocal, BackendS3)
+	}
+}
+
+// Default returns the store new images are written to.
+func (s *Stores) Default() BlobStore {
+	return s.fallback
+}
+
+// All returns the configured stores, the default store first.
+func (s *Stores) All() []BlobStore {
+	all := []BlobStore{s.fallback}
+	backends := make([]string, 0, len(s.stores))
+	for backend := range s.stores {
+		if backend != s.fallback.Backend() {
+			backends = append(backends, backend)
+		}
+	}
+	sort.Strings(backends)
+	for _, backend := range backends {
+		all = append(all, s.stores[backend])
+	}
+	return all
+}
+
+// Get returns the store of a backend; an empty backend means the local store of
+// images recorded before backends were tracked.
+func (s *Stores) Get(backend string) (BlobStore, bool) {
+	if backend == "" {
+		backend = BackendLocal
+	}
+	store, exists := s.stores[backend]
+	return store, exists
+}
+
+// Local returns the local store when one is configured.
+func (s *Stores) Local() (*LocalStore, bool) {
+	store, ok := s.stores[BackendLocal].(*LocalStore)
+	return store, ok
+}
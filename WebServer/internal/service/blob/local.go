@@ -0,0 +1,161 @@
+package blob
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore keeps blobs as files below a directory.
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a LocalStore rooted at dir. Directories are created on demand.
+func NewLocalStore(dir string) *LocalStore {
+	return &LocalStore{dir: dir}
+}
+
+// Backend returns BackendLocal.
+func (s *LocalStore) Backend() string {
+	return BackendLocal
+}
+
+// Put writes a blob, creating its parent directories.
+func (s *LocalStore) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// Get reads a blob.
+func (s *LocalStore) Get(key string) ([]byte, Info, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, Info{}, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	if info.IsDir() {
+		return nil, Info{}, ErrInvalidKey
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	return data, Info{Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Delete removes a blob; missing blobs are not an error.
+func (s *LocalStore) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Location returns the file path of a blob.
+func (s *LocalStore) Location(key string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(key))
+}
+
+// Key returns the key of a file path below the store's directory.
+func (s *LocalStore) Key(location string) (string, bool) {
+	relative, err := filepath.Rel(s.dir, location)
+	if err != nil || !filepath.IsLocal(relative) {
+		return "", false
+	}
+	return filepath.ToSlash(relative), true
+}
+
+// Move renames a blob, creating the parent directories of the new key.
+func (s *LocalStore) Move(from, to string) error {
+	source, err := s.path(from)
+	if err != nil {
+		return err
+	}
+	target, err := s.path(to)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	return os.Rename(source, target)
+}
+
+// List walks the files below the store's directory whose key starts with prefix.
+func (s *LocalStore) List(prefix string, fn func(key string, info Info) error) error {
+	err := filepath.WalkDir(s.dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			// Files deleted during the walk are skipped
+			if os.IsNotExist(err) && path != s.dir {
+				return nil
+			}
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		relative, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relative)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(key, Info{Size: info.Size(), ModTime: info.ModTime()})
+	})
+	// A store nothing was written to yet has no directory
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// Clear removes every file and directory below the store's directory.
+func (s *LocalStore) Clear() error {
+	entries, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := os.RemoveAll(filepath.Join(s.dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// path returns the file path of a key, rejecting keys outside the directory.
+func (s *LocalStore) path(key string) (string, error) {
+	relative := filepath.FromSlash(key)
+	if !filepath.IsLocal(relative) {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, relative), nil
+}
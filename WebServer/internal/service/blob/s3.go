@@ -0,0 +1,338 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultS3Region is used for signing when S3_REGION is empty; MinIO accepts it by default.
+	DefaultS3Region = "us-east-1"
+	// S3Timeout bounds each request to the S3 endpoint.
+	S3Timeout = 30 * time.Second
+)
+
+// S3Config describes an S3-compatible bucket. Objects are stored under Prefix.
+type S3Config struct {
+	Endpoint  string // e.g. http://nas.local:9000
+	Bucket    string
+	Region    string
+	AccessKey string
+	SecretKey string
+	Prefix    string
+}
+
+// S3Store keeps blobs as objects in an S3-compatible bucket, addressed path-style
+// (endpoint/bucket/key) and signed with AWS Signature Version 4.
+type S3Store struct {
+	endpoint *url.URL
+	bucket   string
+	region   string
+	access   string
+	secret   string
+	prefix   string
+	client   *http.Client
+}
+
+// NewS3Store creates an S3Store for the bucket.
+func NewS3Store(config S3Config) (*S3Store, error) {
+	endpoint, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", config.Endpoint)
+	}
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3 bucket is required")
+	}
+
+	region := config.Region
+	if region == "" {
+		region = DefaultS3Region
+	}
+	prefix := strings.Trim(config.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	return &S3Store{
+		endpoint: endpoint,
+		bucket:   config.Bucket,
+		region:   region,
+		access:   config.AccessKey,
+		secret:   config.SecretKey,
+		prefix:   prefix,
+		client:   &http.Client{Timeout: S3Timeout},
+	}, nil
+}
+
+// Backend returns BackendS3.
+func (s *S3Store) Backend() string {
+	return BackendS3
+}
+
+// Put uploads a blob.
+func (s *S3Store) Put(key string, data []byte) error {
+	resp, err := s.do(http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return s.statusError(http.MethodPut, key, resp)
+	}
+	return nil
+}
+
+// Get downloads a blob.
+func (s *S3Store) Get(key string) ([]byte, Info, error) {
+	resp, err := s.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, Info{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, Info{}, fmt.Errorf("s3 object %s: %w", key, fs.ErrNotExist)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, Info{}, s.statusError(http.MethodGet, key, resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, Info{}, fmt.Errorf("failed to read s3 object %s: %w", key, err)
+	}
+	info := Info{Size: int64(len(data))}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modified
+	}
+	return data, info, nil
+}
+
+// Delete removes a blob; missing blobs are not an error.
+func (s *S3Store) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK &&
+		resp.StatusCode != http.StatusNotFound {
+		return s.statusError(http.MethodDelete, key, resp)
+	}
+	return nil
+}
+
+// Location returns the s3:// URL of a blob.
+func (s *S3Store) Location(key string) string {
+	return "s3://" + s.bucket + "/" + s.prefix + key
+}
+
+// Key returns the key of an s3:// URL in the store's bucket and prefix.
+func (s *S3Store) Key(location string) (string, bool) {
+	key, found := strings.CutPrefix(location, s.Location(""))
+	if !found || !isLocalKey(key) {
+		return "", false
+	}
+	return key, true
+}
+
+// List pages through the objects whose key starts with prefix, using ListObjectsV2.
+func (s *S3Store) List(prefix string, fn func(key string, info Info) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix + prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := s.send(http.MethodGet, "/"+s.bucket, query, nil)
+		if err != nil {
+			return err
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := s.statusError(http.MethodGet, "listing of "+s.bucket, resp)
+			resp.Body.Close()
+			return err
+		}
+
+		var page listPage
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("failed to decode s3 listing: %w", err)
+		}
+
+		for _, object := range page.Contents {
+			key := strings.TrimPrefix(object.Key, s.prefix)
+			if !isLocalKey(key) {
+				continue
+			}
+			if err := fn(key, Info{Size: object.Size, ModTime: object.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// listPage is the part of a ListObjectsV2 response used by List.
+type listPage struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// do sends a signed request for the object of a key.
+func (s *S3Store) do(method, key string, body []byte) (*http.Response, error) {
+	if !isLocalKey(key) {
+		return nil, ErrInvalidKey
+	}
+	return s.send(method, "/"+s.bucket+"/"+s.prefix+key, nil, body)
+}
+
+// send sends a signed request for a path of the endpoint with the query parameters.
+func (s *S3Store) send(method, objectPath string, query url.Values, body []byte) (*http.Response, error) {
+	target := *s.endpoint
+	target.Path = s.endpoint.Path + objectPath
+	target.RawPath = s.endpoint.EscapedPath() + escape(objectPath, true)
+	target.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create s3 request: %w", err)
+	}
+	if method == http.MethodPut {
+		req.ContentLength = int64(len(body))
+		if contentType := mime.TypeByExtension(path.Ext(objectPath)); contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	s.sign(req, body)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 %s %s failed: %w", method, objectPath, err)
+	}
+	return resp, nil
+}
+
+// sign adds the AWS Signature Version 4 headers to a request.
+func (s *S3Store) sign(req *http.Request, body []byte) {
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	payload := sha256.Sum256(body)
+	payloadHash := hex.EncodeToString(payload[:])
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		"host:" + req.URL.Host,
+		"x-amz-content-sha256:" + payloadHash,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+
+	scope := date + "/" + s.region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secret), date)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.access, scope, signedHeaders, signature))
+}
+
+// statusError describes an unexpected response, including the start of its body.
+func (s *S3Store) statusError(method, key string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	return fmt.Errorf("s3 %s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with the key.
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalQuery returns the query parameters sorted by name and URI-encoded, as
+// Signature Version 4 expects.
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, escape(name, false)+"="+escape(value, false))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// escape URI-encodes every byte of s except unreserved characters and, when slash is
+// set, slashes, as Signature Version 4 expects.
+func escape(s string, slash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (slash && c == '/') {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// isLocalKey reports whether a key stays inside the bucket prefix.
+func isLocalKey(key string) bool {
+	if key == "" || strings.HasPrefix(key, "/") {
+		return false
+	}
+	for _, part := range strings.Split(key, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,396 @@
+package clip
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service/recording"
+)
+
+const (
+	// MaxFrameRate is the highest expected camera frame rate, used to size pre-roll buffers.
+	MaxFrameRate = 25
+	// CheckInterval defines how often clips are checked for reaching the end of post-roll.
+	CheckInterval = time.Second
+	// PruneInterval defines how often clips beyond the retention limits are deleted.
+	PruneInterval = 10 * time.Minute
+	// pruneBatch is the number of clips loaded at once when deleting.
+	pruneBatch = 100
+	// bytesPerGB converts the configured quota to bytes.
+	bytesPerGB = 1 << 30
+)
+
+// activeClip is a clip still collecting post-roll frames.
+type activeClip struct {
+	clip      model.Clip
+	writer    *recording.AVIWriter
+	lastFrame time.Time
+	until     time.Time
+}
+
+// ClipService records short MJPEG AVI clips around detections: frames from the
+// pre-roll buffer plus frames arriving until the post-roll ends. Detections on a
+// camera that already has an open clip extend it instead of starting a new one.
+// Clips have their own limits, CLIP_RETENTION_DAYS and CLIP_QUOTA_GB, apart from
+// the quotas of stored images.
+type ClipService struct {
+	clipsDir    string
+	preRoll     time.Duration
+	postRoll    time.Duration
+	maxDuration time.Duration
+	maxAge      time.Duration // 0 keeps clips forever
+	quota       int64         // bytes, 0 for no limit
+	active      map[string]*activeClip
+	mu          sync.Mutex
+	clipRepo    repository.ClipRepository
+	logger      *logger.Logger
+}
+
+// NewClipService creates a ClipService using the configured pre-roll, post-roll and
+// maximum clip length, and finalizes clips left open by a previous run.
+func NewClipService(config *config.Config, logger *logger.Logger, clipRepo repository.ClipRepository) *ClipService {
+	service := &ClipService{
+		clipsDir:    config.ClipDirectory,
+		preRoll:     time.Duration(config.ClipPreRollS) * time.Second,
+		postRoll:    time.Duration(config.ClipPostRollS) * time.Second,
+		maxDuration: time.Duration(config.ClipMaxDurationS) * time.Second,
+		maxAge:      time.Duration(max(config.ClipMaxDays, 0)) * 24 * time.Hour,
+		quota:       int64(config.ClipQuotaGB * bytesPerGB),
+		active:      make(map[string]*activeClip),
+		clipRepo:    clipRepo,
+		logger:      logger,
+	}
+	if service.maxDuration < service.preRoll+service.postRoll {
+		service.maxDuration = service.preRoll + service.postRoll
+	}
+
+	service.recoverClips()
+	return service
+}
+
+// Run periodically finalizes clips whose post-roll has ended and deletes clips beyond
+// the retention limits.
+func (s *ClipService) Run() {
+	s.Prune(time.Now())
+
+	ticker := time.NewTicker(CheckInterval)
+	pruneTicker := time.NewTicker(PruneInterval)
+
+	defer ticker.Stop()
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.FinishExpired(time.Now())
+		case <-pruneTicker.C:
+			s.Prune(time.Now())
+		}
+	}
+}
+
+// IsEnabled reports whether clips are recorded. Clips need the database to be linked to images.
+func (s *ClipService) IsEnabled() bool {
+	return s.clipRepo != nil && s.preRoll+s.postRoll > 0
+}
+
+// PreRoll returns how long before a detection frames are included in a clip.
+func (s *ClipService) PreRoll() time.Duration {
+	return s.preRoll
+}
+
+// BufferCapacity returns how many frames a camera's pre-roll buffer must hold.
+func (s *ClipService) BufferCapacity() int {
+	return int(s.preRoll.Seconds()*MaxFrameRate) + 1
+}
+
+// Trigger starts a clip for a detection on the camera, writing the pre-roll frames
+// and linking the stored image. If a clip is already open, its post-roll is extended
+// (up to the maximum clip length) and the image is linked to it.
+func (s *ClipService) Trigger(camera, imageFilename string, preRoll []Frame) {
+	if !s.IsEnabled() {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	active := s.active[camera]
+	if active == nil {
+		var err error
+		if active, err = s.start(camera, now); err != nil {
+			s.logger.Error("Error starting clip for camera %s: %v", camera, err)
+			return
+		}
+		for _, frame := range preRoll {
+			s.writeFrame(active, frame)
+		}
+	}
+
+	active.until = now.Add(s.postRoll)
+	if limit := active.clip.StartTime.Add(s.maxDuration); active.until.After(limit) {
+		active.until = limit
+	}
+
+	if err := s.clipRepo.AddImage(active.clip.ID, imageFilename); err != nil {
+		s.logger.Error("Error linking image %s to clip: %v", imageFilename, err)
+	}
+}
+
+// AddFrame appends a frame to the camera's open clip, if any.
+func (s *ClipService) AddFrame(camera string, data []byte, timestamp time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := s.active[camera]
+	if active == nil {
+		return
+	}
+	if timestamp.After(active.until) {
+		s.finalize(active)
+		return
+	}
+	s.writeFrame(active, Frame{Data: data, Timestamp: timestamp})
+}
+
+// FinishExpired finalizes clips whose post-roll ended before now.
+func (s *ClipService) FinishExpired(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, active := range s.active {
+		if now.After(active.until) {
+			s.finalize(active)
+		}
+	}
+}
+
+// RenameCamera moves the open clip of a renamed camera, so frames arriving under the new
+// name continue it. The database rows are renamed together with the camera.
+func (s *ClipService) RenameCamera(oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	active := s.active[oldName]
+	if active == nil {
+		return
+	}
+	// A clip started for a detection under the new name is kept and the old one closed
+	if s.active[newName] != nil {
+		s.finalize(active)
+		return
+	}
+	delete(s.active, oldName)
+	active.clip.Camera = newName
+	s.active[newName] = active
+}
+
+// Stop finalizes all open clips.
+func (s *ClipService) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, active := range s.active {
+		s.finalize(active)
+	}
+}
+
+// Prune deletes finished clips older than the maximum age, then the oldest ones while
+// the clips exceed the quota, and returns how many were deleted. Images the clips were
+// recorded for are kept.
+func (s *ClipService) Prune(now time.Time) int {
+	if s.clipRepo == nil {
+		return 0
+	}
+
+	removed := 0
+	if s.maxAge > 0 {
+		reason := fmt.Sprintf("older than %g days", s.maxAge.Hours()/24)
+		removed += s.prune(now.Add(-s.maxAge), -1, reason)
+	}
+
+	if s.quota > 0 {
+		used, err := s.clipRepo.GetTotalSize()
+		if err != nil {
+			s.logger.Error("Failed to get clip usage: %v", err)
+			return removed
+		}
+		if used > s.quota {
+			reason := fmt.Sprintf("over the quota of %.2f GB", float64(s.quota)/bytesPerGB)
+			removed += s.prune(time.Time{}, used-s.quota, reason)
+		}
+	}
+	return removed
+}
+
+// prune deletes finished clips that ended before the given time, oldest first, until
+// excess bytes were freed, or every such clip when excess is negative.
+func (s *ClipService) prune(before time.Time, excess int64, reason string) int {
+	count, freed := 0, int64(0)
+
+	for excess < 0 || freed < excess {
+		clips, err := s.clipRepo.GetOldest(before, pruneBatch)
+		if err != nil {
+			s.logger.Error("Failed to list clips for retention: %v", err)
+			break
+		}
+
+		deleted := false
+		for _, clip := range clips {
+			if excess >= 0 && freed >= excess {
+				break
+			}
+			if err := os.Remove(clip.FilePath); err != nil && !os.IsNotExist(err) {
+				s.logger.Error("Failed to delete clip %s: %v", clip.Filename, err)
+				continue
+			}
+			if err := s.clipRepo.Delete(clip.ID); err != nil {
+				s.logger.Error("Failed to delete clip %s from database: %v", clip.Filename, err)
+				continue
+			}
+			deleted = true
+			count++
+			freed += clip.FileSize
+		}
+		if !deleted {
+			break
+		}
+	}
+
+	if count > 0 {
+		s.logger.Info("🧹 Removed %d event clip(s), %.1f MB: %s", count, float64(freed)/(1<<20), reason)
+	}
+	return count
+}
+
+// start registers a new clip for the camera. The file is created with the first frame,
+// when the frame size is known. Caller must hold s.mu.
+func (s *ClipService) start(camera string, now time.Time) (*activeClip, error) {
+	dir := filepath.Join(s.clipsDir, camera)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create clip directory: %w", err)
+	}
+
+	filename := fmt.Sprintf("%s_%s.avi", camera, now.Format("2006-01-02_15-04-05.000"))
+	active := &activeClip{
+		clip: model.Clip{
+			Camera:    camera,
+			Filename:  filename,
+			FilePath:  filepath.Join(dir, filename),
+			StartTime: now,
+			EndTime:   now,
+		},
+	}
+
+	id, err := s.clipRepo.Insert(&active.clip)
+	if err != nil {
+		return nil, err
+	}
+	active.clip.ID = id
+
+	s.active[camera] = active
+	s.logger.Info("🎬 Camera %s: event clip %s started", camera, filename)
+	return active, nil
+}
+
+// writeFrame appends a frame to the clip, creating the file on the first frame.
+// Frames older than the last written one or with a different size are skipped.
+// Caller must hold s.mu.
+func (s *ClipService) writeFrame(active *activeClip, frame Frame) {
+	if active.writer != nil && !frame.Timestamp.After(active.lastFrame) {
+		return
+	}
+
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(frame.Data))
+	if err != nil {
+		return
+	}
+
+	if active.writer == nil {
+		writer, err := recording.CreateAVI(active.clip.FilePath, cfg.Width, cfg.Height)
+		if err != nil {
+			s.logger.Error("Error creating clip %s: %v", active.clip.Filename, err)
+			return
+		}
+		active.writer = writer
+		active.clip.StartTime = frame.Timestamp
+	} else if cfg.Width != active.writer.Width() || cfg.Height != active.writer.Height() {
+		return
+	}
+
+	if err := active.writer.WriteFrame(frame.Data); err != nil {
+		s.logger.Error("Error writing clip %s: %v", active.clip.Filename, err)
+		return
+	}
+	active.lastFrame = frame.Timestamp
+}
+
+// finalize closes the clip file and stores its final state. Caller must hold s.mu.
+func (s *ClipService) finalize(active *activeClip) {
+	delete(s.active, active.clip.Camera)
+
+	clip := &active.clip
+	clip.Complete = true
+	if active.writer != nil {
+		if err := active.writer.Close(active.lastFrame.Sub(clip.StartTime)); err != nil {
+			s.logger.Error("Error closing clip %s: %v", clip.Filename, err)
+		}
+		clip.EndTime = active.lastFrame
+		clip.Frames = active.writer.Frames()
+		clip.FileSize = active.writer.Size()
+	}
+
+	if err := s.clipRepo.Update(clip); err != nil {
+		s.logger.Error("Error updating clip %s in database: %v", clip.Filename, err)
+	}
+	s.logger.Info("🎬 Camera %s: event clip %s saved (%d frames)", clip.Camera, clip.Filename, clip.Frames)
+}
+
+// recoverClips finalizes clips that were still open when the server stopped.
+func (s *ClipService) recoverClips() {
+	if s.clipRepo == nil {
+		return
+	}
+
+	clips, err := s.clipRepo.GetIncomplete()
+	if err != nil {
+		s.logger.Error("Error loading unfinished clips: %v", err)
+		return
+	}
+
+	for i := range clips {
+		clip := &clips[i]
+		clip.Complete = true
+
+		if info, err := os.Stat(clip.FilePath); err == nil {
+			if writer, err := recording.RecoverAVI(clip.FilePath); err != nil {
+				s.logger.Error("Error recovering clip %s: %v", clip.Filename, err)
+			} else {
+				if info.ModTime().After(clip.StartTime) {
+					clip.EndTime = info.ModTime()
+				}
+				if err := writer.Close(clip.EndTime.Sub(clip.StartTime)); err != nil {
+					s.logger.Error("Error closing recovered clip %s: %v", clip.Filename, err)
+				}
+				clip.Frames = writer.Frames()
+				clip.FileSize = writer.Size()
+			}
+		}
+
+		if err := s.clipRepo.Update(clip); err != nil {
+			s.logger.Error("Error updating clip %s in database: %v", clip.Filename, err)
+		}
+	}
+}
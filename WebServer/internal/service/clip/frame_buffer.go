@@ -0,0 +1,56 @@
+package clip
+
+import (
+	"sync"
+	"time"
+)
+
+// Frame is a JPEG frame with the time it was received.
+type Frame struct {
+	Data      []byte
+	Timestamp time.Time
+}
+
+// FrameBuffer is a fixed-capacity ring buffer of a camera's most recent frames.
+type FrameBuffer struct {
+	frames []Frame
+	next   int
+	count  int
+	mu     sync.Mutex
+}
+
+// NewFrameBuffer creates a FrameBuffer holding at most capacity frames.
+func NewFrameBuffer(capacity int) *FrameBuffer {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &FrameBuffer{frames: make([]Frame, capacity)}
+}
+
+// Push stores a frame, overwriting the oldest one when the buffer is full.
+func (b *FrameBuffer) Push(data []byte, timestamp time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.frames[b.next] = Frame{Data: data, Timestamp: timestamp}
+	b.next = (b.next + 1) % len(b.frames)
+	if b.count < len(b.frames) {
+		b.count++
+	}
+}
+
+// Since returns the buffered frames received at or after t, oldest first.
+func (b *FrameBuffer) Since(t time.Time) []Frame {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	frames := make([]Frame, 0, b.count)
+	start := (b.next - b.count + len(b.frames)) % len(b.frames)
+	for i := 0; i < b.count; i++ {
+		frame := b.frames[(start+i)%len(b.frames)]
+		if !frame.Timestamp.Before(t) {
+			frames = append(frames, frame)
+		}
+	}
+	return frames
+}
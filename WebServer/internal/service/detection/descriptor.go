@@ -0,0 +1,152 @@
+package detection
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"webserver/internal/config"
+)
+
+const (
+	// LayoutSSD is the TensorFlow SSD output: rows of [batch, class, confidence, x1, y1, x2, y2]
+	// with coordinates relative to the frame.
+	LayoutSSD = "ssd"
+
+	// DefaultConfidenceThreshold is the minimum confidence for object detections.
+	DefaultConfidenceThreshold = 0.6
+)
+
+// ModelDescriptor describes how frames are fed to a model and how its output is read.
+type ModelDescriptor struct {
+	Name                string         `json:"name"`
+	ModelPath           string         `json:"model_path"`
+	ConfigPath          string         `json:"config_path"`
+	Layout              string         `json:"layout"`
+	InputWidth          int            `json:"input_width"`
+	InputHeight         int            `json:"input_height"`
+	Scale               float64        `json:"scale"`
+	Mean                [3]float64     `json:"mean"`
+	SwapRB              bool           `json:"swap_rb"`
+	ConfidenceThreshold float64        `json:"confidence_threshold"`
+	NMSThreshold        float64        `json:"nms_threshold"` // YOLO layouts only
+	Labels              map[int]string `json:"labels"`
+
+	// Aliases maps lower-cased labels in any language to their canonical name.
+	Aliases map[string]string `json:"-"`
+}
+
+// DefaultDescriptor describes the bundled SSD MobileNet v1 COCO model at MODEL_PATH/CONFIG_PATH.
+func DefaultDescriptor(config *config.Config) ModelDescriptor {
+	return ModelDescriptor{
+		Name:                "ssd_mobilenet_v1_coco",
+		ModelPath:           config.ModelPath,
+		ConfigPath:          config.ConfigPath,
+		Layout:              LayoutSSD,
+		InputWidth:          300,
+		InputHeight:         300,
+		Scale:               1.0 / 127.5,
+		Mean:                [3]float64{127.5, 127.5, 127.5},
+		SwapRB:              true,
+		ConfidenceThreshold: DefaultConfidenceThreshold,
+		NMSThreshold:        DefaultNMSThreshold,
+		Labels:              defaultLabels.Localize(labelLanguage(config)),
+		Aliases:             defaultLabels.Aliases(),
+	}
+}
+
+// LoadDescriptor returns the descriptor from the MODEL_DESCRIPTOR JSON file. Fields
+// missing from the file keep the values of DefaultDescriptor; a label map in the
+// file replaces the default one. The LABEL_MAP file, when set, takes precedence
+// over both and is localized to LABEL_LANGUAGE.
+func LoadDescriptor(config *config.Config) (ModelDescriptor, error) {
+	desc, err := loadModelDescriptor(config)
+	if err != nil || config.LabelMap == "" {
+		return desc, err
+	}
+
+	labels, err := LoadLabelMap(config.LabelMap)
+	if err != nil {
+		return desc, err
+	}
+	desc.Labels = labels.Localize(labelLanguage(config))
+	desc.Aliases = labels.Aliases()
+	return desc, nil
+}
+
+// loadModelDescriptor reads the MODEL_DESCRIPTOR file over the defaults.
+func loadModelDescriptor(config *config.Config) (ModelDescriptor, error) {
+	desc := DefaultDescriptor(config)
+	if config.ModelDescriptor == "" {
+		return desc, nil
+	}
+
+	data, err := os.ReadFile(config.ModelDescriptor)
+	if err != nil {
+		return desc, fmt.Errorf("failed to read model descriptor: %w", err)
+	}
+
+	defaultLabels := desc.Labels
+	desc.Labels = nil
+	if err := json.Unmarshal(data, &desc); err != nil {
+		return DefaultDescriptor(config), fmt.Errorf("failed to parse model descriptor: %w", err)
+	}
+	if desc.Labels == nil {
+		desc.Labels = defaultLabels
+	} else {
+		desc.Aliases = nil
+	}
+
+	if err := desc.Validate(); err != nil {
+		return DefaultDescriptor(config), err
+	}
+	return desc, nil
+}
+
+// Validate checks that the descriptor can be used to run a model.
+func (d ModelDescriptor) Validate() error {
+	if d.Layout != LayoutSSD && !d.IsYOLO() {
+		return fmt.Errorf("unsupported output layout %q", d.Layout)
+	}
+	if d.InputWidth <= 0 || d.InputHeight <= 0 {
+		return fmt.Errorf("invalid input size %dx%d", d.InputWidth, d.InputHeight)
+	}
+	if d.ConfidenceThreshold < 0 || d.ConfidenceThreshold > 1 {
+		return fmt.Errorf("confidence_threshold must be between 0 and 1")
+	}
+	if d.NMSThreshold <= 0 || d.NMSThreshold > 1 {
+		return fmt.Errorf("nms_threshold must be between 0 and 1")
+	}
+	return nil
+}
+
+// IsYOLO reports whether the model uses a YOLO output layout, which needs
+// letterboxed input and non-maximum suppression.
+func (d ModelDescriptor) IsYOLO() bool {
+	return d.Layout == LayoutYOLOv5 || d.Layout == LayoutYOLOv8
+}
+
+// Label maps a class ID to its name. Classes missing from the label map are not
+// reported, so the second result is false for them.
+func (d ModelDescriptor) Label(classID int) (string, bool) {
+	label, exists := d.Labels[classID]
+	return label, exists
+}
+
+// Canonical returns the canonical name of a label in any language, or the
+// lower-cased label itself when it has no alias.
+func (d ModelDescriptor) Canonical(label string) string {
+	label = strings.ToLower(strings.TrimSpace(label))
+	if name, exists := d.Aliases[label]; exists {
+		return name
+	}
+	return label
+}
+
+// labelLanguage returns LABEL_LANGUAGE or the default language.
+func labelLanguage(config *config.Config) string {
+	if config.LabelLanguage == "" {
+		return DefaultLanguage
+	}
+	return config.LabelLanguage
+}
@@ -0,0 +1,20 @@
+package detection
+
+import "webserver/internal/dto"
+
+const (
+	// BackendOpenCV runs the model in-process with the OpenCV DNN module.
+	BackendOpenCV = "opencv"
+	// BackendHTTP sends frames to a remote inference server.
+	BackendHTTP = "http"
+	// BackendFake returns deterministic detections without a model, for tests.
+	BackendFake = "fake"
+)
+
+// ObjectDetector finds objects in an encoded (JPEG) frame. Results use pixel
+// coordinates of the original frame and only contain detections above the
+// model's confidence threshold.
+type ObjectDetector interface {
+	Detect(image []byte) ([]dto.DetectionResult, error)
+	Close() error
+}
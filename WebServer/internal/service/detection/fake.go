@@ -0,0 +1,60 @@
+package detection
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	"sort"
+	"webserver/internal/dto"
+)
+
+// FakeConfidence is the confidence of every detection returned by FakeDetector.
+const FakeConfidence = 0.9
+
+// FakeDetector reports one object in the middle half of every frame, labelled with
+// the descriptor's lowest class ID (nothing when the label map is empty). It needs
+// no model, so the pipeline can be run and tested without OpenCV.
+type FakeDetector struct {
+	label string
+}
+
+// NewFakeDetector creates a FakeDetector for the descriptor's label map.
+func NewFakeDetector(descriptor ModelDescriptor) *FakeDetector {
+	ids := make([]int, 0, len(descriptor.Labels))
+	for id := range descriptor.Labels {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	var label string
+	if len(ids) > 0 {
+		label = descriptor.Labels[ids[0]]
+	}
+	return &FakeDetector{label: label}
+}
+
+// Detect returns the fixed detection scaled to the frame size.
+func (d *FakeDetector) Detect(img []byte) ([]dto.DetectionResult, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %v", err)
+	}
+	if d.label == "" {
+		return nil, nil
+	}
+
+	return []dto.DetectionResult{{
+		Label:      d.label,
+		Confidence: FakeConfidence,
+		X:          cfg.Width / 4,
+		Y:          cfg.Height / 4,
+		Width:      cfg.Width / 2,
+		Height:     cfg.Height / 2,
+	}}, nil
+}
+
+// Close does nothing.
+func (d *FakeDetector) Close() error {
+	return nil
+}
@@ -0,0 +1,103 @@
+package detection
+
+import (
+	"sync"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+)
+
+// Filter decides which detections are kept for a camera. Labels are compared by
+// their canonical name, so allow/deny lists and thresholds may use any language
+// of the label map.
+type Filter struct {
+	descriptor ModelDescriptor
+	threshold  float64                    // default minimum confidence
+	thresholds map[string]float64         // canonical label -> minimum confidence
+	allow      map[string]map[string]bool // camera name -> canonical labels kept
+	deny       map[string]map[string]bool // camera name -> canonical labels dropped
+	mu         sync.RWMutex               // guards allow and deny
+}
+
+// NewFilter builds the filter from DETECT_ALLOW, DETECT_DENY and DETECT_THRESHOLDS.
+// Classes without their own threshold use the descriptor's confidence threshold.
+func NewFilter(config *config.Config, descriptor ModelDescriptor) *Filter {
+	f := &Filter{
+		descriptor: descriptor,
+		threshold:  descriptor.ConfidenceThreshold,
+		thresholds: make(map[string]float64),
+		allow:      make(map[string]map[string]bool),
+		deny:       make(map[string]map[string]bool),
+	}
+
+	for label, threshold := range config.DetectThresholds {
+		f.thresholds[descriptor.Canonical(label)] = threshold
+	}
+	for camera, labels := range config.DetectAllow {
+		f.allow[camera] = f.labelSet(labels)
+	}
+	for camera, labels := range config.DetectDeny {
+		f.deny[camera] = f.labelSet(labels)
+	}
+	return f
+}
+
+// MinThreshold returns the lowest confidence any class may be kept with. Backends
+// use it as their cut-off so per-class thresholds below the default still work.
+func (f *Filter) MinThreshold() float64 {
+	min := f.threshold
+	for _, threshold := range f.thresholds {
+		if threshold < min {
+			min = threshold
+		}
+	}
+	return min
+}
+
+// Allows reports whether a detection of the label with the given confidence is
+// kept for the camera. Cameras without an allow list accept every class that is
+// not denied.
+func (f *Filter) Allows(camera, label string, confidence float64) bool {
+	name := f.descriptor.Canonical(label)
+	f.mu.RLock()
+	allowed, limited := f.allow[camera]
+	denied := f.deny[camera][name]
+	f.mu.RUnlock()
+	if (limited && !allowed[name]) || denied {
+		return false
+	}
+
+	threshold, exists := f.thresholds[name]
+	if !exists {
+		threshold = f.threshold
+	}
+	return confidence > threshold
+}
+
+// Apply returns the detections kept for the camera.
+func (f *Filter) Apply(camera string, detections []dto.DetectionResult) []dto.DetectionResult {
+	var kept []dto.DetectionResult
+	for _, d := range detections {
+		if f.Allows(camera, d.Label, d.Confidence) {
+			kept = append(kept, d)
+		}
+	}
+	return kept
+}
+
+// RenameCamera moves the allow and deny lists of a renamed camera.
+func (f *Filter) RenameCamera(oldName, newName string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	config.RenameCameraKey(f.allow, oldName, newName)
+	config.RenameCameraKey(f.deny, oldName, newName)
+}
+
+// labelSet converts configured labels to a set of canonical names.
+func (f *Filter) labelSet(labels []string) map[string]bool {
+	set := make(map[string]bool, len(labels))
+	for _, label := range labels {
+		set[f.descriptor.Canonical(label)] = true
+	}
+	return set
+}
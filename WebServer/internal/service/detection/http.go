@@ -0,0 +1,100 @@
+package detection
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"webserver/internal/dto"
+)
+
+// httpDetection is a single object in the response of a remote inference server.
+type httpDetection struct {
+	ClassID    int     `json:"class_id"`
+	Label      string  `json:"label"`
+	Confidence float64 `json:"confidence"`
+	X          int     `json:"x"`
+	Y          int     `json:"y"`
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+}
+
+// httpResponse is the body returned by a remote inference server.
+type httpResponse struct {
+	Detections []httpDetection `json:"detections"`
+}
+
+// HTTPDetector posts each JPEG frame to a remote inference server and reads back
+// detections in pixel coordinates. Objects without a label are named using the
+// descriptor's label map.
+type HTTPDetector struct {
+	url        string
+	descriptor ModelDescriptor
+	client     *http.Client
+}
+
+// NewHTTPDetector creates a detector for the inference server at url.
+func NewHTTPDetector(url string, timeout time.Duration, descriptor ModelDescriptor) (*HTTPDetector, error) {
+	if url == "" {
+		return nil, fmt.Errorf("DETECTOR_URL is required for the %s backend", BackendHTTP)
+	}
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+
+	return &HTTPDetector{
+		url:        url,
+		descriptor: descriptor,
+		client:     &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Detect sends the frame to the inference server.
+func (d *HTTPDetector) Detect(image []byte) ([]dto.DetectionResult, error) {
+	resp, err := d.client.Post(d.url, "image/jpeg", bytes.NewReader(image))
+	if err != nil {
+		return nil, fmt.Errorf("inference request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("inference server returned %s: %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	var body httpResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode inference response: %w", err)
+	}
+
+	var results []dto.DetectionResult
+	for _, det := range body.Detections {
+		if det.Confidence <= d.descriptor.ConfidenceThreshold {
+			continue
+		}
+		label := det.Label
+		if label == "" {
+			var known bool
+			if label, known = d.descriptor.Label(det.ClassID); !known {
+				continue
+			}
+		}
+		results = append(results, dto.DetectionResult{
+			Label:      label,
+			Confidence: det.Confidence,
+			X:          det.X,
+			Y:          det.Y,
+			Width:      det.Width,
+			Height:     det.Height,
+		})
+	}
+	return results, nil
+}
+
+// Close releases idle connections to the inference server.
+func (d *HTTPDetector) Close() error {
+	d.client.CloseIdleConnections()
+	return nil
+}
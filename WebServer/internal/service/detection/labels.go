@@ -0,0 +1,79 @@
+package detection
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// LabelName is the key of the canonical (English) class name in a label map entry.
+	LabelName = "name"
+	// DefaultLanguage is the label language used when LABEL_LANGUAGE is not set.
+	DefaultLanguage = "pl"
+)
+
+// LabelMap maps class IDs to their canonical name and its translations, e.g.
+// {"1": {"name": "person", "pl": "osoba"}}.
+type LabelMap map[int]map[string]string
+
+// defaultLabels are the classes of the bundled SSD MobileNet model that are reported.
+var defaultLabels = LabelMap{
+	1:  {LabelName: "person", "pl": "osoba"},
+	2:  {LabelName: "bicycle", "pl": "rower"},
+	3:  {LabelName: "car", "pl": "samochod"},
+	4:  {LabelName: "motorcycle", "pl": "motocykl"},
+	5:  {LabelName: "airplane", "pl": "samolot"},
+	6:  {LabelName: "bus", "pl": "autobus"},
+	8:  {LabelName: "truck", "pl": "ciezarowka"},
+	16: {LabelName: "bird", "pl": "ptak"},
+	17: {LabelName: "cat", "pl": "kot"},
+	18: {LabelName: "dog", "pl": "pies"},
+}
+
+// LoadLabelMap reads a label map JSON file. Every entry needs a canonical name.
+func LoadLabelMap(path string) (LabelMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read label map: %w", err)
+	}
+
+	var labels LabelMap
+	if err := json.Unmarshal(data, &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse label map: %w", err)
+	}
+	for id, entry := range labels {
+		if strings.TrimSpace(entry[LabelName]) == "" {
+			return nil, fmt.Errorf("label %d has no %q", id, LabelName)
+		}
+	}
+	return labels, nil
+}
+
+// Localize returns the labels in the given language, falling back to the canonical
+// name for classes without a translation.
+func (m LabelMap) Localize(language string) map[int]string {
+	labels := make(map[int]string, len(m))
+	for id, entry := range m {
+		if label := entry[language]; label != "" {
+			labels[id] = label
+		} else {
+			labels[id] = entry[LabelName]
+		}
+	}
+	return labels
+}
+
+// Aliases maps every lower-cased name and translation to the canonical name, so
+// configuration can refer to classes in any language.
+func (m LabelMap) Aliases() map[string]string {
+	aliases := make(map[string]string)
+	for _, entry := range m {
+		name := strings.ToLower(entry[LabelName])
+		for _, label := range entry {
+			aliases[strings.ToLower(label)] = name
+		}
+	}
+	return aliases
+}
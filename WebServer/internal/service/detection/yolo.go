@@ -0,0 +1,213 @@
+package detection
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"webserver/internal/dto"
+)
+
+const (
+	// LayoutYOLOv5 is the YOLOv5-style output [1, N, 5+C]: cx, cy, w, h, objectness,
+	// then one score per class, in model input pixels.
+	LayoutYOLOv5 = "yolov5"
+	// LayoutYOLOv8 is the YOLOv8-style output [1, 4+C, N]: cx, cy, w, h, then one score
+	// per class, stored column-wise and without objectness.
+	LayoutYOLOv8 = "yolov8"
+
+	// DefaultNMSThreshold is the IoU above which overlapping boxes of a class are merged.
+	DefaultNMSThreshold = 0.45
+	// LetterboxColor is the grey level used to pad letterboxed frames, as in YOLO training.
+	LetterboxColor = 114
+)
+
+// Box is a candidate detection in pixel coordinates.
+type Box struct {
+	ClassID    int
+	Confidence float64
+	X, Y       float64
+	Width      float64
+	Height     float64
+}
+
+// Letterbox describes how a frame is scaled, keeping its aspect ratio, and padded
+// to the model input size.
+type Letterbox struct {
+	FrameWidth, FrameHeight     int
+	ResizedWidth, ResizedHeight int
+	Left, Top                   int // padding before the resized frame
+	Right, Bottom               int // padding after the resized frame
+	Scale                       float64
+}
+
+// NewLetterbox computes the letterbox for a frame and model input size.
+func NewLetterbox(frameWidth, frameHeight, inputWidth, inputHeight int) Letterbox {
+	scale := math.Min(float64(inputWidth)/float64(frameWidth), float64(inputHeight)/float64(frameHeight))
+	resizedWidth := int(math.Round(float64(frameWidth) * scale))
+	resizedHeight := int(math.Round(float64(frameHeight) * scale))
+
+	left := (inputWidth - resizedWidth) / 2
+	top := (inputHeight - resizedHeight) / 2
+	return Letterbox{
+		FrameWidth:    frameWidth,
+		FrameHeight:   frameHeight,
+		ResizedWidth:  resizedWidth,
+		ResizedHeight: resizedHeight,
+		Left:          left,
+		Top:           top,
+		Right:         inputWidth - resizedWidth - left,
+		Bottom:        inputHeight - resizedHeight - top,
+		Scale:         scale,
+	}
+}
+
+// Rescale maps a box from model input pixels back to the original frame, clipped to the frame.
+func (l Letterbox) Rescale(b Box) Box {
+	x1 := clampFloat((b.X-float64(l.Left))/l.Scale, 0, float64(l.FrameWidth))
+	y1 := clampFloat((b.Y-float64(l.Top))/l.Scale, 0, float64(l.FrameHeight))
+	x2 := clampFloat((b.X+b.Width-float64(l.Left))/l.Scale, 0, float64(l.FrameWidth))
+	y2 := clampFloat((b.Y+b.Height-float64(l.Top))/l.Scale, 0, float64(l.FrameHeight))
+
+	b.X, b.Y, b.Width, b.Height = x1, y1, x2-x1, y2-y1
+	return b
+}
+
+// ParseYOLO reads the candidates above threshold from a YOLOv5 or YOLOv8 output
+// tensor with the given shape (the batch dimension is optional).
+func ParseYOLO(layout string, shape []int, data []float32, threshold float64) ([]Box, error) {
+	if len(shape) == 3 {
+		shape = shape[1:]
+	}
+	if len(shape) != 2 || len(data) < shape[0]*shape[1] {
+		return nil, fmt.Errorf("unexpected output shape %v", shape)
+	}
+
+	var boxes []Box
+	switch layout {
+	case LayoutYOLOv5:
+		rows, cols := shape[0], shape[1]
+		if cols < 6 {
+			return nil, fmt.Errorf("unexpected output shape %v", shape)
+		}
+		for i := 0; i < rows; i++ {
+			row := data[i*cols : (i+1)*cols]
+			classID, score := bestClass(len(row)-5, func(c int) float32 { return row[5+c] })
+			confidence := float64(row[4]) * float64(score)
+			if confidence > threshold {
+				boxes = append(boxes, centerBox(classID, confidence, row[0], row[1], row[2], row[3]))
+			}
+		}
+
+	case LayoutYOLOv8:
+		channels, count := shape[0], shape[1]
+		if channels < 5 {
+			return nil, fmt.Errorf("unexpected output shape %v", shape)
+		}
+		at := func(channel, i int) float32 { return data[channel*count+i] }
+		for i := 0; i < count; i++ {
+			classID, score := bestClass(channels-4, func(c int) float32 { return at(4+c, i) })
+			if float64(score) > threshold {
+				boxes = append(boxes, centerBox(classID, float64(score), at(0, i), at(1, i), at(2, i), at(3, i)))
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported output layout %q", layout)
+	}
+	return boxes, nil
+}
+
+// NMS performs class-aware non-maximum suppression: of boxes of the same class that
+// overlap by more than iouThreshold, only the most confident one is kept.
+func NMS(boxes []Box, iouThreshold float64) []Box {
+	sorted := make([]Box, len(boxes))
+	copy(sorted, boxes)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Confidence > sorted[j].Confidence })
+
+	var kept []Box
+	for _, candidate := range sorted {
+		suppressed := false
+		for _, k := range kept {
+			if k.ClassID == candidate.ClassID && iou(k, candidate) > iouThreshold {
+				suppressed = true
+				break
+			}
+		}
+		if !suppressed {
+			kept = append(kept, candidate)
+		}
+	}
+	return kept
+}
+
+// DecodeYOLO turns a YOLO output tensor into detections in frame coordinates using
+// the descriptor's layout, thresholds and label map.
+func DecodeYOLO(descriptor ModelDescriptor, shape []int, data []float32, letterbox Letterbox) ([]dto.DetectionResult, error) {
+	boxes, err := ParseYOLO(descriptor.Layout, shape, data, descriptor.ConfidenceThreshold)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []dto.DetectionResult
+	for _, box := range NMS(boxes, descriptor.NMSThreshold) {
+		box = letterbox.Rescale(box)
+		label, known := descriptor.Label(box.ClassID)
+		if !known || box.Width <= 0 || box.Height <= 0 {
+			continue
+		}
+		results = append(results, dto.DetectionResult{
+			Label:      label,
+			Confidence: box.Confidence,
+			X:          int(math.Round(box.X)),
+			Y:          int(math.Round(box.Y)),
+			Width:      int(math.Round(box.Width)),
+			Height:     int(math.Round(box.Height)),
+		})
+	}
+	return results, nil
+}
+
+// bestClass returns the class with the highest score.
+func bestClass(classes int, score func(c int) float32) (int, float32) {
+	best, bestScore := 0, float32(-1)
+	for c := 0; c < classes; c++ {
+		if s := score(c); s > bestScore {
+			best, bestScore = c, s
+		}
+	}
+	return best, bestScore
+}
+
+// centerBox converts a centre/size box to a corner/size box.
+func centerBox(classID int, confidence float64, cx, cy, w, h float32) Box {
+	return Box{
+		ClassID:    classID,
+		Confidence: confidence,
+		X:          float64(cx) - float64(w)/2,
+		Y:          float64(cy) - float64(h)/2,
+		Width:      float64(w),
+		Height:     float64(h),
+	}
+}
+
+// iou returns the intersection over union of two boxes.
+func iou(a, b Box) float64 {
+	x1 := math.Max(a.X, b.X)
+	y1 := math.Max(a.Y, b.Y)
+	x2 := math.Min(a.X+a.Width, b.X+b.Width)
+	y2 := math.Min(a.Y+a.Height, b.Y+b.Height)
+	if x2 <= x1 || y2 <= y1 {
+		return 0
+	}
+
+	intersection := (x2 - x1) * (y2 - y1)
+	union := a.Width*a.Height + b.Width*b.Height - intersection
+	if union <= 0 {
+		return 0
+	}
+	return intersection / union
+}
+
+func clampFloat(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(hi, v))
+}
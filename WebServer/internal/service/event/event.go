@@ -0,0 +1,229 @@
+package event
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service/storage"
+)
+
+// ErrNotFound is returned when an event does not exist.
+var ErrNotFound = errors.New("event not found")
+
+// EventService merges detections from the same camera that are no more than the
+// configured gap apart into a single event.
+type EventService struct {
+	gap       time.Duration
+	open      map[string]*model.Event // latest event per camera
+	mu        sync.Mutex
+	eventRepo repository.EventRepository
+	buffer    *storage.BufferService
+	logger    *logger.Logger
+}
+
+// NewEventService creates an EventService using the configured event gap.
+func NewEventService(config *config.Config, logger *logger.Logger, eventRepo repository.EventRepository,
+	buffer *storage.BufferService) *EventService {
+	gap := time.Duration(config.EventGapS) * time.Second
+	if gap <= 0 {
+		gap = 30 * time.Second
+	}
+
+	service := &EventService{
+		gap:       gap,
+		open:      make(map[string]*model.Event),
+		eventRepo: eventRepo,
+		buffer:    buffer,
+		logger:    logger,
+	}
+	if buffer != nil {
+		buffer.OnImageRemoved(service.forgetImage)
+	}
+	return service
+}
+
+// RecordDetection adds a stored image and its detections to the camera's current
+// event, or starts a new event when the previous detection is older than the gap.
+// It returns the ID of the event the image was assigned to.
+func (s *EventService) RecordDetection(camera, imageFilename string, detections []dto.DetectionResult, timestamp time.Time) int64 {
+	if s.eventRepo == nil || len(detections) == 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	confidence := 0.0
+	for _, det := range detections {
+		if det.Confidence > confidence {
+			confidence = det.Confidence
+		}
+	}
+
+	event := s.latest(camera)
+	if event == nil || timestamp.Sub(event.EndTime) > s.gap {
+		event = &model.Event{
+			Camera:         camera,
+			StartTime:      timestamp,
+			EndTime:        timestamp,
+			PeakConfidence: confidence,
+			Objects:        mergeObjects(nil, detections),
+			Thumbnail:      imageFilename,
+			ImageCount:     1,
+		}
+		id, err := s.eventRepo.Insert(event)
+		if err != nil {
+			s.logger.Error("Error saving event for camera %s: %v", camera, err)
+			return 0
+		}
+		event.ID = id
+		s.open[camera] = event
+		s.logger.Info("🚨 Camera %s: event %d started (%v)", camera, event.ID, event.Objects)
+	} else {
+		if timestamp.After(event.EndTime) {
+			event.EndTime = timestamp
+		}
+		if confidence > event.PeakConfidence {
+			event.PeakConfidence = confidence
+			event.Thumbnail = imageFilename
+		}
+		event.Objects = mergeObjects(event.Objects, detections)
+		event.ImageCount++
+		if err := s.eventRepo.Update(event); err != nil {
+			s.logger.Error("Error updating event %d: %v", event.ID, err)
+		}
+	}
+
+	if err := s.eventRepo.AddImage(event.ID, imageFilename); err != nil {
+		s.logger.Error("Error linking image %s to event %d: %v", imageFilename, event.ID, err)
+	}
+	return event.ID
+}
+
+// Extend keeps the camera's current event open for detections whose frame was not
+// saved, such as a parked car suppressed as unchanged. It never starts an event and
+// returns the ID of the extended event, 0 when there is none within the gap.
+func (s *EventService) Extend(camera string, detections []dto.DetectionResult, timestamp time.Time) int64 {
+	if s.eventRepo == nil || len(detections) == 0 {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	event := s.latest(camera)
+	if event == nil || timestamp.Sub(event.EndTime) > s.gap {
+		return 0
+	}
+	if timestamp.After(event.EndTime) {
+		event.EndTime = timestamp
+	}
+	event.Objects = mergeObjects(event.Objects, detections)
+	if err := s.eventRepo.Update(event); err != nil {
+		s.logger.Error("Error updating event %d: %v", event.ID, err)
+	}
+	return event.ID
+}
+
+// Delete removes an event together with its images and their detections.
+func (s *EventService) Delete(id int64) error {
+	if s.eventRepo == nil {
+		return ErrNotFound
+	}
+
+	event, err := s.eventRepo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if event == nil {
+		return ErrNotFound
+	}
+
+	filenames, err := s.eventRepo.GetImageFilenames(id)
+	if err != nil {
+		return err
+	}
+	for _, filename := range filenames {
+		if s.buffer == nil {
+			break
+		}
+		if err := s.buffer.DeleteImage(filename); err != nil {
+			s.logger.Error("Failed to delete image %s: %v", filename, err)
+		}
+	}
+
+	if err := s.eventRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if open, exists := s.open[event.Camera]; exists && open.ID == id {
+		delete(s.open, event.Camera)
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("Deleted event %d with %d image(s)", id, len(filenames))
+	return nil
+}
+
+// RenameCamera forgets the open event of a renamed camera. The database rows are renamed
+// together with the camera, so the event is loaded again under the new name.
+func (s *EventService) RenameCamera(oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.open, oldName)
+	delete(s.open, newName)
+}
+
+// forgetImage drops the open event of a removed image's camera. Removing an image
+// recounts its event and may pick a new thumbnail in the database, so the cached
+// copy would write the old values back on the next detection.
+func (s *EventService) forgetImage(img model.Image) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.open, img.Camera)
+}
+
+// latest returns the camera's most recent event, loading it from the database
+// after a restart. Caller must hold s.mu.
+func (s *EventService) latest(camera string) *model.Event {
+	if event, exists := s.open[camera]; exists {
+		return event
+	}
+
+	event, err := s.eventRepo.GetLatest(camera)
+	if err != nil {
+		s.logger.Error("Error loading latest event for camera %s: %v", camera, err)
+		return nil
+	}
+	if event != nil {
+		s.open[camera] = event
+	}
+	return event
+}
+
+// mergeObjects adds the detected labels to the object list, keeping it sorted and unique.
+func mergeObjects(objects []string, detections []dto.DetectionResult) []string {
+	seen := make(map[string]bool, len(objects))
+	merged := make([]string, 0, len(objects)+len(detections))
+	for _, object := range objects {
+		seen[object] = true
+		merged = append(merged, object)
+	}
+	for _, det := range detections {
+		if !seen[det.Label] {
+			seen[det.Label] = true
+			merged = append(merged, det.Label)
+		}
+	}
+	sort.Strings(merged)
+	return merged
+}
@@ -0,0 +1,322 @@
+package health
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service/registry"
+	"webserver/internal/service/websocket"
+)
+
+const (
+	// StatusUnknown is reported until a camera first goes online or offline.
+	StatusUnknown = "unknown"
+	// StatusOnline is reported while a camera delivers frames.
+	StatusOnline = "online"
+	// StatusOffline is reported after a camera stops delivering frames.
+	StatusOffline = "offline"
+	// EventSceneChange is recorded when a frame-wide illumination change resets motion detection.
+	EventSceneChange = "scene_change"
+
+	// FPSWindow is the time span used to measure frame rate.
+	FPSWindow = 10 * time.Second
+	// CheckInterval defines how often cameras are checked for going offline.
+	CheckInterval = time.Second
+)
+
+// cameraHealth holds tracking state for a single camera.
+type cameraHealth struct {
+	status        dto.CameraHealth
+	frameTimes    []time.Time
+	totalBytes    uint64
+	pendingFrames int
+}
+
+// HealthService tracks per-camera frame delivery, marks cameras online/offline,
+// persists state transitions and broadcasts them to viewers. State is kept by registry
+// ID, so it follows a camera when it is renamed.
+type HealthService struct {
+	cameras      map[int64]*cameraHealth
+	offlineAfter time.Duration
+	onlineFrames int
+	startedAt    time.Time
+	mu           sync.Mutex
+	registry     *registry.RegistryService
+	eventRepo    repository.CameraEventRepository
+	hub          *websocket.HubService
+	logger       *logger.Logger
+}
+
+// NewHealthService creates a HealthService using the configured offline/online thresholds.
+func NewHealthService(config *config.Config, logger *logger.Logger, registry *registry.RegistryService,
+	eventRepo repository.CameraEventRepository, hub *websocket.HubService) *HealthService {
+	offlineAfter := time.Duration(config.CameraOfflineAfterS) * time.Second
+	if offlineAfter <= 0 {
+		offlineAfter = 10 * time.Second
+	}
+	onlineFrames := config.CameraOnlineFrames
+	if onlineFrames <= 0 {
+		onlineFrames = 1
+	}
+
+	return &HealthService{
+		cameras:      make(map[int64]*cameraHealth),
+		offlineAfter: offlineAfter,
+		onlineFrames: onlineFrames,
+		startedAt:    time.Now(),
+		registry:     registry,
+		eventRepo:    eventRepo,
+		hub:          hub,
+		logger:       logger,
+	}
+}
+
+// Run periodically marks cameras offline when no frames arrived within the threshold.
+func (s *HealthService) Run() {
+	ticker := time.NewTicker(CheckInterval)
+
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		s.CheckOffline()
+	}
+}
+
+// RecordFrame registers a received frame of the given size for a camera.
+func (s *HealthService) RecordFrame(camera string, size int) {
+	s.mu.Lock()
+
+	now := time.Now()
+	ch := s.lookup(camera)
+	if ch == nil {
+		s.mu.Unlock()
+		return
+	}
+	ch.status.Frames++
+	ch.status.LastFrame = now
+	ch.status.LastFrameSize = size
+	ch.totalBytes += uint64(size)
+	ch.status.AvgFrameSize = int(ch.totalBytes / ch.status.Frames)
+	ch.frameTimes = append(trimWindow(ch.frameTimes, now), now)
+
+	var changed *dto.CameraHealth
+	if ch.status.Status != StatusOnline {
+		ch.pendingFrames++
+		if ch.pendingFrames >= s.onlineFrames {
+			changed = s.transition(ch, StatusOnline, now)
+		}
+	}
+	s.mu.Unlock()
+
+	if changed != nil {
+		s.publish(*changed, "frames received again")
+	}
+}
+
+// RecordDecodeFailure registers a frame from the camera that could not be decoded.
+func (s *HealthService) RecordDecodeFailure(camera string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch := s.lookup(camera); ch != nil {
+		ch.status.DecodeFailures++
+	}
+}
+
+// RecordSuppressed counts a frame of the camera that was not saved because it only
+// showed stationary objects that were saved recently.
+func (s *HealthService) RecordSuppressed(camera string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if ch := s.lookup(camera); ch != nil {
+		ch.status.Suppressed++
+	}
+}
+
+// RecordSceneChange counts and persists a global illumination change of a camera
+// (auto exposure, lights switched on or off) that was not treated as motion.
+func (s *HealthService) RecordSceneChange(camera, reason string) {
+	s.mu.Lock()
+	if ch := s.lookup(camera); ch != nil {
+		ch.status.SceneChanges++
+	}
+	s.mu.Unlock()
+
+	s.logger.Info("💡 Camera %s: scene change, motion baseline reset (%s)", camera, reason)
+
+	if s.eventRepo == nil {
+		return
+	}
+	event := &model.CameraEvent{
+		Camera:    camera,
+		Type:      EventSceneChange,
+		Message:   reason,
+		Timestamp: time.Now(),
+	}
+	if _, err := s.eventRepo.Insert(event); err != nil {
+		s.logger.Error("Error saving camera event: %v", err)
+	}
+}
+
+// CheckOffline marks registered cameras offline when their last frame (or server start,
+// for cameras that never sent one) is older than the offline threshold, and forgets
+// cameras removed from the registry.
+func (s *HealthService) CheckOffline() {
+	s.mu.Lock()
+
+	now := time.Now()
+	var changed []dto.CameraHealth
+	registered := make(map[int64]bool)
+	for _, cam := range s.registry.GetAll() {
+		registered[cam.ID] = true
+		if !cam.Enabled {
+			continue
+		}
+		ch := s.getCamera(cam)
+		if ch.status.Status == StatusOffline {
+			continue
+		}
+
+		last := ch.status.LastFrame
+		if last.IsZero() {
+			last = s.startedAt
+		}
+		if now.Sub(last) > s.offlineAfter {
+			changed = append(changed, *s.transition(ch, StatusOffline, now))
+		}
+	}
+	for id := range s.cameras {
+		if !registered[id] {
+			delete(s.cameras, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, status := range changed {
+		s.publish(status, fmt.Sprintf("no frames for %s", s.offlineAfter))
+	}
+}
+
+// GetStatus returns the health of every registered camera, sorted by name.
+func (s *HealthService) GetStatus() []dto.CameraHealth {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]dto.CameraHealth, 0, len(s.cameras))
+	for _, cam := range s.registry.GetAll() {
+		ch := s.getCamera(cam)
+		ch.frameTimes = trimWindow(ch.frameTimes, now)
+		status := ch.status
+		status.FPS = measureFPS(ch.frameTimes)
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Camera < statuses[j].Camera })
+	return statuses
+}
+
+// GetStatusByName returns the health of a single camera.
+func (s *HealthService) GetStatusByName(camera string) (dto.CameraHealth, bool) {
+	for _, status := range s.GetStatus() {
+		if status.Camera == camera {
+			return status, true
+		}
+	}
+	return dto.CameraHealth{}, false
+}
+
+// transition changes a camera's status and returns a snapshot. Caller must hold s.mu.
+func (s *HealthService) transition(ch *cameraHealth, status string, now time.Time) *dto.CameraHealth {
+	ch.status.Status = status
+	ch.status.Since = now
+	ch.pendingFrames = 0
+	ch.frameTimes = trimWindow(ch.frameTimes, now)
+
+	snapshot := ch.status
+	snapshot.FPS = measureFPS(ch.frameTimes)
+	return &snapshot
+}
+
+// publish persists a status transition as an event and broadcasts it to viewers.
+func (s *HealthService) publish(status dto.CameraHealth, reason string) {
+	if status.Status == StatusOffline {
+		s.logger.Warning("📴 Camera %s is offline: %s", status.Camera, reason)
+	} else {
+		s.logger.Info("📶 Camera %s is online: %s", status.Camera, reason)
+	}
+
+	if s.eventRepo != nil {
+		event := &model.CameraEvent{
+			Camera:    status.Camera,
+			Type:      status.Status,
+			Message:   reason,
+			Timestamp: status.Since,
+		}
+		if _, err := s.eventRepo.Insert(event); err != nil {
+			s.logger.Error("Error saving camera event: %v", err)
+		}
+	}
+
+	if s.hub == nil || s.hub.GetClientCount() == 0 {
+		return
+	}
+	msg, err := json.Marshal(dto.StatusMessage{Type: "status", CameraHealth: status})
+	if err != nil {
+		s.logger.Error("Error encoding status message: %v", err)
+		return
+	}
+	s.hub.Broadcast(msg, status.Camera)
+}
+
+// getCamera returns the state of a registered camera, creating it when absent, under the
+// camera's current name. Caller must hold s.mu.
+func (s *HealthService) getCamera(cam model.Camera) *cameraHealth {
+	ch, exists := s.cameras[cam.ID]
+	if !exists {
+		ch = &cameraHealth{
+			status: dto.CameraHealth{Status: StatusUnknown, Since: s.startedAt},
+		}
+		s.cameras[cam.ID] = ch
+	}
+	ch.status.Camera = cam.Name
+	return ch
+}
+
+// lookup returns the state of the camera with the given name, or nil when the name is
+// not registered. Caller must hold s.mu.
+func (s *HealthService) lookup(camera string) *cameraHealth {
+	cam, exists := s.registry.GetByName(camera)
+	if !exists {
+		return nil
+	}
+	return s.getCamera(cam)
+}
+
+// trimWindow drops frame times older than FPSWindow.
+func trimWindow(times []time.Time, now time.Time) []time.Time {
+	cut := 0
+	for cut < len(times) && now.Sub(times[cut]) > FPSWindow {
+		cut++
+	}
+	return times[cut:]
+}
+
+// measureFPS computes the frame rate over the frames in the window.
+func measureFPS(times []time.Time) float64 {
+	if len(times) < 2 {
+		return 0
+	}
+	span := times[len(times)-1].Sub(times[0]).Seconds()
+	if span <= 0 {
+		return 0
+	}
+	return float64(len(times)-1) / span
+}
@@ -1,13 +1,32 @@
 package service
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
+	"image"
+	_ "image/jpeg"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 	"webserver/internal/config"
+	"webserver/internal/dto"
 	"webserver/internal/logger"
 	"webserver/internal/service/ai"
+	"webserver/internal/service/clip"
+	"webserver/internal/service/event"
+	"webserver/internal/service/health"
+	"webserver/internal/service/motion"
+	"webserver/internal/service/reconcile"
+	"webserver/internal/service/recording"
+	"webserver/internal/service/registry"
+	"webserver/internal/service/render"
+	"webserver/internal/service/retention"
+	"webserver/internal/service/rule"
 	"webserver/internal/service/storage"
+	"webserver/internal/service/stream"
+	"webserver/internal/service/tracking"
 	"webserver/internal/service/websocket"
 )
 
@@ -16,6 +35,9 @@ const (
 	ProcessingQueueSize = 50
 	// MotionDetectionWorkerId selects which worker performs motion detection for gating.
 	MotionDetectionWorkerId = 0
+	// MaxStoredDetections limits how many detections are stored with an image. Tracking,
+	// events and rules see every detection of the frame.
+	MaxStoredDetections = 5
 )
 
 // Manager orchestrates camera frame handling, motion gating, AI detection,
@@ -24,30 +46,67 @@ type Manager struct {
 	bufferService    *storage.BufferService
 	detectorServices []*ai.DetectorService
 	websocketService *websocket.HubService
+	streamService    *stream.ReassemblerService
+	identityService  *stream.IdentityService
+	registryService  *registry.RegistryService
+	healthService    *health.HealthService
+	recordingService *recording.RecordingService
+	clipService      *clip.ClipService
+	eventService     *event.EventService
+	zoneService      *motion.ZoneService
+	trackingService  *tracking.TrackingService
+	ruleService      *rule.RuleService
+	renderService    *render.RenderService
+	reconcileService *reconcile.ReconcileService
+	retentionService *retention.RetentionService
+	config           *config.Config
 	logger           *logger.Logger
 
 	processingQueue chan ImageProcessingTask
 	frameCounters   map[string]int
+	frameBuffers    map[string]*clip.FrameBuffer // recent frames per camera for clip pre-roll
 	numWorkers      int
 
 	frameCounterMu sync.Mutex
+	frameBufferMu  sync.Mutex
 	wg             sync.WaitGroup
 }
 
 type ImageProcessingTask struct {
-	Image  []byte
-	Camera string
+	Image     []byte
+	Camera    string
+	Timestamp time.Time
 }
 
 // NewManager constructs a Manager and starts processing worker goroutines.
-func NewManager(detectorServices []*ai.DetectorService, bufferService *storage.BufferService, websocketService *websocket.HubService, config *config.Config, logger *logger.Logger) *Manager {
+func NewManager(detectorServices []*ai.DetectorService, bufferService *storage.BufferService, websocketService *websocket.HubService,
+	streamService *stream.ReassemblerService, identityService *stream.IdentityService, registryService *registry.RegistryService,
+	healthService *health.HealthService, recordingService *recording.RecordingService, clipService *clip.ClipService,
+	eventService *event.EventService, zoneService *motion.ZoneService, trackingService *tracking.TrackingService,
+	ruleService *rule.RuleService, renderService *render.RenderService, reconcileService *reconcile.ReconcileService,
+	retentionService *retention.RetentionService, config *config.Config, logger *logger.Logger) *Manager {
 	manager := &Manager{
 		detectorServices: detectorServices,
 		bufferService:    bufferService,
 		websocketService: websocketService,
+		streamService:    streamService,
+		identityService:  identityService,
+		registryService:  registryService,
+		healthService:    healthService,
+		recordingService: recordingService,
+		clipService:      clipService,
+		eventService:     eventService,
+		zoneService:      zoneService,
+		trackingService:  trackingService,
+		ruleService:      ruleService,
+		renderService:    renderService,
+		reconcileService: reconcileService,
+		retentionService: retentionService,
+		config:           config,
 		numWorkers:       config.ProcessingWorkers,
 		processingQueue:  make(chan ImageProcessingTask, ProcessingQueueSize),
 		frameCounters:    make(map[string]int),
+		frameBuffers:     make(map[string]*clip.FrameBuffer),
 		logger:           logger,
 	}
 
@@ -60,31 +119,83 @@ func NewManager(detectorServices []*ai.DetectorService, bufferService *storage.B
 	return manager
 }
 
-// HandleCameraImage broadcasts to viewers (if any), checks motion gating,
-// and enqueues the frame for detection when appropriate.
+// HandleCameraImage broadcasts to viewers (if any), records the frame when continuous
+// recording is enabled for the camera, keeps it for event clips, checks motion gating
+// (recording illumination changes separately), and enqueues the frame for detection
+// when appropriate. Frames from cameras that are
+// not registered or are disabled are ignored.
 func (m *Manager) HandleCameraImage(image []byte, camera string) {
+	if !m.registryService.IsEnabled(camera) {
+		return
+	}
+	m.healthService.RecordFrame(camera, len(image))
+	m.recordingService.WriteFrame(camera, image)
+
+	now := time.Now()
+	if m.clipService.IsEnabled() {
+		m.getFrameBuffer(camera).Push(image, now)
+		m.clipService.AddFrame(camera, image, now)
+	}
+
 	if m.websocketService.GetClientCount() > 0 {
 		m.sendToViewers(image, camera)
 	}
 
-	motionDetected, err := m.detectorServices[MotionDetectionWorkerId].DetectMotion(image, camera)
+	result, err := m.detectorServices[MotionDetectionWorkerId].DetectMotion(image, camera)
 	if err != nil {
 		m.logger.Error("Error detecting motion: %v", err)
+		m.healthService.RecordDecodeFailure(camera)
+		return
+	}
+
+	if result.SceneChange {
+		m.healthService.RecordSceneChange(camera, result.Reason)
 		return
 	}
 
-	if !motionDetected {
+	if !result.Motion {
 		return
 	}
 
 	select {
-	case m.processingQueue <- ImageProcessingTask{Image: image, Camera: camera}:
+	case m.processingQueue <- ImageProcessingTask{Image: image, Camera: camera, Timestamp: now}:
 		m.logger.Info("📹 Camera %s: Frame queued for processing", camera)
 	default:
 		m.logger.Warning("⚠️  Processing queue full for camera %s - skipping AI detection", camera)
 	}
 }
 
+// RenameCamera moves the per-camera state and settings of every service from a camera's
+// old name to its new one after a rename in the registry. Settings from the environment
+// follow the camera until the server restarts, so the ones naming the old camera are
+// reported for updating.
+func (m *Manager) RenameCamera(oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+
+	m.zoneService.RenameCamera(oldName, newName)
+	m.ruleService.RenameCamera(oldName, newName)
+	for _, detector := range m.detectorServices {
+		detector.RenameCamera(oldName, newName)
+	}
+	m.streamService.RenameCamera(oldName, newName)
+	m.recordingService.RenameCamera(oldName, newName)
+	m.clipService.RenameCamera(oldName, newName)
+	m.eventService.RenameCamera(oldName, newName)
+	m.trackingService.RenameCamera(oldName, newName)
+	m.retentionService.RenameCamera(oldName, newName)
+
+	m.frameBufferMu.Lock()
+	config.RenameCameraKey(m.frameBuffers, oldName, newName)
+	m.frameBufferMu.Unlock()
+
+	if variables := m.config.CameraSettings()[oldName]; len(variables) > 0 {
+		m.logger.Warning("⚠️  Camera %s renamed to %s - update %s before the next start", oldName, newName,
+			strings.Join(variables, ", "))
+	}
+}
+
 // GetWebsocketService returns the HubService responsible for viewer connections.
 func (m *Manager) GetWebsocketService() *websocket.HubService {
 	return m.websocketService
@@ -95,6 +206,66 @@ func (m *Manager) GetBufferService() *storage.BufferService {
 	return m.bufferService
 }
 
+// GetStreamService returns the ReassemblerService that rebuilds frames from UDP datagrams.
+func (m *Manager) GetStreamService() *stream.ReassemblerService {
+	return m.streamService
+}
+
+// GetIdentityService returns the IdentityService that authenticates camera datagrams.
+func (m *Manager) GetIdentityService() *stream.IdentityService {
+	return m.identityService
+}
+
+// GetRegistryService returns the RegistryService holding the registered cameras.
+func (m *Manager) GetRegistryService() *registry.RegistryService {
+	return m.registryService
+}
+
+// GetHealthService returns the HealthService tracking camera online/offline state.
+func (m *Manager) GetHealthService() *health.HealthService {
+	return m.healthService
+}
+
+// GetRecordingService returns the RecordingService writing continuous recording segments.
+func (m *Manager) GetRecordingService() *recording.RecordingService {
+	return m.recordingService
+}
+
+// GetClipService returns the ClipService recording event clips around detections.
+func (m *Manager) GetClipService() *clip.ClipService {
+	return m.clipService
+}
+
+// GetEventService returns the EventService grouping detections into events.
+func (m *Manager) GetEventService() *event.EventService {
+	return m.eventService
+}
+
+// GetMotionZoneService returns the ZoneService holding the per-camera motion zones.
+func (m *Manager) GetMotionZoneService() *motion.ZoneService {
+	return m.zoneService
+}
+
+// GetTrackingService returns the TrackingService following objects across frames.
+func (m *Manager) GetTrackingService() *tracking.TrackingService {
+	return m.trackingService
+}
+
+// GetRuleService returns the RuleService evaluating line-crossing and zone rules.
+func (m *Manager) GetRuleService() *rule.RuleService {
+	return m.ruleService
+}
+
+// GetRenderService returns the RenderService drawing detections onto stored images.
+func (m *Manager) GetRenderService() *render.RenderService {
+	return m.renderService
+}
+
+// GetReconcileService returns the ReconcileService comparing stored files with the database.
+func (m *Manager) GetReconcileService() *reconcile.ReconcileService {
+	return m.reconcileService
+}
+
 // GetDetectorService returns the list of DetectorService workers.
 func (m *Manager) GetDetectorService() []*ai.DetectorService {
 	return m.detectorServices
@@ -124,35 +295,83 @@ func (m *Manager) processingWorker(workerID int) {
 	m.logger.Info("🔧 Processing worker %d started", workerID)
 
 	for task := range m.processingQueue {
-		m.processImageAsync(task.Image, task.Camera, workerID)
+		m.processImageAsync(task, workerID)
 	}
 
 	m.logger.Info("🔧 Processing worker %d stopped", workerID)
 }
 
-// processImageAsync performs object detection, draws annotations, and buffers the result.
-func (m *Manager) processImageAsync(image []byte, camera string, workerID int) {
+// processImageAsync performs object detection, assigns track IDs, skips frames showing
+// only unchanged stationary objects, buffers the frame with its detections, assigns it
+// to an event, evaluates the camera's rules and starts (or extends) an event clip for
+// the camera.
+func (m *Manager) processImageAsync(task ImageProcessingTask, workerID int) {
+	image, camera := task.Image, task.Camera
 
-	detections, err := m.detectorServices[workerID].DetectObjects(image)
+	detections, err := m.detectorServices[workerID].DetectObjects(image, camera)
 	if err != nil {
 		m.logger.Error("Błąd detekcji obiektów: %v", err)
 		return
 	}
 
 	if len(detections) > 0 {
-		//imageWithDetections, err := m.detectorServices[workerID].DrawRectangle(detections, image)
-		//if err != nil {
-		//	m.logger.Error("Failed to draw rectangles: %v", err)
-		//	imageWithDetections = image
-		//}
-		// Use original image temporarily until data is collected
-		imageWithDetections := image
-
-		// Limit to 5 detections max
-		if len(detections) > 5 {
-			detections = detections[:5]
+		detections = m.trackingService.Update(camera, detections, task.Timestamp)
+		width, height := frameSize(image)
+
+		// Frames that only show objects saved recently in the same place (a parked car)
+		// are not saved again; the event stays open and the rules still follow the objects
+		if m.trackingService.Unchanged(camera, detections, task.Timestamp) {
+			m.healthService.RecordSuppressed(camera)
+			m.eventService.Extend(camera, detections, task.Timestamp)
+			m.ruleService.Evaluate(camera, "", detections, task.Timestamp, width, height)
+			return
 		}
 
-		m.bufferService.AddImage(imageWithDetections, camera, detections)
+		// Frames are stored unannotated; boxes are drawn when the image is viewed
+		filename := m.bufferService.AddImage(image, camera, mostConfident(detections, MaxStoredDetections))
+		if filename == "" {
+			return
+		}
+
+		m.eventService.RecordDetection(camera, filename, detections, task.Timestamp)
+		m.ruleService.Evaluate(camera, filename, detections, task.Timestamp, width, height)
+		if m.clipService.IsEnabled() {
+			preRoll := m.getFrameBuffer(camera).Since(task.Timestamp.Add(-m.clipService.PreRoll()))
+			m.clipService.Trigger(camera, filename, preRoll)
+		}
+	}
+}
+
+// getFrameBuffer returns the camera's pre-roll buffer, creating it when absent.
+func (m *Manager) getFrameBuffer(camera string) *clip.FrameBuffer {
+	m.frameBufferMu.Lock()
+	defer m.frameBufferMu.Unlock()
+
+	buffer, exists := m.frameBuffers[camera]
+	if !exists {
+		buffer = clip.NewFrameBuffer(m.clipService.BufferCapacity())
+		m.frameBuffers[camera] = buffer
+	}
+	return buffer
+}
+
+// mostConfident returns at most n detections with the highest confidence, leaving the
+// given slice unchanged.
+func mostConfident(detections []dto.DetectionResult, n int) []dto.DetectionResult {
+	if len(detections) <= n {
+		return detections
+	}
+	sorted := make([]dto.DetectionResult, len(detections))
+	copy(sorted, detections)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Confidence > sorted[j].Confidence })
+	return sorted[:n]
+}
+
+// frameSize returns the dimensions of a JPEG frame, or zeros when it cannot be decoded.
+func frameSize(frame []byte) (int, int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(frame))
+	if err != nil {
+		return 0, 0
 	}
+	return cfg.Width, cfg.Height
 }
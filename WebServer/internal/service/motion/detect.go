@@ -0,0 +1,78 @@
+package motion
+
+import (
+	"webserver/internal/dto"
+)
+
+const (
+	// RuleCenter keeps a detection whose bounding-box centre lies inside a detect zone.
+	RuleCenter = "center"
+	// RuleOverlap keeps a detection when enough of its bounding box lies inside a detect zone.
+	RuleOverlap = "overlap"
+	// RuleIoU keeps a detection when the intersection over union of its bounding box
+	// and a detect zone is large enough.
+	RuleIoU = "iou"
+)
+
+// FilterDetections keeps the detections that lie inside one of the camera's detect
+// zones and sets their Zone to the first matching zone. Cameras without detect zones
+// keep every detection. Boxes are in pixels of a width x height frame.
+func (s *ZoneService) FilterDetections(camera string, detections []dto.DetectionResult, width, height int) []dto.DetectionResult {
+	if width <= 0 || height <= 0 {
+		return detections
+	}
+
+	mask := s.getMask(camera, width, height)
+	if len(mask.detect) == 0 {
+		return detections
+	}
+
+	var kept []dto.DetectionResult
+	for _, d := range detections {
+		for _, r := range mask.detect {
+			if s.inRegion(r, d) {
+				d.Zone = r.name
+				kept = append(kept, d)
+				break
+			}
+		}
+	}
+	return kept
+}
+
+// inRegion applies the detect zone rule to a bounding box.
+func (s *ZoneService) inRegion(r region, d dto.DetectionResult) bool {
+	if s.detectRule == RuleCenter {
+		cx, cy := d.X+d.Width/2, d.Y+d.Height/2
+		for _, sp := range r.spans {
+			if sp.y == cy && cx >= sp.x0 && cx < sp.x1 {
+				return true
+			}
+		}
+		return false
+	}
+
+	boxArea := d.Width * d.Height
+	if boxArea <= 0 || r.area == 0 {
+		return false
+	}
+
+	intersection := 0
+	for _, sp := range r.spans {
+		if sp.y < d.Y || sp.y >= d.Y+d.Height {
+			continue
+		}
+		x0, x1 := max(sp.x0, d.X), min(sp.x1, d.X+d.Width)
+		if x1 > x0 {
+			intersection += x1 - x0
+		}
+	}
+
+	var percent float64
+	if s.detectRule == RuleIoU {
+		percent = float64(intersection) * 100 / float64(boxArea+r.area-intersection)
+	} else {
+		percent = float64(intersection) * 100 / float64(boxArea)
+	}
+	return percent >= r.threshold
+}
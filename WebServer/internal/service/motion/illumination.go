@@ -0,0 +1,49 @@
+package motion
+
+import (
+	"fmt"
+	"math"
+	"webserver/internal/config"
+)
+
+const (
+	// DefaultLumaJump is the change of mean brightness (0-255) treated as a scene change.
+	DefaultLumaJump = 25
+	// DefaultSceneChangePercent is the share of changed pixels treated as a scene change.
+	DefaultSceneChangePercent = 60
+)
+
+// IlluminationCheck recognises frame-wide brightness shifts, such as the ESP32 auto
+// exposure adjusting or lights being switched on, which change almost every pixel
+// without anything moving.
+type IlluminationCheck struct {
+	lumaJump      float64
+	changePercent float64
+}
+
+// NewIlluminationCheck creates an IlluminationCheck using the configured limits.
+func NewIlluminationCheck(config *config.Config) *IlluminationCheck {
+	lumaJump := config.SceneLumaJump
+	if lumaJump <= 0 {
+		lumaJump = DefaultLumaJump
+	}
+	changePercent := config.SceneChangePct
+	if changePercent <= 0 {
+		changePercent = DefaultSceneChangePercent
+	}
+
+	return &IlluminationCheck{lumaJump: lumaJump, changePercent: changePercent}
+}
+
+// Check compares the mean brightness of two consecutive frames and the share of the
+// frame that changed between them. It returns a description of the scene change, or
+// false when the difference can be ordinary motion.
+func (c *IlluminationCheck) Check(previousLuma, luma, changedPercent float64) (string, bool) {
+	if math.Abs(luma-previousLuma) >= c.lumaJump {
+		return fmt.Sprintf("mean brightness changed from %.0f to %.0f", previousLuma, luma), true
+	}
+	if changedPercent >= c.changePercent {
+		return fmt.Sprintf("%.0f%% of the frame changed", changedPercent), true
+	}
+	return "", false
+}
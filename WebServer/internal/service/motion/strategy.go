@@ -0,0 +1,38 @@
+package motion
+
+import (
+	"strings"
+	"webserver/internal/config"
+)
+
+const (
+	// StrategyDiff compares each frame with the previous one.
+	StrategyDiff = "diff"
+	// StrategyAverage compares each frame with a running average of earlier frames.
+	StrategyAverage = "average"
+	// StrategyMOG2 models every pixel as a mixture of Gaussians (OpenCV MOG2).
+	StrategyMOG2 = "mog2"
+)
+
+// Strategy returns the motion detector configured for a camera in MOTION_DETECTORS,
+// falling back to MOTION_DETECTOR and then to frame differencing for unknown names.
+func Strategy(config *config.Config, camera string) string {
+	name, exists := config.MotionDetectors[camera]
+	if !exists {
+		name = config.MotionDetector
+	}
+	return ParseStrategy(name)
+}
+
+// ParseStrategy returns the motion detector with the given name, frame differencing for
+// unknown names.
+func ParseStrategy(name string) string {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case StrategyAverage:
+		return StrategyAverage
+	case StrategyMOG2:
+		return StrategyMOG2
+	default:
+		return StrategyDiff
+	}
+}
@@ -0,0 +1,424 @@
+package motion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+)
+
+// DefaultThresholdPercent is used when neither the zone nor MOTION_THRESHOLD_PERCENT set a threshold.
+const DefaultThresholdPercent = 0.2
+
+var (
+	// ErrNotFound is returned when a motion zone does not exist.
+	ErrNotFound = errors.New("motion zone not found")
+	// ErrReadOnly is returned for changes when no database is available.
+	ErrReadOnly = errors.New("motion zones are read-only without a database")
+	// ErrInvalid is returned for zone definitions that fail validation.
+	ErrInvalid = errors.New("invalid motion zone")
+)
+
+// Result describes how much of a camera's motion mask changed. SceneChange is set
+// instead of Motion when the whole scene changed brightness.
+type Result struct {
+	Motion         bool
+	ChangedPercent float64 // highest share of a zone's area that changed
+	Zone           string  // zone with the highest share, "" for the whole frame
+	SceneChange    bool
+	Reason         string // why the frame was treated as a scene change
+}
+
+// ZoneService keeps the motion zones of all cameras in memory and evaluates
+// thresholded difference masks and object detections against them.
+type ZoneService struct {
+	zoneRepo         repository.MotionZoneRepository
+	zones            map[int64]model.MotionZone
+	masks            map[string]*zoneMask // rasterized zones per camera, rebuilt on change
+	defaultThreshold float64
+	detectRule       string
+	detectMinPct     float64
+	mu               sync.RWMutex
+	logger           *logger.Logger
+}
+
+// zoneMask holds a camera's zones rasterized for one frame size.
+type zoneMask struct {
+	width    int
+	height   int
+	excluded []bool // nil when the camera has no exclude zones
+	regions  []region
+	detect   []region // detect zones, ignoring exclude zones
+}
+
+// region is an include zone (or the whole frame) as horizontal pixel runs.
+type region struct {
+	name      string
+	spans     []span
+	area      int // pixels not covered by an exclude zone
+	threshold float64
+}
+
+// span covers pixels [x0, x1) of row y.
+type span struct {
+	y, x0, x1 int
+}
+
+// NewZoneService loads the configured motion zones from the repository.
+func NewZoneService(config *config.Config, logger *logger.Logger, zoneRepo repository.MotionZoneRepository) *ZoneService {
+	threshold := config.MotionThresholdPct
+	if threshold <= 0 {
+		threshold = DefaultThresholdPercent
+	}
+
+	service := &ZoneService{
+		zoneRepo:         zoneRepo,
+		zones:            make(map[int64]model.MotionZone),
+		masks:            make(map[string]*zoneMask),
+		defaultThreshold: threshold,
+		detectRule:       config.DetectZoneRule,
+		detectMinPct:     config.DetectZoneMinPct,
+		logger:           logger,
+	}
+	if service.detectRule == "" {
+		service.detectRule = RuleCenter
+	}
+
+	if zoneRepo != nil {
+		zones, err := zoneRepo.GetAll()
+		if err != nil {
+			service.logger.Error("Failed to load motion zones: %v", err)
+		}
+		for _, zone := range zones {
+			service.zones[zone.ID] = zone
+		}
+	}
+
+	service.logger.Info("🔲 Loaded %d motion zone(s)", len(service.zones))
+	return service
+}
+
+// GetAll returns the zones of a camera, or of all cameras when camera is empty.
+func (s *ZoneService) GetAll(camera string) []model.MotionZone {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zones := make([]model.MotionZone, 0, len(s.zones))
+	for _, zone := range s.zones {
+		if camera == "" || zone.Camera == camera {
+			zones = append(zones, zone)
+		}
+	}
+	sort.Slice(zones, func(i, j int) bool {
+		if zones[i].Camera != zones[j].Camera {
+			return zones[i].Camera < zones[j].Camera
+		}
+		return zones[i].ID < zones[j].ID
+	})
+	return zones
+}
+
+// GetByID returns the zone with the given ID.
+func (s *ZoneService) GetByID(id int64) (model.MotionZone, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	zone, exists := s.zones[id]
+	return zone, exists
+}
+
+// Create validates and stores a new zone.
+func (s *ZoneService) Create(zone model.MotionZone) (model.MotionZone, error) {
+	if err := validateZone(&zone); err != nil {
+		return model.MotionZone{}, err
+	}
+	if s.zoneRepo == nil {
+		return model.MotionZone{}, ErrReadOnly
+	}
+
+	id, err := s.zoneRepo.Insert(&zone)
+	if err != nil {
+		return model.MotionZone{}, err
+	}
+	stored, err := s.zoneRepo.GetByID(id)
+	if err != nil || stored == nil {
+		return model.MotionZone{}, fmt.Errorf("failed to reload motion zone %d: %v", id, err)
+	}
+
+	s.mu.Lock()
+	s.zones[id] = *stored
+	delete(s.masks, stored.Camera)
+	s.mu.Unlock()
+
+	s.logger.Info("🔲 Added %s zone %q for camera %s", stored.Type, stored.Name, stored.Camera)
+	return *stored, nil
+}
+
+// Update validates and saves changes to an existing zone.
+func (s *ZoneService) Update(zone model.MotionZone) (model.MotionZone, error) {
+	if err := validateZone(&zone); err != nil {
+		return model.MotionZone{}, err
+	}
+	if s.zoneRepo == nil {
+		return model.MotionZone{}, ErrReadOnly
+	}
+
+	s.mu.RLock()
+	old, exists := s.zones[zone.ID]
+	s.mu.RUnlock()
+	if !exists {
+		return model.MotionZone{}, ErrNotFound
+	}
+
+	if err := s.zoneRepo.Update(&zone); err != nil {
+		return model.MotionZone{}, err
+	}
+	zone.CreatedAt = old.CreatedAt
+
+	s.mu.Lock()
+	s.zones[zone.ID] = zone
+	delete(s.masks, old.Camera)
+	delete(s.masks, zone.Camera)
+	s.mu.Unlock()
+
+	return zone, nil
+}
+
+// Delete removes a zone.
+func (s *ZoneService) Delete(id int64) error {
+	if s.zoneRepo == nil {
+		return ErrReadOnly
+	}
+
+	s.mu.RLock()
+	zone, exists := s.zones[id]
+	s.mu.RUnlock()
+	if !exists {
+		return ErrNotFound
+	}
+
+	if err := s.zoneRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.zones, id)
+	delete(s.masks, zone.Camera)
+	s.mu.Unlock()
+
+	s.logger.Info("🔲 Removed zone %q from camera %s", zone.Name, zone.Camera)
+	return nil
+}
+
+// RenameCamera moves the in-memory zones of a renamed camera. The database rows
+// are renamed together with the camera.
+func (s *ZoneService) RenameCamera(oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, zone := range s.zones {
+		if zone.Camera == oldName {
+			zone.Camera = newName
+			s.zones[id] = zone
+		}
+	}
+	delete(s.masks, oldName)
+	delete(s.masks, newName)
+}
+
+// Evaluate checks a thresholded difference mask (one byte per pixel, non-zero for
+// changed pixels) against the camera's zones. Changes inside exclude zones are
+// ignored. Motion is reported when the changed share of any include zone, or of
+// the whole frame when the camera has none, exceeds the zone's threshold.
+func (s *ZoneService) Evaluate(camera string, diff []byte, width, height int) Result {
+	if width <= 0 || height <= 0 || len(diff) < width*height {
+		return Result{}
+	}
+
+	mask := s.getMask(camera, width, height)
+
+	var result Result
+	for _, r := range mask.regions {
+		if r.area == 0 {
+			continue
+		}
+
+		changed := 0
+		for _, sp := range r.spans {
+			row := sp.y * width
+			for x := sp.x0; x < sp.x1; x++ {
+				if diff[row+x] != 0 && (mask.excluded == nil || !mask.excluded[row+x]) {
+					changed++
+				}
+			}
+		}
+
+		percent := float64(changed) * 100 / float64(r.area)
+		if percent > result.ChangedPercent {
+			result.ChangedPercent = percent
+			result.Zone = r.name
+		}
+		if percent > r.threshold {
+			result.Motion = true
+		}
+	}
+
+	return result
+}
+
+// getMask returns the camera's rasterized zones for the frame size, building them when needed.
+func (s *ZoneService) getMask(camera string, width, height int) *zoneMask {
+	s.mu.RLock()
+	mask, exists := s.masks[camera]
+	s.mu.RUnlock()
+	if exists && mask.width == width && mask.height == height {
+		return mask
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	mask = &zoneMask{width: width, height: height}
+	var includes, detects []model.MotionZone
+	for _, zone := range s.zones {
+		if zone.Camera != camera || !zone.Enabled {
+			continue
+		}
+		if zone.Type == model.ZoneDetect {
+			detects = append(detects, zone)
+			continue
+		}
+		if zone.Type == model.ZoneExclude {
+			if mask.excluded == nil {
+				mask.excluded = make([]bool, width*height)
+			}
+			for _, sp := range rasterize(zone.Points, width, height) {
+				for x := sp.x0; x < sp.x1; x++ {
+					mask.excluded[sp.y*width+x] = true
+				}
+			}
+			continue
+		}
+		includes = append(includes, zone)
+	}
+	sort.Slice(includes, func(i, j int) bool { return includes[i].ID < includes[j].ID })
+	sort.Slice(detects, func(i, j int) bool { return detects[i].ID < detects[j].ID })
+
+	if len(includes) == 0 {
+		whole := region{threshold: s.defaultThreshold, spans: make([]span, 0, height)}
+		for y := 0; y < height; y++ {
+			whole.spans = append(whole.spans, span{y: y, x0: 0, x1: width})
+		}
+		mask.regions = append(mask.regions, whole)
+	}
+	for _, zone := range includes {
+		r := region{name: zone.Name, spans: rasterize(zone.Points, width, height), threshold: zone.ThresholdPercent}
+		if r.threshold <= 0 {
+			r.threshold = s.defaultThreshold
+		}
+		mask.regions = append(mask.regions, r)
+	}
+
+	for i := range mask.regions {
+		r := &mask.regions[i]
+		for _, sp := range r.spans {
+			for x := sp.x0; x < sp.x1; x++ {
+				if mask.excluded == nil || !mask.excluded[sp.y*width+x] {
+					r.area++
+				}
+			}
+		}
+	}
+
+	for _, zone := range detects {
+		r := region{name: zone.Name, spans: rasterize(zone.Points, width, height), threshold: zone.ThresholdPercent}
+		if r.threshold <= 0 {
+			r.threshold = s.detectMinPct
+		}
+		for _, sp := range r.spans {
+			r.area += sp.x1 - sp.x0
+		}
+		mask.detect = append(mask.detect, r)
+	}
+
+	s.masks[camera] = mask
+	return mask
+}
+
+// rasterize converts a polygon with relative coordinates into the pixel runs whose
+// centres lie inside it (even-odd rule).
+func rasterize(points []model.Point, width, height int) []span {
+	var spans []span
+	if len(points) < 3 {
+		return spans
+	}
+
+	xs := make([]float64, 0, len(points))
+	for y := 0; y < height; y++ {
+		cy := (float64(y) + 0.5) / float64(height)
+
+		xs = xs[:0]
+		for i := range points {
+			a, b := points[i], points[(i+1)%len(points)]
+			if (a.Y <= cy && cy < b.Y) || (b.Y <= cy && cy < a.Y) {
+				x := a.X + (cy-a.Y)*(b.X-a.X)/(b.Y-a.Y)
+				xs = append(xs, x*float64(width))
+			}
+		}
+		sort.Float64s(xs)
+
+		for i := 0; i+1 < len(xs); i += 2 {
+			x0 := clamp(int(math.Ceil(xs[i]-0.5)), 0, width)
+			x1 := clamp(int(math.Ceil(xs[i+1]-0.5)), 0, width)
+			if x1 > x0 {
+				spans = append(spans, span{y: y, x0: x0, x1: x1})
+			}
+		}
+	}
+	return spans
+}
+
+// validateZone normalizes and checks a zone definition.
+func validateZone(zone *model.MotionZone) error {
+	zone.Camera = strings.TrimSpace(zone.Camera)
+	zone.Name = strings.TrimSpace(zone.Name)
+
+	if zone.Camera == "" {
+		return fmt.Errorf("%w: camera is required", ErrInvalid)
+	}
+	if zone.Type != model.ZoneInclude && zone.Type != model.ZoneExclude && zone.Type != model.ZoneDetect {
+		return fmt.Errorf("%w: type must be %q, %q or %q", ErrInvalid, model.ZoneInclude, model.ZoneExclude, model.ZoneDetect)
+	}
+	if len(zone.Points) < 3 {
+		return fmt.Errorf("%w: a polygon needs at least 3 points", ErrInvalid)
+	}
+	for _, p := range zone.Points {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return fmt.Errorf("%w: point coordinates must be between 0 and 1", ErrInvalid)
+		}
+	}
+	if zone.ThresholdPercent < 0 || zone.ThresholdPercent > 100 {
+		return fmt.Errorf("%w: threshold_percent must be between 0 and 100", ErrInvalid)
+	}
+	return nil
+}
+
+func clamp(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
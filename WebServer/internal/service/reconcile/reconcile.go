@@ -0,0 +1,339 @@
+package reconcile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/storage"
+)
+
+const (
+	// QuarantineDir is the key prefix files without an image row are moved below.
+	QuarantineDir = "quarantine"
+	// Grace is how old a file without an image row has to be before it is quarantined,
+	// so images written to the store but not yet recorded are left alone.
+	Grace = 10 * time.Minute
+	// rowBatch is the number of image rows loaded at once.
+	rowBatch = 100
+	// maxItems is the number of problems listed in a report.
+	maxItems = 200
+)
+
+// Actions taken for the problems found by reconciliation.
+const (
+	ActionRelinked    = "relinked"
+	ActionFlagged     = "flagged"
+	ActionQuarantined = "quarantined"
+	ActionFailed      = "failed"
+)
+
+// ErrTooManyMissing is returned by a repairing pass when a store lists no files, or too
+// many of its images have none, which points at an unmounted or misconfigured store
+// rather than lost files. Nothing is changed then.
+var ErrTooManyMissing = errors.New("too many images without a file")
+
+// ReconcileService compares the files of the blob stores with the image rows of the
+// database. Rows whose file lies under another key, for example after an interrupted
+// layout migration, are pointed to it; rows whose file is gone are flagged as missing
+// but kept; files without a row are moved to quarantine/ in their store, where they
+// can be inspected. Stores that cannot list their files are skipped. Scheduled passes
+// only report the problems unless RECONCILE_REPAIR is set.
+type ReconcileService struct {
+	stores     *blob.Stores
+	imageRepo  repository.ImageRepository
+	interval   time.Duration // 0 reconciles only on request
+	repair     bool          // scheduled passes fix the problems found
+	maxMissing float64       // percent of a store's images allowed to lack a file when repairing
+	mu         sync.Mutex    // one pass at a time
+	last       *dto.ReconcileReport
+	logger     *logger.Logger
+}
+
+// fix is a problem found in a store together with the change that fixes it.
+type fix struct {
+	item  dto.ReconcileItem
+	apply func() error
+}
+
+// storePlan collects what a pass found in one store before anything is changed.
+type storePlan struct {
+	store   blob.BlobStore
+	files   int
+	rows    int
+	missing []int64 // IDs of the images without a file
+	fixes   []fix
+}
+
+// NewReconcileService creates a ReconcileService running every RECONCILE_INTERVAL
+// seconds. Without a database nothing is reconciled.
+func NewReconcileService(config *config.Config, logger *logger.Logger, stores *blob.Stores,
+	imageRepo repository.ImageRepository) *ReconcileService {
+	return &ReconcileService{
+		stores:     stores,
+		imageRepo:  imageRepo,
+		interval:   time.Duration(config.ReconcileIntervalS) * time.Second,
+		repair:     config.ReconcileRepair,
+		maxMissing: config.ReconcileMaxMissing,
+		logger:     logger,
+	}
+}
+
+// Run reconciles every interval, starting one interval after startup so images
+// spooled before a restart are stored first. It returns at once when the interval is
+// not positive.
+func (s *ReconcileService) Run() {
+	if s.interval <= 0 || s.imageRepo == nil {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		if _, err := s.Reconcile(!s.repair, time.Now()); err != nil {
+			s.logger.Error("Failed to reconcile stored images: %v", err)
+		}
+	}
+}
+
+// LastReport returns the report of the last pass, or nil before the first one.
+func (s *ReconcileService) LastReport() *dto.ReconcileReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.last
+}
+
+// Reconcile checks every store that can list its files against the database at now
+// and fixes the problems found. With dryRun set the problems are only reported.
+// Otherwise every store is checked before anything is changed, and the pass stops
+// with ErrTooManyMissing when a store looks unavailable.
+func (s *ReconcileService) Reconcile(dryRun bool, now time.Time) (*dto.ReconcileReport, error) {
+	if s.imageRepo == nil {
+		return nil, fmt.Errorf("reconciliation needs the database")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	report := &dto.ReconcileReport{StartedAt: now, DryRun: dryRun, Items: []dto.ReconcileItem{}}
+	var plans []*storePlan
+	for _, store := range s.stores.All() {
+		lister, ok := store.(blob.Lister)
+		if !ok {
+			s.logger.Warning("⚠️  %s storage cannot list its files, not reconciling it", store.Backend())
+			continue
+		}
+		plan, err := s.checkStore(store, lister, now, report)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.checkMissing(plan); err != nil {
+			if !dryRun {
+				return nil, err
+			}
+			s.logger.Warning("⚠️  %v", err)
+		}
+		plans = append(plans, plan)
+	}
+
+	var missing []int64
+	for _, plan := range plans {
+		for _, f := range plan.fixes {
+			s.apply(f, dryRun, report)
+		}
+		missing = append(missing, plan.missing...)
+	}
+	if !dryRun {
+		if err := s.imageRepo.SetMissing(missing); err != nil {
+			return nil, err
+		}
+	}
+
+	mode := ""
+	if dryRun {
+		mode = " (dry run)"
+	}
+	s.logger.Info("🔎 Reconciled %d files with %d images%s: %d relinked, %d flagged as missing, %d quarantined, %d failed",
+		report.Files, report.Rows, mode, report.Relinked, report.Flagged, report.Quarantined, report.Failed)
+	s.last = report
+	return report, nil
+}
+
+// checkStore compares the files of one store with the image rows recorded for it and
+// returns the fixes for the problems found, without changing anything.
+func (s *ReconcileService) checkStore(store blob.BlobStore, lister blob.Lister, now time.Time,
+	report *dto.ReconcileReport) (*storePlan, error) {
+	files := make(map[string]blob.Info)
+	err := lister.List("", func(key string, info blob.Info) error {
+		// Skip already quarantined files and hidden files such as .DS_Store
+		if !strings.HasPrefix(key, QuarantineDir+"/") && !strings.HasPrefix(path.Base(key), ".") {
+			files[key] = info
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s storage: %w", store.Backend(), err)
+	}
+	report.Files += len(files)
+
+	plan := &storePlan{store: store, files: len(files)}
+	seen := make(map[string]bool, len(files))
+	var afterID int64
+	for {
+		images, err := s.imageRepo.GetBatch(afterID, rowBatch)
+		if err != nil {
+			return nil, err
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for _, img := range images {
+			afterID = img.ID
+			if recorded, exists := s.stores.Get(img.Storage); !exists || recorded != store {
+				continue
+			}
+			report.Rows++
+			plan.rows++
+			s.checkImage(plan, img, files, seen, report)
+		}
+	}
+
+	orphans := make([]string, 0)
+	for key := range files {
+		if !seen[key] {
+			orphans = append(orphans, key)
+		}
+	}
+	sort.Strings(orphans)
+	for _, key := range orphans {
+		if now.Sub(files[key].ModTime) < Grace {
+			report.Skipped++
+			continue
+		}
+		key := key
+		plan.fixes = append(plan.fixes, fix{
+			item: dto.ReconcileItem{Action: ActionQuarantined, Storage: store.Backend(), Key: key, Reason: "no image row"},
+			apply: func() error {
+				return blob.Move(store, key, path.Join(QuarantineDir, key))
+			},
+		})
+	}
+	return plan, nil
+}
+
+// checkImage looks for the file of an image row and marks it and its variants as seen.
+// Rows are pointed to a file found under the layout or flat key of their filename, and
+// flagged as missing when there is none.
+func (s *ReconcileService) checkImage(plan *storePlan, img model.Image, files map[string]blob.Info,
+	seen map[string]bool, report *dto.ReconcileReport) {
+	store := plan.store
+	key := storage.KeyOf(store, img)
+	if _, exists := files[key]; exists {
+		markSeen(seen, key)
+		return
+	}
+
+	for _, candidate := range []string{storage.ImageKey(img.Camera, img.Timestamp, img.Filename), img.Filename} {
+		if _, exists := files[candidate]; !exists || candidate == key {
+			continue
+		}
+		markSeen(seen, candidate)
+		plan.fixes = append(plan.fixes, fix{
+			item: dto.ReconcileItem{Action: ActionRelinked, Storage: store.Backend(), Key: candidate, Filename: img.Filename,
+				Reason: "recorded under " + key},
+			apply: func() error {
+				return s.imageRepo.UpdateFilePath(img.ID, store.Location(candidate))
+			},
+		})
+		return
+	}
+
+	// The file may have been written after the store was listed
+	markSeen(seen, key)
+	_, _, err := store.Get(key)
+	if err == nil {
+		return
+	}
+	item := dto.ReconcileItem{Action: ActionFlagged, Storage: store.Backend(), Key: key, Filename: img.Filename,
+		Reason: "file is missing"}
+	if !errors.Is(err, fs.ErrNotExist) {
+		s.fail(report, item, err)
+		return
+	}
+	plan.missing = append(plan.missing, img.ID)
+	plan.fixes = append(plan.fixes, fix{item: item})
+}
+
+// checkMissing returns ErrTooManyMissing when a store lists no files although images
+// are recorded for it, or more than the allowed percentage of them have no file.
+func (s *ReconcileService) checkMissing(plan *storePlan) error {
+	if plan.rows == 0 {
+		return nil
+	}
+	if plan.files == 0 {
+		return fmt.Errorf("%w: %s storage lists no files but has %d images", ErrTooManyMissing,
+			plan.store.Backend(), plan.rows)
+	}
+	if percent := 100 * float64(len(plan.missing)) / float64(plan.rows); percent > s.maxMissing {
+		return fmt.Errorf("%w: %d of %d images in %s storage (%.0f%%, at most %g%% allowed)", ErrTooManyMissing,
+			len(plan.missing), plan.rows, plan.store.Backend(), percent, s.maxMissing)
+	}
+	return nil
+}
+
+// apply makes the change of a fix, unless reporting a dry run, and records it.
+func (s *ReconcileService) apply(f fix, dryRun bool, report *dto.ReconcileReport) {
+	if !dryRun && f.apply != nil {
+		if err := f.apply(); err != nil {
+			s.fail(report, f.item, err)
+			return
+		}
+	}
+	switch f.item.Action {
+	case ActionRelinked:
+		report.Relinked++
+	case ActionFlagged:
+		report.Flagged++
+	case ActionQuarantined:
+		report.Quarantined++
+	}
+	addItem(report, f.item)
+}
+
+// fail records a problem that could not be fixed.
+func (s *ReconcileService) fail(report *dto.ReconcileReport, item dto.ReconcileItem, err error) {
+	s.logger.Error("Failed to reconcile %s in %s storage (%s): %v", item.Key, item.Storage, item.Reason, err)
+	item.Action = ActionFailed
+	item.Reason += ": " + err.Error()
+	report.Failed++
+	addItem(report, item)
+}
+
+// markSeen marks the file of an image and its variants as belonging to a row.
+func markSeen(seen map[string]bool, key string) {
+	seen[key] = true
+	for _, size := range storage.VariantSizes {
+		seen[storage.VariantPath(size, key)] = true
+	}
+}
+
+// addItem lists a problem in the report, up to maxItems.
+func addItem(report *dto.ReconcileReport, item dto.ReconcileItem) {
+	if len(report.Items) < maxItems {
+		report.Items = append(report.Items, item)
+	}
+}
@@ -0,0 +1,288 @@
+package recording
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+const (
+	// aviHeaderSize covers the RIFF header, the hdrl list and the movi list header.
+	aviHeaderSize = 224
+	// moviOffset is the file position of the "movi" fourcc; idx1 offsets are relative to it.
+	moviOffset = 220
+	// widthOffset is the file position of dwWidth in the main AVI header.
+	widthOffset = 64
+
+	avifHasIndex  = 0x10
+	aviifKeyframe = 0x10
+
+	// defaultFrameInterval is written to the header when the frame rate is unknown.
+	defaultFrameInterval = 100 * time.Millisecond
+)
+
+// ErrNotAVI is returned when recovering a file that does not start with an AVI header.
+var ErrNotAVI = errors.New("not an AVI file")
+
+// aviIndexEntry locates one frame chunk inside the movi list.
+type aviIndexEntry struct {
+	offset uint32
+	size   uint32
+}
+
+// AVIWriter writes JPEG frames into a Motion-JPEG AVI file. Frame count, frame rate
+// and the index are only known at the end, so the header is rewritten on Close.
+type AVIWriter struct {
+	file         *os.File
+	buf          *bufio.Writer
+	width        int
+	height       int
+	index        []aviIndexEntry
+	moviSize     uint32
+	indexSize    uint32
+	maxFrameSize uint32
+}
+
+// CreateAVI creates an AVI file for frames of the given dimensions.
+func CreateAVI(path string, width, height int) (*AVIWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AVI file: %w", err)
+	}
+
+	w := &AVIWriter{
+		file:     file,
+		buf:      bufio.NewWriterSize(file, 64*1024),
+		width:    width,
+		height:   height,
+		moviSize: 4,
+	}
+	if _, err := w.buf.Write(w.header(0)); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to write AVI header: %w", err)
+	}
+	return w, nil
+}
+
+// RecoverAVI reopens a file that was not closed properly (e.g. after a crash), dropping
+// a truncated trailing frame and any old index so it can be finalized with Close.
+func RecoverAVI(path string) (*AVIWriter, error) {
+	file, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open AVI file: %w", err)
+	}
+
+	header := make([]byte, aviHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil || string(header[0:4]) != "RIFF" || string(header[8:12]) != "AVI " {
+		file.Close()
+		return nil, ErrNotAVI
+	}
+
+	w := &AVIWriter{
+		file:     file,
+		width:    int(binary.LittleEndian.Uint32(header[widthOffset:])),
+		height:   int(binary.LittleEndian.Uint32(header[widthOffset+4:])),
+		moviSize: 4,
+	}
+
+	reader := bufio.NewReader(file)
+	chunk := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, chunk); err != nil || string(chunk[0:4]) != "00dc" {
+			break
+		}
+		size := binary.LittleEndian.Uint32(chunk[4:])
+		padded := int64(size + size%2)
+		if n, err := io.CopyN(io.Discard, reader, padded); err != nil || n != padded {
+			break
+		}
+		w.index = append(w.index, aviIndexEntry{offset: w.moviSize, size: size})
+		w.moviSize += 8 + uint32(padded)
+		if size > w.maxFrameSize {
+			w.maxFrameSize = size
+		}
+	}
+
+	end := int64(moviOffset) + int64(w.moviSize)
+	if err := file.Truncate(end); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to truncate AVI file: %w", err)
+	}
+	if _, err := file.Seek(end, io.SeekStart); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to seek AVI file: %w", err)
+	}
+	w.buf = bufio.NewWriterSize(file, 64*1024)
+	return w, nil
+}
+
+// WriteFrame appends one JPEG frame.
+func (w *AVIWriter) WriteFrame(jpeg []byte) error {
+	size := uint32(len(jpeg))
+	chunk := make([]byte, 8)
+	copy(chunk, "00dc")
+	binary.LittleEndian.PutUint32(chunk[4:], size)
+
+	if _, err := w.buf.Write(chunk); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	if _, err := w.buf.Write(jpeg); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	if size%2 == 1 {
+		if err := w.buf.WriteByte(0); err != nil {
+			return fmt.Errorf("failed to write frame: %w", err)
+		}
+	}
+
+	w.index = append(w.index, aviIndexEntry{offset: w.moviSize, size: size})
+	w.moviSize += 8 + size + size%2
+	if size > w.maxFrameSize {
+		w.maxFrameSize = size
+	}
+	return nil
+}
+
+// Frames returns the number of frames written so far.
+func (w *AVIWriter) Frames() int {
+	return len(w.index)
+}
+
+// Size returns the current file size in bytes, including buffered data.
+func (w *AVIWriter) Size() int64 {
+	return int64(moviOffset) + int64(w.moviSize) + int64(w.indexSize)
+}
+
+// Width returns the frame width stored in the header.
+func (w *AVIWriter) Width() int {
+	return w.width
+}
+
+// Height returns the frame height stored in the header.
+func (w *AVIWriter) Height() int {
+	return w.height
+}
+
+// Close writes the index, rewrites the header with the final frame count and a frame
+// rate derived from span (time between the first and last frame) and closes the file.
+func (w *AVIWriter) Close(span time.Duration) error {
+	defer w.file.Close()
+
+	idx := make([]byte, 8+16*len(w.index))
+	copy(idx, "idx1")
+	binary.LittleEndian.PutUint32(idx[4:], uint32(16*len(w.index)))
+	for i, entry := range w.index {
+		e := idx[8+16*i:]
+		copy(e, "00dc")
+		binary.LittleEndian.PutUint32(e[4:], aviifKeyframe)
+		binary.LittleEndian.PutUint32(e[8:], entry.offset)
+		binary.LittleEndian.PutUint32(e[12:], entry.size)
+	}
+	if _, err := w.buf.Write(idx); err != nil {
+		return fmt.Errorf("failed to write AVI index: %w", err)
+	}
+	if err := w.buf.Flush(); err != nil {
+		return fmt.Errorf("failed to flush AVI file: %w", err)
+	}
+	w.indexSize = uint32(len(idx))
+
+	if _, err := w.file.WriteAt(w.header(span), 0); err != nil {
+		return fmt.Errorf("failed to rewrite AVI header: %w", err)
+	}
+	return w.file.Sync()
+}
+
+// header builds the RIFF, hdrl and movi list headers for the current state.
+func (w *AVIWriter) header(span time.Duration) []byte {
+	frames := uint32(len(w.index))
+	interval := defaultFrameInterval
+	if frames > 1 && span > 0 {
+		interval = span / time.Duration(frames-1)
+	}
+	usPerFrame := uint32(interval.Microseconds())
+	if usPerFrame == 0 {
+		usPerFrame = 1
+	}
+	maxBytesPerSec := uint32(uint64(w.maxFrameSize) * 1000000 / uint64(usPerFrame))
+
+	h := make([]byte, 0, aviHeaderSize)
+	fourcc := func(s string) { h = append(h, s...) }
+	u32 := func(v uint32) { h = binary.LittleEndian.AppendUint32(h, v) }
+	u16 := func(v uint16) { h = binary.LittleEndian.AppendUint16(h, v) }
+
+	fourcc("RIFF")
+	u32(aviHeaderSize - 8 + w.moviSize - 4 + w.indexSize)
+	fourcc("AVI ")
+
+	fourcc("LIST")
+	u32(192)
+	fourcc("hdrl")
+
+	// Main AVI header
+	fourcc("avih")
+	u32(56)
+	u32(usPerFrame)
+	u32(maxBytesPerSec)
+	u32(0) // padding granularity
+	u32(avifHasIndex)
+	u32(frames)
+	u32(0) // initial frames
+	u32(1) // streams
+	u32(w.maxFrameSize)
+	u32(uint32(w.width))
+	u32(uint32(w.height))
+	u32(0)
+	u32(0)
+	u32(0)
+	u32(0)
+
+	fourcc("LIST")
+	u32(116)
+	fourcc("strl")
+
+	// Stream header: scale/rate gives the frame duration in microseconds
+	fourcc("strh")
+	u32(56)
+	fourcc("vids")
+	fourcc("MJPG")
+	u32(0) // flags
+	u16(0) // priority
+	u16(0) // language
+	u32(0) // initial frames
+	u32(usPerFrame)
+	u32(1000000)
+	u32(0) // start
+	u32(frames)
+	u32(w.maxFrameSize)
+	u32(0xFFFFFFFF) // default quality
+	u32(0)          // sample size
+	u16(0)
+	u16(0)
+	u16(uint16(w.width))
+	u16(uint16(w.height))
+
+	// Stream format (BITMAPINFOHEADER)
+	fourcc("strf")
+	u32(40)
+	u32(40)
+	u32(uint32(w.width))
+	u32(uint32(w.height))
+	u16(1)  // planes
+	u16(24) // bit count
+	fourcc("MJPG")
+	u32(uint32(w.width * w.height * 3))
+	u32(0)
+	u32(0)
+	u32(0)
+	u32(0)
+
+	fourcc("LIST")
+	u32(w.moviSize)
+	fourcc("movi")
+
+	return h
+}
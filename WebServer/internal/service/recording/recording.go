@@ -0,0 +1,477 @@
+package recording
+
+import (
+	"bytes"
+	"fmt"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+)
+
+const (
+	// AllCameras in RECORD_CAMERAS enables recording for every camera.
+	AllCameras = "all"
+	// CheckInterval defines how often open segments are checked for rotation.
+	CheckInterval = time.Second
+	// IdleTimeout closes a segment when its camera sent no frames for this long.
+	IdleTimeout = 30 * time.Second
+	// QueueSize is how many frames of a camera may wait to be written before new ones are dropped.
+	QueueSize = 64
+	// PruneInterval defines how often old segments are deleted.
+	PruneInterval = 10 * time.Minute
+	// pruneBatch is the number of segments loaded at once when deleting.
+	pruneBatch = 100
+	// bytesPerGB converts the configured quota to bytes.
+	bytesPerGB = 1 << 30
+)
+
+// segment is a recording file currently being written.
+type segment struct {
+	recording model.Recording
+	writer    *AVIWriter
+	lastFrame time.Time
+}
+
+// queuedFrame is a frame waiting to be written, or a flush marker when done is set.
+type queuedFrame struct {
+	data []byte
+	at   time.Time
+	done chan struct{}
+}
+
+// recorder writes the frames of one camera from its own goroutine, so a slow disk
+// only delays that camera's recording and never the frame intake.
+type recorder struct {
+	camera  string
+	frames  chan queuedFrame
+	segment *segment
+	mu      sync.Mutex // guards segment
+}
+
+// RecordingService continuously records frames of selected cameras into
+// time-segmented MJPEG AVI files and indexes them in the database. Segments older
+// than RECORDING_RETENTION_DAYS, and the oldest ones beyond RECORDING_QUOTA_GB,
+// are deleted.
+type RecordingService struct {
+	recordingsDir   string
+	segmentDuration time.Duration
+	maxAge          time.Duration // 0 keeps segments forever
+	quota           int64         // bytes, 0 for no limit
+	cameras         map[string]bool
+	allCameras      bool
+	recorders       map[string]*recorder
+	mu              sync.Mutex // guards cameras and recorders
+	recordingRepo   repository.RecordingRepository
+	logger          *logger.Logger
+}
+
+// NewRecordingService creates a RecordingService for the cameras listed in RECORD_CAMERAS
+// and finalizes segments left open by a previous run.
+func NewRecordingService(config *config.Config, logger *logger.Logger, recordingRepo repository.RecordingRepository) *RecordingService {
+	segmentDuration := time.Duration(config.RecordingSegmentS) * time.Second
+	if segmentDuration <= 0 {
+		segmentDuration = 5 * time.Minute
+	}
+
+	service := &RecordingService{
+		recordingsDir:   config.RecordingDirectory,
+		segmentDuration: segmentDuration,
+		quota:           int64(config.RecordingQuotaGB * bytesPerGB),
+		cameras:         make(map[string]bool),
+		recorders:       make(map[string]*recorder),
+		recordingRepo:   recordingRepo,
+		logger:          logger,
+	}
+	for _, camera := range config.RecordCameras {
+		if strings.EqualFold(camera, AllCameras) {
+			service.allCameras = true
+		}
+		service.cameras[camera] = true
+	}
+	if config.RecordingMaxDays > 0 {
+		service.maxAge = time.Duration(config.RecordingMaxDays) * 24 * time.Hour
+	}
+
+	service.recoverSegments()
+	return service
+}
+
+// Run periodically closes segments that reached the segment duration or went idle,
+// and deletes segments beyond the retention limits.
+func (s *RecordingService) Run() {
+	s.Prune(time.Now())
+
+	ticker := time.NewTicker(CheckInterval)
+	pruneTicker := time.NewTicker(PruneInterval)
+
+	defer ticker.Stop()
+	defer pruneTicker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Rotate(time.Now())
+		case <-pruneTicker.C:
+			s.Prune(time.Now())
+		}
+	}
+}
+
+// IsEnabled reports whether continuous recording is enabled for the camera.
+func (s *RecordingService) IsEnabled(camera string) bool {
+	if s.allCameras {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cameras[camera]
+}
+
+// RenameCamera moves the RECORD_CAMERAS entry and the recorder of a renamed camera. Its
+// open segment is closed, so the next one starts in the directory of the new name. The
+// database rows are renamed together with the camera.
+func (s *RecordingService) RenameCamera(oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+
+	s.mu.Lock()
+	config.RenameCameraKey(s.cameras, oldName, newName)
+	r, exists := s.recorders[oldName]
+	// A recorder started for frames that arrived under the new name keeps its place
+	_, started := s.recorders[newName]
+	if exists && !started {
+		delete(s.recorders, oldName)
+		s.recorders[newName] = r
+	}
+	s.mu.Unlock()
+
+	if !exists {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.segment != nil {
+		s.finalize(r)
+	}
+	if !started {
+		r.camera = newName
+	}
+}
+
+// WriteFrame queues a JPEG frame for the camera's current segment without waiting for
+// the disk. Frames are dropped while the camera's queue is full.
+func (s *RecordingService) WriteFrame(camera string, frame []byte) {
+	if !s.IsEnabled(camera) {
+		return
+	}
+
+	select {
+	case s.getRecorder(camera).frames <- queuedFrame{data: frame, at: time.Now()}:
+	default:
+		s.logger.Warning("⚠️  Recording queue full for camera %s - frame dropped", camera)
+	}
+}
+
+// Flush waits until the frames queued so far have been written.
+func (s *RecordingService) Flush() {
+	for _, r := range s.getRecorders() {
+		done := make(chan struct{})
+		r.frames <- queuedFrame{done: done}
+		<-done
+	}
+}
+
+// Rotate closes segments that are older than the segment duration or received
+// no frames within IdleTimeout.
+func (s *RecordingService) Rotate(now time.Time) {
+	for _, r := range s.getRecorders() {
+		r.mu.Lock()
+		if seg := r.segment; seg != nil &&
+			(now.Sub(seg.recording.StartTime) >= s.segmentDuration || now.Sub(seg.lastFrame) >= IdleTimeout) {
+			s.finalize(r)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Stop writes the queued frames and finalizes all open segments.
+func (s *RecordingService) Stop() {
+	s.Flush()
+	for _, r := range s.getRecorders() {
+		r.mu.Lock()
+		if r.segment != nil {
+			s.finalize(r)
+		}
+		r.mu.Unlock()
+	}
+}
+
+// Prune deletes finished segments older than the maximum age, then the oldest ones
+// while the segments exceed the quota, and returns how many were deleted.
+func (s *RecordingService) Prune(now time.Time) int {
+	if s.recordingRepo == nil {
+		return 0
+	}
+
+	removed := 0
+	if s.maxAge > 0 {
+		reason := fmt.Sprintf("older than %g days", s.maxAge.Hours()/24)
+		removed += s.prune(now.Add(-s.maxAge), -1, reason)
+	}
+
+	if s.quota > 0 {
+		used, err := s.recordingRepo.GetTotalSize()
+		if err != nil {
+			s.logger.Error("Failed to get recording usage: %v", err)
+			return removed
+		}
+		if used > s.quota {
+			reason := fmt.Sprintf("over the quota of %.2f GB", float64(s.quota)/bytesPerGB)
+			removed += s.prune(time.Time{}, used-s.quota, reason)
+		}
+	}
+	return removed
+}
+
+// prune deletes finished segments that ended before the given time, oldest first, until
+// excess bytes were freed, or every such segment when excess is negative.
+func (s *RecordingService) prune(before time.Time, excess int64, reason string) int {
+	count, freed := 0, int64(0)
+
+	for excess < 0 || freed < excess {
+		recordings, err := s.recordingRepo.GetOldest(before, pruneBatch)
+		if err != nil {
+			s.logger.Error("Failed to list recordings for retention: %v", err)
+			break
+		}
+
+		deleted := false
+		for _, rec := range recordings {
+			if excess >= 0 && freed >= excess {
+				break
+			}
+			if err := os.Remove(rec.FilePath); err != nil && !os.IsNotExist(err) {
+				s.logger.Error("Failed to delete recording %s: %v", rec.Filename, err)
+				continue
+			}
+			if err := s.recordingRepo.Delete(rec.ID); err != nil {
+				s.logger.Error("Failed to delete recording %s from database: %v", rec.Filename, err)
+				continue
+			}
+			deleted = true
+			count++
+			freed += rec.FileSize
+		}
+		if !deleted {
+			break
+		}
+	}
+
+	if count > 0 {
+		s.logger.Info("🧹 Removed %d recording segment(s), %.1f MB: %s", count, float64(freed)/(1<<20), reason)
+	}
+	return count
+}
+
+// getRecorder returns the camera's recorder, starting its writer goroutine when absent.
+func (s *RecordingService) getRecorder(camera string) *recorder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, exists := s.recorders[camera]
+	if !exists {
+		r = &recorder{camera: camera, frames: make(chan queuedFrame, QueueSize)}
+		s.recorders[camera] = r
+		go s.writeLoop(r)
+	}
+	return r
+}
+
+// getRecorders returns a snapshot of all recorders.
+func (s *RecordingService) getRecorders() []*recorder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	recorders := make([]*recorder, 0, len(s.recorders))
+	for _, r := range s.recorders {
+		recorders = append(recorders, r)
+	}
+	return recorders
+}
+
+// writeLoop writes the queued frames of a camera.
+func (s *RecordingService) writeLoop(r *recorder) {
+	for frame := range r.frames {
+		if frame.done != nil {
+			close(frame.done)
+			continue
+		}
+		s.write(r, frame.data, frame.at)
+	}
+}
+
+// write appends a frame to the camera's current segment, starting a new segment when
+// none is open, the current one is full or the resolution changed.
+func (s *RecordingService) write(r *recorder, frame []byte, now time.Time) {
+	cfg, err := jpeg.DecodeConfig(bytes.NewReader(frame))
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	seg := r.segment
+	if seg != nil && (now.Sub(seg.recording.StartTime) >= s.segmentDuration ||
+		seg.writer.Width() != cfg.Width || seg.writer.Height() != cfg.Height) {
+		s.finalize(r)
+		seg = nil
+	}
+	if seg == nil {
+		if seg, err = s.open(r, now, cfg.Width, cfg.Height); err != nil {
+			s.logger.Error("Error starting recording for camera %s: %v", r.camera, err)
+			return
+		}
+	}
+
+	if err := seg.writer.WriteFrame(frame); err != nil {
+		s.logger.Error("Error recording frame for camera %s: %v", r.camera, err)
+		s.finalize(r)
+		return
+	}
+	seg.lastFrame = now
+}
+
+// open starts a new segment file for the camera. Caller must hold r.mu.
+func (s *RecordingService) open(r *recorder, now time.Time, width, height int) (*segment, error) {
+	camera := r.camera
+	dir := filepath.Join(s.recordingsDir, camera)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create recording directory: %w", err)
+	}
+
+	// A segment can start in the same millisecond as the previous one on a resolution change
+	stamp := now.Format("2006-01-02_15-04-05.000")
+	filename := fmt.Sprintf("%s_%s.avi", camera, stamp)
+	fullpath := filepath.Join(dir, filename)
+	for i := 1; fileExists(fullpath); i++ {
+		filename = fmt.Sprintf("%s_%s-%d.avi", camera, stamp, i)
+		fullpath = filepath.Join(dir, filename)
+	}
+	writer, err := CreateAVI(fullpath, width, height)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{
+		recording: model.Recording{
+			Camera:    camera,
+			Filename:  filename,
+			FilePath:  fullpath,
+			StartTime: now,
+			EndTime:   now,
+			Width:     width,
+			Height:    height,
+		},
+		writer:    writer,
+		lastFrame: now,
+	}
+
+	if s.recordingRepo != nil {
+		id, err := s.recordingRepo.Insert(&seg.recording)
+		if err != nil {
+			s.logger.Error("Error saving recording %s to database: %v", filename, err)
+		}
+		seg.recording.ID = id
+	}
+
+	r.segment = seg
+	s.logger.Info("🎥 Camera %s: recording segment %s started", camera, filename)
+	return seg, nil
+}
+
+// finalize closes the camera's segment file and stores its final state. Caller must hold r.mu.
+func (s *RecordingService) finalize(r *recorder) {
+	seg := r.segment
+	r.segment = nil
+
+	rec := &seg.recording
+	if err := seg.writer.Close(seg.lastFrame.Sub(rec.StartTime)); err != nil {
+		s.logger.Error("Error closing recording %s: %v", rec.Filename, err)
+	}
+	rec.EndTime = seg.lastFrame
+	rec.Frames = seg.writer.Frames()
+	rec.FileSize = seg.writer.Size()
+	rec.Complete = true
+
+	s.save(rec)
+	s.logger.Info("🎥 Camera %s: recording segment %s closed (%d frames)", rec.Camera, rec.Filename, rec.Frames)
+}
+
+// recoverSegments finalizes segments that were still open when the server stopped,
+// using the file modification time as the end of the segment.
+func (s *RecordingService) recoverSegments() {
+	if s.recordingRepo == nil {
+		return
+	}
+
+	recordings, err := s.recordingRepo.GetIncomplete()
+	if err != nil {
+		s.logger.Error("Error loading unfinished recordings: %v", err)
+		return
+	}
+
+	for i := range recordings {
+		rec := &recordings[i]
+		rec.Complete = true
+
+		info, err := os.Stat(rec.FilePath)
+		if err != nil {
+			s.logger.Warning("⚠️  Recording %s is missing: %v", rec.Filename, err)
+			s.save(rec)
+			continue
+		}
+
+		writer, err := RecoverAVI(rec.FilePath)
+		if err != nil {
+			s.logger.Error("Error recovering recording %s: %v", rec.Filename, err)
+			s.save(rec)
+			continue
+		}
+		if info.ModTime().After(rec.StartTime) {
+			rec.EndTime = info.ModTime()
+		}
+		if err := writer.Close(rec.EndTime.Sub(rec.StartTime)); err != nil {
+			s.logger.Error("Error closing recovered recording %s: %v", rec.Filename, err)
+		}
+		rec.Frames = writer.Frames()
+		rec.FileSize = writer.Size()
+
+		s.save(rec)
+		s.logger.Info("🎥 Recovered recording %s (%d frames)", rec.Filename, rec.Frames)
+	}
+}
+
+// save writes the segment state to the database, if one is configured.
+func (s *RecordingService) save(rec *model.Recording) {
+	if s.recordingRepo == nil || rec.ID == 0 {
+		return
+	}
+	if err := s.recordingRepo.Update(rec); err != nil {
+		s.logger.Error("Error updating recording %s in database: %v", rec.Filename, err)
+	}
+}
+
+// fileExists reports whether a file exists at the given path.
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
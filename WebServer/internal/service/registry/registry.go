@@ -0,0 +1,456 @@
+package registry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+)
+
+const (
+	// MaxDeviceID is the largest camera ID that fits in the datagram header.
+	MaxDeviceID = 65535
+	// SecretBytes is the length of generated camera secrets before hex encoding.
+	SecretBytes = 16
+	// MaxNameLength bounds camera names, which become path segments of stored files.
+	MaxNameLength = 32
+)
+
+// reservedNames are top-level directories of the image stores that cameras must not shadow.
+var reservedNames = []string{"thumb", "medium", "quarantine"}
+
+var (
+	// ErrNotFound is returned when a camera does not exist in the registry.
+	ErrNotFound = errors.New("camera not found")
+	// ErrReadOnly is returned for changes when no database is available.
+	ErrReadOnly = errors.New("camera registry is read-only without a database")
+	// ErrConflict is returned when a name, device ID or IP address is already taken.
+	ErrConflict = errors.New("camera name, device id or ip address already in use")
+	// ErrInvalid is returned for camera definitions that fail validation.
+	ErrInvalid = errors.New("invalid camera")
+)
+
+// RegistryService keeps the camera registry in memory for the UDP hot path and
+// writes changes through to the database.
+type RegistryService struct {
+	cameraRepo repository.CameraRepository
+	byID       map[int64]model.Camera
+	byName     map[string]int64
+	byDevice   map[int]int64
+	byIP       map[string]int64
+	nextMemID  int64
+	mu         sync.RWMutex
+	logger     *logger.Logger
+}
+
+// NewRegistryService loads cameras from the repository. When the registry is empty,
+// it is seeded from CAMERA_TOKENS and CAMERAS so existing deployments keep working.
+// It fails when a seeded camera has a name that cannot be registered, since frames of
+// a camera missing from the registry would be dropped.
+func NewRegistryService(config *config.Config, logger *logger.Logger, cameraRepo repository.CameraRepository) (*RegistryService, error) {
+	service := &RegistryService{
+		cameraRepo: cameraRepo,
+		logger:     logger,
+	}
+
+	cameras, err := service.loadCameras()
+	if err != nil {
+		service.logger.Error("Failed to load camera registry: %v", err)
+	}
+
+	if len(cameras) == 0 {
+		seeds := seedCameras(config)
+		for _, cam := range seeds {
+			if err := validName(cam.Name); err != nil {
+				return nil, fmt.Errorf("camera %q from CAMERAS or CAMERA_TOKENS: %w", cam.Name, err)
+			}
+		}
+		for _, cam := range seeds {
+			if service.cameraRepo != nil {
+				id, err := service.cameraRepo.Insert(&cam)
+				if err != nil {
+					service.logger.Error("Failed to seed camera %s: %v", cam.Name, err)
+					continue
+				}
+				cam.ID = id
+			} else {
+				service.nextMemID++
+				cam.ID = service.nextMemID
+			}
+			cameras = append(cameras, cam)
+			service.logger.Info("📷 Registered camera %s from environment", cam.Name)
+		}
+	}
+
+	for _, cam := range cameras {
+		if err := validName(cam.Name); err != nil {
+			service.logger.Warning("Camera %s has an unsafe name, rename it: %v", cam.Name, err)
+		}
+	}
+
+	service.rebuild(cameras)
+	service.logger.Info("📷 Camera registry loaded with %d camera(s)", len(cameras))
+	return service, nil
+}
+
+// GetAll returns all registered cameras sorted by name.
+func (s *RegistryService) GetAll() []model.Camera {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cameras := make([]model.Camera, 0, len(s.byID))
+	for _, cam := range s.byID {
+		cameras = append(cameras, cam)
+	}
+	sort.Slice(cameras, func(i, j int) bool { return cameras[i].Name < cameras[j].Name })
+	return cameras
+}
+
+// GetNames returns the names of all registered cameras sorted alphabetically.
+func (s *RegistryService) GetNames() []string {
+	cameras := s.GetAll()
+	names := make([]string, 0, len(cameras))
+	for _, cam := range cameras {
+		names = append(names, cam.Name)
+	}
+	return names
+}
+
+// GetByID returns the camera with the given registry ID.
+func (s *RegistryService) GetByID(id int64) (model.Camera, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cam, exists := s.byID[id]
+	return cam, exists
+}
+
+// GetByName returns the camera with the given name.
+func (s *RegistryService) GetByName(name string) (model.Camera, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.byName[name]
+	if !exists {
+		return model.Camera{}, false
+	}
+	return s.byID[id], true
+}
+
+// GetByDeviceID returns the camera that uses the given datagram header ID.
+func (s *RegistryService) GetByDeviceID(deviceID uint16) (model.Camera, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.byDevice[int(deviceID)]
+	if !exists {
+		return model.Camera{}, false
+	}
+	return s.byID[id], true
+}
+
+// GetByIP returns the camera mapped to the given source address.
+func (s *RegistryService) GetByIP(ip string) (model.Camera, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	id, exists := s.byIP[ip]
+	if !exists {
+		return model.Camera{}, false
+	}
+	return s.byID[id], true
+}
+
+// IsEnabled reports whether a camera with the given name is registered and enabled.
+func (s *RegistryService) IsEnabled(name string) bool {
+	cam, exists := s.GetByName(name)
+	return exists && cam.Enabled
+}
+
+// AcceptBoot checks the boot counter of a signed datagram against the highest one accepted
+// from the camera. Lower counters belong to earlier boots and are replays. A higher counter
+// is stored before it is accepted, so the order survives server restarts.
+func (s *RegistryService) AcceptBoot(id int64, boot uint32) bool {
+	s.mu.RLock()
+	cam, exists := s.byID[id]
+	s.mu.RUnlock()
+	if !exists || int64(boot) < cam.Boot {
+		return false
+	}
+	if int64(boot) == cam.Boot {
+		return true
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cam, exists = s.byID[id]
+	if !exists || int64(boot) < cam.Boot {
+		return false
+	}
+	// A failed write only weakens the check after a restart; the camera is not locked out
+	if int64(boot) > cam.Boot && s.cameraRepo != nil {
+		if err := s.cameraRepo.SetBoot(id, int64(boot)); err != nil {
+			s.logger.Error("Failed to store boot counter of camera %s: %v", cam.Name, err)
+		}
+	}
+	cam.Boot = int64(boot)
+	s.byID[id] = cam
+	return true
+}
+
+// Create validates and registers a new camera. A secret is generated when the camera
+// has a device ID but no secret was given.
+func (s *RegistryService) Create(cam model.Camera) (model.Camera, error) {
+	if s.cameraRepo == nil {
+		return model.Camera{}, ErrReadOnly
+	}
+	cam.Name = strings.TrimSpace(cam.Name)
+	if err := s.validate(cam); err != nil {
+		return model.Camera{}, err
+	}
+
+	if cam.DeviceID != 0 && cam.Secret == "" {
+		secret, err := GenerateSecret()
+		if err != nil {
+			return model.Camera{}, err
+		}
+		cam.Secret = secret
+	}
+
+	id, err := s.cameraRepo.Insert(&cam)
+	if err != nil {
+		return model.Camera{}, err
+	}
+
+	created, err := s.cameraRepo.GetByID(id)
+	if err != nil || created == nil {
+		return model.Camera{}, fmt.Errorf("failed to reload camera %d: %v", id, err)
+	}
+
+	s.mu.Lock()
+	s.put(*created)
+	s.mu.Unlock()
+
+	s.logger.Info("📷 Camera %s registered", created.Name)
+	return *created, nil
+}
+
+// Update replaces a camera definition. Renames keep the camera's historical images.
+func (s *RegistryService) Update(cam model.Camera) (model.Camera, error) {
+	if s.cameraRepo == nil {
+		return model.Camera{}, ErrReadOnly
+	}
+
+	previous, exists := s.GetByID(cam.ID)
+	if !exists {
+		return model.Camera{}, ErrNotFound
+	}
+	cam.Name = strings.TrimSpace(cam.Name)
+	cam.Boot = previous.Boot
+	if err := s.validate(cam); err != nil {
+		return model.Camera{}, err
+	}
+
+	if err := s.cameraRepo.Update(&cam); err != nil {
+		return model.Camera{}, err
+	}
+
+	s.mu.Lock()
+	s.remove(previous)
+	s.put(cam)
+	s.mu.Unlock()
+
+	if previous.Name != cam.Name {
+		s.logger.Info("📷 Camera %s renamed to %s", previous.Name, cam.Name)
+	}
+	if previous.Enabled != cam.Enabled {
+		s.logger.Info("📷 Camera %s enabled: %t", cam.Name, cam.Enabled)
+	}
+	return cam, nil
+}
+
+// Delete removes a camera from the registry. Its stored images are kept.
+func (s *RegistryService) Delete(id int64) error {
+	if s.cameraRepo == nil {
+		return ErrReadOnly
+	}
+
+	cam, exists := s.GetByID(id)
+	if !exists {
+		return ErrNotFound
+	}
+
+	if err := s.cameraRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.remove(cam)
+	s.mu.Unlock()
+
+	s.logger.Info("📷 Camera %s removed from registry", cam.Name)
+	return nil
+}
+
+// GenerateSecret returns a random hex-encoded camera secret.
+func GenerateSecret() (string, error) {
+	buf := make([]byte, SecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+// validate checks field ranges and uniqueness against the other registered cameras.
+func (s *RegistryService) validate(cam model.Camera) error {
+	if err := validName(cam.Name); err != nil {
+		return err
+	}
+	if cam.DeviceID < 0 || cam.DeviceID > MaxDeviceID {
+		return fmt.Errorf("%w: device id must be between 1 and %d", ErrInvalid, MaxDeviceID)
+	}
+	if cam.DeviceID == 0 && cam.IPAddress == "" {
+		return fmt.Errorf("%w: device id or ip address is required", ErrInvalid)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if id, exists := s.byName[cam.Name]; exists && id != cam.ID {
+		return ErrConflict
+	}
+	if id, exists := s.byDevice[cam.DeviceID]; exists && cam.DeviceID != 0 && id != cam.ID {
+		return ErrConflict
+	}
+	if id, exists := s.byIP[cam.IPAddress]; exists && cam.IPAddress != "" && id != cam.ID {
+		return ErrConflict
+	}
+	return nil
+}
+
+// IsNameRune reports whether r may appear in a camera name: letters (including
+// diacritics), digits, '-' and '_'. Such names are safe as a single file path and
+// storage key segment.
+func IsNameRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_'
+}
+
+// validName checks that a camera name can be used as a directory and storage key segment:
+// only runes accepted by IsNameRune, and none of the store's own top-level directories.
+func validName(name string) error {
+	if name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalid)
+	}
+	if utf8.RuneCountInString(name) > MaxNameLength {
+		return fmt.Errorf("%w: name must be at most %d characters", ErrInvalid, MaxNameLength)
+	}
+	if strings.IndexFunc(name, func(r rune) bool { return !IsNameRune(r) }) >= 0 {
+		return fmt.Errorf("%w: name may only contain letters, digits, '-' and '_'", ErrInvalid)
+	}
+	for _, reserved := range reservedNames {
+		if strings.EqualFold(name, reserved) {
+			return fmt.Errorf("%w: name %q is reserved", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// loadCameras reads all cameras from the repository, if one is configured.
+func (s *RegistryService) loadCameras() ([]model.Camera, error) {
+	if s.cameraRepo == nil {
+		return nil, nil
+	}
+	return s.cameraRepo.GetAll()
+}
+
+// rebuild replaces the in-memory indexes with the given cameras.
+func (s *RegistryService) rebuild(cameras []model.Camera) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.byID = make(map[int64]model.Camera)
+	s.byName = make(map[string]int64)
+	s.byDevice = make(map[int]int64)
+	s.byIP = make(map[string]int64)
+	for _, cam := range cameras {
+		s.put(cam)
+	}
+}
+
+// put indexes a camera. Caller must hold s.mu.
+func (s *RegistryService) put(cam model.Camera) {
+	s.byID[cam.ID] = cam
+	s.byName[cam.Name] = cam.ID
+	if cam.DeviceID != 0 {
+		s.byDevice[cam.DeviceID] = cam.ID
+	}
+	if cam.IPAddress != "" {
+		s.byIP[cam.IPAddress] = cam.ID
+	}
+}
+
+// remove drops a camera from the indexes. Caller must hold s.mu.
+func (s *RegistryService) remove(cam model.Camera) {
+	delete(s.byID, cam.ID)
+	delete(s.byName, cam.Name)
+	if cam.DeviceID != 0 {
+		delete(s.byDevice, cam.DeviceID)
+	}
+	if cam.IPAddress != "" {
+		delete(s.byIP, cam.IPAddress)
+	}
+}
+
+// seedCameras merges CAMERA_TOKENS and CAMERAS into initial registry entries,
+// joining token and IP definitions that share a camera name.
+func seedCameras(config *config.Config) []model.Camera {
+	byName := make(map[string]*model.Camera)
+	var order []string
+
+	add := func(name string) *model.Camera {
+		if cam, exists := byName[name]; exists {
+			return cam
+		}
+		cam := &model.Camera{Name: name, Enabled: true}
+		byName[name] = cam
+		order = append(order, name)
+		return cam
+	}
+
+	deviceIDs := make([]int, 0, len(config.CameraTokens))
+	for id := range config.CameraTokens {
+		deviceIDs = append(deviceIDs, int(id))
+	}
+	sort.Ints(deviceIDs)
+	for _, id := range deviceIDs {
+		token := config.CameraTokens[uint16(id)]
+		cam := add(token.Name)
+		cam.DeviceID = id
+		cam.Secret = token.Secret
+	}
+
+	ips := make([]string, 0, len(config.CameraNames))
+	for ip := range config.CameraNames {
+		ips = append(ips, ip)
+	}
+	sort.Strings(ips)
+	for _, ip := range ips {
+		add(config.CameraNames[ip]).IPAddress = ip
+	}
+
+	cameras := make([]model.Camera, 0, len(order))
+	for _, name := range order {
+		cameras = append(cameras, *byName[name])
+	}
+	return cameras
+}
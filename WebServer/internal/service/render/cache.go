@@ -0,0 +1,74 @@
+package render
+
+import (
+	"container/list"
+	"sync"
+)
+
+// Cache keeps the most recently used rendered images up to a fixed number of entries.
+type Cache struct {
+	capacity int
+	entries  map[string]*list.Element
+	order    *list.List // front is the most recently used
+	mu       sync.Mutex
+}
+
+// cacheEntry is a rendered image stored under its key.
+type cacheEntry struct {
+	key  string
+	data []byte
+}
+
+// NewCache creates a Cache holding up to capacity images. A capacity of zero or less
+// disables caching.
+func NewCache(capacity int) *Cache {
+	return &Cache{
+		capacity: capacity,
+		entries:  make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+// Get returns the image stored under key and marks it as recently used.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, exists := c.entries[key]
+	if !exists {
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*cacheEntry).data, true
+}
+
+// Put stores an image under key, evicting the least recently used images when full.
+func (c *Cache) Put(key string, data []byte) {
+	if c.capacity <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if element, exists := c.entries[key]; exists {
+		element.Value.(*cacheEntry).data = data
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&cacheEntry{key: key, data: data})
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// Len returns the number of cached images.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
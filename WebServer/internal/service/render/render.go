@@ -0,0 +1,143 @@
+package render
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+	"webserver/internal/service/motion"
+	"webserver/internal/service/storage"
+)
+
+// Box is a detection drawn onto an image, in pixels, with its caption.
+type Box struct {
+	X       int    `json:"x"`
+	Y       int    `json:"y"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+	Caption string `json:"caption"`
+}
+
+// Overlay is everything drawn onto an image: the camera's zones and the detections
+// stored for the image.
+type Overlay struct {
+	Zones []model.MotionZone `json:"zones"`
+	Boxes []Box              `json:"boxes"`
+}
+
+// DrawFunc draws an overlay onto a JPEG frame and returns the re-encoded JPEG.
+type DrawFunc func(frame []byte, overlay Overlay) ([]byte, error)
+
+// RenderService renders stored images with their detections and zones drawn on at
+// request time, so the files on disk stay unannotated. Rendered images are cached by
+// filename and overlay, so edited zones produce a fresh rendering.
+type RenderService struct {
+	buffer        *storage.BufferService
+	cache         *Cache
+	draw          DrawFunc
+	imageRepo     repository.ImageRepository
+	detectionRepo repository.DetectionRepository
+	zones         *motion.ZoneService
+	logger        *logger.Logger
+}
+
+// NewRenderService creates a RenderService caching ANNOTATE_CACHE_SIZE images.
+// Without a database images are rendered with their camera's zones only.
+func NewRenderService(config *config.Config, logger *logger.Logger, buffer *storage.BufferService,
+	imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository, zones *motion.ZoneService,
+	draw DrawFunc) *RenderService {
+	return &RenderService{
+		buffer:        buffer,
+		cache:         NewCache(config.AnnotateCacheSize),
+		draw:          draw,
+		imageRepo:     imageRepo,
+		detectionRepo: detectionRepo,
+		zones:         zones,
+		logger:        logger,
+	}
+}
+
+// Annotated returns the stored image with its overlay drawn on. Errors match
+// fs.ErrNotExist when the image does not exist or its name is not a valid key.
+func (s *RenderService) Annotated(filename string) ([]byte, error) {
+	// Read first, so deleted images are not served from the cache
+	frame, _, _, err := s.buffer.ReadImage(filename, "")
+	if err != nil {
+		return nil, err
+	}
+
+	overlay, err := s.Overlay(filename)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := json.Marshal(overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode overlay: %w", err)
+	}
+	sum := sha256.Sum256(encoded)
+	key := filename + "|" + hex.EncodeToString(sum[:])
+
+	if rendered, cached := s.cache.Get(key); cached {
+		return rendered, nil
+	}
+
+	rendered, err := s.draw(frame, overlay)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", filename, err)
+	}
+	s.cache.Put(key, rendered)
+	return rendered, nil
+}
+
+// Overlay collects the enabled zones of the image's camera and the detections stored
+// for the image.
+func (s *RenderService) Overlay(filename string) (Overlay, error) {
+	overlay := Overlay{Zones: []model.MotionZone{}, Boxes: []Box{}}
+	if s.imageRepo == nil {
+		return overlay, nil
+	}
+
+	image, err := s.imageRepo.GetByFilename(filename)
+	if err != nil {
+		return overlay, err
+	}
+	if image == nil {
+		return overlay, nil
+	}
+
+	if s.zones != nil {
+		for _, zone := range s.zones.GetAll(image.Camera) {
+			if zone.Enabled {
+				overlay.Zones = append(overlay.Zones, zone)
+			}
+		}
+	}
+
+	if s.detectionRepo != nil {
+		detections, err := s.detectionRepo.GetByImageID(image.ID)
+		if err != nil {
+			return overlay, err
+		}
+		for _, d := range detections {
+			overlay.Boxes = append(overlay.Boxes, Box{X: d.X, Y: d.Y, Width: d.Width, Height: d.Height,
+				Caption: Caption(d)})
+		}
+	}
+	return overlay, nil
+}
+
+// Caption returns the text drawn above a detection: label, confidence, track ID and zone.
+func Caption(d model.Detection) string {
+	caption := fmt.Sprintf("%s (%.2f)", d.ObjectName, d.Confidence)
+	if d.TrackID != 0 {
+		caption += fmt.Sprintf(" #%d", d.TrackID)
+	}
+	if d.Zone != "" {
+		caption += " @" + d.Zone
+	}
+	return caption
+}
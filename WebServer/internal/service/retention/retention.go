@@ -0,0 +1,240 @@
+package retention
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/repository"
+	"webserver/internal/service/storage"
+)
+
+const (
+	// DefaultInterval is how often the policies are enforced when RETENTION_INTERVAL is not positive.
+	DefaultInterval = 10 * time.Minute
+	// deleteBatch is the number of images loaded at once when deleting.
+	deleteBatch = 100
+	// bytesPerGB converts the configured quotas to bytes.
+	bytesPerGB = 1 << 30
+)
+
+// RetentionService keeps stored images within their limits: images older than the
+// maximum age of their camera are deleted, then the oldest images of cameras over
+// their quota, then the oldest images overall while the total quota is exceeded.
+// Quotas delete images without a priority label (people, cars) before those with one.
+// Files, thumbnails and database rows are removed together.
+type RetentionService struct {
+	buffer       *storage.BufferService
+	quota        int64            // total bytes, 0 for no limit
+	cameraQuotas map[string]int64 // camera name -> bytes
+	maxAge       time.Duration    // 0 keeps images forever
+	cameraMaxAge map[string]time.Duration
+	priority     []string
+	interval     time.Duration
+	mu           sync.Mutex // one pass at a time, guards the per-camera limits
+	imageRepo    repository.ImageRepository
+	logger       *logger.Logger
+}
+
+// NewRetentionService creates a RetentionService using STORAGE_QUOTA_GB,
+// STORAGE_CAMERA_QUOTAS, RETENTION_DAYS, RETENTION_CAMERA_DAYS, RETENTION_PRIORITY
+// and RETENTION_INTERVAL. Without a database nothing is deleted.
+func NewRetentionService(config *config.Config, logger *logger.Logger, buffer *storage.BufferService,
+	imageRepo repository.ImageRepository) *RetentionService {
+	interval := time.Duration(config.RetentionIntervalS) * time.Second
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	service := &RetentionService{
+		buffer:       buffer,
+		quota:        int64(config.StorageQuotaGB * bytesPerGB),
+		cameraQuotas: make(map[string]int64),
+		maxAge:       days(float64(config.RetentionDays)),
+		cameraMaxAge: make(map[string]time.Duration),
+		priority:     config.RetentionPriority,
+		interval:     interval,
+		imageRepo:    imageRepo,
+		logger:       logger,
+	}
+	for camera, quota := range config.CameraQuotasGB {
+		if quota > 0 {
+			service.cameraQuotas[camera] = int64(quota * bytesPerGB)
+		}
+	}
+	for camera, maxAge := range config.RetentionCameraDays {
+		service.cameraMaxAge[camera] = days(maxAge)
+	}
+	return service
+}
+
+// RenameCamera moves the STORAGE_CAMERA_QUOTAS and RETENTION_CAMERA_DAYS limits of a
+// renamed camera.
+func (s *RetentionService) RenameCamera(oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config.RenameCameraKey(s.cameraQuotas, oldName, newName)
+	config.RenameCameraKey(s.cameraMaxAge, oldName, newName)
+}
+
+// Run enforces the policies right away and then every interval.
+func (s *RetentionService) Run() {
+	s.Enforce(time.Now())
+
+	ticker := time.NewTicker(s.interval)
+
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		s.Enforce(time.Now())
+	}
+}
+
+// Enforce applies the age limits and quotas at now and returns the number of images deleted.
+func (s *RetentionService) Enforce(now time.Time) int {
+	if s.imageRepo == nil {
+		return 0
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+
+	usage, ok := s.usage()
+	if !ok {
+		return removed
+	}
+	for _, camera := range sortedCameras(usage) {
+		if maxAge := s.maxAgeOf(camera); maxAge > 0 {
+			reason := fmt.Sprintf("older than %s", formatAge(maxAge))
+			removed += s.purge(camera, now.Add(-maxAge), nil, -1, reason)
+		}
+	}
+
+	if usage, ok = s.usage(); !ok {
+		return removed
+	}
+	for _, camera := range sortedCameras(usage) {
+		if quota, limited := s.cameraQuotas[camera]; limited && usage[camera] > quota {
+			reason := fmt.Sprintf("over the camera quota of %s", formatBytes(quota))
+			removed += s.purge(camera, time.Time{}, s.priority, usage[camera]-quota, reason)
+		}
+	}
+
+	if s.quota <= 0 {
+		return removed
+	}
+	if usage, ok = s.usage(); !ok {
+		return removed
+	}
+	var total int64
+	for _, size := range usage {
+		total += size
+	}
+	if total > s.quota {
+		reason := fmt.Sprintf("over the total quota of %s", formatBytes(s.quota))
+		removed += s.purge("", time.Time{}, s.priority, total-s.quota, reason)
+	}
+	return removed
+}
+
+// purge deletes retention candidates of a camera (all cameras when empty) taken before
+// the given time until excess bytes were freed, or every candidate when excess is negative.
+func (s *RetentionService) purge(camera string, before time.Time, priority []string, excess int64, reason string) int {
+	count, freed := 0, int64(0)
+	var oldest, newest time.Time
+
+	for excess < 0 || freed < excess {
+		images, err := s.imageRepo.GetRetentionCandidates(camera, before, priority, deleteBatch)
+		if err != nil {
+			s.logger.Error("Failed to list images for retention: %v", err)
+			break
+		}
+
+		deleted := false
+		for _, img := range images {
+			if excess >= 0 && freed >= excess {
+				break
+			}
+			if err := s.buffer.RemoveImage(img); err != nil {
+				s.logger.Error("Failed to delete image %s: %v", img.Filename, err)
+				continue
+			}
+			deleted = true
+			count++
+			freed += img.FileSize
+			if oldest.IsZero() || img.Timestamp.Before(oldest) {
+				oldest = img.Timestamp
+			}
+			if img.Timestamp.After(newest) {
+				newest = img.Timestamp
+			}
+		}
+		if !deleted {
+			break
+		}
+	}
+
+	if count > 0 {
+		scope := "all cameras"
+		if camera != "" {
+			scope = "camera " + camera
+		}
+		s.logger.Info("🧹 Retention removed %d image(s), %s, of %s taken %s - %s: %s", count, formatBytes(freed),
+			scope, oldest.Format("2006-01-02 15:04"), newest.Format("2006-01-02 15:04"), reason)
+	}
+	return count
+}
+
+// usage returns the bytes stored per camera.
+func (s *RetentionService) usage() (map[string]int64, bool) {
+	usage, err := s.imageRepo.GetUsageByCamera()
+	if err != nil {
+		s.logger.Error("Failed to get storage usage: %v", err)
+		return nil, false
+	}
+	return usage, true
+}
+
+// maxAgeOf returns the maximum image age of a camera, 0 when images are kept forever.
+func (s *RetentionService) maxAgeOf(camera string) time.Duration {
+	if maxAge, exists := s.cameraMaxAge[camera]; exists {
+		return maxAge
+	}
+	return s.maxAge
+}
+
+// sortedCameras returns the camera names of a usage map in alphabetical order.
+func sortedCameras(usage map[string]int64) []string {
+	cameras := make([]string, 0, len(usage))
+	for camera := range usage {
+		cameras = append(cameras, camera)
+	}
+	sort.Strings(cameras)
+	return cameras
+}
+
+// days converts a number of days to a duration; non-positive values give 0.
+func days(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n * float64(24*time.Hour))
+}
+
+// formatAge formats a maximum age in days.
+func formatAge(d time.Duration) string {
+	return fmt.Sprintf("%g days", d.Hours()/24)
+}
+
+// formatBytes formats a size in MB or GB.
+func formatBytes(n int64) string {
+	if n >= bytesPerGB {
+		return fmt.Sprintf("%.2f GB", float64(n)/bytesPerGB)
+	}
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+}
@@ -0,0 +1,194 @@
+package rule
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"webserver/internal/dto"
+	"webserver/internal/model"
+)
+
+// objectState is what the rules remember about a tracked object between frames.
+type objectState struct {
+	camera   string
+	label    string
+	point    model.Point         // last box centre relative to the frame
+	seen     time.Time           // when the object was last seen
+	inside   map[int64]time.Time // zone rule ID -> when the object entered the zone
+	loitered map[int64]bool      // loiter rules that already fired for the object
+}
+
+// Evaluate checks the tracked detections of a camera's frame against its rules and
+// records a trigger for every rule that fires. Objects of the camera that were not
+// seen for the maximum age are treated as lost, which fires zone_leave rules for the
+// zones they were in. Boxes are in pixels of a width x height frame; detections
+// without a track are ignored.
+func (s *RuleService) Evaluate(camera, image string, detections []dto.DetectionResult, timestamp time.Time,
+	width, height int) []model.RuleTrigger {
+	if width <= 0 || height <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var triggers []model.RuleTrigger
+	fire := func(rule model.Rule, trackID int64, label, detail, image string) {
+		triggers = append(triggers, model.RuleTrigger{RuleID: rule.ID, RuleName: rule.Name, Type: rule.Type,
+			Camera: camera, TrackID: trackID, Label: label, Detail: detail, Image: image, Timestamp: timestamp})
+	}
+
+	for trackID, object := range s.objects {
+		if object.camera != camera || timestamp.Sub(object.seen) <= s.maxAge {
+			continue
+		}
+		for ruleID := range object.inside {
+			if rule, exists := s.rules[ruleID]; exists && rule.Enabled && rule.Type == model.RuleZoneLeave {
+				fire(rule, trackID, object.label, "lost", "")
+			}
+		}
+		delete(s.objects, trackID)
+	}
+
+	rules := s.cameraRules(camera)
+	for _, d := range detections {
+		if d.TrackID == 0 {
+			continue
+		}
+		point := model.Point{
+			X: (float64(d.X) + float64(d.Width)/2) / float64(width),
+			Y: (float64(d.Y) + float64(d.Height)/2) / float64(height),
+		}
+
+		object, known := s.objects[d.TrackID]
+		if !known {
+			object = &objectState{camera: camera, label: d.Label, inside: make(map[int64]time.Time),
+				loitered: make(map[int64]bool)}
+			s.objects[d.TrackID] = object
+		} else if timestamp.Before(object.seen) {
+			// Analysed out of order; the newer position is already known
+			continue
+		}
+
+		for _, rule := range rules {
+			if !matchesLabel(rule, d.Label) {
+				continue
+			}
+
+			if rule.Type == model.RuleLineCrossing {
+				if !known {
+					continue
+				}
+				if side, crossed := crossing(object.point, point, rule.Points[0], rule.Points[1]); crossed &&
+					(rule.Direction == "" || rule.Direction == side) {
+					fire(rule, d.TrackID, d.Label, side, image)
+				}
+				continue
+			}
+
+			entered, wasInside := object.inside[rule.ID]
+			isInside := insidePolygon(point, rule.Points)
+			switch {
+			case isInside && !wasInside:
+				object.inside[rule.ID] = timestamp
+				if rule.Type == model.RuleZoneEnter {
+					fire(rule, d.TrackID, d.Label, "", image)
+				}
+			case !isInside && wasInside:
+				delete(object.inside, rule.ID)
+				delete(object.loitered, rule.ID)
+				if rule.Type == model.RuleZoneLeave {
+					fire(rule, d.TrackID, d.Label, "left", image)
+				}
+			case isInside && rule.Type == model.RuleLoiter && !object.loitered[rule.ID]:
+				if dwell := timestamp.Sub(entered).Seconds(); dwell >= rule.DwellSeconds {
+					object.loitered[rule.ID] = true
+					fire(rule, d.TrackID, d.Label, fmt.Sprintf("%.0fs", dwell), image)
+				}
+			}
+		}
+
+		object.point = point
+		object.seen = timestamp
+	}
+
+	for i := range triggers {
+		s.logger.Info("🚨 Rule %q (%s) on camera %s: %s track %d %s", triggers[i].RuleName, triggers[i].Type,
+			camera, triggers[i].Label, triggers[i].TrackID, triggers[i].Detail)
+		if s.triggerRepo == nil {
+			continue
+		}
+		id, err := s.triggerRepo.Insert(&triggers[i])
+		if err != nil {
+			s.logger.Error("Failed to save trigger of rule %d: %v", triggers[i].RuleID, err)
+			continue
+		}
+		triggers[i].ID = id
+	}
+	return triggers
+}
+
+// cameraRules returns the enabled rules of a camera ordered by ID. Callers must hold s.mu.
+func (s *RuleService) cameraRules(camera string) []model.Rule {
+	var rules []model.Rule
+	for _, rule := range s.rules {
+		if rule.Camera == camera && rule.Enabled {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
+	return rules
+}
+
+// matchesLabel reports whether a rule applies to the label.
+func matchesLabel(rule model.Rule, label string) bool {
+	if len(rule.Labels) == 0 {
+		return true
+	}
+	for _, l := range rule.Labels {
+		if strings.EqualFold(l, label) {
+			return true
+		}
+	}
+	return false
+}
+
+// crossing reports whether the move from p to q crosses the segment a-b and to which
+// side of it, looking from a towards b, the object moved.
+func crossing(p, q, a, b model.Point) (string, bool) {
+	sideP, sideQ := side(a, b, p), side(a, b, q)
+	if sideP == 0 || sideQ == 0 || (sideP > 0) == (sideQ > 0) {
+		return "", false
+	}
+	// The line a-b must also separate p and q, otherwise the move passed beside the segment
+	sideA, sideB := side(p, q, a), side(p, q, b)
+	if (sideA > 0) == (sideB > 0) && sideA != 0 && sideB != 0 {
+		return "", false
+	}
+
+	// Image coordinates grow downwards, so a positive cross product is on the right
+	if sideQ > 0 {
+		return model.DirectionRight, true
+	}
+	return model.DirectionLeft, true
+}
+
+// side returns the cross product of b-a and p-a, whose sign tells on which side of the line a-b p lies.
+func side(a, b, p model.Point) float64 {
+	return (b.X-a.X)*(p.Y-a.Y) - (b.Y-a.Y)*(p.X-a.X)
+}
+
+// insidePolygon reports whether p lies inside the polygon (even-odd rule).
+func insidePolygon(p model.Point, polygon []model.Point) bool {
+	inside := false
+	for i := range polygon {
+		a, b := polygon[i], polygon[(i+1)%len(polygon)]
+		if (a.Y <= p.Y && p.Y < b.Y) || (b.Y <= p.Y && p.Y < a.Y) {
+			if x := a.X + (p.Y-a.Y)*(b.X-a.X)/(b.Y-a.Y); p.X < x {
+				inside = !inside
+			}
+		}
+	}
+	return inside
+}
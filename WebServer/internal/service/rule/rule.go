@@ -0,0 +1,268 @@
+package rule
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+)
+
+// DefaultMaxAge is how long an object may go unseen before it counts as lost.
+const DefaultMaxAge = 5 * time.Second
+
+var (
+	// ErrNotFound is returned when a rule does not exist.
+	ErrNotFound = errors.New("rule not found")
+	// ErrReadOnly is returned for changes when no database is available.
+	ErrReadOnly = errors.New("rules are read-only without a database")
+	// ErrInvalid is returned for rule definitions that fail validation.
+	ErrInvalid = errors.New("invalid rule")
+)
+
+// RuleService keeps the rules of all cameras in memory and evaluates them against
+// tracked detections, recording a trigger each time a rule fires.
+type RuleService struct {
+	rules       map[int64]model.Rule
+	objects     map[int64]*objectState // tracked objects by track ID
+	maxAge      time.Duration
+	mu          sync.Mutex
+	ruleRepo    repository.RuleRepository
+	triggerRepo repository.RuleTriggerRepository
+	logger      *logger.Logger
+}
+
+// NewRuleService loads the configured rules from the repository. Objects unseen for
+// TRACK_MAX_AGE count as lost, like their tracks.
+func NewRuleService(config *config.Config, logger *logger.Logger, ruleRepo repository.RuleRepository,
+	triggerRepo repository.RuleTriggerRepository) *RuleService {
+	maxAge := time.Duration(config.TrackMaxAgeS) * time.Second
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+
+	service := &RuleService{
+		rules:       make(map[int64]model.Rule),
+		objects:     make(map[int64]*objectState),
+		maxAge:      maxAge,
+		ruleRepo:    ruleRepo,
+		triggerRepo: triggerRepo,
+		logger:      logger,
+	}
+
+	if ruleRepo != nil {
+		rules, err := ruleRepo.GetAll()
+		if err != nil {
+			service.logger.Error("Failed to load rules: %v", err)
+		}
+		for _, rule := range rules {
+			service.rules[rule.ID] = rule
+		}
+	}
+
+	service.logger.Info("📏 Loaded %d rule(s)", len(service.rules))
+	return service
+}
+
+// GetAll returns the rules of a camera, or of all cameras when camera is empty.
+func (s *RuleService) GetAll(camera string) []model.Rule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rules := make([]model.Rule, 0, len(s.rules))
+	for _, rule := range s.rules {
+		if camera == "" || rule.Camera == camera {
+			rules = append(rules, rule)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Camera != rules[j].Camera {
+			return rules[i].Camera < rules[j].Camera
+		}
+		return rules[i].ID < rules[j].ID
+	})
+	return rules
+}
+
+// GetByID returns the rule with the given ID.
+func (s *RuleService) GetByID(id int64) (model.Rule, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, exists := s.rules[id]
+	return rule, exists
+}
+
+// Create validates and stores a new rule.
+func (s *RuleService) Create(rule model.Rule) (model.Rule, error) {
+	if err := validateRule(&rule); err != nil {
+		return model.Rule{}, err
+	}
+	if s.ruleRepo == nil {
+		return model.Rule{}, ErrReadOnly
+	}
+
+	id, err := s.ruleRepo.Insert(&rule)
+	if err != nil {
+		return model.Rule{}, err
+	}
+	stored, err := s.ruleRepo.GetByID(id)
+	if err != nil || stored == nil {
+		return model.Rule{}, fmt.Errorf("failed to reload rule %d: %v", id, err)
+	}
+
+	s.mu.Lock()
+	s.rules[id] = *stored
+	s.mu.Unlock()
+
+	s.logger.Info("📏 Added %s rule %q for camera %s", stored.Type, stored.Name, stored.Camera)
+	return *stored, nil
+}
+
+// Update validates and saves changes to an existing rule. Objects are evaluated
+// against the changed rule as if they had just appeared.
+func (s *RuleService) Update(rule model.Rule) (model.Rule, error) {
+	if err := validateRule(&rule); err != nil {
+		return model.Rule{}, err
+	}
+	if s.ruleRepo == nil {
+		return model.Rule{}, ErrReadOnly
+	}
+
+	s.mu.Lock()
+	old, exists := s.rules[rule.ID]
+	s.mu.Unlock()
+	if !exists {
+		return model.Rule{}, ErrNotFound
+	}
+
+	if err := s.ruleRepo.Update(&rule); err != nil {
+		return model.Rule{}, err
+	}
+	rule.CreatedAt = old.CreatedAt
+
+	s.mu.Lock()
+	s.rules[rule.ID] = rule
+	s.forget(rule.ID)
+	s.mu.Unlock()
+
+	return rule, nil
+}
+
+// Delete removes a rule. Its triggers are kept.
+func (s *RuleService) Delete(id int64) error {
+	if s.ruleRepo == nil {
+		return ErrReadOnly
+	}
+
+	s.mu.Lock()
+	rule, exists := s.rules[id]
+	s.mu.Unlock()
+	if !exists {
+		return ErrNotFound
+	}
+
+	if err := s.ruleRepo.Delete(id); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	delete(s.rules, id)
+	s.forget(id)
+	s.mu.Unlock()
+
+	s.logger.Info("📏 Removed rule %q from camera %s", rule.Name, rule.Camera)
+	return nil
+}
+
+// RenameCamera moves the in-memory rules and objects of a renamed camera. The database
+// rows are renamed together with the camera.
+func (s *RuleService) RenameCamera(oldName, newName string) {
+	if oldName == newName {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, rule := range s.rules {
+		if rule.Camera == oldName {
+			rule.Camera = newName
+			s.rules[id] = rule
+		}
+	}
+	for _, object := range s.objects {
+		if object.camera == oldName {
+			object.camera = newName
+		}
+	}
+}
+
+// GetTriggers returns the newest triggers, optionally limited to one camera and rule.
+func (s *RuleService) GetTriggers(camera string, ruleID int64, limit int) ([]model.RuleTrigger, error) {
+	if s.triggerRepo == nil {
+		return []model.RuleTrigger{}, nil
+	}
+	triggers, err := s.triggerRepo.GetRecent(camera, ruleID, limit)
+	if triggers == nil {
+		triggers = []model.RuleTrigger{}
+	}
+	return triggers, err
+}
+
+// forget drops the zone state of a changed or deleted rule. Callers must hold s.mu.
+func (s *RuleService) forget(ruleID int64) {
+	for _, object := range s.objects {
+		delete(object.inside, ruleID)
+		delete(object.loitered, ruleID)
+	}
+}
+
+// validateRule normalizes and checks a rule definition.
+func validateRule(rule *model.Rule) error {
+	rule.Camera = strings.TrimSpace(rule.Camera)
+	rule.Name = strings.TrimSpace(rule.Name)
+
+	labels := make([]string, 0, len(rule.Labels))
+	for _, label := range rule.Labels {
+		if label = strings.TrimSpace(label); label != "" {
+			labels = append(labels, label)
+		}
+	}
+	rule.Labels = labels
+
+	if rule.Camera == "" {
+		return fmt.Errorf("%w: camera is required", ErrInvalid)
+	}
+	for _, p := range rule.Points {
+		if p.X < 0 || p.X > 1 || p.Y < 0 || p.Y > 1 {
+			return fmt.Errorf("%w: point coordinates must be between 0 and 1", ErrInvalid)
+		}
+	}
+
+	switch rule.Type {
+	case model.RuleLineCrossing:
+		if len(rule.Points) != 2 || rule.Points[0] == rule.Points[1] {
+			return fmt.Errorf("%w: a line needs 2 different points", ErrInvalid)
+		}
+		if rule.Direction != "" && rule.Direction != model.DirectionLeft && rule.Direction != model.DirectionRight {
+			return fmt.Errorf("%w: direction must be empty, %q or %q", ErrInvalid, model.DirectionLeft, model.DirectionRight)
+		}
+	case model.RuleZoneEnter, model.RuleZoneLeave, model.RuleLoiter:
+		if len(rule.Points) < 3 {
+			return fmt.Errorf("%w: a polygon needs at least 3 points", ErrInvalid)
+		}
+		if rule.Type == model.RuleLoiter && rule.DwellSeconds <= 0 {
+			return fmt.Errorf("%w: dwell_seconds must be positive", ErrInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: type must be %q, %q, %q or %q", ErrInvalid,
+			model.RuleLineCrossing, model.RuleZoneEnter, model.RuleZoneLeave, model.RuleLoiter)
+	}
+	return nil
+}
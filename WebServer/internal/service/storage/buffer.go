@@ -1,9 +1,8 @@
 package storage
 
 import (
+	"errors"
 	"fmt"
-	"os"
-	"path/filepath"
 	"sync"
 	"time"
 	"webserver/internal/config"
@@ -11,145 +10,335 @@ import (
 	"webserver/internal/logger"
 	"webserver/internal/model"
 	"webserver/internal/repository"
+	"webserver/internal/service/blob"
 )
 
 const (
-	// ImageBufferLimit limits how many images per camera are buffered before flushing.
-	ImageBufferLimit = 10
-	// ImageBufferFlushInterval defines how often (seconds) buffered images are flushed to disk.
-	ImageBufferFlushInterval = 30
+	// SpoolRetryInterval is how often the spool is drained again while the store or
+	// database is failing.
+	SpoolRetryInterval = 5 * time.Second
+	// SpoolMaxAttempts is how many times the oldest spooled image is tried, about five
+	// minutes of retries, before it is moved to the dead-letter file so the images
+	// behind it can be stored.
+	SpoolMaxAttempts = 60
+	// DefaultSpoolSegmentMB is the spool segment size when SPOOL_SEGMENT_MB is not positive.
+	DefaultSpoolSegmentMB = 16
+	// DefaultThumbnailWidth is the thumbnail width when THUMBNAIL_WIDTH is not positive.
+	DefaultThumbnailWidth = 320
+	// DefaultPreviewWidth is the preview width when PREVIEW_WIDTH is not positive.
+	DefaultPreviewWidth = 1024
 )
 
-// BufferService buffers images in memory and periodically flushes them to disk.
+// BufferService accepts detection frames into a durable on-disk spool and drains them
+// into the default blob store and the database in the background, so frames survive
+// crashes, restarts and storage outages. Frames are only dropped when the spool is
+// full or cannot be written, and drops are counted. It also reads and deletes stored
+// images through the store recorded for each image.
 type BufferService struct {
-	imagesDir     string
-	images        []dto.BufferedImage
-	bufferCount   map[string]int
-	mu            sync.Mutex
+	stores        *blob.Stores
+	spool         *Spool
+	spoolErr      error // why the spool could not be opened
+	notify        chan struct{}
+	drainMu       sync.Mutex // one drain at a time
+	head          string     // filename of the oldest spooled image, guarded by drainMu
+	headAttempts  int        // failed attempts to store head, guarded by drainMu
+	mu            sync.Mutex // guards the counters
+	stats         dto.SpoolStats
 	logger        *logger.Logger
 	imageRepo     repository.ImageRepository
-	detectionRepo repository.DetectionRepository
+	variantRepo   repository.ImageVariantRepository
+	variantWidths map[string]int // variant size -> width in pixels
+	removed       []func(img model.Image)
 }
 
-// NewBufferService creates a new BufferService with the target directory and logger.
-func NewBufferService(config *config.Config, logger *logger.Logger, imageRepo repository.ImageRepository, detectionRepo repository.DetectionRepository) *BufferService {
-	return &BufferService{
-		imagesDir:     config.ImageDirectory,
-		images:        make([]dto.BufferedImage, 0),
-		bufferCount:   make(map[string]int),
+// NewBufferService creates a new BufferService spooling to SPOOL_DIR and writing to the
+// given stores, or to IMAGE_DIR when stores is nil. The spool holds at most SPOOL_MAX_MB
+// megabytes in segments of SPOOL_SEGMENT_MB. Thumbnails and previews are THUMBNAIL_WIDTH
+// and PREVIEW_WIDTH pixels wide.
+func NewBufferService(config *config.Config, logger *logger.Logger, stores *blob.Stores, imageRepo repository.ImageRepository,
+	variantRepo repository.ImageVariantRepository) *BufferService {
+	if stores == nil {
+		stores = blob.NewStores(blob.NewLocalStore(config.ImageDirectory))
+	}
+	thumbnailWidth, previewWidth := config.ThumbnailWidth, config.PreviewWidth
+	if thumbnailWidth <= 0 {
+		thumbnailWidth = DefaultThumbnailWidth
+	}
+	if previewWidth <= 0 {
+		previewWidth = DefaultPreviewWidth
+	}
+
+	service := &BufferService{
+		stores:        stores,
+		notify:        make(chan struct{}, 1),
+		stats:         dto.SpoolStats{DroppedByCamera: make(map[string]uint64)},
 		logger:        logger,
 		imageRepo:     imageRepo,
-		detectionRepo: detectionRepo,
-		mu:            sync.Mutex{},
+		variantRepo:   variantRepo,
+		variantWidths: map[string]int{VariantThumb: thumbnailWidth, VariantMedium: previewWidth},
+	}
+
+	segmentMB := config.SpoolSegmentMB
+	if segmentMB <= 0 {
+		segmentMB = DefaultSpoolSegmentMB
 	}
+	if config.SpoolDir == "" {
+		service.spoolErr = errors.New("SPOOL_DIR is not set")
+	} else {
+		service.spool, service.spoolErr = OpenSpool(config.SpoolDir, int64(segmentMB)<<20, int64(config.SpoolMaxMB)<<20)
+	}
+	if service.spoolErr != nil {
+		logger.Error("Failed to open image spool, detections cannot be saved: %v", service.spoolErr)
+	} else if truncated := service.spool.Truncated(); truncated > 0 {
+		logger.Warning("⚠️  Removed %d bytes of incomplete spool records in %s", truncated, config.SpoolDir)
+	}
+	return service
 }
 
-// Run starts a ticker loop that periodically flushes images to disk.
+// Run replays images spooled before a restart, then drains the spool whenever images
+// are added. While storing fails it only retries every SpoolRetryInterval.
 func (s *BufferService) Run() {
-	ticker := time.NewTicker(time.Duration(ImageBufferFlushInterval) * time.Second)
+	if s.spool == nil {
+		return
+	}
+	if records, size := s.spool.Backlog(); records > 0 {
+		s.logger.Info("📼 Replaying %d spooled images (%s)", records, formatMB(size))
+	}
+	s.FlushImages()
+
+	ticker := time.NewTicker(SpoolRetryInterval)
 
 	defer ticker.Stop()
 	for {
-		<-ticker.C
+		select {
+		case <-s.notify:
+			if s.failing() {
+				continue
+			}
+		case <-ticker.C:
+		}
 		s.FlushImages()
 	}
 }
 
-// AddImage appends an image to the in-memory buffer for a given camera.
-func (s *BufferService) AddImage(imageData []byte, cameraId string, detections []dto.DetectionResult) {
-	s.mu.Lock()
-	defer s.mu.Unlock()
-
-	timestamp := time.Now().Format("2006-01-02_15-04_05.000")
+// AddImage writes an image to the spool and returns the filename it will be stored
+// under, or "" when the image was dropped because the spool is full or failing.
+func (s *BufferService) AddImage(imageData []byte, cameraId string, detections []dto.DetectionResult) string {
 	image := dto.BufferedImage{
-		Timestamp:  timestamp,
+		Filename:   NewFilename(),
+		Timestamp:  time.Now().Format("2006-01-02_15-04_05.000"),
 		Camera:     cameraId,
 		Detections: detections,
 		Data:       imageData,
 	}
 
-	if s.bufferCount[cameraId] < ImageBufferLimit {
-		s.logger.Info("Buffer size for camera %s: %d/%d", cameraId, len(s.images), ImageBufferLimit)
-		s.images = append(s.images, image)
-		s.bufferCount[cameraId]++
+	err := s.spoolErr
+	if s.spool != nil {
+		err = s.spool.Append(image)
 	}
-}
 
-// FlushImages writes buffered images to disk and resets the buffer and per-camera counters.
-func (s *BufferService) FlushImages() {
 	s.mu.Lock()
-	defer s.mu.Unlock()
+	if err != nil {
+		s.stats.Dropped++
+		s.stats.DroppedByCamera[cameraId]++
+		s.stats.LastError = err.Error()
+	} else {
+		s.stats.Accepted++
+	}
+	s.mu.Unlock()
 
-	if len(s.images) == 0 {
-		return
+	if err != nil {
+		s.logger.Warning("⚠️  Dropped image from camera %s: %v", cameraId, err)
+		return ""
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
 	}
+	return image.Filename
+}
 
-	if err := os.MkdirAll(s.imagesDir, 0755); err != nil {
-		s.logger.Error("Error creating directory: %v", err)
+// FlushImages drains the spool, writing each image with its thumbnail and preview to
+// the default store and the database, in the order they were added. It stops at the
+// first image that cannot be stored; that image is retried on the next drain, and
+// moved to the dead-letter file after SpoolMaxAttempts failed drains.
+func (s *BufferService) FlushImages() {
+	if s.spool == nil {
 		return
 	}
 
+	s.drainMu.Lock()
+	defer s.drainMu.Unlock()
+
 	savedCount := 0
-	for _, image := range s.images {
-		// Build filename with detected object names
-		objects := ""
-		for _, det := range image.Detections {
-			objects += det.Label + "_"
+	for {
+		image, err := s.spool.Peek()
+		if err == nil && image != nil {
+			if err = s.storeImage(*image); err != nil && s.giveUp(*image, err) {
+				continue
+			}
 		}
+		if err != nil {
+			s.recordResult(err)
+			break
+		}
+		if image == nil {
+			break
+		}
+		if err := s.spool.Ack(); err != nil {
+			s.recordResult(err)
+			break
+		}
+		savedCount++
+		s.recordResult(nil)
+	}
+
+	if savedCount > 0 {
+		records, _ := s.spool.Backlog()
+		s.logger.Info("Flushed %d images to %s storage, %d waiting", savedCount, s.stores.Default().Backend(), records)
+	}
+}
+
+// Stats returns the spool counters and backlog.
+func (s *BufferService) Stats() dto.SpoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-		filename := fmt.Sprintf("%s_%s_%s.jpg", image.Timestamp, image.Camera, objects)
-		fullpath := filepath.Join(s.imagesDir, filename)
+	stats := s.stats
+	stats.DroppedByCamera = make(map[string]uint64, len(s.stats.DroppedByCamera))
+	for camera, count := range s.stats.DroppedByCamera {
+		stats.DroppedByCamera[camera] = count
+	}
+	if s.spool != nil {
+		stats.Backlog, stats.BacklogBytes = s.spool.Backlog()
+		stats.DeadLetters, stats.DeadLetterBytes = s.spool.DeadLetters()
+	}
+	return stats
+}
 
-		if err := os.WriteFile(fullpath, image.Data, 0644); err != nil {
-			s.logger.Error("Error saving image %s: %v", filename, err)
-			continue
+// failing reports whether storing the oldest spooled image is failing.
+func (s *BufferService) failing() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.stats.Failing
+}
+
+// giveUp counts a failed attempt to store the oldest spooled image and moves it to the
+// dead-letter file once it has failed SpoolMaxAttempts times. It reports whether the
+// image was moved. Caller must hold s.drainMu.
+func (s *BufferService) giveUp(image dto.BufferedImage, err error) bool {
+	if image.Filename != s.head {
+		s.head, s.headAttempts = image.Filename, 0
+	}
+	s.headAttempts++
+	if s.headAttempts < SpoolMaxAttempts {
+		return false
+	}
+
+	if deadErr := s.spool.DeadLetter(); deadErr != nil {
+		s.logger.Error("Failed to move spooled image %s to the dead letters: %v", image.Filename, deadErr)
+		return false
+	}
+	s.logger.Error("Gave up storing image %s from camera %s after %d attempts, kept in %s: %v",
+		image.Filename, image.Camera, s.headAttempts, deadLetterFile, err)
+	s.head, s.headAttempts = "", 0
+
+	s.mu.Lock()
+	s.stats.LastError = err.Error()
+	s.mu.Unlock()
+	return true
+}
+
+// recordResult records the result of storing a spooled image, logging when storing
+// starts failing and when it recovers.
+func (s *BufferService) recordResult(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		s.stats.Stored++
+		if s.stats.Failing {
+			s.stats.Failing = false
+			s.logger.Info("✅ Storing spooled images again")
 		}
+		return
+	}
 
-		// Save to database if repositories are available
-		if s.imageRepo != nil {
-			ts, err := time.Parse("2006-01-02_15-04_05.000", image.Timestamp)
-			if err != nil {
-				ts = time.Now()
-			}
+	s.stats.LastError = err.Error()
+	if !s.stats.Failing {
+		s.stats.Failing = true
+		s.logger.Error("Failed to store spooled image, retrying every %s: %v", SpoolRetryInterval, err)
+	}
+}
 
-			dbImage := &model.Image{
-				Filename:  filename,
-				Camera:    image.Camera,
-				Timestamp: ts,
-				FilePath:  fullpath,
-				FileSize:  int64(len(image.Data)),
-			}
+// storeImage writes a spooled image and its variants to the default store and records
+// it with its detections in the database. Images already in the database, stored
+// before a crash but not yet removed from the spool, are skipped.
+func (s *BufferService) storeImage(image dto.BufferedImage) error {
+	store := s.stores.Default()
+	filename := image.Filename
+	ts, err := time.Parse("2006-01-02_15-04_05.000", image.Timestamp)
+	if err != nil {
+		ts = time.Now()
+	}
+	key := ImageKey(image.Camera, ts, filename)
 
-			imageID, err := s.imageRepo.Insert(dbImage)
-			if err != nil {
-				s.logger.Error("Error saving image to database %s: %v", filename, err)
-				continue
-			}
+	if s.imageRepo != nil {
+		existing, err := s.imageRepo.GetByFilename(filename)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			return nil
+		}
+	}
 
-			// Insert detections for this image
-			if s.detectionRepo != nil && len(image.Detections) > 0 {
-				var dbDetections []model.Detection
-				for _, det := range image.Detections {
-					dbDetections = append(dbDetections, model.Detection{
-						ImageID:    imageID,
-						ObjectName: det.Label,
-						X:          det.X,
-						Y:          det.Y,
-						Width:      det.Width,
-						Height:     det.Height,
-						Confidence: det.Confidence,
-					})
-				}
-				if err := s.detectionRepo.InsertBatch(dbDetections); err != nil {
-					s.logger.Error("Error saving detections to database: %v", err)
-				}
-			}
+	if err := store.Put(key, image.Data); err != nil {
+		return fmt.Errorf("failed to save image %s: %w", filename, err)
+	}
+
+	// Record the image with its detections in one step; on failure the file is removed
+	// again, so the retry from the spool starts from a clean state
+	var imageID int64
+	if s.imageRepo != nil {
+		dbImage := &model.Image{
+			Filename:  filename,
+			Camera:    image.Camera,
+			Timestamp: ts,
+			FilePath:  store.Location(key),
+			FileSize:  int64(len(image.Data)),
+			Storage:   store.Backend(),
+		}
+		dbDetections := make([]model.Detection, 0, len(image.Detections))
+		for _, det := range image.Detections {
+			dbDetections = append(dbDetections, model.Detection{
+				ObjectName: det.Label,
+				X:          det.X,
+				Y:          det.Y,
+				Width:      det.Width,
+				Height:     det.Height,
+				Confidence: det.Confidence,
+				Zone:       det.Zone,
+				TrackID:    det.TrackID,
+			})
 		}
 
-		savedCount++
+		imageID, err = s.imageRepo.InsertWithDetections(dbImage, dbDetections)
+		if err != nil {
+			if deleteErr := store.Delete(key); deleteErr != nil {
+				s.logger.Error("Failed to remove %s after the database error: %v", key, deleteErr)
+			}
+			return fmt.Errorf("failed to save image %s to database: %w", filename, err)
+		}
 	}
 
-	s.logger.Info("Flushed %d images to disk", savedCount)
-	s.images = s.images[:0] // Clear buffer
-	s.bufferCount = make(map[string]int)
+	s.saveVariants(store, imageID, key, image.Data)
+	return nil
+}
+
+// formatMB formats a size in megabytes.
+func formatMB(n int64) string {
+	return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
 }
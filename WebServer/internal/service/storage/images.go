@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"time"
+	"webserver/internal/model"
+	"webserver/internal/service/blob"
+)
+
+// clearBatch is the number of images loaded at once when clearing all images.
+const clearBatch = 100
+
+// ReadImage returns a stored image, or its thumb or medium variant when size names
+// one, from the store recorded for the image, together with the size that was read.
+// Missing variants fall back to the original, reported as size "". Errors match
+// fs.ErrNotExist when the image does not exist.
+func (s *BufferService) ReadImage(filename, size string) ([]byte, blob.Info, string, error) {
+	store, key, err := s.locate(filename)
+	if err != nil {
+		return nil, blob.Info{}, "", err
+	}
+
+	if size != "" {
+		data, info, err := store.Get(VariantPath(size, key))
+		if !errors.Is(err, fs.ErrNotExist) {
+			return data, info, size, err
+		}
+	}
+	data, info, err := store.Get(key)
+	return data, info, "", err
+}
+
+// DeleteImage removes an image by filename. Images without a database row are
+// removed from the default store.
+func (s *BufferService) DeleteImage(filename string) error {
+	if s.imageRepo != nil {
+		img, err := s.imageRepo.GetByFilename(filename)
+		if err != nil {
+			return err
+		}
+		if img != nil {
+			return s.RemoveImage(*img)
+		}
+	}
+	s.deleteFiles(s.stores.Default(), filename)
+	return nil
+}
+
+// OnImageRemoved registers fn to be called after an image's database rows are deleted,
+// so services caching rows that referenced the image can reload them. Register all
+// callbacks before images are removed.
+func (s *BufferService) OnImageRemoved(fn func(img model.Image)) {
+	s.removed = append(s.removed, fn)
+}
+
+// RemoveImage deletes an image's database rows, including its links from events, clips
+// and rule triggers, then its file and variants from the image's store. Files that
+// cannot be deleted are logged and left behind.
+func (s *BufferService) RemoveImage(img model.Image) error {
+	if s.imageRepo != nil {
+		if err := s.imageRepo.Delete(img.ID); err != nil {
+			return err
+		}
+		for _, fn := range s.removed {
+			fn(img)
+		}
+	}
+
+	store, exists := s.stores.Get(img.Storage)
+	if !exists {
+		s.logger.Warning("⚠️  Storage backend %q of %s is not configured, leaving its files", img.Storage, img.Filename)
+		return nil
+	}
+	s.deleteFiles(store, KeyOf(store, img))
+	return nil
+}
+
+// ClearImages deletes every stored image from every store and the database.
+func (s *BufferService) ClearImages() error {
+	if s.imageRepo != nil {
+		for {
+			images, err := s.imageRepo.GetRetentionCandidates("", time.Time{}, nil, clearBatch)
+			if err != nil {
+				return err
+			}
+			if len(images) == 0 {
+				break
+			}
+			for _, img := range images {
+				if err := s.RemoveImage(img); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	// Files without a database row, such as images saved while the database was down
+	if local, exists := s.stores.Local(); exists {
+		if err := local.Clear(); err != nil {
+			return fmt.Errorf("failed to clear image directory: %w", err)
+		}
+	}
+	return nil
+}
+
+// locate returns the store and key of an image. Images without a database row are
+// looked up by filename in the default store.
+func (s *BufferService) locate(filename string) (blob.BlobStore, string, error) {
+	if s.imageRepo == nil {
+		return s.stores.Default(), filename, nil
+	}
+
+	img, err := s.imageRepo.GetByFilename(filename)
+	if err != nil {
+		return nil, "", err
+	}
+	if img == nil {
+		return s.stores.Default(), filename, nil
+	}
+	store, exists := s.stores.Get(img.Storage)
+	if !exists {
+		return nil, "", fmt.Errorf("storage backend %q of %s is not configured", img.Storage, filename)
+	}
+	return store, KeyOf(store, *img), nil
+}
+
+// deleteFiles removes an image and its variants from a store.
+func (s *BufferService) deleteFiles(store blob.BlobStore, key string) {
+	keys := []string{key}
+	for _, size := range VariantSizes {
+		keys = append(keys, VariantPath(size, key))
+	}
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			s.logger.Error("Failed to delete %s from %s storage: %v", key, store.Backend(), err)
+		}
+	}
+}
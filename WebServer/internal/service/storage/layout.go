@@ -0,0 +1,139 @@
+package storage
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+	"webserver/internal/model"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/registry"
+)
+
+// migrateBatch is the number of images loaded at once when migrating the layout.
+const migrateBatch = 100
+
+// NewFilename returns a random, collision-free image filename based on a version 4 UUID.
+func NewFilename() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x.jpg", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// ImageKey returns the blob key an image is stored under: camera/YYYY/MM/DD/filename.
+// Characters other than letters, digits, '-' and '_' in the camera name are replaced.
+func ImageKey(camera string, timestamp time.Time, filename string) string {
+	return path.Join(cameraDir(camera), timestamp.Format("2006/01/02"), filename)
+}
+
+// cameraDir returns a camera name that is safe as a single path segment. Names accepted
+// by the registry are kept as they are.
+func cameraDir(camera string) string {
+	dir := strings.Map(func(r rune) rune {
+		if registry.IsNameRune(r) {
+			return r
+		}
+		return '_'
+	}, camera)
+	if dir == "" {
+		return "unknown"
+	}
+	return dir
+}
+
+// KeyOf returns the key of an image in its store, read from its file path. Images
+// whose path does not lie in the store are looked up by filename.
+func KeyOf(store blob.BlobStore, img model.Image) string {
+	if key, ok := store.Key(img.FilePath); ok {
+		return key
+	}
+	return img.Filename
+}
+
+// MigrateLayout moves images stored flat in their store, with their thumbnails and
+// previews, to camera/YYYY/MM/DD/ and rewrites their file paths. Filenames are kept, so
+// links from events stay valid. Images that are already migrated are skipped, so an
+// interrupted migration can be run again. It returns the number of images moved.
+func (s *BufferService) MigrateLayout() (int, error) {
+	if s.imageRepo == nil {
+		return 0, fmt.Errorf("migrating the image layout needs the database")
+	}
+
+	var afterID int64
+	moved := 0
+	for {
+		images, err := s.imageRepo.GetBatch(afterID, migrateBatch)
+		if err != nil {
+			return moved, err
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for _, img := range images {
+			afterID = img.ID
+			store, exists := s.stores.Get(img.Storage)
+			if !exists {
+				s.logger.Warning("⚠️  Storage backend %q of %s is not configured, skipping it", img.Storage, img.Filename)
+				continue
+			}
+			from := KeyOf(store, img)
+			if strings.Contains(from, "/") {
+				continue
+			}
+
+			if err := s.migrateImage(store, img, from); err != nil {
+				s.logger.Error("Failed to migrate %s: %v", img.Filename, err)
+				continue
+			}
+			moved++
+		}
+	}
+
+	s.logger.Info("📂 Moved %d images to the camera/date layout", moved)
+	return moved, nil
+}
+
+// migrateImage moves an image and its variants to their new keys and records the new
+// location. Variants that do not exist are skipped. A missing original is an error
+// unless an earlier, interrupted run already moved it.
+func (s *BufferService) migrateImage(store blob.BlobStore, img model.Image, from string) error {
+	to := ImageKey(img.Camera, img.Timestamp, img.Filename)
+	if err := blob.Move(store, from, to); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if _, _, getErr := store.Get(to); getErr != nil {
+			return err
+		}
+	}
+
+	var variants []model.ImageVariant
+	if s.variantRepo != nil {
+		var err error
+		if variants, err = s.variantRepo.GetByImageID(img.ID); err != nil {
+			return err
+		}
+	}
+	for _, size := range VariantSizes {
+		if err := blob.Move(store, VariantPath(size, from), VariantPath(size, to)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	for _, variant := range variants {
+		variant.Filename = VariantPath(variant.Size, to)
+		if _, err := s.variantRepo.Upsert(&variant); err != nil {
+			return err
+		}
+	}
+
+	return s.imageRepo.UpdateFilePath(img.ID, store.Location(to))
+}
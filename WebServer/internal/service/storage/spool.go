@@ -0,0 +1,416 @@
+package storage
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"webserver/internal/dto"
+)
+
+const (
+	// spoolMagic starts every spool record.
+	spoolMagic = "SPL1"
+	// spoolHeaderSize is the record header: magic, metadata length, data length and CRC32.
+	spoolHeaderSize = 16
+	// spoolExt is the extension of spool segment files.
+	spoolExt = ".spool"
+	// deadLetterFile keeps the records that could not be stored, in segment format.
+	deadLetterFile = "deadletter.rec"
+	// maxRecordSize bounds the record length read from a header, so a corrupted
+	// header cannot cause a huge allocation.
+	maxRecordSize = 64 << 20
+)
+
+// ErrSpoolFull is returned by Append when the spool has reached its maximum size.
+var ErrSpoolFull = errors.New("spool is full")
+
+// spoolMeta is the JSON metadata stored in front of the image data of a record.
+type spoolMeta struct {
+	Filename   string                `json:"filename"`
+	Timestamp  string                `json:"timestamp"`
+	Camera     string                `json:"camera"`
+	Detections []dto.DetectionResult `json:"detections"`
+}
+
+// spoolSegment is a journal file of the spool.
+type spoolSegment struct {
+	seq  uint64
+	size int64
+}
+
+// Spool is an append-only on-disk journal of images waiting to be stored. Records are
+// appended to numbered segment files, synced before Append returns, and read back in
+// order by a single consumer with Peek and Ack. Drained segments are deleted. Records
+// that cannot be stored are moved out of the way with DeadLetter.
+//
+// Each record is a 16-byte header (magic "SPL1", metadata length, data length, CRC32 of
+// metadata and data) followed by the JSON metadata and the JPEG data. A record torn by a
+// crash fails its checksum and is cut off when the spool is opened again.
+type Spool struct {
+	dir         string
+	segmentSize int64
+	maxSize     int64 // 0 for no limit
+	mu          sync.Mutex
+	segments    []spoolSegment // oldest first; the last one is written to
+	writer      *os.File
+	reader      *os.File // oldest segment
+	readOffset  int64
+	peeked      int64 // length of the record returned by Peek
+	records     int   // records not yet acknowledged
+	size        int64 // bytes not yet acknowledged
+	deadRecords int   // records in the dead-letter file
+	deadSize    int64 // intact bytes of the dead-letter file
+	truncated   int64
+}
+
+// OpenSpool opens the spool in dir, creating it when needed. Records left by a previous
+// run are kept for replay; bytes after the last intact record of a segment are removed.
+// New segments are started once the current one exceeds segmentSize bytes.
+func OpenSpool(dir string, segmentSize, maxSize int64) (*Spool, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create spool directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	s := &Spool{dir: dir, segmentSize: segmentSize, maxSize: maxSize}
+	for _, entry := range entries {
+		seq, err := strconv.ParseUint(strings.TrimSuffix(entry.Name(), spoolExt), 10, 64)
+		if err != nil || !strings.HasSuffix(entry.Name(), spoolExt) {
+			continue
+		}
+		s.segments = append(s.segments, spoolSegment{seq: seq})
+	}
+	sort.Slice(s.segments, func(i, j int) bool { return s.segments[i].seq < s.segments[j].seq })
+
+	for i := range s.segments {
+		segment := &s.segments[i]
+		records, valid, size, err := scanSegment(s.path(segment.seq))
+		if err != nil {
+			return nil, err
+		}
+		if valid < size {
+			if err := os.Truncate(s.path(segment.seq), valid); err != nil {
+				return nil, fmt.Errorf("failed to truncate spool segment: %w", err)
+			}
+			s.truncated += size - valid
+		}
+		segment.size = valid
+		s.records += records
+		s.size += valid
+	}
+
+	if _, err := os.Stat(s.deadLetterPath()); err == nil {
+		records, valid, size, err := scanSegment(s.deadLetterPath())
+		if err != nil {
+			return nil, err
+		}
+		if valid < size {
+			if err := os.Truncate(s.deadLetterPath(), valid); err != nil {
+				return nil, fmt.Errorf("failed to truncate spool dead letters: %w", err)
+			}
+		}
+		s.deadRecords, s.deadSize = records, valid
+	}
+
+	if len(s.segments) == 0 {
+		s.segments = append(s.segments, spoolSegment{seq: 1})
+	}
+	if s.writer, err = s.openWriter(s.segments[len(s.segments)-1].seq); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Append writes an image to the spool and syncs it to disk.
+func (s *Spool) Append(image dto.BufferedImage) error {
+	record, err := encodeRecord(image)
+	if err != nil {
+		return err
+	}
+	length := int64(len(record))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.maxSize > 0 && s.size+length > s.maxSize {
+		return ErrSpoolFull
+	}
+
+	last := &s.segments[len(s.segments)-1]
+	if last.size > 0 && last.size+length > s.segmentSize {
+		writer, err := s.openWriter(last.seq + 1)
+		if err != nil {
+			return err
+		}
+		s.writer.Close()
+		s.writer = writer
+		s.segments = append(s.segments, spoolSegment{seq: last.seq + 1})
+		last = &s.segments[len(s.segments)-1]
+	}
+
+	if _, err := s.writer.Write(record); err != nil {
+		s.writer.Truncate(last.size)
+		return fmt.Errorf("failed to write spool record: %w", err)
+	}
+	if err := s.writer.Sync(); err != nil {
+		s.writer.Truncate(last.size)
+		return fmt.Errorf("failed to sync spool: %w", err)
+	}
+	last.size += length
+	s.records++
+	s.size += length
+	return nil
+}
+
+// Peek returns the oldest image not yet acknowledged, or nil when the spool is empty.
+// It returns the same image until Ack is called.
+func (s *Spool) Peek() (*dto.BufferedImage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.records == 0 {
+		return nil, nil
+	}
+
+	for s.readOffset >= s.segments[0].size && len(s.segments) > 1 {
+		if err := s.dropOldest(); err != nil {
+			return nil, err
+		}
+	}
+	if s.reader == nil {
+		reader, err := os.Open(s.path(s.segments[0].seq))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open spool segment: %w", err)
+		}
+		s.reader = reader
+	}
+
+	image, length, err := readRecord(io.NewSectionReader(s.reader, s.readOffset, s.segments[0].size-s.readOffset))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool record: %w", err)
+	}
+	s.peeked = length
+	return image, nil
+}
+
+// Ack removes the image returned by the last Peek from the spool.
+func (s *Spool) Ack() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.ack()
+}
+
+// DeadLetter moves the image returned by the last Peek from the spool to the dead-letter
+// file in the spool directory, where it is kept for inspection but never replayed.
+func (s *Spool) DeadLetter() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.peeked == 0 {
+		return nil
+	}
+	record := make([]byte, s.peeked)
+	if _, err := s.reader.ReadAt(record, s.readOffset); err != nil {
+		return fmt.Errorf("failed to read spool record: %w", err)
+	}
+
+	file, err := os.OpenFile(s.deadLetterPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open spool dead letters: %w", err)
+	}
+	defer file.Close()
+	if _, err := file.Write(record); err != nil {
+		file.Truncate(s.deadSize)
+		return fmt.Errorf("failed to write spool dead letter: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Truncate(s.deadSize)
+		return fmt.Errorf("failed to sync spool dead letters: %w", err)
+	}
+	s.deadRecords++
+	s.deadSize += s.peeked
+	return s.ack()
+}
+
+// ack removes the peeked record. Caller must hold s.mu.
+func (s *Spool) ack() error {
+	if s.peeked == 0 {
+		return nil
+	}
+	s.readOffset += s.peeked
+	s.records--
+	s.size -= s.peeked
+	s.peeked = 0
+
+	if s.readOffset < s.segments[0].size {
+		return nil
+	}
+	if len(s.segments) > 1 {
+		return s.dropOldest()
+	}
+
+	// Drained the segment being written to: start it over
+	if err := s.writer.Truncate(0); err != nil {
+		return fmt.Errorf("failed to reset spool segment: %w", err)
+	}
+	s.segments[0].size = 0
+	s.readOffset = 0
+	return nil
+}
+
+// Backlog returns the number and total size of the images waiting in the spool.
+func (s *Spool) Backlog() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.records, s.size
+}
+
+// DeadLetters returns the number and total size of the images in the dead-letter file.
+func (s *Spool) DeadLetters() (int, int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.deadRecords, s.deadSize
+}
+
+// Truncated returns the number of bytes of torn records removed when the spool was opened.
+func (s *Spool) Truncated() int64 {
+	return s.truncated
+}
+
+// Close closes the segment files.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	return s.writer.Close()
+}
+
+// dropOldest deletes the oldest segment once it has been drained.
+func (s *Spool) dropOldest() error {
+	if s.reader != nil {
+		s.reader.Close()
+		s.reader = nil
+	}
+	if err := os.Remove(s.path(s.segments[0].seq)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to delete spool segment: %w", err)
+	}
+	s.segments = s.segments[1:]
+	s.readOffset = 0
+	return nil
+}
+
+// openWriter opens a segment for appending.
+func (s *Spool) openWriter(seq uint64) (*os.File, error) {
+	file, err := os.OpenFile(s.path(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	return file, nil
+}
+
+// path returns the file path of a segment.
+func (s *Spool) path(seq uint64) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%020d%s", seq, spoolExt))
+}
+
+// deadLetterPath returns the file path of the dead-letter file.
+func (s *Spool) deadLetterPath() string {
+	return filepath.Join(s.dir, deadLetterFile)
+}
+
+// encodeRecord returns the journal record of an image.
+func encodeRecord(image dto.BufferedImage) ([]byte, error) {
+	meta, err := json.Marshal(spoolMeta{Filename: image.Filename, Timestamp: image.Timestamp, Camera: image.Camera,
+		Detections: image.Detections})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode spool record: %w", err)
+	}
+
+	record := make([]byte, spoolHeaderSize, spoolHeaderSize+len(meta)+len(image.Data))
+	copy(record, spoolMagic)
+	binary.BigEndian.PutUint32(record[4:], uint32(len(meta)))
+	binary.BigEndian.PutUint32(record[8:], uint32(len(image.Data)))
+	record = append(append(record, meta...), image.Data...)
+	binary.BigEndian.PutUint32(record[12:], crc32.ChecksumIEEE(record[spoolHeaderSize:]))
+	return record, nil
+}
+
+// readRecord reads one record and returns its image and length in bytes.
+func readRecord(r io.Reader) (*dto.BufferedImage, int64, error) {
+	header := make([]byte, spoolHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, 0, err
+	}
+	if !bytes.Equal(header[:4], []byte(spoolMagic)) {
+		return nil, 0, fmt.Errorf("bad record magic")
+	}
+	metaLen := binary.BigEndian.Uint32(header[4:])
+	dataLen := binary.BigEndian.Uint32(header[8:])
+	if int64(metaLen)+int64(dataLen) > maxRecordSize {
+		return nil, 0, fmt.Errorf("record too large")
+	}
+
+	body := make([]byte, int64(metaLen)+int64(dataLen))
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, 0, err
+	}
+	if crc32.ChecksumIEEE(body) != binary.BigEndian.Uint32(header[12:]) {
+		return nil, 0, fmt.Errorf("record checksum mismatch")
+	}
+
+	var meta spoolMeta
+	if err := json.Unmarshal(body[:metaLen], &meta); err != nil {
+		return nil, 0, fmt.Errorf("bad record metadata: %w", err)
+	}
+	image := &dto.BufferedImage{Filename: meta.Filename, Timestamp: meta.Timestamp, Camera: meta.Camera,
+		Detections: meta.Detections, Data: body[metaLen:]}
+	return image, spoolHeaderSize + int64(len(body)), nil
+}
+
+// scanSegment counts the intact records of a segment file and returns the offset after
+// the last one along with the file size.
+func scanSegment(path string) (int, int64, int64, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to stat spool segment: %w", err)
+	}
+
+	reader := bufio.NewReader(io.LimitReader(file, info.Size()))
+	records, valid := 0, int64(0)
+	for valid < info.Size() {
+		_, length, err := readRecord(reader)
+		if err != nil {
+			break
+		}
+		records++
+		valid += length
+	}
+	return records, valid, info.Size(), nil
+}
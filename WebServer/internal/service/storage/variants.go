@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"path"
+	"webserver/internal/model"
+	"webserver/internal/service/blob"
+)
+
+const (
+	// VariantThumb is the small variant shown in the gallery grid.
+	VariantThumb = "thumb"
+	// VariantMedium is the preview variant for viewing on smaller screens.
+	VariantMedium = "medium"
+	// VariantQuality is the JPEG quality of the generated variants.
+	VariantQuality = 80
+	// backfillBatch is the number of images loaded at once when backfilling variants.
+	backfillBatch = 50
+)
+
+// VariantSizes lists the generated variants, smallest first.
+var VariantSizes = []string{VariantThumb, VariantMedium}
+
+// VariantPath returns the blob key of a variant of the image stored under key, such as
+// thumb/gate/2024/05/01/<uuid>.jpg.
+func VariantPath(size, key string) string {
+	return path.Join(size, key)
+}
+
+// EncodeVariant decodes a JPEG image, scales it down to the given width keeping its
+// aspect ratio and returns the re-encoded JPEG with its dimensions. Images that are
+// already narrower keep their size.
+func EncodeVariant(data []byte, width int) ([]byte, int, int, error) {
+	src, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	scaled := Downscale(src, width)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, scaled, &jpeg.Options{Quality: VariantQuality}); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode image: %w", err)
+	}
+	bounds := scaled.Bounds()
+	return buf.Bytes(), bounds.Dx(), bounds.Dy(), nil
+}
+
+// Downscale returns the image scaled to the given width, averaging the source pixels
+// covered by each target pixel (box filter). Images that are already narrower are
+// returned unchanged.
+func Downscale(src image.Image, width int) image.Image {
+	bounds := src.Bounds()
+	sw, sh := bounds.Dx(), bounds.Dy()
+	if width <= 0 || sw <= width {
+		return src
+	}
+	height := max(1, sh*width/sw)
+
+	rgba := image.NewRGBA(image.Rect(0, 0, sw, sh))
+	draw.Draw(rgba, rgba.Bounds(), src, bounds.Min, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0, y1 := y*sh/height, max((y+1)*sh/height, y*sh/height+1)
+		for x := 0; x < width; x++ {
+			x0, x1 := x*sw/width, max((x+1)*sw/width, x*sw/width+1)
+
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := rgba.Pix[sy*rgba.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					p := row[sx*4 : sx*4+4]
+					r, g, b, a = r+int(p[0]), g+int(p[1]), b+int(p[2]), a+int(p[3])
+					n++
+				}
+			}
+
+			d := dst.Pix[y*dst.Stride+x*4:]
+			d[0], d[1], d[2], d[3] = uint8(r/n), uint8(g/n), uint8(b/n), uint8(a/n)
+		}
+	}
+	return dst
+}
+
+// saveVariants writes the thumbnail and preview of an image to the image's store and
+// records them for the image when a database is available. Files of an image whose row
+// was deleted meanwhile are removed again. It reports whether every variant was saved.
+func (s *BufferService) saveVariants(store blob.BlobStore, imageID int64, key string, data []byte) bool {
+	saved := true
+	for _, size := range VariantSizes {
+		encoded, width, height, err := EncodeVariant(data, s.variantWidths[size])
+		if err != nil {
+			s.logger.Error("Error creating %s of %s: %v", size, key, err)
+			return false
+		}
+
+		relative := VariantPath(size, key)
+		if err := store.Put(relative, encoded); err != nil {
+			s.logger.Error("Error saving %s of %s: %v", size, key, err)
+			saved = false
+			continue
+		}
+
+		if s.variantRepo == nil || imageID == 0 {
+			continue
+		}
+		variant := &model.ImageVariant{ImageID: imageID, Size: size, Filename: relative, Width: width,
+			Height: height, FileSize: int64(len(encoded))}
+		exists, err := s.variantRepo.Upsert(variant)
+		if err != nil {
+			s.logger.Error("Error saving %s of %s to database: %v", size, key, err)
+			saved = false
+			continue
+		}
+		if !exists {
+			// Deleted meanwhile, for example by retention, which did not see these files
+			s.deleteFiles(store, key)
+			return false
+		}
+	}
+	return saved
+}
+
+// BackfillVariants creates the missing thumbnails and previews of images stored
+// before variants were generated. Images whose file cannot be read, or whose
+// storage backend is not configured, are skipped.
+func (s *BufferService) BackfillVariants() {
+	if s.imageRepo == nil || s.variantRepo == nil {
+		return
+	}
+
+	var afterID int64
+	created := 0
+	for {
+		images, err := s.variantRepo.GetMissing(afterID, len(VariantSizes), backfillBatch)
+		if err != nil {
+			s.logger.Error("Error listing images without variants: %v", err)
+			return
+		}
+		if len(images) == 0 {
+			break
+		}
+
+		for _, img := range images {
+			afterID = img.ID
+			store, exists := s.stores.Get(img.Storage)
+			if !exists {
+				continue
+			}
+			key := KeyOf(store, img)
+			data, _, err := store.Get(key)
+			if err != nil {
+				s.logger.Warning("⚠️  Cannot create variants of %s: %v", img.Filename, err)
+				continue
+			}
+			if s.saveVariants(store, img.ID, key, data) {
+				created++
+			}
+		}
+	}
+
+	if created > 0 {
+		s.logger.Info("🖼️ Created thumbnails and previews of %d stored images", created)
+	}
+}
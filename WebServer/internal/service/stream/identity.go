@@ -0,0 +1,143 @@
+package stream
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/service/registry"
+)
+
+const (
+	// RejectLogInterval limits how often rejected traffic from one source is logged.
+	RejectLogInterval = time.Minute
+)
+
+var (
+	// ErrUnknownCamera is returned when a datagram names a camera ID without a registered secret.
+	ErrUnknownCamera = errors.New("unknown camera id")
+	// ErrCameraDisabled is returned for datagrams from cameras disabled in the registry.
+	ErrCameraDisabled = errors.New("camera disabled")
+	// ErrBadSignature is returned when a datagram's HMAC tag does not verify.
+	ErrBadSignature = errors.New("invalid signature")
+	// ErrUnauthenticated is returned for unsigned datagrams when IP fallback does not allow them.
+	ErrUnauthenticated = errors.New("unauthenticated datagram")
+	// ErrReplayed is returned for signed datagrams whose boot counter is lower than the
+	// camera's last accepted one.
+	ErrReplayed = errors.New("datagram from an earlier boot")
+)
+
+// rejection tracks dropped datagrams from a single source address.
+type rejection struct {
+	stats    dto.RejectedSource
+	unlogged uint64
+	lastLog  time.Time
+}
+
+// IdentityService resolves which camera sent a datagram using the camera registry.
+// Signed datagrams are verified against the per-camera secret and must not come from
+// an earlier boot than the registry has seen; unsigned ones are only accepted from
+// registered IPs when the IP fallback mode is enabled.
+type IdentityService struct {
+	registry   *registry.RegistryService
+	allowIP    bool
+	rejections map[string]*rejection
+	mu         sync.Mutex
+	logger     *logger.Logger
+}
+
+// NewIdentityService creates an IdentityService backed by the camera registry.
+func NewIdentityService(cfg *config.Config, logger *logger.Logger, registry *registry.RegistryService) *IdentityService {
+	service := &IdentityService{
+		registry:   registry,
+		allowIP:    cfg.CameraAuthMode == config.CameraAuthIP,
+		rejections: make(map[string]*rejection),
+		logger:     logger,
+	}
+
+	if service.allowIP {
+		service.logger.Warning("Camera IP fallback enabled - unsigned frames from known IPs are accepted")
+	}
+	return service
+}
+
+// Identify returns the camera name for a framed datagram received from ip.
+func (s *IdentityService) Identify(pkt *Packet, ip string) (string, error) {
+	if pkt.IsAuthenticated() {
+		cam, exists := s.registry.GetByDeviceID(pkt.CameraID)
+		if !exists {
+			return "", ErrUnknownCamera
+		}
+		if !pkt.Verify([]byte(cam.Secret)) {
+			return "", ErrBadSignature
+		}
+		if !cam.Enabled {
+			return "", ErrCameraDisabled
+		}
+		if !s.registry.AcceptBoot(cam.ID, pkt.Boot) {
+			return "", ErrReplayed
+		}
+		return cam.Name, nil
+	}
+	return s.IdentifyByIP(ip)
+}
+
+// IdentifyByIP returns the camera name for unsigned traffic (unsigned framed or legacy datagrams).
+func (s *IdentityService) IdentifyByIP(ip string) (string, error) {
+	if !s.allowIP {
+		return "", ErrUnauthenticated
+	}
+	cam, exists := s.registry.GetByIP(ip)
+	if !exists {
+		return "", ErrUnauthenticated
+	}
+	if !cam.Enabled {
+		return "", ErrCameraDisabled
+	}
+	return cam.Name, nil
+}
+
+// LookupName returns the registered name for a camera ID without verifying anything.
+func (s *IdentityService) LookupName(cameraID uint16) (string, bool) {
+	cam, exists := s.registry.GetByDeviceID(cameraID)
+	return cam.Name, exists
+}
+
+// Reject records a dropped datagram and logs it, at most once per RejectLogInterval per source.
+func (s *IdentityService) Reject(source string, reason error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	rej, exists := s.rejections[source]
+	if !exists {
+		rej = &rejection{stats: dto.RejectedSource{Source: source}}
+		s.rejections[source] = rej
+	}
+	rej.stats.Count++
+	rej.stats.LastReason = reason.Error()
+	rej.stats.LastSeen = now
+	rej.unlogged++
+
+	if now.Sub(rej.lastLog) >= RejectLogInterval {
+		s.logger.Warning("🚫 Rejected %d packet(s) from %s: %v", rej.unlogged, source, reason)
+		rej.unlogged = 0
+		rej.lastLog = now
+	}
+}
+
+// GetRejections returns a snapshot of rejected traffic per source, sorted by address.
+func (s *IdentityService) GetRejections() []dto.RejectedSource {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rejections := make([]dto.RejectedSource, 0, len(s.rejections))
+	for _, rej := range s.rejections {
+		rejections = append(rejections, rej.stats)
+	}
+	sort.Slice(rejections, func(i, j int) bool { return rejections[i].Source < rejections[j].Source })
+	return rejections
+}
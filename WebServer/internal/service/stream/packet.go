@@ -0,0 +1,163 @@
+package stream
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+const (
+	// ProtocolVersion is the current version of the framed datagram header.
+	ProtocolVersion = 1
+	// HeaderSize is the length in bytes of the framed datagram header.
+	HeaderSize = 18
+	// MaxChunkCount bounds how many chunks a single frame may be split into.
+	MaxChunkCount = 1024
+	// BootSize is the length of the boot counter authenticated datagrams carry after the header.
+	BootSize = 4
+	// TagSize is the length of the truncated HMAC-SHA256 tag trailing authenticated datagrams.
+	TagSize = 16
+	// FlagAuthenticated marks datagrams that carry an HMAC tag after the payload.
+	FlagAuthenticated = 0x01
+)
+
+// Magic marks the start of a framed datagram ("SC").
+var Magic = [2]byte{0x53, 0x43}
+
+var (
+	// ErrNotFramed is returned for datagrams that do not carry a framed header
+	// and should be treated as part of a legacy headerless JPEG stream.
+	ErrNotFramed = errors.New("datagram is not framed")
+	// ErrChecksum is returned when the payload CRC does not match the header.
+	// The returned packet still carries the decoded header.
+	ErrChecksum = errors.New("payload checksum mismatch")
+)
+
+// Packet is a single framed datagram carrying one chunk of a JPEG frame.
+//
+// Wire layout (big-endian):
+//
+//	0  magic        [2]byte "SC"
+//	2  version      uint8
+//	3  flags        uint8
+//	4  camera id    uint16
+//	6  frame id     uint32
+//	10 chunk index  uint16
+//	12 chunk count  uint16
+//	14 crc32        uint32 (IEEE, over payload)
+//	18 boot counter uint32, only with FlagAuthenticated
+//	.. payload
+//	.. tag          [16]byte HMAC-SHA256(secret, header+boot+payload), only with FlagAuthenticated
+//
+// The boot counter is kept in the camera's flash and incremented at every boot. It is
+// covered by the tag, so a recorded datagram cannot claim a later boot to restart the stream.
+type Packet struct {
+	Version    uint8
+	Flags      uint8
+	CameraID   uint16
+	FrameID    uint32
+	ChunkIndex uint16
+	ChunkCount uint16
+	Boot       uint32
+	Payload    []byte
+	Tag        []byte
+
+	signed []byte
+}
+
+// ParsePacket decodes a framed datagram. It returns ErrNotFramed when the data
+// does not start with the protocol magic so callers can fall back to the legacy stream.
+func ParsePacket(data []byte) (*Packet, error) {
+	if len(data) < HeaderSize || data[0] != Magic[0] || data[1] != Magic[1] {
+		return nil, ErrNotFramed
+	}
+
+	pkt := &Packet{
+		Version:    data[2],
+		Flags:      data[3],
+		CameraID:   binary.BigEndian.Uint16(data[4:6]),
+		FrameID:    binary.BigEndian.Uint32(data[6:10]),
+		ChunkIndex: binary.BigEndian.Uint16(data[10:12]),
+		ChunkCount: binary.BigEndian.Uint16(data[12:14]),
+		Payload:    data[HeaderSize:],
+		signed:     data,
+	}
+
+	if pkt.IsAuthenticated() {
+		if len(data) < HeaderSize+BootSize+TagSize {
+			return nil, fmt.Errorf("authenticated datagram too short: %d bytes", len(data))
+		}
+		pkt.Boot = binary.BigEndian.Uint32(data[HeaderSize : HeaderSize+BootSize])
+		pkt.Payload = data[HeaderSize+BootSize : len(data)-TagSize]
+		pkt.Tag = data[len(data)-TagSize:]
+		pkt.signed = data[:len(data)-TagSize]
+	}
+
+	if pkt.Version != ProtocolVersion {
+		return nil, fmt.Errorf("unsupported protocol version %d", pkt.Version)
+	}
+	if pkt.ChunkCount == 0 || pkt.ChunkCount > MaxChunkCount {
+		return nil, fmt.Errorf("invalid chunk count %d", pkt.ChunkCount)
+	}
+	if pkt.ChunkIndex >= pkt.ChunkCount {
+		return nil, fmt.Errorf("chunk index %d out of range (count %d)", pkt.ChunkIndex, pkt.ChunkCount)
+	}
+	if crc32.ChecksumIEEE(pkt.Payload) != binary.BigEndian.Uint32(data[14:18]) {
+		return pkt, ErrChecksum
+	}
+
+	return pkt, nil
+}
+
+// IsAuthenticated reports whether the datagram carries an HMAC tag.
+func (p *Packet) IsAuthenticated() bool {
+	return p.Flags&FlagAuthenticated != 0
+}
+
+// Verify checks the packet's HMAC tag against the camera's shared secret.
+func (p *Packet) Verify(secret []byte) bool {
+	if !p.IsAuthenticated() || len(secret) == 0 {
+		return false
+	}
+	return hmac.Equal(p.Tag, computeTag(secret, p.signed))
+}
+
+// Marshal encodes the packet into its wire representation, without a tag.
+func (p *Packet) Marshal() []byte {
+	offset := HeaderSize
+	if p.IsAuthenticated() {
+		offset += BootSize
+	}
+
+	buf := make([]byte, offset+len(p.Payload))
+	buf[0], buf[1] = Magic[0], Magic[1]
+	buf[2] = p.Version
+	buf[3] = p.Flags
+	binary.BigEndian.PutUint16(buf[4:6], p.CameraID)
+	binary.BigEndian.PutUint32(buf[6:10], p.FrameID)
+	binary.BigEndian.PutUint16(buf[10:12], p.ChunkIndex)
+	binary.BigEndian.PutUint16(buf[12:14], p.ChunkCount)
+	binary.BigEndian.PutUint32(buf[14:18], crc32.ChecksumIEEE(p.Payload))
+	if p.IsAuthenticated() {
+		binary.BigEndian.PutUint32(buf[HeaderSize:offset], p.Boot)
+	}
+	copy(buf[offset:], p.Payload)
+	return buf
+}
+
+// Sign encodes the packet with FlagAuthenticated set and an HMAC tag appended.
+func (p *Packet) Sign(secret []byte) []byte {
+	p.Flags |= FlagAuthenticated
+	buf := p.Marshal()
+	return append(buf, computeTag(secret, buf)...)
+}
+
+// computeTag returns the truncated HMAC-SHA256 of data.
+func computeTag(secret, data []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(data)
+	return mac.Sum(nil)[:TagSize]
+}
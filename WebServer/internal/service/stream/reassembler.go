@@ -0,0 +1,381 @@
+package stream
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+)
+
+const (
+	// MaxPendingFrames limits how many partially received frames are kept per camera.
+	MaxPendingFrames = 8
+	// MaxLegacyFrameSize bounds the legacy reassembly buffer so a missing footer cannot grow it forever.
+	MaxLegacyFrameSize = 1 << 20
+	// StreamResetWindow is how far (in frame IDs) a frame may lag behind the last delivered
+	// one before it is treated as a camera restart rather than a late packet. Signed streams
+	// only restart with a higher boot counter.
+	StreamResetWindow = 1000
+)
+
+var (
+	jpegHeader = []byte{0xFF, 0xD8}
+	jpegFooter = []byte{0xFF, 0xD9}
+)
+
+// partialFrame collects the chunks of a single framed JPEG.
+type partialFrame struct {
+	chunks    [][]byte
+	received  int
+	size      int
+	nextIndex uint16
+	reordered bool
+	firstSeen time.Time
+}
+
+// cameraStream holds reassembly state and link counters for one camera.
+type cameraStream struct {
+	pending       map[uint32]*partialFrame
+	expired       []uint32
+	lastDelivered uint32
+	delivered     bool
+	legacy        bytes.Buffer
+	legacyStarted time.Time
+	boot          uint32
+	hasBoot       bool
+	stats         dto.LinkStats
+}
+
+// ReassemblerService rebuilds JPEG frames from UDP datagrams. Framed datagrams are
+// reassembled by frame ID and chunk index, tolerating reordering; headerless datagrams
+// fall back to the legacy 0xFFD8/0xFFD9 marker scan.
+type ReassemblerService struct {
+	streams map[string]*cameraStream
+	timeout time.Duration
+	mu      sync.Mutex
+	logger  *logger.Logger
+}
+
+// NewReassemblerService creates a ReassemblerService using the configured frame timeout.
+func NewReassemblerService(config *config.Config, logger *logger.Logger) *ReassemblerService {
+	timeout := time.Duration(config.FrameTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+	return &ReassemblerService{
+		streams: make(map[string]*cameraStream),
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Run periodically drops frames that did not complete within the timeout.
+func (s *ReassemblerService) Run() {
+	ticker := time.NewTicker(s.timeout)
+
+	defer ticker.Stop()
+	for {
+		<-ticker.C
+		s.ExpireStale()
+	}
+}
+
+// AddChunk stores a framed chunk for the given camera and returns the complete
+// JPEG once all chunks of its frame have arrived, or nil otherwise.
+func (s *ReassemblerService) AddChunk(camera string, pkt *Packet) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	st := s.getStream(camera)
+	st.stats.Packets++
+	st.stats.Bytes += uint64(HeaderSize + len(pkt.Payload))
+	st.stats.LastPacket = now
+	s.expire(st, now)
+
+	if pkt.IsAuthenticated() && !s.checkBoot(camera, st, pkt.Boot) {
+		st.stats.Replayed++
+		return nil
+	}
+
+	if st.delivered {
+		behind := int32(pkt.FrameID - st.lastDelivered)
+		switch {
+		case behind < -StreamResetWindow && !pkt.IsAuthenticated():
+			s.logger.Info("Camera %s restarted its frame sequence (frame %d after %d)", camera, pkt.FrameID, st.lastDelivered)
+			s.resetStream(st)
+		case behind == 0:
+			st.stats.Duplicates++
+			return nil
+		case behind < 0:
+			st.stats.Late++
+			return nil
+		}
+	}
+
+	frame, ok := st.pending[pkt.FrameID]
+	if !ok {
+		if len(st.pending) >= MaxPendingFrames {
+			s.evictOldest(st)
+		}
+		frame = &partialFrame{
+			chunks:    make([][]byte, pkt.ChunkCount),
+			firstSeen: now,
+		}
+		st.pending[pkt.FrameID] = frame
+	}
+
+	if int(pkt.ChunkCount) != len(frame.chunks) {
+		st.stats.Corrupted++
+		return nil
+	}
+	if frame.chunks[pkt.ChunkIndex] != nil {
+		st.stats.Duplicates++
+		return nil
+	}
+
+	if pkt.ChunkIndex < frame.nextIndex {
+		frame.reordered = true
+	} else {
+		frame.nextIndex = pkt.ChunkIndex + 1
+	}
+
+	payload := make([]byte, len(pkt.Payload))
+	copy(payload, pkt.Payload)
+	frame.chunks[pkt.ChunkIndex] = payload
+	frame.received++
+	frame.size += len(payload)
+
+	if frame.received < len(frame.chunks) {
+		return nil
+	}
+
+	return s.deliver(st, pkt.FrameID, frame)
+}
+
+// AddLegacy appends a headerless datagram to the camera's legacy buffer and returns
+// the complete JPEG once its end-of-image marker arrives, or nil otherwise.
+func (s *ReassemblerService) AddLegacy(camera string, data []byte) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	st := s.getStream(camera)
+	st.stats.Packets++
+	st.stats.Bytes += uint64(len(data))
+	st.stats.LastPacket = now
+
+	if bytes.HasPrefix(data, jpegHeader) {
+		if st.legacy.Len() > 0 {
+			st.stats.Incomplete++
+		}
+		st.legacy.Reset()
+		st.legacyStarted = now
+	} else if st.legacy.Len() == 0 {
+		// Continuation of a frame whose start was lost
+		return nil
+	}
+
+	st.legacy.Write(data)
+	if st.legacy.Len() > MaxLegacyFrameSize {
+		st.stats.Incomplete++
+		st.legacy.Reset()
+		return nil
+	}
+
+	if !bytes.HasSuffix(data, jpegFooter) {
+		return nil
+	}
+
+	fullFrame := make([]byte, st.legacy.Len())
+	copy(fullFrame, st.legacy.Bytes())
+	st.legacy.Reset()
+	st.stats.Frames++
+	st.stats.LegacyFrames++
+	return fullFrame
+}
+
+// RecordReplayed counts a signed datagram rejected because it belongs to an earlier boot.
+func (s *ReassemblerService) RecordReplayed(camera string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.getStream(camera)
+	st.stats.Packets++
+	st.stats.Replayed++
+	st.stats.LastPacket = time.Now()
+}
+
+// RecordCorrupted counts a datagram that failed header or checksum validation.
+func (s *ReassemblerService) RecordCorrupted(camera string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := s.getStream(camera)
+	st.stats.Packets++
+	st.stats.Corrupted++
+	st.stats.LastPacket = time.Now()
+}
+
+// ExpireStale drops partially received frames older than the timeout for all cameras.
+func (s *ReassemblerService) ExpireStale() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, st := range s.streams {
+		s.expire(st, now)
+	}
+}
+
+// RenameCamera moves the stream state and link counters of a renamed camera.
+func (s *ReassemblerService) RenameCamera(oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config.RenameCameraKey(s.streams, oldName, newName)
+	if st, exists := s.streams[newName]; exists {
+		st.stats.Camera = newName
+	}
+}
+
+// GetStats returns a snapshot of link counters for every camera, sorted by name.
+func (s *ReassemblerService) GetStats() []dto.LinkStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := make([]dto.LinkStats, 0, len(s.streams))
+	for _, st := range s.streams {
+		snapshot := st.stats
+		snapshot.Pending = len(st.pending)
+		stats = append(stats, snapshot)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Camera < stats[j].Camera })
+	return stats
+}
+
+// deliver joins the chunks of a completed frame and updates loss counters. Pending
+// frames older than the delivered one can no longer be emitted and are dropped.
+func (s *ReassemblerService) deliver(st *cameraStream, frameID uint32, frame *partialFrame) []byte {
+	delete(st.pending, frameID)
+
+	accounted := uint64(0)
+	for id := range st.pending {
+		if int32(id-frameID) < 0 {
+			delete(st.pending, id)
+			st.stats.Incomplete++
+			if st.delivered && int32(id-st.lastDelivered) > 0 {
+				accounted++
+			}
+		}
+	}
+
+	remaining := st.expired[:0]
+	for _, id := range st.expired {
+		if int32(id-frameID) < 0 {
+			if st.delivered && int32(id-st.lastDelivered) > 0 {
+				accounted++
+			}
+			continue
+		}
+		remaining = append(remaining, id)
+	}
+	st.expired = remaining
+
+	if st.delivered {
+		if gap := uint64(frameID-st.lastDelivered) - 1; gap > accounted {
+			st.stats.Lost += gap - accounted
+		}
+	}
+
+	if frame.reordered {
+		st.stats.Reordered++
+	}
+	st.stats.Frames++
+	st.lastDelivered = frameID
+	st.delivered = true
+
+	fullFrame := make([]byte, 0, frame.size)
+	for _, chunk := range frame.chunks {
+		fullFrame = append(fullFrame, chunk...)
+	}
+	return fullFrame
+}
+
+// expire drops pending frames and legacy data that exceeded the timeout.
+func (s *ReassemblerService) expire(st *cameraStream, now time.Time) {
+	for id, frame := range st.pending {
+		if now.Sub(frame.firstSeen) > s.timeout {
+			delete(st.pending, id)
+			st.expired = append(st.expired, id)
+			st.stats.Incomplete++
+		}
+	}
+
+	if st.legacy.Len() > 0 && now.Sub(st.legacyStarted) > s.timeout {
+		st.legacy.Reset()
+		st.stats.Incomplete++
+	}
+}
+
+// evictOldest drops the pending frame that started first to make room for a new one.
+func (s *ReassemblerService) evictOldest(st *cameraStream) {
+	var oldestID uint32
+	var oldest *partialFrame
+	for id, frame := range st.pending {
+		if oldest == nil || frame.firstSeen.Before(oldest.firstSeen) {
+			oldestID, oldest = id, frame
+		}
+	}
+	if oldest != nil {
+		delete(st.pending, oldestID)
+		st.expired = append(st.expired, oldestID)
+		st.stats.Incomplete++
+	}
+}
+
+// checkBoot tracks the boot counter of a signed stream. A higher counter means the camera
+// rebooted and restarts the frame sequence; lower counters belong to earlier boots and are
+// replays. The registry keeps the order across server restarts (see IdentityService).
+func (s *ReassemblerService) checkBoot(camera string, st *cameraStream, boot uint32) bool {
+	if !st.hasBoot {
+		st.boot = boot
+		st.hasBoot = true
+		return true
+	}
+	if boot == st.boot {
+		return true
+	}
+	if boot < st.boot {
+		return false
+	}
+
+	s.logger.Info("Camera %s rebooted (boot %d)", camera, boot)
+	st.boot = boot
+	s.resetStream(st)
+	return true
+}
+
+// resetStream forgets sequence state after a camera restarts its frame counter.
+func (s *ReassemblerService) resetStream(st *cameraStream) {
+	st.stats.Incomplete += uint64(len(st.pending))
+	st.pending = make(map[uint32]*partialFrame)
+	st.expired = st.expired[:0]
+	st.delivered = false
+}
+
+// getStream returns the per-camera state, creating it when absent. Caller must hold s.mu.
+func (s *ReassemblerService) getStream(camera string) *cameraStream {
+	st, exists := s.streams[camera]
+	if !exists {
+		st = &cameraStream{
+			pending: make(map[uint32]*partialFrame),
+			stats:   dto.LinkStats{Camera: camera},
+		}
+		s.streams[camera] = st
+	}
+	return st
+}
@@ -0,0 +1,83 @@
+package tracking
+
+import (
+	"time"
+	"webserver/internal/dto"
+)
+
+const (
+	// DefaultStationaryIoU is the minimum box overlap for a detection to count as the same
+	// stationary object.
+	DefaultStationaryIoU = 0.8
+)
+
+// stationaryObject is a detection remembered between saved frames, such as a parked car.
+type stationaryObject struct {
+	box   dto.DetectionResult
+	saved time.Time // when a saved frame last showed the object
+	seen  time.Time // when the object was last detected in place
+}
+
+// Unchanged reports whether every detection of a camera's frame is a stationary object
+// that was already saved less than the refresh interval ago, so the frame does not need
+// to be saved again. Detections match remembered objects of the same label whose boxes
+// overlap by at least STATIONARY_IOU. When the frame is to be saved, its detections are
+// remembered as saved at timestamp. Objects unseen for the refresh interval are forgotten.
+// A zero refresh interval disables suppression.
+func (s *TrackingService) Unchanged(camera string, detections []dto.DetectionResult, timestamp time.Time) bool {
+	if s.refresh <= 0 || len(detections) == 0 {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var objects []*stationaryObject
+	for _, o := range s.stationary[camera] {
+		if timestamp.Sub(o.seen) <= s.refresh {
+			objects = append(objects, o)
+		}
+	}
+
+	matches := make([]*stationaryObject, len(detections))
+	used := make(map[*stationaryObject]bool)
+	unchanged := true
+	for di, d := range detections {
+		var best *stationaryObject
+		bestIoU := s.stationaryIoU
+		for _, o := range objects {
+			if used[o] || o.box.Label != d.Label {
+				continue
+			}
+			if overlap := iou(o.box, d); overlap >= bestIoU {
+				best, bestIoU = o, overlap
+			}
+		}
+		if best == nil {
+			unchanged = false
+			continue
+		}
+		used[best] = true
+		matches[di] = best
+		if timestamp.Sub(best.saved) >= s.refresh {
+			unchanged = false
+		}
+	}
+
+	for di, d := range detections {
+		o := matches[di]
+		if o == nil {
+			o = &stationaryObject{box: d}
+			objects = append(objects, o)
+		}
+		if timestamp.After(o.seen) {
+			o.seen = timestamp
+		}
+		if !unchanged {
+			o.box, o.saved = d, timestamp
+		}
+	}
+
+	s.stationary[camera] = objects
+	return unchanged
+}
@@ -0,0 +1,269 @@
+package tracking
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/logger"
+	"webserver/internal/model"
+	"webserver/internal/repository"
+)
+
+const (
+	// DefaultIoUThreshold is the minimum box overlap for a detection to continue a track.
+	DefaultIoUThreshold = 0.3
+	// DefaultMaxAge is how long a track waits for its object to be seen again.
+	DefaultMaxAge = 5 * time.Second
+	// MaxPathPoints bounds the stored path; the first point and the most recent ones are kept.
+	MaxPathPoints = 100
+)
+
+// TrackingService follows objects across the analysed frames of each camera (SORT-style,
+// without motion prediction): detections are matched to the camera's open tracks of the
+// same label by bounding-box overlap, falling back to the distance between box centres,
+// and unmatched detections start new tracks. Tracks not seen for the maximum age are closed.
+type TrackingService struct {
+	iouThreshold  float64
+	maxAge        time.Duration
+	cameras       map[string][]*openTrack // open tracks per camera
+	nextID        int64                   // track IDs without a database
+	stationaryIoU float64
+	refresh       time.Duration                  // how often unchanged stationary objects are saved again
+	stationary    map[string][]*stationaryObject // remembered stationary objects per camera
+	mu            sync.Mutex
+	trackRepo     repository.TrackRepository
+	logger        *logger.Logger
+}
+
+// openTrack is a track that can still be continued, with the object's latest box.
+type openTrack struct {
+	track model.Track
+	box   dto.DetectionResult
+}
+
+// candidate is a possible match between an open track and a detection.
+type candidate struct {
+	track, detection int
+	score            float64
+}
+
+// NewTrackingService creates a TrackingService using TRACK_IOU and TRACK_MAX_AGE, and
+// STATIONARY_IOU and STATIONARY_REFRESH for suppressing unchanged stationary objects.
+func NewTrackingService(config *config.Config, logger *logger.Logger, trackRepo repository.TrackRepository) *TrackingService {
+	iouThreshold := config.TrackIoU
+	if iouThreshold <= 0 || iouThreshold > 1 {
+		iouThreshold = DefaultIoUThreshold
+	}
+	maxAge := time.Duration(config.TrackMaxAgeS) * time.Second
+	if maxAge <= 0 {
+		maxAge = DefaultMaxAge
+	}
+	stationaryIoU := config.StationaryIoU
+	if stationaryIoU <= 0 || stationaryIoU > 1 {
+		stationaryIoU = DefaultStationaryIoU
+	}
+
+	return &TrackingService{
+		iouThreshold:  iouThreshold,
+		maxAge:        maxAge,
+		cameras:       make(map[string][]*openTrack),
+		stationaryIoU: stationaryIoU,
+		refresh:       time.Duration(config.StationaryRefreshS) * time.Second,
+		stationary:    make(map[string][]*stationaryObject),
+		trackRepo:     trackRepo,
+		logger:        logger,
+	}
+}
+
+// Update assigns a track ID to each detection of a camera's frame taken at timestamp
+// and returns the detections with their TrackID set. Track changes are saved right away
+// so the IDs can be stored with the detections.
+func (s *TrackingService) Update(camera string, detections []dto.DetectionResult, timestamp time.Time) []dto.DetectionResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracks := s.expire(camera, timestamp)
+
+	var candidates []candidate
+	for ti, t := range tracks {
+		for di, d := range detections {
+			if t.track.Label != d.Label {
+				continue
+			}
+			if overlap := iou(t.box, d); overlap >= s.iouThreshold {
+				candidates = append(candidates, candidate{track: ti, detection: di, score: overlap})
+				continue
+			}
+			// Objects that moved too far for their boxes to overlap are matched by centre
+			// distance within one box diagonal, ranked below every overlap match
+			if reach := diagonal(t.box); reach > 0 {
+				if distance := centreDistance(t.box, d); distance <= reach {
+					candidates = append(candidates, candidate{track: ti, detection: di, score: -distance / reach})
+				}
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].score > candidates[j].score })
+
+	matchedTracks := make(map[int]bool)
+	matchedDetections := make(map[int]bool)
+	for _, c := range candidates {
+		if matchedTracks[c.track] || matchedDetections[c.detection] {
+			continue
+		}
+		matchedTracks[c.track], matchedDetections[c.detection] = true, true
+
+		t := tracks[c.track]
+		t.extend(detections[c.detection], timestamp)
+		if s.trackRepo != nil {
+			if err := s.trackRepo.Update(&t.track); err != nil {
+				s.logger.Error("Failed to update track %d: %v", t.track.ID, err)
+			}
+		}
+		detections[c.detection].TrackID = t.track.ID
+	}
+
+	for di, d := range detections {
+		if matchedDetections[di] {
+			continue
+		}
+		t := &openTrack{
+			track: model.Track{Camera: camera, Label: d.Label, StartTime: timestamp, EndTime: timestamp,
+				Path: []model.TrackPoint{centre(d, timestamp)}},
+			box: d,
+		}
+		if !s.save(t) {
+			continue
+		}
+		tracks = append(tracks, t)
+		detections[di].TrackID = t.track.ID
+	}
+
+	s.cameras[camera] = tracks
+	return detections
+}
+
+// Active returns the open tracks of a camera, or of all cameras when camera is empty.
+func (s *TrackingService) Active(camera string) []model.Track {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tracks := []model.Track{}
+	for name, open := range s.cameras {
+		if camera != "" && name != camera {
+			continue
+		}
+		for _, t := range open {
+			tracks = append(tracks, t.track)
+		}
+	}
+	sort.Slice(tracks, func(i, j int) bool { return tracks[i].ID < tracks[j].ID })
+	return tracks
+}
+
+// RenameCamera moves the open tracks and remembered stationary objects of a renamed
+// camera. The database rows are renamed together with the camera.
+func (s *TrackingService) RenameCamera(oldName, newName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	config.RenameCameraKey(s.cameras, oldName, newName)
+	config.RenameCameraKey(s.stationary, oldName, newName)
+	for _, t := range s.cameras[newName] {
+		t.track.Camera = newName
+	}
+}
+
+// expire closes the camera's tracks whose object was last seen more than the maximum
+// age before timestamp and returns the remaining ones.
+func (s *TrackingService) expire(camera string, timestamp time.Time) []*openTrack {
+	open := s.cameras[camera]
+	kept := open[:0]
+	for _, t := range open {
+		if timestamp.Sub(t.track.EndTime) <= s.maxAge {
+			kept = append(kept, t)
+			continue
+		}
+		s.logger.Info("🎯 Track %d (%s) on camera %s ended after %.1fs", t.track.ID, t.track.Label, camera,
+			t.track.DwellSeconds)
+	}
+	return kept
+}
+
+// save stores a new track and assigns its ID. Without a database IDs are counted in memory.
+func (s *TrackingService) save(t *openTrack) bool {
+	if s.trackRepo == nil {
+		s.nextID++
+		t.track.ID = s.nextID
+		return true
+	}
+
+	id, err := s.trackRepo.Insert(&t.track)
+	if err != nil {
+		s.logger.Error("Failed to save track: %v", err)
+		return false
+	}
+	t.track.ID = id
+	return true
+}
+
+// extend adds a detection to the track. Frames analysed out of order are inserted
+// into the path by timestamp.
+func (t *openTrack) extend(d dto.DetectionResult, timestamp time.Time) {
+	point := centre(d, timestamp)
+	i := len(t.track.Path)
+	for i > 0 && t.track.Path[i-1].Timestamp.After(timestamp) {
+		i--
+	}
+	t.track.Path = append(t.track.Path, model.TrackPoint{})
+	copy(t.track.Path[i+1:], t.track.Path[i:])
+	t.track.Path[i] = point
+
+	if len(t.track.Path) > MaxPathPoints {
+		t.track.Path = append(t.track.Path[:1], t.track.Path[2:]...)
+	}
+
+	if timestamp.Before(t.track.StartTime) {
+		t.track.StartTime = timestamp
+	}
+	if timestamp.After(t.track.EndTime) {
+		t.track.EndTime = timestamp
+		t.box = d
+	}
+	t.track.DwellSeconds = t.track.EndTime.Sub(t.track.StartTime).Seconds()
+}
+
+// iou returns the intersection over union of two boxes.
+func iou(a, b dto.DetectionResult) float64 {
+	x0, y0 := max(a.X, b.X), max(a.Y, b.Y)
+	x1, y1 := min(a.X+a.Width, b.X+b.Width), min(a.Y+a.Height, b.Y+b.Height)
+	if x1 <= x0 || y1 <= y0 {
+		return 0
+	}
+
+	intersection := float64((x1 - x0) * (y1 - y0))
+	union := float64(a.Width*a.Height+b.Width*b.Height) - intersection
+	if union <= 0 {
+		return 0
+	}
+	return intersection / union
+}
+
+// centre returns the centre of a detection's box as a path point.
+func centre(d dto.DetectionResult, timestamp time.Time) model.TrackPoint {
+	return model.TrackPoint{X: d.X + d.Width/2, Y: d.Y + d.Height/2, Timestamp: timestamp}
+}
+
+// centreDistance returns the distance between the centres of two boxes in pixels.
+func centreDistance(a, b dto.DetectionResult) float64 {
+	ca, cb := centre(a, time.Time{}), centre(b, time.Time{})
+	return math.Hypot(float64(ca.X-cb.X), float64(ca.Y-cb.Y))
+}
+
+// diagonal returns the length of a box's diagonal in pixels.
+func diagonal(d dto.DetectionResult) float64 {
+	return math.Hypot(float64(d.Width), float64(d.Height))
+}
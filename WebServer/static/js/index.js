@@ -2,21 +2,57 @@ class CameraMonitor {
     constructor() {
         this.socket = null;
         this.connectionBadge = document.getElementById('connection-badge');
-        this.cameraStatus = {
-            brama: document.getElementById('status_brama'),
-            drzwi: document.getElementById('status_drzwi')
-        };
-        this.cameraActivity = {
-            brama: Date.now(),
-            drzwi: Date.now()
-        };
+        this.camerasGrid = document.getElementById('cameras-grid');
+        this.cameraStatus = {};
         
         this.init();
     }
 
-    init() {
+    async init() {
+        await this.loadCameras();
+        await this.loadCameraStatus();
         this.connectWebSocket();
-        this.startActivityChecker();
+    }
+
+    async loadCameras() {
+        try {
+            const response = await fetch('/api/cameras');
+            const cameras = await response.json();
+            cameras.filter(camera => camera.enabled).forEach(camera => this.addCameraCard(camera.name));
+        } catch (error) {
+            console.error("Błąd podczas pobierania listy kamer:", error);
+        }
+    }
+
+    async loadCameraStatus() {
+        try {
+            const response = await fetch('/api/cameras/status');
+            const statuses = await response.json();
+            statuses.forEach(status => this.applyHealth(status));
+        } catch (error) {
+            console.error("Błąd podczas pobierania stanu kamer:", error);
+        }
+    }
+
+    addCameraCard(camera) {
+        const card = document.createElement('div');
+        card.className = 'camera-card';
+        card.innerHTML = `
+            <div class="camera-title">
+                <div class="camera-icon">📹</div>
+                <span></span>
+            </div>
+            <div class="camera-container">
+                <div class="camera-placeholder">Oczekiwanie na sygnał z kamery...</div>
+                <div class="status-indicator"></div>
+                <img class="camera-image" style="display: none;" />
+            </div>`;
+        card.querySelector('.camera-title span').textContent = 'Kamera ' + camera;
+        card.querySelector('.status-indicator').id = 'status_' + camera;
+        card.querySelector('.camera-image').id = 'camera_' + camera;
+        this.camerasGrid.appendChild(card);
+
+        this.cameraStatus[camera] = card.querySelector('.status-indicator');
     }
 
     connectWebSocket() {
@@ -32,6 +68,11 @@ class CameraMonitor {
     handleMessage(event) {
         try {
             const data = JSON.parse(event.data);
+            if (data.type === 'status') {
+                this.applyHealth(data);
+                return;
+            }
+
             const base64Image = data.image;
             const camera = data.camera;
 
@@ -51,6 +92,7 @@ class CameraMonitor {
     handleOpen(event) {
         console.log("Połączenie WebSocket nawiązane");
         this.updateConnectionStatus(true);
+        this.loadCameraStatus();
     }
 
     handleClose(event) {
@@ -83,9 +125,16 @@ class CameraMonitor {
         }
     }
 
+    applyHealth(health) {
+        this.updateCameraStatus(health.camera, health.status === 'online');
+        if (this.cameraStatus[health.camera]) {
+            this.cameraStatus[health.camera].title = health.status + ' (' + health.fps.toFixed(1) + ' FPS)';
+        }
+    }
+
     showImage(camera, src) {
         const img = document.getElementById("camera_" + camera);
-        const placeholder = img.parentNode.querySelector('.camera-placeholder');
+        const placeholder = img ? img.parentNode.querySelector('.camera-placeholder') : null;
         
         if (img && placeholder) {
             img.onload = () => {
@@ -94,20 +143,8 @@ class CameraMonitor {
                 img.style.opacity = '1';
             };
             img.src = src;
-            
-            this.cameraActivity[camera] = Date.now();
-            this.updateCameraStatus(camera, true);
         }
     }
-    startActivityChecker() {
-        setInterval(() => {
-            const now = Date.now();
-            Object.keys(this.cameraActivity).forEach(camera => {
-                const isActive = (now - this.cameraActivity[camera]) < 10000; 
-                this.updateCameraStatus(camera, isActive);
-            });
-        }, 5000);
-    }
 }
 
 document.addEventListener('DOMContentLoaded', () => {
@@ -39,6 +39,7 @@ async function loadPictures(page = 1) {
         const data = await response.json();
 
         displayPictures(data);
+        displayCameraOptions(data);
         displayPagination(data);
         updateInfo(data);
         updateSizeBar(data);
@@ -52,6 +53,18 @@ async function loadPictures(page = 1) {
     }
 }
 
+function displayCameraOptions(data) {
+    const options = document.getElementById('cameraOptions');
+    if (!options || !data.cameras) return;
+
+    options.innerHTML = '';
+    data.cameras.forEach(camera => {
+        const option = document.createElement('option');
+        option.value = camera;
+        options.appendChild(option);
+    });
+}
+
 function displayPictures(data) {
     const gallery = document.getElementById('gallery');
     const emptyMessage = document.getElementById('empty');
@@ -72,7 +85,7 @@ function displayPictures(data) {
         card.className = 'photo-card';
         card.dataset.filename = picture.name;
         
-        const imagePath = `${data.imagesDir}/${picture.name}`;
+        const imagePath = picture.thumbnail || `/api/pictures/view?image=${encodeURIComponent(picture.name)}`;
 
         card.innerHTML = `
             <div class="photo-image-container">
@@ -82,6 +95,7 @@ function displayPictures(data) {
                         onerror="this.parentElement.innerHTML='<div class=\'image-error\'>Błąd ładowania</div>'">
                 <div class="photo-overlay">
                     <button class="btn-view" onclick="openPicture('${picture.name}')" title="Otwórz w nowej karcie">🔎</button>
+                    ${picture.clip ? `<button class="btn-view" onclick="openClip('${picture.clip.url}')" title="Pobierz nagranie zdarzenia (${picture.clip.duration.toFixed(1)} s)">🎬</button>` : ''}
                     <button class="btn-delete" onclick="confirmDeletePicture('${picture.name}')" title="Usuń zdjęcie">🗑️</button>
                 </div>
             </div>
@@ -146,7 +160,8 @@ function updateSizeBar(data) {
     const currentSizeBytes = data.size || 0;
     const currentSizeGB = currentSizeBytes / (1024 * 1024 * 1024);
     
-    const percentage = Math.min((currentSizeBytes / maxSizeBytes) * 100, 100);
+    // Without a storage quota the bar stays empty
+    const percentage = maxSizeBytes > 0 ? Math.min((currentSizeBytes / maxSizeBytes) * 100, 100) : 0;
     
     const progressBar = sizeBar.querySelector('.size-progress');
     const sizeText = sizeBar.querySelector('.size-text');
@@ -165,10 +180,12 @@ function updateSizeBar(data) {
     }
     
     if (sizeText) {
-        sizeText.textContent = `${currentSizeGB.toFixed(2)} GB / ${data.maxSize} GB`;
+        sizeText.textContent = maxSizeBytes > 0
+            ? `${currentSizeGB.toFixed(2)} GB / ${data.maxSize} GB`
+            : `${currentSizeGB.toFixed(2)} GB`;
     }
     
-    if (sizeDetails) {
+    if (sizeDetails && maxSizeBytes > 0) {
         const freeSpace = data.maxSize - currentSizeGB;
         sizeDetails.textContent = `Wolne: ${freeSpace.toFixed(2)} GB (${(100 - percentage).toFixed(1)}%)`;
     }
@@ -186,6 +203,10 @@ function openPicture(filename) {
     window.open(`/api/pictures/view?image=${encodeURIComponent(filename)}`, '_blank');
 }
 
+function openClip(url) {
+    window.open(url, '_blank');
+}
+
 let pictureToDelete = null;
 
 function confirmDeletePicture(filename) {
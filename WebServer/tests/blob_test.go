@@ -0,0 +1,280 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/storage"
+)
+
+// fakeS3 is an in-memory stand-in for an S3-compatible server such as MinIO. It checks
+// that requests are signed and that the payload hash matches the body.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte // bucket/key -> data
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	sum := sha256.Sum256(body)
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=minio/") ||
+		r.Header.Get("X-Amz-Content-Sha256") != hex.EncodeToString(sum[:]) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := strings.TrimPrefix(r.URL.Path, "/")
+	switch r.Method {
+	case http.MethodPut:
+		f.objects[key] = body
+	case http.MethodGet:
+		if r.URL.Query().Get("list-type") == "2" {
+			f.list(w, key, r.URL.Query())
+			return
+		}
+		data, exists := f.objects[key]
+		if !exists {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+// list answers a ListObjectsV2 request with pages of two objects, continuing after the
+// key given as continuation token.
+func (f *fakeS3) list(w http.ResponseWriter, bucket string, query url.Values) {
+	var keys []string
+	for key := range f.objects {
+		name := strings.TrimPrefix(key, bucket+"/")
+		if name != key && strings.HasPrefix(name, query.Get("prefix")) && name > query.Get("continuation-token") {
+			keys = append(keys, name)
+		}
+	}
+	sort.Strings(keys)
+
+	truncated := len(keys) > 2
+	if truncated {
+		keys = keys[:2]
+	}
+	fmt.Fprint(w, `<ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	for _, key := range keys {
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size><LastModified>2024-05-01T10:00:00.000Z</LastModified></Contents>",
+			key, len(f.objects[bucket+"/"+key]))
+	}
+	if truncated {
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>", keys[1])
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+// setupS3 starts a fake S3 server and returns a store for its "cameras" bucket.
+func setupS3(t *testing.T) (*blob.S3Store, *fakeS3) {
+	t.Helper()
+
+	fake := &fakeS3{objects: make(map[string][]byte)}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+
+	store, err := blob.NewS3Store(blob.S3Config{Endpoint: server.URL, Bucket: "cameras", AccessKey: "minio",
+		SecretKey: "minio123", Prefix: "images"})
+	if err != nil {
+		t.Fatalf("Failed to create S3 store: %v", err)
+	}
+	return store, fake
+}
+
+// ========================================
+// Blob Store Tests
+// ========================================
+
+func TestBlob_S3RoundTrip(t *testing.T) {
+	store, fake := setupS3(t)
+
+	if err := store.Put("thumb/a b.jpg", []byte("jpeg")); err != nil {
+		t.Fatalf("Failed to put: %v", err)
+	}
+	if _, exists := fake.objects["cameras/images/thumb/a b.jpg"]; !exists {
+		t.Errorf("Expected the object under the bucket prefix, got %v", fake.objects)
+	}
+
+	data, info, err := store.Get("thumb/a b.jpg")
+	if err != nil || string(data) != "jpeg" || info.Size != 4 || info.ModTime.IsZero() {
+		t.Errorf("Unexpected get result %q %+v: %v", data, info, err)
+	}
+	if location := store.Location("a.jpg"); location != "s3://cameras/images/a.jpg" {
+		t.Errorf("Unexpected location %s", location)
+	}
+
+	if err := store.Delete("thumb/a b.jpg"); err != nil {
+		t.Fatalf("Failed to delete: %v", err)
+	}
+	if _, _, err := store.Get("thumb/a b.jpg"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a not-exist error after deleting, got %v", err)
+	}
+	if err := store.Delete("thumb/a b.jpg"); err != nil {
+		t.Errorf("Deleting a missing object should succeed, got %v", err)
+	}
+}
+
+func TestBlob_RejectsKeysOutsideStore(t *testing.T) {
+	s3, _ := setupS3(t)
+	for _, store := range []blob.BlobStore{blob.NewLocalStore(t.TempDir()), s3} {
+		for _, key := range []string{"../secret.jpg", "/etc/passwd", "thumb/../../x.jpg", ""} {
+			if _, _, err := store.Get(key); !errors.Is(err, fs.ErrNotExist) {
+				t.Errorf("%s: expected %q to be rejected, got %v", store.Backend(), key, err)
+			}
+			if err := store.Put(key, []byte("x")); err == nil {
+				t.Errorf("%s: expected writing %q to fail", store.Backend(), key)
+			}
+		}
+	}
+}
+
+func TestBlob_List(t *testing.T) {
+	s3, _ := setupS3(t)
+	for _, store := range []blob.BlobStore{blob.NewLocalStore(t.TempDir()), s3} {
+		for _, key := range []string{"gate/2024/05/01/a.jpg", "gate/2024/05/02/b.jpg", "thumb/gate/2024/05/01/a.jpg",
+			"yard/c d.jpg"} {
+			store.Put(key, []byte("jpeg"))
+		}
+
+		listed := make(map[string]int64)
+		err := store.(blob.Lister).List("gate/", func(key string, info blob.Info) error {
+			listed[key] = info.Size
+			if info.ModTime.IsZero() {
+				t.Errorf("%s: expected a modification time for %s", store.Backend(), key)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("%s: failed to list: %v", store.Backend(), err)
+		}
+		if len(listed) != 2 || listed["gate/2024/05/01/a.jpg"] != 4 || listed["gate/2024/05/02/b.jpg"] != 4 {
+			t.Errorf("%s: unexpected listing %v", store.Backend(), listed)
+		}
+
+		count := 0
+		store.(blob.Lister).List("", func(key string, info blob.Info) error {
+			count++
+			return nil
+		})
+		if count != 4 {
+			t.Errorf("%s: expected to list every blob, got %d", store.Backend(), count)
+		}
+	}
+
+	if err := blob.NewLocalStore(filepath.Join(t.TempDir(), "missing")).List("", nil); err != nil {
+		t.Errorf("Listing a store without a directory should succeed, got %v", err)
+	}
+}
+
+func TestBlob_OpenSelectsBackend(t *testing.T) {
+	dir := t.TempDir()
+
+	stores, err := blob.Open(&config.Config{ImageDirectory: dir})
+	if err != nil || stores.Default().Backend() != blob.BackendLocal {
+		t.Errorf("Expected local storage by default, got %v", err)
+	}
+
+	stores, err = blob.Open(&config.Config{ImageDirectory: dir, StorageBackend: "s3", S3Endpoint: "http://nas:9000",
+		S3Bucket: "cameras"})
+	if err != nil || stores.Default().Backend() != blob.BackendS3 {
+		t.Fatalf("Expected S3 storage, got %v", err)
+	}
+	if _, exists := stores.Get(""); !exists {
+		t.Error("Expected images stored before switching to stay readable from the local store")
+	}
+
+	if _, err := blob.Open(&config.Config{StorageBackend: "s3"}); err == nil {
+		t.Error("Expected an error for S3 storage without a bucket")
+	}
+	if _, err := blob.Open(&config.Config{StorageBackend: "ftp"}); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+}
+
+func TestBlob_MixedStorage(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	s3, fake := setupS3(t)
+	env.cfg.ThumbnailWidth, env.cfg.PreviewWidth = 16, 32
+	stores := blob.NewStores(s3, blob.NewLocalStore(imagesDir))
+	buffer := storage.NewBufferService(env.cfg, env.logger, stores, imageRepo, sqlite.NewImageVariantRepository(env.db))
+
+	// Saved to disk before switching to S3
+	if err := os.WriteFile(filepath.Join(imagesDir, "old.jpg"), []byte("old"), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	imageRepo.Insert(&model.Image{Filename: "old.jpg", Camera: "gate", Timestamp: time.Now(),
+		FilePath: filepath.Join(imagesDir, "old.jpg")})
+
+	filename := buffer.AddImage(encodeJPEG(t, 64, 48), "gate", []dto.DetectionResult{box("car", 0, 0, 10, 10)})
+	buffer.FlushImages()
+
+	img, err := imageRepo.GetByFilename(filename)
+	if err != nil || img == nil {
+		t.Fatalf("Expected the image to be stored, got %v", err)
+	}
+	key := storage.ImageKey("gate", img.Timestamp, filename)
+	if img.Storage != blob.BackendS3 || img.FilePath != "s3://cameras/images/"+key {
+		t.Errorf("Expected the image recorded in S3, got %s at %s", img.Storage, img.FilePath)
+	}
+	if _, err := os.Stat(filepath.Join(imagesDir, filename)); !os.IsNotExist(err) {
+		t.Error("New images should not be written to disk")
+	}
+
+	if data, _, served, err := buffer.ReadImage("old.jpg", storage.VariantThumb); err != nil || string(data) != "old" || served != "" {
+		t.Errorf("Expected the local original without a thumbnail, got %q as %q: %v", data, served, err)
+	}
+	thumb, _, served, err := buffer.ReadImage(filename, storage.VariantThumb)
+	if err != nil || string(thumb) != string(fake.objects["cameras/images/thumb/"+key]) || served != storage.VariantThumb {
+		t.Errorf("Expected the thumbnail from S3: %v", err)
+	}
+
+	if err := buffer.DeleteImage(filename); err != nil {
+		t.Fatalf("Failed to delete image: %v", err)
+	}
+	if len(fake.objects) != 0 {
+		t.Errorf("Expected the image and its variants removed from S3, got %d objects", len(fake.objects))
+	}
+
+	if err := buffer.ClearImages(); err != nil {
+		t.Fatalf("Failed to clear images: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(imagesDir, "old.jpg")); !os.IsNotExist(err) {
+		t.Error("Expected the local image to be cleared")
+	}
+	if count, _ := imageRepo.GetTotalCount(&dto.ImageFilters{}); count != 0 {
+		t.Errorf("Expected no images left in the database, got %d", count)
+	}
+}
@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/registry"
+	"webserver/internal/service/storage"
+)
+
+// ========================================
+// Camera Repository Tests
+// ========================================
+
+func TestCameraRepository_InsertAndGet(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := sqlite.NewCameraRepository(db)
+
+	id, err := repo.Insert(&model.Camera{Name: "gate", DeviceID: 1, Secret: "abc", Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to insert camera: %v", err)
+	}
+
+	cam, err := repo.GetByID(id)
+	if err != nil {
+		t.Fatalf("Failed to get camera: %v", err)
+	}
+	if cam == nil || cam.Name != "gate" || cam.DeviceID != 1 || cam.Secret != "abc" || !cam.Enabled {
+		t.Errorf("Unexpected camera: %+v", cam)
+	}
+	if cam.IPAddress != "" {
+		t.Errorf("Expected empty IP address, got %s", cam.IPAddress)
+	}
+}
+
+func TestCameraRepository_MultipleCamerasWithoutDeviceID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := sqlite.NewCameraRepository(db)
+
+	if _, err := repo.Insert(&model.Camera{Name: "a", IPAddress: "10.0.0.1"}); err != nil {
+		t.Fatalf("Failed to insert first camera: %v", err)
+	}
+	if _, err := repo.Insert(&model.Camera{Name: "b", IPAddress: "10.0.0.2"}); err != nil {
+		t.Fatalf("Unset device IDs should not conflict: %v", err)
+	}
+}
+
+func TestCameraRepository_RenameKeepsImages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	cameraRepo := sqlite.NewCameraRepository(db)
+	imageRepo := sqlite.NewImageRepository(db)
+
+	id, _ := cameraRepo.Insert(&model.Camera{Name: "brama", DeviceID: 2, Enabled: true})
+	for _, name := range []string{"a.jpg", "b.jpg"} {
+		imageRepo.Insert(&model.Image{Filename: name, Camera: "brama", Timestamp: time.Now(), FilePath: "/tmp/" + name})
+	}
+
+	cam, _ := cameraRepo.GetByID(id)
+	cam.Name = "gate"
+	if err := cameraRepo.Update(cam); err != nil {
+		t.Fatalf("Failed to rename camera: %v", err)
+	}
+
+	count, err := imageRepo.GetTotalCount(&dto.ImageFilters{Camera: "gate"})
+	if err != nil {
+		t.Fatalf("Failed to count images: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 images under the new name, got %d", count)
+	}
+}
+
+func TestCameraRepository_DeleteKeepsImages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	cameraRepo := sqlite.NewCameraRepository(db)
+	imageRepo := sqlite.NewImageRepository(db)
+
+	id, _ := cameraRepo.Insert(&model.Camera{Name: "gate", DeviceID: 1})
+	imageRepo.Insert(&model.Image{Filename: "a.jpg", Camera: "gate", Timestamp: time.Now(), FilePath: "/tmp/a.jpg"})
+
+	if err := cameraRepo.Delete(id); err != nil {
+		t.Fatalf("Failed to delete camera: %v", err)
+	}
+
+	cam, _ := cameraRepo.GetByID(id)
+	if cam != nil {
+		t.Error("Camera should be deleted")
+	}
+	if count, _ := imageRepo.GetTotalCount(&dto.ImageFilters{Camera: "gate"}); count != 1 {
+		t.Errorf("Images should be kept after deleting the camera, got %d", count)
+	}
+}
+
+// ========================================
+// Camera Registry Tests
+// ========================================
+
+func TestRegistry_SeedsFromConfig(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens = map[uint16]config.CameraToken{1: {Name: "gate", Secret: "s1"}}
+	env.cfg.CameraNames = map[string]string{"192.168.1.32": "door", "192.168.1.29": "gate"}
+	reg := env.registry()
+
+	if names := reg.GetNames(); len(names) != 2 {
+		t.Fatalf("Expected 2 seeded cameras, got %v", names)
+	}
+
+	gate, ok := reg.GetByDeviceID(1)
+	if !ok || gate.Name != "gate" || gate.IPAddress != "192.168.1.29" {
+		t.Errorf("Token and IP definitions should be merged, got %+v", gate)
+	}
+
+	// A second start must load from the database instead of seeding again
+	reloaded, err := registry.NewRegistryService(&config.Config{}, env.logger, sqlite.NewCameraRepository(env.db))
+	if err != nil {
+		t.Fatalf("Failed to reload registry: %v", err)
+	}
+	if _, ok := reloaded.GetByIP("192.168.1.32"); !ok {
+		t.Error("Registry should persist seeded cameras")
+	}
+}
+
+func TestRegistry_SeedsNamesWithUnderscoresAndDiacritics(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"192.168.1.32": "brama_wjazd", "192.168.1.29": "wejście"}
+	reg := env.registry()
+
+	for _, name := range []string{"brama_wjazd", "wejście"} {
+		if !reg.IsEnabled(name) {
+			t.Errorf("Expected camera %s to be seeded and enabled", name)
+		}
+	}
+	if dir := storage.ImageKey("wejście", time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), "a.jpg"); dir != "wejście/2024/05/01/a.jpg" {
+		t.Errorf("Expected the registered name as the storage directory, got %s", dir)
+	}
+}
+
+func TestRegistry_RefusesUnsafeSeedName(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"192.168.1.32": "front door", "192.168.1.29": "gate"}
+
+	if _, err := registry.NewRegistryService(env.cfg, env.logger, sqlite.NewCameraRepository(env.db)); !errors.Is(err, registry.ErrInvalid) {
+		t.Fatalf("Expected ErrInvalid for an unsafe seed name, got %v", err)
+	}
+	if cameras, _ := sqlite.NewCameraRepository(env.db).GetAll(); len(cameras) != 0 {
+		t.Errorf("Expected no camera to be seeded, got %+v", cameras)
+	}
+}
+
+func TestRegistry_CreateGeneratesSecret(t *testing.T) {
+	env := newTestEnv(t)
+	reg := env.registry()
+
+	cam, err := reg.Create(model.Camera{Name: "garage", DeviceID: 5, Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create camera: %v", err)
+	}
+	if cam.Secret == "" {
+		t.Error("Expected a generated secret")
+	}
+	if found, ok := reg.GetByDeviceID(5); !ok || found.ID != cam.ID {
+		t.Error("Created camera should be resolvable by device ID")
+	}
+}
+
+func TestRegistry_CreateValidation(t *testing.T) {
+	env := newTestEnv(t)
+	reg := env.registry()
+	reg.Create(model.Camera{Name: "gate", DeviceID: 1})
+
+	tests := []struct {
+		name     string
+		camera   model.Camera
+		expected error
+	}{
+		{"missing name", model.Camera{DeviceID: 2}, registry.ErrInvalid},
+		{"space in name", model.Camera{Name: "front door", DeviceID: 2}, registry.ErrInvalid},
+		{"parent directory name", model.Camera{Name: "..", DeviceID: 2}, registry.ErrInvalid},
+		{"reserved name", model.Camera{Name: "Thumb", DeviceID: 2}, registry.ErrInvalid},
+		{"name too long", model.Camera{Name: strings.Repeat("a", registry.MaxNameLength+1), DeviceID: 2}, registry.ErrInvalid},
+		{"no identity", model.Camera{Name: "door"}, registry.ErrInvalid},
+		{"device id out of range", model.Camera{Name: "door", DeviceID: 70000}, registry.ErrInvalid},
+		{"duplicate name", model.Camera{Name: "gate", DeviceID: 2}, registry.ErrConflict},
+		{"duplicate device id", model.Camera{Name: "door", DeviceID: 1}, registry.ErrConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := reg.Create(tt.camera); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestRegistry_RenameAndDisable(t *testing.T) {
+	env := newTestEnv(t)
+	reg := env.registry()
+	cam, _ := reg.Create(model.Camera{Name: "brama", DeviceID: 1, Enabled: true})
+
+	cam.Name = "gate"
+	cam.Enabled = false
+	if _, err := reg.Update(cam); err != nil {
+		t.Fatalf("Failed to update camera: %v", err)
+	}
+
+	if _, ok := reg.GetByName("brama"); ok {
+		t.Error("Old name should no longer resolve")
+	}
+	if reg.IsEnabled("gate") {
+		t.Error("Camera should be disabled")
+	}
+	if found, ok := reg.GetByDeviceID(1); !ok || found.Name != "gate" {
+		t.Errorf("Device ID should resolve to the renamed camera, got %+v", found)
+	}
+}
+
+func TestRegistry_Delete(t *testing.T) {
+	env := newTestEnv(t)
+	reg := env.registry()
+	cam, _ := reg.Create(model.Camera{Name: "gate", DeviceID: 1, Enabled: true})
+
+	if err := reg.Delete(cam.ID); err != nil {
+		t.Fatalf("Failed to delete camera: %v", err)
+	}
+	if _, ok := reg.GetByDeviceID(1); ok {
+		t.Error("Deleted camera should not resolve")
+	}
+	if err := reg.Delete(cam.ID); !errors.Is(err, registry.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRegistry_ReadOnlyWithoutDatabase(t *testing.T) {
+	cfg := &config.Config{CameraNames: map[string]string{"10.0.0.1": "gate"}}
+	reg, _ := registry.NewRegistryService(cfg, setupTestLogger(t), nil)
+
+	if _, ok := reg.GetByIP("10.0.0.1"); !ok {
+		t.Error("Cameras from config should be available without a database")
+	}
+	if _, err := reg.Create(model.Camera{Name: "door", DeviceID: 1}); !errors.Is(err, registry.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+}
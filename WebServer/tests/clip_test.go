@@ -0,0 +1,211 @@
+package tests
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/clip"
+)
+
+// ========================================
+// Frame Buffer Tests
+// ========================================
+
+func TestFrameBuffer_KeepsMostRecentFrames(t *testing.T) {
+	buffer := clip.NewFrameBuffer(3)
+	base := time.Now()
+	for i := 0; i < 5; i++ {
+		buffer.Push([]byte{byte(i)}, base.Add(time.Duration(i)*time.Second))
+	}
+
+	frames := buffer.Since(time.Time{})
+	if len(frames) != 3 {
+		t.Fatalf("Expected 3 frames, got %d", len(frames))
+	}
+	for i, frame := range frames {
+		if frame.Data[0] != byte(i+2) {
+			t.Errorf("Frame %d: expected %d, got %d", i, i+2, frame.Data[0])
+		}
+	}
+
+	if recent := buffer.Since(base.Add(4 * time.Second)); len(recent) != 1 {
+		t.Errorf("Expected 1 frame since the last timestamp, got %d", len(recent))
+	}
+}
+
+// ========================================
+// Clip Service Tests
+// ========================================
+
+func TestClip_PreAndPostRoll(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.ClipPreRollS, env.cfg.ClipPostRollS, env.cfg.ClipMaxDurationS = 2, 1, 60
+	clips, repo := env.clips(), sqlite.NewClipRepository(env.db)
+
+	frame := encodeJPEG(t, 32, 32)
+	now := time.Now()
+	preRoll := []clip.Frame{
+		{Data: frame, Timestamp: now.Add(-2 * time.Second)},
+		{Data: frame, Timestamp: now.Add(-time.Second)},
+		{Data: frame, Timestamp: now},
+	}
+
+	clips.Trigger("gate", "trigger.jpg", preRoll)
+	// A frame already written from the pre-roll buffer is not duplicated
+	clips.AddFrame("gate", frame, now)
+	clips.AddFrame("gate", frame, now.Add(500*time.Millisecond))
+	// Frames after the post-roll close the clip
+	clips.AddFrame("gate", frame, now.Add(3*time.Second))
+	clips.AddFrame("gate", frame, now.Add(4*time.Second))
+
+	saved, err := repo.GetByImage("trigger.jpg")
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to get clip for image: %v", err)
+	}
+	if !saved.Complete {
+		t.Error("Clip should be complete after the post-roll")
+	}
+	if saved.Frames != 4 {
+		t.Errorf("Expected 3 pre-roll and 1 post-roll frames, got %d", saved.Frames)
+	}
+	if frames, _ := readAVI(t, saved.FilePath); frames != 4 {
+		t.Errorf("Expected 4 frames in the clip file, got %d", frames)
+	}
+}
+
+func TestClip_RepeatedDetectionsExtendClip(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.ClipPreRollS, env.cfg.ClipPostRollS, env.cfg.ClipMaxDurationS = 0, 5, 60
+	clips, repo := env.clips(), sqlite.NewClipRepository(env.db)
+
+	frame := encodeJPEG(t, 32, 32)
+	clips.Trigger("gate", "first.jpg", nil)
+	clips.AddFrame("gate", frame, time.Now())
+	clips.Trigger("gate", "second.jpg", nil)
+	clips.Stop()
+
+	first, _ := repo.GetByImage("first.jpg")
+	second, _ := repo.GetByImage("second.jpg")
+	if first == nil || second == nil || first.ID != second.ID {
+		t.Fatalf("Both images should be linked to one clip, got %+v and %+v", first, second)
+	}
+	if first.Frames != 1 {
+		t.Errorf("Expected 1 frame, got %d", first.Frames)
+	}
+}
+
+func TestClip_FollowsRenamedCamera(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.ClipPreRollS, env.cfg.ClipPostRollS, env.cfg.ClipMaxDurationS = 0, 5, 60
+	clips, repo := env.clips(), sqlite.NewClipRepository(env.db)
+
+	frame := encodeJPEG(t, 32, 32)
+	clips.Trigger("brama", "first.jpg", nil)
+	clips.AddFrame("brama", frame, time.Now())
+	clips.RenameCamera("brama", "gate")
+	clips.AddFrame("gate", frame, time.Now())
+	clips.Trigger("gate", "second.jpg", nil)
+	clips.Stop()
+
+	first, _ := repo.GetByImage("first.jpg")
+	second, _ := repo.GetByImage("second.jpg")
+	if first == nil || second == nil || first.ID != second.ID {
+		t.Fatalf("Expected the open clip to continue under the new name, got %+v and %+v", first, second)
+	}
+	if first.Frames != 2 {
+		t.Errorf("Expected 2 frames, got %d", first.Frames)
+	}
+}
+
+func TestClip_FinishExpired(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.ClipPreRollS, env.cfg.ClipPostRollS, env.cfg.ClipMaxDurationS = 0, 1, 60
+	clips, repo := env.clips(), sqlite.NewClipRepository(env.db)
+
+	clips.Trigger("gate", "trigger.jpg", nil)
+	clips.AddFrame("gate", encodeJPEG(t, 32, 32), time.Now())
+
+	clips.FinishExpired(time.Now())
+	if saved, _ := repo.GetByImage("trigger.jpg"); saved.Complete {
+		t.Error("Clip should stay open during the post-roll")
+	}
+
+	clips.FinishExpired(time.Now().Add(2 * time.Second))
+	if saved, _ := repo.GetByImage("trigger.jpg"); !saved.Complete {
+		t.Error("Clip should be finalized after the post-roll")
+	}
+}
+
+func TestClip_DisabledWithoutDatabase(t *testing.T) {
+	clips := clip.NewClipService(&config.Config{ClipPreRollS: 5, ClipPostRollS: 5}, setupTestLogger(t), nil)
+	if clips.IsEnabled() {
+		t.Error("Clips should be disabled without a database")
+	}
+}
+
+func TestClip_PrunesOldClips(t *testing.T) {
+	env := newTestEnv(t)
+	repo := sqlite.NewClipRepository(env.db)
+	dir := env.cfg.ClipDirectory
+	now := time.Now()
+	for i, age := range []time.Duration{10 * 24 * time.Hour, 3 * 24 * time.Hour, 2 * 24 * time.Hour, time.Hour} {
+		path := filepath.Join(dir, fmt.Sprintf("gate-%d.avi", i))
+		os.WriteFile(path, make([]byte, 1<<20), 0644)
+		id, _ := repo.Insert(&model.Clip{Camera: "gate", Filename: filepath.Base(path), FilePath: path,
+			StartTime: now.Add(-age), EndTime: now.Add(-age).Add(10 * time.Second), FileSize: 1 << 20, Complete: true})
+		repo.AddImage(id, fmt.Sprintf("gate-%d.jpg", i))
+	}
+
+	env.cfg.ClipPreRollS, env.cfg.ClipPostRollS, env.cfg.ClipMaxDays, env.cfg.ClipQuotaGB = 5, 5, 7, 2.0/1024
+	clips := env.clips()
+
+	if removed := clips.Prune(now); removed != 2 {
+		t.Errorf("Expected the expired clip and the oldest one over the quota to be removed, got %d", removed)
+	}
+	for i, want := range []bool{false, false, true, true} {
+		found, _ := repo.GetByImage(fmt.Sprintf("gate-%d.jpg", i))
+		if (found != nil) != want {
+			t.Errorf("Clip %d: expected kept=%v, got %+v", i, want, found)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gate-0.avi")); !os.IsNotExist(err) {
+		t.Error("Expected the file of the expired clip to be deleted")
+	}
+}
+
+// ========================================
+// Clip Repository Tests
+// ========================================
+
+func TestClipRepository_GetByImage(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := sqlite.NewClipRepository(db)
+	id, err := repo.Insert(&model.Clip{Camera: "gate", Filename: "clip.avi", StartTime: time.Now(), EndTime: time.Now()})
+	if err != nil {
+		t.Fatalf("Failed to insert clip: %v", err)
+	}
+	if err := repo.AddImage(id, "a.jpg"); err != nil {
+		t.Fatalf("Failed to link image: %v", err)
+	}
+	if err := repo.AddImage(id, "a.jpg"); err != nil {
+		t.Errorf("Linking the same image twice should be ignored: %v", err)
+	}
+
+	if found, _ := repo.GetByImage("a.jpg"); found == nil || found.ID != id {
+		t.Errorf("Expected clip %d, got %+v", id, found)
+	}
+	if found, _ := repo.GetByImage("b.jpg"); found != nil {
+		t.Errorf("Expected no clip for an unlinked image, got %+v", found)
+	}
+	if incomplete, _ := repo.GetIncomplete(); len(incomplete) != 1 {
+		t.Errorf("Expected 1 incomplete clip, got %d", len(incomplete))
+	}
+}
@@ -1,6 +1,7 @@
 package tests
 
 import (
+	"database/sql"
 	"os"
 	"path/filepath"
 	"testing"
@@ -78,6 +79,45 @@ func TestDatabase_Migration(t *testing.T) {
 	}
 }
 
+func TestDatabase_AddsMissingColumns(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// A detections table created before the zone column existed
+	old, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := old.Exec(`CREATE TABLE detections (
+		id INTEGER PRIMARY KEY AUTOINCREMENT, image_id INTEGER NOT NULL, object_name TEXT NOT NULL,
+		x INTEGER DEFAULT 0, y INTEGER DEFAULT 0, width INTEGER DEFAULT 0, height INTEGER DEFAULT 0,
+		confidence REAL DEFAULT 0)`); err != nil {
+		t.Fatalf("Failed to create old table: %v", err)
+	}
+	if _, err := old.Exec(`INSERT INTO detections (image_id, object_name) VALUES (1, 'person')`); err != nil {
+		t.Fatalf("Failed to insert old detection: %v", err)
+	}
+	old.Close()
+
+	db, err := sqlite.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	defer db.Close()
+
+	repo := sqlite.NewDetectionRepository(db)
+	if _, err := repo.Insert(&model.Detection{ImageID: 1, ObjectName: "car", Zone: "driveway"}); err != nil {
+		t.Fatalf("Failed to insert detection with a zone: %v", err)
+	}
+
+	detections, err := repo.GetByImageID(1)
+	if err != nil {
+		t.Fatalf("Failed to get detections: %v", err)
+	}
+	if len(detections) != 2 || detections[0].Zone != "" || detections[1].Zone != "driveway" {
+		t.Errorf("Expected the old row without a zone and the new one in driveway, got %+v", detections)
+	}
+}
+
 func TestDatabase_ConcurrentAccess(t *testing.T) {
 	tempDir, err := os.MkdirTemp("", "db_concurrent_test")
 	if err != nil {
@@ -0,0 +1,429 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/service/detection"
+)
+
+// writeDescriptor writes a model descriptor file and returns its path.
+func writeDescriptor(t *testing.T, content string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write descriptor: %v", err)
+	}
+	return path
+}
+
+// ========================================
+// Model Descriptor Tests
+// ========================================
+
+func TestDescriptor_Defaults(t *testing.T) {
+	cfg := &config.Config{ModelPath: "model.pb", ConfigPath: "model.pbtxt"}
+
+	desc, err := detection.LoadDescriptor(cfg)
+	if err != nil {
+		t.Fatalf("Failed to load descriptor: %v", err)
+	}
+	if desc.Layout != detection.LayoutSSD || desc.InputWidth != 300 || !desc.SwapRB || desc.ModelPath != "model.pb" {
+		t.Errorf("Unexpected default descriptor: %+v", desc)
+	}
+	if label, _ := desc.Label(1); label != "osoba" {
+		t.Errorf("Expected Polish labels by default, got %s", label)
+	}
+	if label, known := desc.Label(99); known {
+		t.Errorf("Expected class 99 to be unknown, got %s", label)
+	}
+	if desc.Canonical("Osoba") != "person" || desc.Canonical("person") != "person" {
+		t.Errorf("Expected labels in both languages to map to person, got %s", desc.Canonical("Osoba"))
+	}
+}
+
+func TestDescriptor_LoadFromFile(t *testing.T) {
+	cfg := &config.Config{
+		ModelPath: "model.pb",
+		ModelDescriptor: writeDescriptor(t, `{
+			"name": "custom",
+			"input_width": 512,
+			"input_height": 512,
+			"confidence_threshold": 0.4,
+			"labels": {"1": "person", "3": "car"}
+		}`),
+	}
+
+	desc, err := detection.LoadDescriptor(cfg)
+	if err != nil {
+		t.Fatalf("Failed to load descriptor: %v", err)
+	}
+	if desc.Name != "custom" || desc.InputWidth != 512 || desc.ConfidenceThreshold != 0.4 {
+		t.Errorf("Descriptor fields not loaded: %+v", desc)
+	}
+	if desc.Layout != detection.LayoutSSD || desc.ModelPath != "model.pb" || desc.Scale != 1.0/127.5 {
+		t.Errorf("Missing fields should keep their defaults: %+v", desc)
+	}
+	if label, _ := desc.Label(3); len(desc.Labels) != 2 || label != "car" {
+		t.Errorf("Label map should replace the default one, got %v", desc.Labels)
+	}
+}
+
+func TestDescriptor_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"malformed json", `{"name":`},
+		{"unknown layout", `{"layout": "rcnn"}`},
+		{"bad input size", `{"input_width": 0}`},
+		{"bad threshold", `{"confidence_threshold": 2}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{ModelDescriptor: writeDescriptor(t, tt.content)}
+			desc, err := detection.LoadDescriptor(cfg)
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if desc.Layout != detection.LayoutSSD || desc.InputWidth != 300 {
+				t.Errorf("Expected the default descriptor on error, got %+v", desc)
+			}
+		})
+	}
+}
+
+// ========================================
+// Label Map Tests
+// ========================================
+
+func TestLabelMap_Localize(t *testing.T) {
+	path := writeDescriptor(t, `{
+		"1": {"name": "person", "pl": "osoba"},
+		"3": {"name": "car", "pl": "samochod"},
+		"62": {"name": "chair"}
+	}`)
+
+	for _, tt := range []struct {
+		language string
+		expected []string
+	}{
+		{"pl", []string{"osoba", "samochod", "chair"}},
+		{"en", []string{"person", "car", "chair"}},
+	} {
+		cfg := &config.Config{LabelMap: path, LabelLanguage: tt.language}
+		desc, err := detection.LoadDescriptor(cfg)
+		if err != nil {
+			t.Fatalf("Failed to load descriptor: %v", err)
+		}
+		for i, id := range []int{1, 3, 62} {
+			if label, _ := desc.Label(id); label != tt.expected[i] {
+				t.Errorf("%s: expected %s for class %d, got %s", tt.language, tt.expected[i], id, label)
+			}
+		}
+		if desc.Canonical("SAMOCHOD") != "car" {
+			t.Errorf("%s: expected samochod to map to car, got %s", tt.language, desc.Canonical("SAMOCHOD"))
+		}
+	}
+}
+
+func TestLabelMap_Invalid(t *testing.T) {
+	for _, content := range []string{`{"1": "person"}`, `{"1": {"pl": "osoba"}}`, `{"x": {"name": "person"}}`} {
+		if _, err := detection.LoadLabelMap(writeDescriptor(t, content)); err == nil {
+			t.Errorf("Expected an error for %s", content)
+		}
+	}
+
+	if _, err := detection.LoadLabelMap(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Expected an error for a missing file")
+	}
+}
+
+func TestLabelMap_Bundled(t *testing.T) {
+	for _, name := range []string{"coco_labels.json", "coco80_labels.json"} {
+		labels, err := detection.LoadLabelMap(filepath.Join("..", "internal", "service", "ai", name))
+		if err != nil {
+			t.Fatalf("Failed to load %s: %v", name, err)
+		}
+		if len(labels) != 80 {
+			t.Errorf("Expected 80 classes in %s, got %d", name, len(labels))
+		}
+	}
+}
+
+// ========================================
+// Detection Filter Tests
+// ========================================
+
+func TestFilter_AllowDeny(t *testing.T) {
+	cfg := &config.Config{
+		DetectAllow: map[string][]string{"Gate": {"car", "Osoba"}},
+		DetectDeny:  map[string][]string{"Garden": {"pies"}},
+	}
+	filter := detection.NewFilter(cfg, detection.DefaultDescriptor(cfg))
+
+	tests := []struct {
+		camera   string
+		label    string
+		expected bool
+	}{
+		{"Gate", "osoba", true},
+		{"Gate", "samochod", true},
+		{"Gate", "pies", false},
+		{"Garden", "pies", false},
+		{"Garden", "dog", false},
+		{"Garden", "kot", true},
+		{"Other", "pies", true},
+	}
+
+	for _, tt := range tests {
+		if allowed := filter.Allows(tt.camera, tt.label, 0.9); allowed != tt.expected {
+			t.Errorf("%s/%s: expected %v, got %v", tt.camera, tt.label, tt.expected, allowed)
+		}
+	}
+
+	kept := filter.Apply("Gate", []dto.DetectionResult{
+		{Label: "osoba", Confidence: 0.9},
+		{Label: "kot", Confidence: 0.9},
+		{Label: "samochod", Confidence: 0.7},
+	})
+	if len(kept) != 2 || kept[0].Label != "osoba" || kept[1].Label != "samochod" {
+		t.Errorf("Expected osoba and samochod to be kept, got %+v", kept)
+	}
+}
+
+func TestFilter_FollowsRenamedCamera(t *testing.T) {
+	cfg := &config.Config{DetectAllow: map[string][]string{"brama": {"car"}}}
+	filter := detection.NewFilter(cfg, detection.DefaultDescriptor(cfg))
+
+	filter.RenameCamera("brama", "gate")
+	if filter.Allows("gate", "person", 0.9) || !filter.Allows("gate", "car", 0.9) {
+		t.Error("Expected the allow list to apply under the new name")
+	}
+	if !filter.Allows("brama", "person", 0.9) {
+		t.Error("Expected the old name to have no allow list left")
+	}
+}
+
+func TestFilter_Thresholds(t *testing.T) {
+	cfg := &config.Config{DetectThresholds: map[string]float64{"person": 0.4, "samochod": 0.8}}
+	filter := detection.NewFilter(cfg, detection.DefaultDescriptor(cfg))
+
+	if filter.MinThreshold() != 0.4 {
+		t.Errorf("Expected the lowest threshold 0.4, got %.2f", filter.MinThreshold())
+	}
+
+	tests := []struct {
+		label      string
+		confidence float64
+		expected   bool
+	}{
+		{"osoba", 0.5, true},
+		{"osoba", 0.4, false},
+		{"samochod", 0.7, false},
+		{"samochod", 0.85, true},
+		{"pies", 0.5, false}, // default threshold 0.6
+		{"pies", 0.65, true},
+	}
+
+	for _, tt := range tests {
+		if allowed := filter.Allows("Gate", tt.label, tt.confidence); allowed != tt.expected {
+			t.Errorf("%s at %.2f: expected %v, got %v", tt.label, tt.confidence, tt.expected, allowed)
+		}
+	}
+}
+
+// ========================================
+// Detector Backend Tests
+// ========================================
+
+func TestHTTPDetector(t *testing.T) {
+	frame := encodeJPEG(t, 64, 48)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "image/jpeg" || len(body) != len(frame) {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"detections": []map[string]interface{}{
+				{"class_id": 1, "confidence": 0.8, "x": 1, "y": 2, "width": 30, "height": 40},
+				{"label": "dog", "confidence": 0.9, "x": 5, "y": 5, "width": 10, "height": 10},
+				{"class_id": 3, "confidence": 0.3},
+				{"class_id": 99, "confidence": 0.9},
+			},
+		})
+	}))
+	defer server.Close()
+
+	desc := detection.DefaultDescriptor(&config.Config{})
+	detector, err := detection.NewHTTPDetector(server.URL, time.Second, desc)
+	if err != nil {
+		t.Fatalf("Failed to create detector: %v", err)
+	}
+	defer detector.Close()
+
+	results, err := detector.Detect(frame)
+	if err != nil {
+		t.Fatalf("Failed to detect: %v", err)
+	}
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 known detections above the threshold, got %+v", results)
+	}
+	if results[0].Label != "osoba" || results[0].Width != 30 || results[0].Height != 40 {
+		t.Errorf("Expected the class ID to be mapped to a label, got %+v", results[0])
+	}
+	if results[1].Label != "dog" {
+		t.Errorf("Expected the server label to be kept, got %+v", results[1])
+	}
+
+	if _, err := detector.Detect([]byte("not a jpeg")); err == nil {
+		t.Error("Expected an error when the server rejects the request")
+	}
+
+	if _, err := detection.NewHTTPDetector("", time.Second, desc); err == nil {
+		t.Error("Expected an error without a URL")
+	}
+}
+
+func TestFakeDetector_Deterministic(t *testing.T) {
+	detector := detection.NewFakeDetector(detection.DefaultDescriptor(&config.Config{}))
+
+	frame := encodeJPEG(t, 80, 40)
+	first, err := detector.Detect(frame)
+	if err != nil {
+		t.Fatalf("Failed to detect: %v", err)
+	}
+	second, _ := detector.Detect(frame)
+
+	if len(first) != 1 || first[0] != second[0] {
+		t.Fatalf("Expected the same single detection twice, got %+v and %+v", first, second)
+	}
+	if first[0].Label != "osoba" || first[0].X != 20 || first[0].Y != 10 || first[0].Width != 40 || first[0].Height != 20 {
+		t.Errorf("Unexpected fake detection: %+v", first[0])
+	}
+
+	if _, err := detector.Detect([]byte("not a jpeg")); err == nil {
+		t.Error("Expected an error for an undecodable frame")
+	}
+}
+
+// ========================================
+// YOLO Post-processing Tests
+// ========================================
+
+func TestLetterbox_ScalesAndPads(t *testing.T) {
+	lb := detection.NewLetterbox(640, 480, 640, 640)
+	if lb.Scale != 1 || lb.ResizedWidth != 640 || lb.ResizedHeight != 480 || lb.Top != 80 || lb.Bottom != 80 || lb.Left != 0 {
+		t.Fatalf("Unexpected letterbox: %+v", lb)
+	}
+
+	box := lb.Rescale(detection.Box{X: 100, Y: 180, Width: 50, Height: 60})
+	if box.X != 100 || box.Y != 100 || box.Width != 50 || box.Height != 60 {
+		t.Errorf("Expected the padding to be removed, got %+v", box)
+	}
+
+	lb = detection.NewLetterbox(1280, 720, 640, 640)
+	if lb.Scale != 0.5 || lb.ResizedHeight != 360 || lb.Top != 140 {
+		t.Fatalf("Unexpected letterbox: %+v", lb)
+	}
+	box = lb.Rescale(detection.Box{X: -10, Y: 140, Width: 100, Height: 400})
+	if box.X != 0 || box.Y != 0 || box.Width != 180 || box.Height != 720 {
+		t.Errorf("Expected the box to be scaled and clipped to the frame, got %+v", box)
+	}
+}
+
+func TestParseYOLO_Layouts(t *testing.T) {
+	// Two candidates with 2 classes: cx, cy, w, h, objectness, class scores
+	v5 := []float32{
+		100, 100, 20, 40, 0.9, 0.1, 0.8,
+		300, 300, 10, 10, 0.2, 0.9, 0.1,
+	}
+	boxes, err := detection.ParseYOLO(detection.LayoutYOLOv5, []int{1, 2, 7}, v5, 0.5)
+	if err != nil {
+		t.Fatalf("Failed to parse v5 output: %v", err)
+	}
+	if len(boxes) != 1 || boxes[0].ClassID != 1 || boxes[0].X != 90 || boxes[0].Y != 80 {
+		t.Errorf("Unexpected v5 boxes: %+v", boxes)
+	}
+
+	// The same candidates column-wise without objectness
+	v8 := []float32{
+		100, 300, // cx
+		100, 300, // cy
+		20, 10, // w
+		40, 10, // h
+		0.1, 0.9, // class 0
+		0.8, 0.1, // class 1
+	}
+	boxes, err = detection.ParseYOLO(detection.LayoutYOLOv8, []int{1, 6, 2}, v8, 0.5)
+	if err != nil {
+		t.Fatalf("Failed to parse v8 output: %v", err)
+	}
+	if len(boxes) != 2 || boxes[0].ClassID != 1 || boxes[1].ClassID != 0 || boxes[1].X != 295 {
+		t.Errorf("Unexpected v8 boxes: %+v", boxes)
+	}
+
+	if _, err := detection.ParseYOLO(detection.LayoutYOLOv8, []int{1, 6, 2}, v8[:5], 0.5); err == nil {
+		t.Error("Expected an error for a truncated tensor")
+	}
+}
+
+func TestNMS_ClassAware(t *testing.T) {
+	boxes := []detection.Box{
+		{ClassID: 0, Confidence: 0.7, X: 12, Y: 10, Width: 100, Height: 100},
+		{ClassID: 0, Confidence: 0.9, X: 10, Y: 10, Width: 100, Height: 100},
+		{ClassID: 1, Confidence: 0.8, X: 10, Y: 10, Width: 100, Height: 100},
+		{ClassID: 0, Confidence: 0.6, X: 300, Y: 300, Width: 50, Height: 50},
+	}
+
+	kept := detection.NMS(boxes, 0.45)
+	if len(kept) != 3 {
+		t.Fatalf("Expected 3 boxes after NMS, got %+v", kept)
+	}
+	if kept[0].Confidence != 0.9 || kept[1].ClassID != 1 || kept[2].X != 300 {
+		t.Errorf("Expected the most confident overlapping box per class to be kept, got %+v", kept)
+	}
+}
+
+func TestDecodeYOLO_FrameCoordinates(t *testing.T) {
+	desc := detection.ModelDescriptor{
+		Layout:              detection.LayoutYOLOv8,
+		ConfidenceThreshold: 0.5,
+		NMSThreshold:        0.45,
+		Labels:              map[int]string{0: "person"},
+	}
+	letterbox := detection.NewLetterbox(1280, 720, 640, 640)
+
+	// Two overlapping person boxes in model input pixels
+	data := []float32{
+		320, 322, // cx
+		320, 320, // cy
+		100, 100, // w
+		200, 200, // h
+		0.9, 0.7, // person
+	}
+	results, err := detection.DecodeYOLO(desc, []int{1, 5, 2}, data, letterbox)
+	if err != nil {
+		t.Fatalf("Failed to decode output: %v", err)
+	}
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 detection after NMS, got %+v", results)
+	}
+
+	r := results[0]
+	if r.Label != "person" || r.Confidence != float64(float32(0.9)) || r.X != 540 || r.Y != 160 || r.Width != 200 || r.Height != 400 {
+		t.Errorf("Unexpected detection: %+v", r)
+	}
+}
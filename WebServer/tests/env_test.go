@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"webserver/internal/config"
+	"webserver/internal/logger"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/clip"
+	"webserver/internal/service/event"
+	"webserver/internal/service/health"
+	"webserver/internal/service/motion"
+	"webserver/internal/service/recording"
+	"webserver/internal/service/registry"
+	"webserver/internal/service/retention"
+	"webserver/internal/service/rule"
+	"webserver/internal/service/storage"
+	"webserver/internal/service/stream"
+)
+
+// ========================================
+// Test Environment
+// ========================================
+
+// testEnv is the common fixture of the service tests: a migrated database, a config
+// whose directories are temporary, and a logger. Services are built from env.cfg when
+// requested, so tests set the options they need first. Everything is removed when the
+// test ends.
+type testEnv struct {
+	t      *testing.T
+	db     *sqlite.DB
+	cfg    *config.Config
+	logger *logger.Logger
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+
+	db, cleanup := setupTestDB(t)
+	t.Cleanup(cleanup)
+
+	return &testEnv{
+		t:  t,
+		db: db,
+		cfg: &config.Config{
+			ImageDirectory:     t.TempDir(),
+			SpoolDir:           t.TempDir(),
+			RecordingDirectory: t.TempDir(),
+			ClipDirectory:      t.TempDir(),
+		},
+		logger: setupTestLogger(t),
+	}
+}
+
+func setupTestLogger(t *testing.T) *logger.Logger {
+	t.Helper()
+
+	tempDir, err := os.MkdirTemp("", "logs_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(tempDir) })
+
+	return logger.NewLogger(&config.Config{LogDirectory: tempDir})
+}
+
+// buffer returns a BufferService storing images and their variants into the local
+// image directory.
+func (e *testEnv) buffer() *storage.BufferService {
+	return storage.NewBufferService(e.cfg, e.logger, nil, sqlite.NewImageRepository(e.db),
+		sqlite.NewImageVariantRepository(e.db))
+}
+
+// registry returns a RegistryService of the cameras stored in the database, seeded with
+// the cameras of env.cfg.
+func (e *testEnv) registry() *registry.RegistryService {
+	e.t.Helper()
+
+	reg, err := registry.NewRegistryService(e.cfg, e.logger, sqlite.NewCameraRepository(e.db))
+	if err != nil {
+		e.t.Fatalf("Failed to load camera registry: %v", err)
+	}
+	return reg
+}
+
+func (e *testEnv) clips() *clip.ClipService {
+	return clip.NewClipService(e.cfg, e.logger, sqlite.NewClipRepository(e.db))
+}
+
+func (e *testEnv) events() *event.EventService {
+	return event.NewEventService(e.cfg, e.logger, sqlite.NewEventRepository(e.db), e.buffer())
+}
+
+func (e *testEnv) health() *health.HealthService {
+	return health.NewHealthService(e.cfg, e.logger, e.registry(), sqlite.NewCameraEventRepository(e.db), nil)
+}
+
+func (e *testEnv) zones() *motion.ZoneService {
+	return motion.NewZoneService(e.cfg, e.logger, sqlite.NewMotionZoneRepository(e.db))
+}
+
+func (e *testEnv) rules() *rule.RuleService {
+	return rule.NewRuleService(e.cfg, e.logger, sqlite.NewRuleRepository(e.db), sqlite.NewRuleTriggerRepository(e.db))
+}
+
+func (e *testEnv) recorder() *recording.RecordingService {
+	return recording.NewRecordingService(e.cfg, e.logger, sqlite.NewRecordingRepository(e.db))
+}
+
+func (e *testEnv) retention() *retention.RetentionService {
+	return retention.NewRetentionService(e.cfg, e.logger, e.buffer(), sqlite.NewImageRepository(e.db))
+}
+
+func (e *testEnv) reassembler() *stream.ReassemblerService {
+	return stream.NewReassemblerService(e.cfg, e.logger)
+}
+
+func (e *testEnv) identity() *stream.IdentityService {
+	return stream.NewIdentityService(e.cfg, e.logger, e.registry())
+}
@@ -0,0 +1,311 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/event"
+)
+
+func detected(label string, confidence float64) []dto.DetectionResult {
+	return []dto.DetectionResult{{Label: label, Confidence: confidence}}
+}
+
+// ========================================
+// Event Repository Tests
+// ========================================
+
+func TestEventRepository_Filters(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	repo := sqlite.NewEventRepository(db)
+
+	day := time.Date(2025, 3, 10, 0, 0, 0, 0, time.Local)
+	fixtures := []model.Event{
+		{Camera: "gate", StartTime: day.Add(8 * time.Hour), Objects: []string{"car", "person"}},
+		{Camera: "gate", StartTime: day.Add(20 * time.Hour), Objects: []string{"dog"}},
+		{Camera: "garden", StartTime: day.Add(32 * time.Hour), Objects: []string{"person"}},
+	}
+	for i := range fixtures {
+		fixtures[i].EndTime = fixtures[i].StartTime.Add(time.Minute)
+		if _, err := repo.Insert(&fixtures[i]); err != nil {
+			t.Fatalf("Failed to insert event: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		filter   dto.ImageFilters
+		expected int
+	}{
+		{"all", dto.ImageFilters{}, 3},
+		{"camera", dto.ImageFilters{Camera: "gate"}, 2},
+		{"object", dto.ImageFilters{Object: "person"}, 2},
+		{"object is not a substring match", dto.ImageFilters{Object: "per"}, 0},
+		{"date after", dto.ImageFilters{DateAfter: day.Add(24 * time.Hour)}, 1},
+		{"date before", dto.ImageFilters{DateBefore: day}, 2},
+		{"time after", dto.ImageFilters{TimeAfter: time.Date(0, 1, 1, 12, 0, 0, 0, time.Local)}, 1},
+		{"time before", dto.ImageFilters{TimeBefore: time.Date(0, 1, 1, 12, 0, 0, 0, time.Local)}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter := tt.filter
+			count, err := repo.GetTotalCount(&filter)
+			if err != nil {
+				t.Fatalf("Failed to count events: %v", err)
+			}
+			if count != tt.expected {
+				t.Errorf("Expected %d events, got %d", tt.expected, count)
+			}
+
+			events, err := repo.GetAll(&filter)
+			if err != nil {
+				t.Fatalf("Failed to get events: %v", err)
+			}
+			if len(events) != tt.expected {
+				t.Errorf("Expected %d listed events, got %d", tt.expected, len(events))
+			}
+		})
+	}
+
+	page, err := repo.GetAll(&dto.ImageFilters{Limit: 2, Page: 2})
+	if err != nil {
+		t.Fatalf("Failed to get events: %v", err)
+	}
+	if len(page) != 1 || page[0].Camera != "gate" || page[0].Objects[0] != "car" {
+		t.Errorf("Expected the oldest event on page 2, got %+v", page)
+	}
+}
+
+func TestEventRepository_Delete(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	repo := sqlite.NewEventRepository(db)
+
+	now := time.Now()
+	id, err := repo.Insert(&model.Event{Camera: "gate", StartTime: now, EndTime: now})
+	if err != nil {
+		t.Fatalf("Failed to insert event: %v", err)
+	}
+	for _, filename := range []string{"b.jpg", "a.jpg", "a.jpg"} {
+		if err := repo.AddImage(id, filename); err != nil {
+			t.Fatalf("Failed to link image: %v", err)
+		}
+	}
+
+	filenames, err := repo.GetImageFilenames(id)
+	if err != nil {
+		t.Fatalf("Failed to get event images: %v", err)
+	}
+	if len(filenames) != 2 || filenames[0] != "a.jpg" {
+		t.Errorf("Expected [a.jpg b.jpg], got %v", filenames)
+	}
+
+	if err := repo.Delete(id); err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+	if saved, _ := repo.GetByID(id); saved != nil {
+		t.Error("Event should be deleted")
+	}
+	if filenames, _ := repo.GetImageFilenames(id); len(filenames) != 0 {
+		t.Errorf("Image links should be deleted, got %v", filenames)
+	}
+}
+
+// ========================================
+// Event Service Tests
+// ========================================
+
+func TestEvent_MergesWithinGap(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+	events, repo := env.events(), sqlite.NewEventRepository(env.db)
+
+	now := time.Now()
+	first := events.RecordDetection("gate", "1.jpg", detected("person", 0.6), now)
+	second := events.RecordDetection("gate", "2.jpg", detected("car", 0.9), now.Add(5*time.Second))
+	third := events.RecordDetection("gate", "3.jpg", detected("person", 0.7), now.Add(12*time.Second))
+
+	if first == 0 || first != second || second != third {
+		t.Fatalf("Expected one event, got IDs %d, %d, %d", first, second, third)
+	}
+
+	saved, err := repo.GetByID(first)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	if saved.PeakConfidence != 0.9 || saved.Thumbnail != "2.jpg" {
+		t.Errorf("Expected peak 0.9 with thumbnail 2.jpg, got %.2f with %s", saved.PeakConfidence, saved.Thumbnail)
+	}
+	if len(saved.Objects) != 2 || saved.Objects[0] != "car" || saved.Objects[1] != "person" {
+		t.Errorf("Expected objects [car person], got %v", saved.Objects)
+	}
+	if saved.ImageCount != 3 {
+		t.Errorf("Expected 3 images, got %d", saved.ImageCount)
+	}
+	if saved.EndTime.Sub(saved.StartTime) != 12*time.Second {
+		t.Errorf("Expected a 12s event, got %v", saved.EndTime.Sub(saved.StartTime))
+	}
+}
+
+func TestEvent_SplitsAfterGapAndPerCamera(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+	events := env.events()
+
+	now := time.Now()
+	first := events.RecordDetection("gate", "1.jpg", detected("person", 0.6), now)
+	other := events.RecordDetection("garden", "2.jpg", detected("person", 0.6), now.Add(time.Second))
+	later := events.RecordDetection("gate", "3.jpg", detected("person", 0.6), now.Add(11*time.Second))
+
+	if first == other {
+		t.Error("Detections from different cameras should not share an event")
+	}
+	if first == later {
+		t.Error("Detections further apart than the gap should start a new event")
+	}
+}
+
+func TestEvent_ExtendedBySuppressedDetections(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+	events, repo := env.events(), sqlite.NewEventRepository(env.db)
+
+	now := time.Now()
+	if events.Extend("gate", detected("car", 0.9), now) != 0 {
+		t.Error("Expected no event to be started without a saved image")
+	}
+
+	first := events.RecordDetection("gate", "1.jpg", detected("car", 0.9), now)
+	// A parked car is suppressed for longer than the gap
+	for i := 1; i <= 3; i++ {
+		if id := events.Extend("gate", detected("car", 0.9), now.Add(time.Duration(i)*8*time.Second)); id != first {
+			t.Fatalf("Expected the suppressed detection to extend event %d, got %d", first, id)
+		}
+	}
+	if later := events.RecordDetection("gate", "2.jpg", detected("person", 0.7), now.Add(30*time.Second)); later != first {
+		t.Errorf("Expected the event to stay open while the car is parked, got a new event %d", later)
+	}
+
+	saved, _ := repo.GetByID(first)
+	if saved.ImageCount != 2 || saved.EndTime.Sub(saved.StartTime) != 30*time.Second {
+		t.Errorf("Expected a 30s event with 2 images, got %+v", saved)
+	}
+}
+
+func TestEvent_ContinuesAfterRestart(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+
+	now := time.Now()
+	before := env.events()
+	first := before.RecordDetection("gate", "1.jpg", detected("person", 0.6), now)
+
+	after := env.events()
+	if second := after.RecordDetection("gate", "2.jpg", detected("person", 0.6), now.Add(time.Second)); second != first {
+		t.Errorf("Expected the event to continue after a restart, got %d and %d", first, second)
+	}
+}
+
+func TestEvent_FollowsRenamedCamera(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+	events := env.events()
+
+	now := time.Now()
+	first := events.RecordDetection("brama", "1.jpg", detected("person", 0.6), now)
+	if _, err := env.db.Conn().Exec(`UPDATE events SET camera = 'gate' WHERE camera = 'brama'`); err != nil {
+		t.Fatalf("Failed to rename camera: %v", err)
+	}
+	events.RenameCamera("brama", "gate")
+
+	if second := events.RecordDetection("gate", "2.jpg", detected("person", 0.6), now.Add(time.Second)); second != first {
+		t.Errorf("Expected the event to continue under the new name, got %d and %d", first, second)
+	}
+	if third := events.RecordDetection("brama", "3.jpg", detected("person", 0.6), now.Add(2*time.Second)); third == first {
+		t.Error("Expected the old name to start a new event")
+	}
+}
+
+func TestEvent_DeleteRemovesImages(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+	events, repo, imageRepo := env.events(), sqlite.NewEventRepository(env.db), sqlite.NewImageRepository(env.db)
+	dir := env.cfg.ImageDirectory
+
+	now := time.Now()
+	var id int64
+	for _, filename := range []string{"1.jpg", "2.jpg"} {
+		path := filepath.Join(dir, filename)
+		if err := os.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+			t.Fatalf("Failed to write image: %v", err)
+		}
+		if _, err := imageRepo.Insert(&model.Image{Filename: filename, Camera: "gate", Timestamp: now, FilePath: path}); err != nil {
+			t.Fatalf("Failed to insert image: %v", err)
+		}
+		id = events.RecordDetection("gate", filename, detected("person", 0.6), now)
+	}
+
+	if err := events.Delete(id); err != nil {
+		t.Fatalf("Failed to delete event: %v", err)
+	}
+
+	if saved, _ := repo.GetByID(id); saved != nil {
+		t.Error("Event should be deleted")
+	}
+	for _, filename := range []string{"1.jpg", "2.jpg"} {
+		if _, err := os.Stat(filepath.Join(dir, filename)); !os.IsNotExist(err) {
+			t.Errorf("File %s should be deleted", filename)
+		}
+		if img, _ := imageRepo.GetByFilename(filename); img != nil {
+			t.Errorf("Image %s should be deleted from the database", filename)
+		}
+	}
+
+	if err := events.Delete(id); !errors.Is(err, event.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted event, got %v", err)
+	}
+
+	// A new detection after deletion starts a fresh event
+	if next := events.RecordDetection("gate", "3.jpg", detected("person", 0.6), now); next == id || next == 0 {
+		t.Errorf("Expected a new event after deletion, got %d", next)
+	}
+}
+
+func TestEvent_ReloadsAfterImageRemoved(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.EventGapS = 10
+	buffer, repo, imageRepo := env.buffer(), sqlite.NewEventRepository(env.db), sqlite.NewImageRepository(env.db)
+	events := event.NewEventService(env.cfg, env.logger, repo, buffer)
+
+	now := time.Now()
+	var id int64
+	for i, filename := range []string{"1.jpg", "2.jpg"} {
+		if _, err := imageRepo.Insert(&model.Image{Filename: filename, Camera: "gate", Timestamp: now}); err != nil {
+			t.Fatalf("Failed to insert image: %v", err)
+		}
+		id = events.RecordDetection("gate", filename, detected("person", 0.6+0.3*float64(i)), now)
+	}
+
+	if err := buffer.DeleteImage("2.jpg"); err != nil {
+		t.Fatalf("Failed to delete image: %v", err)
+	}
+	if next := events.RecordDetection("gate", "3.jpg", detected("person", 0.5), now.Add(time.Second)); next != id {
+		t.Fatalf("Expected the event to continue, got %d and %d", id, next)
+	}
+
+	saved, err := repo.GetByID(id)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to get event: %v", err)
+	}
+	if saved.ImageCount != 2 || saved.Thumbnail != "1.jpg" {
+		t.Errorf("Expected 2 images with thumbnail 1.jpg, got %d with %s", saved.ImageCount, saved.Thumbnail)
+	}
+}
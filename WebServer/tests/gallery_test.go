@@ -364,6 +364,34 @@ func TestImageRepository_DeleteAll(t *testing.T) {
 	}
 }
 
+func TestImageRepository_DeleteAllUnlinksImages(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo, eventRepo, clipRepo := sqlite.NewImageRepository(db), sqlite.NewEventRepository(db), sqlite.NewClipRepository(db)
+	now := time.Now()
+	repo.Insert(&model.Image{Filename: "a.jpg", Camera: "gate", Timestamp: now})
+	eventID, _ := eventRepo.Insert(&model.Event{Camera: "gate", StartTime: now, EndTime: now, Thumbnail: "a.jpg", ImageCount: 1})
+	eventRepo.AddImage(eventID, "a.jpg")
+	clipID, _ := clipRepo.Insert(&model.Clip{Camera: "gate", StartTime: now, EndTime: now})
+	clipRepo.AddImage(clipID, "a.jpg")
+
+	if err := repo.DeleteAll(); err != nil {
+		t.Fatalf("DeleteAll failed: %v", err)
+	}
+
+	event, _ := eventRepo.GetByID(eventID)
+	if event == nil || event.ImageCount != 0 || event.Thumbnail != "" {
+		t.Errorf("Expected the event without images, got %+v", event)
+	}
+	if filenames, _ := eventRepo.GetImageFilenames(eventID); len(filenames) != 0 {
+		t.Errorf("Expected the event links deleted, got %v", filenames)
+	}
+	if clip, _ := clipRepo.GetByImage("a.jpg"); clip != nil {
+		t.Errorf("Expected the clip link deleted, got clip %d", clip.ID)
+	}
+}
+
 func TestImageRepository_GetTotalCount(t *testing.T) {
 	db, cleanup := setupTestDB(t)
 	defer cleanup()
@@ -0,0 +1,186 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/health"
+)
+
+// ========================================
+// Camera Event Repository Tests
+// ========================================
+
+func TestCameraEventRepository_GetRecent(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := sqlite.NewCameraEventRepository(db)
+	base := time.Now()
+	events := []model.CameraEvent{
+		{Camera: "gate", Type: health.StatusOnline, Timestamp: base},
+		{Camera: "door", Type: health.StatusOnline, Timestamp: base.Add(time.Second)},
+		{Camera: "gate", Type: health.StatusOffline, Message: "no frames", Timestamp: base.Add(2 * time.Second)},
+	}
+	for i := range events {
+		if _, err := repo.Insert(&events[i]); err != nil {
+			t.Fatalf("Failed to insert event: %v", err)
+		}
+	}
+
+	recent, err := repo.GetRecent("gate", "", 10)
+	if err != nil {
+		t.Fatalf("Failed to get events: %v", err)
+	}
+	if len(recent) != 2 {
+		t.Fatalf("Expected 2 events for gate, got %d", len(recent))
+	}
+	if recent[0].Type != health.StatusOffline || recent[0].Message != "no frames" {
+		t.Errorf("Newest event should come first, got %+v", recent[0])
+	}
+
+	if all, _ := repo.GetRecent("", "", 2); len(all) != 2 {
+		t.Errorf("Expected limit to be applied, got %d events", len(all))
+	}
+}
+
+// ========================================
+// Health Service Tests
+// ========================================
+
+func TestHealth_OnlineAfterFrames(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"10.0.0.1": "gate"}
+	env.cfg.CameraOnlineFrames = 3
+	svc, eventRepo := env.health(), sqlite.NewCameraEventRepository(env.db)
+
+	svc.RecordFrame("gate", 1000)
+	svc.RecordFrame("gate", 2000)
+	if status, _ := svc.GetStatusByName("gate"); status.Status != health.StatusUnknown {
+		t.Errorf("Camera should not be online before %d frames, got %s", 3, status.Status)
+	}
+
+	svc.RecordFrame("gate", 3000)
+	status, ok := svc.GetStatusByName("gate")
+	if !ok {
+		t.Fatal("Registered camera should have a status")
+	}
+	if status.Status != health.StatusOnline {
+		t.Errorf("Expected online, got %s", status.Status)
+	}
+	if status.Frames != 3 || status.LastFrameSize != 3000 || status.AvgFrameSize != 2000 {
+		t.Errorf("Unexpected frame statistics: %+v", status)
+	}
+
+	events, _ := eventRepo.GetRecent("gate", "", 10)
+	if len(events) != 1 || events[0].Type != health.StatusOnline {
+		t.Errorf("Expected one online event, got %+v", events)
+	}
+}
+
+func TestHealth_GoesOffline(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"10.0.0.1": "gate"}
+	env.cfg.CameraOfflineAfterS, env.cfg.CameraOnlineFrames = 1, 1
+	svc, eventRepo := env.health(), sqlite.NewCameraEventRepository(env.db)
+
+	svc.RecordFrame("gate", 1000)
+	svc.CheckOffline()
+	if status, _ := svc.GetStatusByName("gate"); status.Status != health.StatusOnline {
+		t.Fatalf("Camera should stay online right after a frame, got %s", status.Status)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	svc.CheckOffline()
+	svc.CheckOffline()
+
+	if status, _ := svc.GetStatusByName("gate"); status.Status != health.StatusOffline {
+		t.Errorf("Expected offline, got %s", status.Status)
+	}
+
+	events, _ := eventRepo.GetRecent("gate", "", 10)
+	if len(events) != 2 || events[0].Type != health.StatusOffline {
+		t.Errorf("Expected a single offline transition after online, got %+v", events)
+	}
+}
+
+func TestHealth_DecodeFailures(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"10.0.0.1": "gate"}
+	svc := env.health()
+
+	svc.RecordDecodeFailure("gate")
+	svc.RecordDecodeFailure("gate")
+
+	if status, _ := svc.GetStatusByName("gate"); status.DecodeFailures != 2 {
+		t.Errorf("Expected 2 decode failures, got %d", status.DecodeFailures)
+	}
+	if _, ok := svc.GetStatusByName("unknown"); ok {
+		t.Error("Unregistered cameras should not be reported")
+	}
+}
+
+func TestHealth_SuppressedFrames(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"10.0.0.1": "gate"}
+	svc := env.health()
+
+	svc.RecordSuppressed("gate")
+
+	if status, _ := svc.GetStatusByName("gate"); status.Suppressed != 1 {
+		t.Errorf("Expected 1 suppressed frame, got %d", status.Suppressed)
+	}
+}
+
+func TestHealth_SceneChangesRecordedSeparately(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraNames = map[string]string{"10.0.0.1": "gate"}
+	env.cfg.CameraOnlineFrames = 1
+	svc, eventRepo := env.health(), sqlite.NewCameraEventRepository(env.db)
+
+	svc.RecordFrame("gate", 1000)
+	svc.RecordSceneChange("gate", "mean brightness changed from 40 to 120")
+
+	if status, _ := svc.GetStatusByName("gate"); status.SceneChanges != 1 {
+		t.Errorf("Expected 1 scene change, got %d", status.SceneChanges)
+	}
+
+	changes, err := eventRepo.GetRecent("gate", health.EventSceneChange, 10)
+	if err != nil {
+		t.Fatalf("Failed to get events: %v", err)
+	}
+	if len(changes) != 1 || changes[0].Message != "mean brightness changed from 40 to 120" {
+		t.Errorf("Expected one scene change event, got %+v", changes)
+	}
+
+	if online, _ := eventRepo.GetRecent("gate", health.StatusOnline, 10); len(online) != 1 {
+		t.Errorf("Expected the type filter to return only the online event, got %+v", online)
+	}
+}
+
+func TestHealth_FollowsRenamedCamera(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraOnlineFrames = 1
+	reg := env.registry()
+	eventRepo := sqlite.NewCameraEventRepository(env.db)
+	svc := health.NewHealthService(env.cfg, env.logger, reg, eventRepo, nil)
+
+	cam, _ := reg.Create(model.Camera{Name: "brama", DeviceID: 1, Enabled: true})
+	svc.RecordFrame("brama", 1000)
+
+	cam.Name = "gate"
+	if _, err := reg.Update(cam); err != nil {
+		t.Fatalf("Failed to rename camera: %v", err)
+	}
+	svc.RecordFrame("gate", 1000)
+
+	statuses := svc.GetStatus()
+	if len(statuses) != 1 || statuses[0].Camera != "gate" || statuses[0].Status != health.StatusOnline || statuses[0].Frames != 2 {
+		t.Errorf("Expected the health of the camera to follow its new name, got %+v", statuses)
+	}
+	if events, _ := eventRepo.GetRecent("gate", health.StatusOnline, 10); len(events) != 1 {
+		t.Errorf("Expected the online event to move to the new name, got %+v", events)
+	}
+}
@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/storage"
+)
+
+// ========================================
+// Image Layout Tests
+// ========================================
+
+func TestLayout_ImageKey(t *testing.T) {
+	ts := time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC)
+
+	if key := storage.ImageKey("Gate", ts, "a.jpg"); key != "Gate/2024/05/01/a.jpg" {
+		t.Errorf("Unexpected key %s", key)
+	}
+	if key := storage.ImageKey("../Front door", ts, "a.jpg"); key != "___Front_door/2024/05/01/a.jpg" {
+		t.Errorf("Expected unsafe camera names to be replaced, got %s", key)
+	}
+	if key := storage.ImageKey("", ts, "a.jpg"); key != "unknown/2024/05/01/a.jpg" {
+		t.Errorf("Unexpected key for an empty camera %s", key)
+	}
+
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}\.jpg$`)
+	first, second := storage.NewFilename(), storage.NewFilename()
+	if !uuid.MatchString(first) || first == second {
+		t.Errorf("Expected distinct UUID filenames, got %s and %s", first, second)
+	}
+}
+
+func TestLayout_NewImagesArePartitioned(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	buffer := env.buffer()
+
+	filename := buffer.AddImage(encodeJPEG(t, 64, 48), "gate", []dto.DetectionResult{box("person", 0, 0, 10, 10)})
+	buffer.FlushImages()
+
+	img, err := imageRepo.GetByFilename(filename)
+	if err != nil || img == nil {
+		t.Fatalf("Expected the image to be stored, got %v", err)
+	}
+	expected := filepath.Join(imagesDir, "gate", img.Timestamp.Format("2006/01/02"), filename)
+	if img.FilePath != expected {
+		t.Errorf("Expected the image at %s, got %s", expected, img.FilePath)
+	}
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("Expected the file on disk: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(imagesDir, "thumb", "gate", img.Timestamp.Format("2006/01/02"), filename)); err != nil {
+		t.Errorf("Expected the thumbnail in the same layout: %v", err)
+	}
+	if _, _, _, err := buffer.ReadImage(filename, ""); err != nil {
+		t.Errorf("Failed to read the image by filename: %v", err)
+	}
+}
+
+func TestLayout_MigrateFlatImages(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	variantRepo := sqlite.NewImageVariantRepository(env.db)
+	buffer := env.buffer()
+
+	// Stored flat before the layout existed, with a thumbnail, and a row whose file is gone
+	name := "2024-05-01_10-00_00.000_gate_osoba_.jpg"
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	os.MkdirAll(filepath.Join(imagesDir, "thumb"), 0755)
+	os.WriteFile(filepath.Join(imagesDir, name), []byte("original"), 0644)
+	os.WriteFile(filepath.Join(imagesDir, "thumb", name), []byte("thumb"), 0644)
+	id, _ := imageRepo.Insert(&model.Image{Filename: name, Camera: "gate", Timestamp: ts,
+		FilePath: filepath.Join(imagesDir, name)})
+	variantRepo.Upsert(&model.ImageVariant{ImageID: id, Size: storage.VariantThumb, Filename: "thumb/" + name})
+	imageRepo.Insert(&model.Image{Filename: "gone.jpg", Camera: "gate", Timestamp: ts,
+		FilePath: filepath.Join(imagesDir, "gone.jpg")})
+
+	moved, err := buffer.MigrateLayout()
+	if err != nil || moved != 1 {
+		t.Fatalf("Expected 1 image moved, got %d: %v", moved, err)
+	}
+
+	img, _ := imageRepo.GetByID(id)
+	if expected := filepath.Join(imagesDir, "gate", "2024", "05", "01", name); img.FilePath != expected {
+		t.Errorf("Expected the row to point to %s, got %s", expected, img.FilePath)
+	}
+	if _, err := os.Stat(filepath.Join(imagesDir, name)); !os.IsNotExist(err) {
+		t.Error("Expected the flat file to be moved")
+	}
+	if variants, _ := variantRepo.GetByImageID(id); len(variants) != 1 ||
+		variants[0].Filename != "thumb/gate/2024/05/01/"+name {
+		t.Errorf("Expected the variant row to be rewritten, got %+v", variants)
+	}
+	if data, _, _, err := buffer.ReadImage(name, storage.VariantThumb); err != nil || string(data) != "thumb" {
+		t.Errorf("Expected the moved thumbnail, got %q: %v", data, err)
+	}
+
+	if moved, _ := buffer.MigrateLayout(); moved != 0 {
+		t.Errorf("Expected a second run to move nothing, got %d", moved)
+	}
+}
@@ -0,0 +1,201 @@
+package tests
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+)
+
+// fixtureDB creates a database from an SQL file in testdata and returns its path.
+func fixtureDB(t *testing.T, name string) string {
+	t.Helper()
+
+	script, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("Failed to read fixture: %v", err)
+	}
+	dbPath := filepath.Join(t.TempDir(), "fixture.db")
+	conn, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Exec(string(script)); err != nil {
+		t.Fatalf("Failed to load fixture: %v", err)
+	}
+	return dbPath
+}
+
+// columnExists reports whether a table of the database has a column.
+func columnExists(t *testing.T, db *sqlite.DB, table, column string) bool {
+	t.Helper()
+
+	var count int
+	if err := db.Conn().QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&count); err != nil {
+		t.Fatalf("Failed to read columns: %v", err)
+	}
+	return count > 0
+}
+
+// ========================================
+// Schema Migration Tests
+// ========================================
+
+func TestMigrations_UpgradeBaselineDatabase(t *testing.T) {
+	dbPath := fixtureDB(t, "baseline.sql")
+
+	before, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if version, _ := before.Version(); version != 0 {
+		t.Errorf("Expected an untracked database to be at version 0, got %d", version)
+	}
+	before.Close()
+
+	db, err := sqlite.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	defer db.Close()
+
+	statuses, err := db.MigrationStatus()
+	if err != nil || len(statuses) != sqlite.LatestVersion() {
+		t.Fatalf("Expected %d migrations, got %d: %v", sqlite.LatestVersion(), len(statuses), err)
+	}
+	for _, status := range statuses {
+		if !status.Applied || status.AppliedAt.IsZero() || status.Unknown {
+			t.Errorf("Expected migration %d to be applied, got %+v", status.Version, status)
+		}
+	}
+
+	// The old rows survive with defaults for the new columns
+	img, err := sqlite.NewImageRepository(db).GetByFilename("2024-05-01_10-00_00.000_gate_osoba_.jpg")
+	if err != nil || img == nil || img.Storage != "local" || img.FileSize != 2048 {
+		t.Fatalf("Expected the old image with local storage, got %+v: %v", img, err)
+	}
+	detections, _ := sqlite.NewDetectionRepository(db).GetByImageID(img.ID)
+	if len(detections) != 1 || detections[0].ObjectName != "osoba" || detections[0].Zone != "" || detections[0].TrackID != 0 {
+		t.Errorf("Expected the old detection without zone and track, got %+v", detections)
+	}
+	if _, err := sqlite.NewCameraRepository(db).Insert(&model.Camera{Name: "gate", Enabled: true}); err != nil {
+		t.Errorf("Expected the tables of later migrations to exist: %v", err)
+	}
+}
+
+func TestMigrations_DownAndUp(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	sqlite.NewImageRepository(db).InsertWithDetections(&model.Image{Filename: "a.jpg", Camera: "gate"},
+		[]model.Detection{{ObjectName: "car", Zone: "driveway", TrackID: 3}})
+
+	reverted, err := db.MigrateDown(7)
+	if err != nil || reverted != sqlite.LatestVersion()-7 {
+		t.Fatalf("Expected %d migrations reverted, got %d: %v", sqlite.LatestVersion()-7, reverted, err)
+	}
+	if version, _ := db.Version(); version != 7 {
+		t.Errorf("Expected version 7, got %d", version)
+	}
+	if columnExists(t, db, "detections", "zone") || columnExists(t, db, "images", "storage") {
+		t.Error("Expected the columns of reverted migrations to be dropped")
+	}
+	var tables int
+	db.Conn().QueryRow(`SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name IN ('tracks', 'rules', 'image_variants')`).Scan(&tables)
+	if tables != 0 {
+		t.Errorf("Expected the tables of reverted migrations to be dropped, %d left", tables)
+	}
+	var images int
+	db.Conn().QueryRow(`SELECT COUNT(*) FROM images`).Scan(&images)
+	if images != 1 {
+		t.Error("Expected the rows of kept tables to survive")
+	}
+
+	if applied, err := db.MigrateUp(sqlite.LatestVersion()); err != nil || applied != sqlite.LatestVersion()-7 {
+		t.Fatalf("Expected the reverted migrations to be applied again, got %d: %v", applied, err)
+	}
+	if applied, _ := db.MigrateUp(sqlite.LatestVersion()); applied != 0 {
+		t.Errorf("Expected nothing left to apply, got %d", applied)
+	}
+	if !columnExists(t, db, "detections", "track_id") {
+		t.Error("Expected the track_id column to be added again")
+	}
+}
+
+func TestMigrations_AdoptsUntrackedSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+
+	// A current schema written before migrations were tracked
+	db, err := sqlite.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	sqlite.NewImageRepository(db).Insert(&model.Image{Filename: "a.jpg", Camera: "gate", Storage: "s3"})
+	db.Conn().Exec(`DROP TABLE schema_migrations`)
+	db.Close()
+
+	db, err = sqlite.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to migrate an untracked database: %v", err)
+	}
+	defer db.Close()
+
+	if version, _ := db.Version(); version != sqlite.LatestVersion() {
+		t.Errorf("Expected version %d, got %d", sqlite.LatestVersion(), version)
+	}
+	if img, _ := sqlite.NewImageRepository(db).GetByFilename("a.jpg"); img == nil || img.Storage != "s3" {
+		t.Errorf("Expected the existing rows to be kept, got %+v", img)
+	}
+}
+
+func TestMigrations_FailedStepIsRolledBack(t *testing.T) {
+	dbPath := fixtureDB(t, "baseline.sql")
+
+	// A view where migration 9 creates the tracks table cannot be indexed
+	conn, _ := sql.Open("sqlite3", dbPath)
+	conn.Exec(`CREATE VIEW tracks AS SELECT 1 AS camera, 2 AS start_time`)
+	conn.Close()
+
+	if _, err := sqlite.New(dbPath); err == nil {
+		t.Fatal("Expected the migration to fail")
+	}
+
+	db, err := sqlite.Open(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if version, _ := db.Version(); version != 8 {
+		t.Errorf("Expected the migrations before the failing one to stay applied, got version %d", version)
+	}
+	if columnExists(t, db, "detections", "track_id") {
+		t.Error("Expected the failed migration to be rolled back")
+	}
+}
+
+func TestMigrations_RejectsNewerSchema(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "test.db")
+	db, err := sqlite.New(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	db.Conn().Exec(`INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, 'from the future', CURRENT_TIMESTAMP)`,
+		sqlite.LatestVersion()+1)
+	db.Close()
+
+	if _, err := sqlite.New(dbPath); err == nil {
+		t.Error("Expected a database migrated by a newer server to be rejected")
+	}
+
+	db, _ = sqlite.Open(dbPath)
+	defer db.Close()
+	statuses, _ := db.MigrationStatus()
+	if last := statuses[len(statuses)-1]; !last.Unknown || last.Version != sqlite.LatestVersion()+1 {
+		t.Errorf("Expected the unknown migration to be listed, got %+v", last)
+	}
+}
@@ -0,0 +1,337 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/motion"
+)
+
+// rect returns a rectangular polygon in relative coordinates.
+func rect(x0, y0, x1, y1 float64) []model.Point {
+	return []model.Point{{X: x0, Y: y0}, {X: x1, Y: y0}, {X: x1, Y: y1}, {X: x0, Y: y1}}
+}
+
+// diffMask returns a width x height mask with the given pixel rectangle marked as changed.
+func diffMask(width, height, x0, y0, x1, y1 int) []byte {
+	mask := make([]byte, width*height)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			mask[y*width+x] = 255
+		}
+	}
+	return mask
+}
+
+// ========================================
+// Zone Evaluation Tests
+// ========================================
+
+func TestMotionZones_WholeFrameThreshold(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.MotionThresholdPct = 1
+	zones := env.zones()
+
+	// 1% of a 100x100 frame is 100 pixels
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 10, 10), 100, 100); result.Motion {
+		t.Errorf("Exactly the threshold should not be motion, got %.2f%%", result.ChangedPercent)
+	}
+	result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 10, 11), 100, 100)
+	if !result.Motion || result.ChangedPercent != 1.1 || result.Zone != "" {
+		t.Errorf("Expected motion on 1.1%% of the frame, got %+v", result)
+	}
+}
+
+func TestMotionZones_ExcludeZone(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.MotionThresholdPct = 1
+	zones := env.zones()
+
+	if _, err := zones.Create(model.MotionZone{Camera: "gate", Name: "street", Type: model.ZoneExclude,
+		Points: rect(0, 0, 0.5, 1), Enabled: true}); err != nil {
+		t.Fatalf("Failed to create zone: %v", err)
+	}
+
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 50, 100), 100, 100); result.Motion {
+		t.Errorf("Changes inside an exclude zone should be ignored, got %+v", result)
+	}
+
+	// 60 pixels are 1.2% of the 5000 pixels left outside the exclude zone
+	if result := zones.Evaluate("gate", diffMask(100, 100, 40, 0, 60, 6), 100, 100); !result.Motion || result.ChangedPercent != 1.2 {
+		t.Errorf("Expected motion on 1.2%% of the remaining area, got %+v", result)
+	}
+
+	if result := zones.Evaluate("garden", diffMask(100, 100, 0, 0, 50, 100), 100, 100); !result.Motion {
+		t.Error("Zones of another camera should not apply")
+	}
+}
+
+func TestMotionZones_IncludeZoneThreshold(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.MotionThresholdPct = 1
+	zones := env.zones()
+
+	if _, err := zones.Create(model.MotionZone{Camera: "gate", Name: "driveway", Type: model.ZoneInclude,
+		Points: rect(0.5, 0.5, 1, 1), ThresholdPercent: 10, Enabled: true}); err != nil {
+		t.Fatalf("Failed to create zone: %v", err)
+	}
+
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 50, 50), 100, 100); result.Motion {
+		t.Errorf("Changes outside the include zone should be ignored, got %+v", result)
+	}
+	if result := zones.Evaluate("gate", diffMask(100, 100, 50, 50, 100, 55), 100, 100); result.Motion {
+		t.Errorf("Changes on 10%% of the zone should not reach its threshold, got %+v", result)
+	}
+
+	result := zones.Evaluate("gate", diffMask(100, 100, 50, 50, 100, 60), 100, 100)
+	if !result.Motion || result.Zone != "driveway" || result.ChangedPercent != 20 {
+		t.Errorf("Expected motion on 20%% of zone driveway, got %+v", result)
+	}
+
+	// Thresholds are relative, so the same zone works at another resolution
+	if result := zones.Evaluate("gate", diffMask(200, 200, 100, 100, 200, 120), 200, 200); !result.Motion || result.ChangedPercent != 20 {
+		t.Errorf("Expected motion on 20%% of the zone at 200x200, got %+v", result)
+	}
+}
+
+func TestMotionZones_TrianglePolygon(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.MotionThresholdPct = 1
+	zones := env.zones()
+
+	if _, err := zones.Create(model.MotionZone{Camera: "gate", Name: "corner", Type: model.ZoneInclude,
+		Points: []model.Point{{X: 0, Y: 0}, {X: 1, Y: 0}, {X: 0, Y: 1}}, ThresholdPercent: 50, Enabled: true}); err != nil {
+		t.Fatalf("Failed to create zone: %v", err)
+	}
+
+	// The bottom-right half of the frame lies outside the triangle
+	if result := zones.Evaluate("gate", diffMask(100, 100, 60, 60, 100, 100), 100, 100); result.ChangedPercent != 0 {
+		t.Errorf("Expected no changes inside the triangle, got %+v", result)
+	}
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 100, 100), 100, 100); !result.Motion || result.ChangedPercent != 100 {
+		t.Errorf("Expected the whole triangle to change, got %+v", result)
+	}
+}
+
+// ========================================
+// Zone Management Tests
+// ========================================
+
+func TestMotionZones_Validation(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.MotionThresholdPct = 1
+	zones := env.zones()
+
+	tests := []struct {
+		name string
+		zone model.MotionZone
+	}{
+		{"missing camera", model.MotionZone{Type: model.ZoneInclude, Points: rect(0, 0, 1, 1)}},
+		{"unknown type", model.MotionZone{Camera: "gate", Type: "mask", Points: rect(0, 0, 1, 1)}},
+		{"too few points", model.MotionZone{Camera: "gate", Type: model.ZoneInclude, Points: rect(0, 0, 1, 1)[:2]}},
+		{"point outside frame", model.MotionZone{Camera: "gate", Type: model.ZoneInclude, Points: rect(0, 0, 1.5, 1)}},
+		{"threshold above 100", model.MotionZone{Camera: "gate", Type: model.ZoneInclude, Points: rect(0, 0, 1, 1), ThresholdPercent: 150}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := zones.Create(tt.zone); !errors.Is(err, motion.ErrInvalid) {
+				t.Errorf("Expected ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMotionZones_PersistAndRename(t *testing.T) {
+	env := newTestEnv(t)
+	zones, repo := env.zones(), sqlite.NewMotionZoneRepository(env.db)
+
+	created, err := zones.Create(model.MotionZone{Camera: "gate", Name: "street", Type: model.ZoneExclude,
+		Points: rect(0, 0, 0.5, 1), Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create zone: %v", err)
+	}
+
+	reloaded := env.zones()
+	loaded := reloaded.GetAll("gate")
+	if len(loaded) != 1 || loaded[0].Name != "street" || len(loaded[0].Points) != 4 || loaded[0].Points[2].X != 0.5 {
+		t.Fatalf("Expected the zone to be reloaded, got %+v", loaded)
+	}
+
+	created.Enabled = false
+	if _, err := zones.Update(created); err != nil {
+		t.Fatalf("Failed to update zone: %v", err)
+	}
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 50, 100), 100, 100); !result.Motion {
+		t.Error("Disabled zones should not be applied")
+	}
+
+	zones.RenameCamera("gate", "entrance")
+	if len(zones.GetAll("gate")) != 0 || len(zones.GetAll("entrance")) != 1 {
+		t.Error("Expected the zone to move to the renamed camera")
+	}
+
+	if err := zones.Delete(created.ID); err != nil {
+		t.Fatalf("Failed to delete zone: %v", err)
+	}
+	if saved, _ := repo.GetByID(created.ID); saved != nil {
+		t.Error("Zone should be deleted from the database")
+	}
+	if err := zones.Delete(created.ID); !errors.Is(err, motion.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestMotionZones_ReadOnlyWithoutDatabase(t *testing.T) {
+	zones := motion.NewZoneService(&config.Config{}, setupTestLogger(t), nil)
+
+	if _, err := zones.Create(model.MotionZone{Camera: "gate", Type: model.ZoneInclude, Points: rect(0, 0, 1, 1)}); !errors.Is(err, motion.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+
+	// The default threshold applies to the whole frame
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 10, 3), 100, 100); !result.Motion {
+		t.Errorf("Expected motion above the default threshold, got %+v", result)
+	}
+}
+
+// ========================================
+// Detect Zone Tests
+// ========================================
+
+func TestDetectZones_CenterRule(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.MotionThresholdPct = 1
+	zones := env.zones()
+
+	if _, err := zones.Create(model.MotionZone{Camera: "gate", Name: "driveway", Type: model.ZoneDetect,
+		Points: rect(0, 0.5, 1, 1), Enabled: true}); err != nil {
+		t.Fatalf("Failed to create zone: %v", err)
+	}
+
+	detections := []dto.DetectionResult{
+		{Label: "car", X: 10, Y: 10, Width: 20, Height: 20},    // parked across the street
+		{Label: "person", X: 40, Y: 40, Width: 20, Height: 30}, // centre at (50, 55)
+	}
+	kept := zones.FilterDetections("gate", detections, 100, 100)
+	if len(kept) != 1 || kept[0].Label != "person" || kept[0].Zone != "driveway" {
+		t.Errorf("Expected only the person inside the driveway, got %+v", kept)
+	}
+
+	if kept := zones.FilterDetections("garden", detections, 100, 100); len(kept) != 2 || kept[0].Zone != "" {
+		t.Errorf("Cameras without detect zones should keep every detection, got %+v", kept)
+	}
+
+	// Detect zones do not limit motion detection
+	if result := zones.Evaluate("gate", diffMask(100, 100, 0, 0, 20, 20), 100, 100); !result.Motion || result.Zone != "" {
+		t.Errorf("Expected whole-frame motion, got %+v", result)
+	}
+}
+
+func TestDetectZones_OverlapRules(t *testing.T) {
+	env := newTestEnv(t)
+
+	// A 20x20 box with its top 5 rows inside a zone covering the bottom half of a 100x100 frame:
+	// overlap is 100/400 = 25%, IoU is 100/(400+5000-100) = 1.9%
+	box := []dto.DetectionResult{{Label: "car", X: 40, Y: 35, Width: 20, Height: 20}}
+
+	tests := []struct {
+		rule      string
+		threshold float64
+		expected  int
+	}{
+		{motion.RuleCenter, 0, 0},
+		{motion.RuleOverlap, 25, 1},
+		{motion.RuleOverlap, 30, 0},
+		{motion.RuleIoU, 1.5, 1},
+		{motion.RuleIoU, 25, 0},
+	}
+
+	for _, tt := range tests {
+		env.cfg.DetectZoneRule, env.cfg.DetectZoneMinPct = tt.rule, tt.threshold
+		zones := env.zones()
+		if len(zones.GetAll("gate")) == 0 {
+			if _, err := zones.Create(model.MotionZone{Camera: "gate", Name: "driveway", Type: model.ZoneDetect,
+				Points: rect(0, 0.5, 1, 1), Enabled: true}); err != nil {
+				t.Fatalf("Failed to create zone: %v", err)
+			}
+		}
+
+		if kept := zones.FilterDetections("gate", box, 100, 100); len(kept) != tt.expected {
+			t.Errorf("%s at %.1f%%: expected %d detections, got %+v", tt.rule, tt.threshold, tt.expected, kept)
+		}
+	}
+}
+
+// ========================================
+// Motion Detector Selection Tests
+// ========================================
+
+func TestMotionStrategy_PerCamera(t *testing.T) {
+	cfg := &config.Config{
+		MotionDetector:  "average",
+		MotionDetectors: map[string]string{"gate": "MOG2", "garden": "optical-flow"},
+	}
+
+	tests := []struct {
+		camera   string
+		expected string
+	}{
+		{"gate", motion.StrategyMOG2},
+		{"garden", motion.StrategyDiff},
+		{"street", motion.StrategyAverage},
+	}
+
+	for _, tt := range tests {
+		if strategy := motion.Strategy(cfg, tt.camera); strategy != tt.expected {
+			t.Errorf("Camera %s: expected %s, got %s", tt.camera, tt.expected, strategy)
+		}
+	}
+
+	if strategy := motion.Strategy(&config.Config{}, "gate"); strategy != motion.StrategyDiff {
+		t.Errorf("Expected frame differencing by default, got %s", strategy)
+	}
+}
+
+// ========================================
+// Illumination Change Tests
+// ========================================
+
+func TestIlluminationCheck(t *testing.T) {
+	check := motion.NewIlluminationCheck(&config.Config{SceneLumaJump: 20, SceneChangePct: 50})
+
+	tests := []struct {
+		name           string
+		previousLuma   float64
+		luma           float64
+		changedPercent float64
+		expected       bool
+	}{
+		{"small movement", 100, 102, 3, false},
+		{"lights switched on", 40, 120, 90, true},
+		{"exposure drops", 150, 125, 10, true},
+		{"brightness just below the jump", 100, 119, 10, false},
+		{"most pixels changed", 100, 105, 55, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason, sceneChange := check.Check(tt.previousLuma, tt.luma, tt.changedPercent)
+			if sceneChange != tt.expected {
+				t.Errorf("Expected scene change %v, got %v (%s)", tt.expected, sceneChange, reason)
+			}
+			if sceneChange && reason == "" {
+				t.Error("A scene change should have a reason")
+			}
+		})
+	}
+
+	defaults := motion.NewIlluminationCheck(&config.Config{})
+	if _, sceneChange := defaults.Check(100, 100+motion.DefaultLumaJump, 0); !sceneChange {
+		t.Error("Expected the default brightness jump to be a scene change")
+	}
+}
@@ -0,0 +1,234 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/reconcile"
+	"webserver/internal/service/storage"
+)
+
+// failDetections makes inserting a detection labelled "boom" fail, returning a function
+// that removes the trigger again.
+func failDetections(t *testing.T, db *sqlite.DB) func() {
+	t.Helper()
+
+	_, err := db.Conn().Exec(`CREATE TRIGGER fail_detection BEFORE INSERT ON detections
+		WHEN NEW.object_name = 'boom' BEGIN SELECT RAISE(ABORT, 'boom'); END`)
+	if err != nil {
+		t.Fatalf("Failed to create trigger: %v", err)
+	}
+	return func() { db.Conn().Exec(`DROP TRIGGER fail_detection`) }
+}
+
+// flaggedMissing reports whether an image row is flagged as having no file.
+func flaggedMissing(t *testing.T, db *sqlite.DB, filename string) bool {
+	t.Helper()
+
+	var missing bool
+	if err := db.Conn().QueryRow(`SELECT missing FROM images WHERE filename = ?`, filename).Scan(&missing); err != nil {
+		t.Fatalf("Failed to read image %s: %v", filename, err)
+	}
+	return missing
+}
+
+// ========================================
+// Image Unit of Work Tests
+// ========================================
+
+func TestImageRepository_InsertWithDetections(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	imageRepo := sqlite.NewImageRepository(db)
+	detectionRepo := sqlite.NewDetectionRepository(db)
+
+	id, err := imageRepo.InsertWithDetections(&model.Image{Filename: "a.jpg", Camera: "gate", Timestamp: time.Now()},
+		[]model.Detection{{ObjectName: "car"}, {ObjectName: "person"}})
+	if err != nil {
+		t.Fatalf("Failed to insert: %v", err)
+	}
+	if names, _ := detectionRepo.GetObjectNamesByImageID(id); len(names) != 2 {
+		t.Errorf("Expected 2 detections of the new image, got %v", names)
+	}
+
+	defer failDetections(t, db)()
+	_, err = imageRepo.InsertWithDetections(&model.Image{Filename: "b.jpg", Camera: "gate", Timestamp: time.Now()},
+		[]model.Detection{{ObjectName: "car"}, {ObjectName: "boom"}})
+	if err == nil {
+		t.Fatal("Expected the insert to fail")
+	}
+	if img, _ := imageRepo.GetByFilename("b.jpg"); img != nil {
+		t.Error("Expected the image to be rolled back with its detections")
+	}
+}
+
+func TestBuffer_DatabaseFailureLeavesNoFile(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	buffer := env.buffer()
+
+	removeTrigger := failDetections(t, env.db)
+	filename := buffer.AddImage(encodeJPEG(t, 32, 24), "gate", detected("boom", 0.9))
+	buffer.FlushImages()
+
+	if stats := buffer.Stats(); !stats.Failing || stats.Backlog != 1 {
+		t.Errorf("Expected the image to stay spooled, got %+v", stats)
+	}
+	files := 0
+	filepath.WalkDir(imagesDir, func(path string, entry os.DirEntry, err error) error {
+		if err == nil && !entry.IsDir() {
+			files++
+		}
+		return nil
+	})
+	if files != 0 {
+		t.Errorf("Expected the file to be removed after the database error, found %d files", files)
+	}
+
+	removeTrigger()
+	buffer.FlushImages()
+	img, _ := imageRepo.GetByFilename(filename)
+	if img == nil {
+		t.Fatal("Expected the image to be stored once the database works again")
+	}
+	if names, _ := sqlite.NewDetectionRepository(env.db).GetObjectNamesByImageID(img.ID); len(names) != 1 {
+		t.Errorf("Expected the image with its detection, got %v", names)
+	}
+}
+
+// ========================================
+// Reconciliation Tests
+// ========================================
+
+func TestReconcile_RepairsAndQuarantines(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	env.cfg.ReconcileMaxMissing = 50
+	local := blob.NewLocalStore(imagesDir)
+	stores := blob.NewStores(local)
+	buffer := storage.NewBufferService(env.cfg, env.logger, stores, imageRepo, sqlite.NewImageVariantRepository(env.db))
+	reconciler := reconcile.NewReconcileService(env.cfg, env.logger, stores, imageRepo)
+
+	// A healthy image with its thumbnail and preview
+	healthy := buffer.AddImage(encodeJPEG(t, 64, 48), "gate", detected("car", 0.9))
+	buffer.FlushImages()
+
+	// Moved by an interrupted layout migration, still recorded flat
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	local.Put(storage.ImageKey("gate", ts, "moved.jpg"), []byte("moved"))
+	movedID, _ := imageRepo.Insert(&model.Image{Filename: "moved.jpg", Camera: "gate", Timestamp: ts,
+		FilePath: local.Location("moved.jpg")})
+
+	// A row whose file is gone, an old file without a row and one that may still be stored
+	imageRepo.Insert(&model.Image{Filename: "gone.jpg", Camera: "gate", Timestamp: ts, FilePath: local.Location("gone.jpg")})
+	local.Put("gate/2024/05/01/orphan.jpg", []byte("orphan"))
+	old := time.Now().Add(-time.Hour)
+	os.Chtimes(filepath.Join(imagesDir, "gate", "2024", "05", "01", "orphan.jpg"), old, old)
+	local.Put("gate/2024/05/01/fresh.jpg", []byte("fresh"))
+
+	if reconciler.LastReport() != nil {
+		t.Error("Expected no report before the first pass")
+	}
+
+	report, err := reconciler.Reconcile(true, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if report.Relinked != 1 || report.Flagged != 1 || report.Quarantined != 1 || report.Skipped != 1 || report.Failed != 0 {
+		t.Errorf("Unexpected dry run report %+v", report)
+	}
+	if img, _ := imageRepo.GetByID(movedID); img.FilePath != local.Location("moved.jpg") || flaggedMissing(t, env.db, "gone.jpg") {
+		t.Error("Expected a dry run to change nothing")
+	}
+
+	report, err = reconciler.Reconcile(false, time.Now())
+	if err != nil {
+		t.Fatalf("Failed to reconcile: %v", err)
+	}
+	if report.Rows != 3 || report.Relinked != 1 || report.Flagged != 1 || report.Quarantined != 1 || len(report.Items) != 3 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	if reconciler.LastReport() != report {
+		t.Error("Expected the last report to be kept")
+	}
+
+	if img, _ := imageRepo.GetByID(movedID); img.FilePath != local.Location("gate/2024/05/01/moved.jpg") {
+		t.Errorf("Expected the row to point to the moved file, got %s", img.FilePath)
+	}
+	if img, _ := imageRepo.GetByFilename("gone.jpg"); img == nil || !flaggedMissing(t, env.db, "gone.jpg") {
+		t.Error("Expected the row without a file to be kept and flagged")
+	}
+	if flaggedMissing(t, env.db, "moved.jpg") || flaggedMissing(t, env.db, healthy) {
+		t.Error("Expected only the row without a file to be flagged")
+	}
+	if _, err := os.Stat(filepath.Join(imagesDir, "quarantine", "gate", "2024", "05", "01", "orphan.jpg")); err != nil {
+		t.Errorf("Expected the file without a row in quarantine: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(imagesDir, "gate", "2024", "05", "01", "fresh.jpg")); err != nil {
+		t.Errorf("Expected the recent file to be left alone: %v", err)
+	}
+	if _, _, _, err := buffer.ReadImage(healthy, storage.VariantThumb); err != nil {
+		t.Errorf("Expected the healthy image to be untouched: %v", err)
+	}
+
+	report, _ = reconciler.Reconcile(false, time.Now())
+	if report.Relinked+report.Quarantined+report.Failed != 0 || report.Flagged != 1 {
+		t.Errorf("Expected a second pass to find only the missing file again, got %+v", report)
+	}
+
+	// The flag is cleared once the file is back
+	local.Put("gone.jpg", []byte("gone"))
+	if report, _ = reconciler.Reconcile(false, time.Now()); report.Flagged != 0 || flaggedMissing(t, env.db, "gone.jpg") {
+		t.Errorf("Expected the restored image to be unflagged, got %+v", report)
+	}
+}
+
+func TestReconcile_RefusesRepairOfUnavailableStore(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := filepath.Join(t.TempDir(), "images")
+	imageRepo := sqlite.NewImageRepository(env.db)
+	local := blob.NewLocalStore(imagesDir)
+	ts := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		local.Put(storage.ImageKey("gate", ts, name), []byte(name))
+		imageRepo.Insert(&model.Image{Filename: name, Camera: "gate", Timestamp: ts,
+			FilePath: local.Location(storage.ImageKey("gate", ts, name))})
+	}
+	env.cfg.ReconcileMaxMissing = 10
+	reconciler := reconcile.NewReconcileService(env.cfg, env.logger, blob.NewStores(local), imageRepo)
+
+	// One of three files gone is more than the allowed share
+	os.Remove(filepath.Join(imagesDir, "gate", "2024", "05", "01", "a.jpg"))
+	if _, err := reconciler.Reconcile(false, time.Now()); !errors.Is(err, reconcile.ErrTooManyMissing) {
+		t.Errorf("Expected the repair to be refused, got %v", err)
+	}
+
+	// An unmounted image directory lists no files at all
+	os.RemoveAll(imagesDir)
+	if _, err := reconciler.Reconcile(false, time.Now()); !errors.Is(err, reconcile.ErrTooManyMissing) {
+		t.Errorf("Expected the repair to be refused, got %v", err)
+	}
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		if flaggedMissing(t, env.db, name) {
+			t.Errorf("Expected %s to be left alone", name)
+		}
+	}
+
+	// A dry run still reports what it found
+	report, err := reconciler.Reconcile(true, time.Now())
+	if err != nil || report.Flagged != 3 {
+		t.Errorf("Expected a dry run to report 3 missing files, got %+v: %v", report, err)
+	}
+}
@@ -0,0 +1,289 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/recording"
+)
+
+// encodeJPEG returns a real JPEG image of the given dimensions.
+func encodeJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// readAVI returns the frame count from the main header and the number of idx1 entries.
+func readAVI(t *testing.T, path string) (frames uint32, indexed int) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read AVI file: %v", err)
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:12]) != "AVI " {
+		t.Fatalf("Missing RIFF/AVI header")
+	}
+	if riffSize := binary.LittleEndian.Uint32(data[4:]); int(riffSize) != len(data)-8 {
+		t.Errorf("RIFF size %d does not match file size %d", riffSize, len(data))
+	}
+
+	moviSize := binary.LittleEndian.Uint32(data[216:])
+	idx := 220 + int(moviSize)
+	if idx+8 > len(data) || string(data[idx:idx+4]) != "idx1" {
+		t.Fatalf("Missing idx1 chunk after movi list")
+	}
+	return binary.LittleEndian.Uint32(data[48:]), int(binary.LittleEndian.Uint32(data[idx+4:])) / 16
+}
+
+// ========================================
+// AVI Writer Tests
+// ========================================
+
+func TestAVIWriter_WritesIndexedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.avi")
+	writer, err := recording.CreateAVI(path, 64, 48)
+	if err != nil {
+		t.Fatalf("Failed to create AVI: %v", err)
+	}
+
+	frame := encodeJPEG(t, 64, 48)
+	for i := 0; i < 5; i++ {
+		if err := writer.WriteFrame(append(frame, byte(i))); err != nil {
+			t.Fatalf("Failed to write frame: %v", err)
+		}
+	}
+	if err := writer.Close(time.Second); err != nil {
+		t.Fatalf("Failed to close AVI: %v", err)
+	}
+
+	frames, indexed := readAVI(t, path)
+	if frames != 5 || indexed != 5 {
+		t.Errorf("Expected 5 frames and 5 index entries, got %d and %d", frames, indexed)
+	}
+	if info, _ := os.Stat(path); info.Size() != writer.Size() {
+		t.Errorf("Reported size %d does not match file size %d", writer.Size(), info.Size())
+	}
+}
+
+func TestAVIWriter_RecoverTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crash.avi")
+	writer, _ := recording.CreateAVI(path, 64, 48)
+	frame := encodeJPEG(t, 64, 48)
+	for i := 0; i < 3; i++ {
+		writer.WriteFrame(frame)
+	}
+	// Simulate a crash: flush frames but never write the index, then cut the last frame
+	writer.Close(time.Second)
+	info, _ := os.Stat(path)
+	os.Truncate(path, info.Size()-8-3*16-10)
+
+	recovered, err := recording.RecoverAVI(path)
+	if err != nil {
+		t.Fatalf("Failed to recover AVI: %v", err)
+	}
+	if recovered.Width() != 64 || recovered.Height() != 48 {
+		t.Errorf("Expected dimensions from header, got %dx%d", recovered.Width(), recovered.Height())
+	}
+	if err := recovered.Close(time.Second); err != nil {
+		t.Fatalf("Failed to close recovered AVI: %v", err)
+	}
+
+	if frames, indexed := readAVI(t, path); frames != 2 || indexed != 2 {
+		t.Errorf("Expected 2 complete frames after recovery, got %d and %d", frames, indexed)
+	}
+}
+
+// ========================================
+// Recording Service Tests
+// ========================================
+
+func TestRecording_OnlySelectedCameras(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.RecordCameras, env.cfg.RecordingSegmentS = []string{"gate"}, 300
+	recorder, repo := env.recorder(), sqlite.NewRecordingRepository(env.db)
+
+	recorder.WriteFrame("door", encodeJPEG(t, 32, 32))
+	recorder.WriteFrame("gate", encodeJPEG(t, 32, 32))
+	recorder.Stop()
+
+	recordings, err := repo.GetByRange("", time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatalf("Failed to list recordings: %v", err)
+	}
+	if len(recordings) != 1 || recordings[0].Camera != "gate" {
+		t.Fatalf("Expected a single gate recording, got %+v", recordings)
+	}
+	if !recordings[0].Complete || recordings[0].Frames != 1 {
+		t.Errorf("Expected a finalized one-frame segment, got %+v", recordings[0])
+	}
+}
+
+func TestRecording_FollowsRenamedCamera(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.RecordCameras, env.cfg.RecordingSegmentS = []string{"brama"}, 300
+	recorder, repo := env.recorder(), sqlite.NewRecordingRepository(env.db)
+
+	recorder.WriteFrame("brama", encodeJPEG(t, 32, 32))
+	recorder.Flush()
+	recorder.RenameCamera("brama", "gate")
+
+	if recorder.IsEnabled("brama") || !recorder.IsEnabled("gate") {
+		t.Fatal("Expected continuous recording to follow the new name")
+	}
+	recordings, _ := repo.GetByRange("brama", time.Time{}, time.Time{})
+	if len(recordings) != 1 || !recordings[0].Complete {
+		t.Fatalf("Expected the open segment to be closed on rename, got %+v", recordings)
+	}
+
+	recorder.WriteFrame("gate", encodeJPEG(t, 32, 32))
+	recorder.Stop()
+	if recordings, _ := repo.GetByRange("gate", time.Time{}, time.Time{}); len(recordings) != 1 || recordings[0].Frames != 1 {
+		t.Errorf("Expected a new segment under the new name, got %+v", recordings)
+	}
+}
+
+func TestRecording_RotatesSegments(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.RecordCameras, env.cfg.RecordingSegmentS = []string{"gate"}, 300
+	recorder, repo := env.recorder(), sqlite.NewRecordingRepository(env.db)
+
+	frame := encodeJPEG(t, 32, 32)
+	recorder.WriteFrame("gate", frame)
+	recorder.WriteFrame("gate", frame)
+
+	// A resolution change starts a new segment
+	recorder.WriteFrame("gate", encodeJPEG(t, 64, 32))
+
+	// Segments past their duration are closed by Rotate
+	recorder.Flush()
+	recorder.Rotate(time.Now().Add(301 * time.Second))
+
+	recordings, _ := repo.GetByRange("gate", time.Time{}, time.Time{})
+	if len(recordings) != 2 {
+		t.Fatalf("Expected 2 segments, got %d", len(recordings))
+	}
+	if recordings[0].Frames != 2 || recordings[1].Frames != 1 || recordings[1].Width != 64 {
+		t.Errorf("Unexpected segments: %+v", recordings)
+	}
+	for _, rec := range recordings {
+		if !rec.Complete {
+			t.Errorf("Segment %s should be complete", rec.Filename)
+		}
+		if frames, _ := readAVI(t, rec.FilePath); int(frames) != rec.Frames {
+			t.Errorf("File %s has %d frames, index says %d", rec.Filename, frames, rec.Frames)
+		}
+	}
+}
+
+func TestRecording_PrunesOldSegments(t *testing.T) {
+	env := newTestEnv(t)
+	repo := sqlite.NewRecordingRepository(env.db)
+	dir := env.cfg.RecordingDirectory
+	now := time.Now()
+	for i, age := range []time.Duration{10 * 24 * time.Hour, 3 * 24 * time.Hour, 2 * 24 * time.Hour, time.Hour} {
+		path := filepath.Join(dir, fmt.Sprintf("gate-%d.avi", i))
+		os.WriteFile(path, make([]byte, 1<<20), 0644)
+		repo.Insert(&model.Recording{Camera: "gate", Filename: filepath.Base(path), FilePath: path,
+			StartTime: now.Add(-age), EndTime: now.Add(-age).Add(5 * time.Minute), FileSize: 1 << 20, Complete: true})
+	}
+
+	env.cfg.RecordCameras, env.cfg.RecordingMaxDays, env.cfg.RecordingQuotaGB = []string{"gate"}, 7, 2.0/1024
+	recorder := env.recorder()
+
+	if removed := recorder.Prune(now); removed != 2 {
+		t.Errorf("Expected the expired segment and the oldest one over the quota to be removed, got %d", removed)
+	}
+	recordings, _ := repo.GetByRange("gate", time.Time{}, time.Time{})
+	if len(recordings) != 2 || recordings[0].Filename != "gate-2.avi" {
+		t.Errorf("Unexpected remaining segments: %+v", recordings)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "gate-0.avi")); !os.IsNotExist(err) {
+		t.Error("Expected the file of the expired segment to be deleted")
+	}
+}
+
+func TestRecording_RecoversUnfinishedSegments(t *testing.T) {
+	env := newTestEnv(t)
+	repo := sqlite.NewRecordingRepository(env.db)
+	path := filepath.Join(env.cfg.RecordingDirectory, "gate.avi")
+	writer, _ := recording.CreateAVI(path, 32, 32)
+	writer.WriteFrame(encodeJPEG(t, 32, 32))
+	writer.Close(0)
+
+	start := time.Now().Add(-time.Minute)
+	id, _ := repo.Insert(&model.Recording{Camera: "gate", Filename: "gate.avi", FilePath: path, StartTime: start, EndTime: start})
+
+	env.cfg.RecordCameras = []string{"gate"}
+	env.recorder()
+
+	rec, err := repo.GetByID(id)
+	if err != nil || rec == nil {
+		t.Fatalf("Failed to get recording: %v", err)
+	}
+	if !rec.Complete || rec.Frames != 1 || !rec.EndTime.After(start) {
+		t.Errorf("Unfinished segment should be finalized on startup, got %+v", rec)
+	}
+}
+
+// ========================================
+// Recording Repository Tests
+// ========================================
+
+func TestRecordingRepository_GetByRange(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	repo := sqlite.NewRecordingRepository(db)
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	for i, camera := range []string{"gate", "gate", "door"} {
+		start := base.Add(time.Duration(i) * 5 * time.Minute)
+		repo.Insert(&model.Recording{
+			Camera:    camera,
+			Filename:  start.Format("150405") + camera + ".avi",
+			StartTime: start,
+			EndTime:   start.Add(5 * time.Minute),
+			Complete:  true,
+		})
+	}
+
+	tests := []struct {
+		name     string
+		camera   string
+		from     time.Time
+		to       time.Time
+		expected int
+	}{
+		{"all", "", time.Time{}, time.Time{}, 3},
+		{"by camera", "gate", time.Time{}, time.Time{}, 2},
+		{"overlapping start", "gate", base.Add(7 * time.Minute), time.Time{}, 1},
+		{"overlapping end", "", time.Time{}, base.Add(2 * time.Minute), 1},
+		{"outside", "", base.Add(time.Hour), time.Time{}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recordings, err := repo.GetByRange(tt.camera, tt.from, tt.to)
+			if err != nil {
+				t.Fatalf("Failed to query recordings: %v", err)
+			}
+			if len(recordings) != tt.expected {
+				t.Errorf("Expected %d recordings, got %d", tt.expected, len(recordings))
+			}
+		})
+	}
+}
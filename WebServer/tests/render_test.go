@@ -0,0 +1,131 @@
+package tests
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/motion"
+	"webserver/internal/service/render"
+	"webserver/internal/service/storage"
+)
+
+// ========================================
+// Render Cache Tests
+// ========================================
+
+func TestRenderCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := render.NewCache(2)
+
+	cache.Put("a", []byte("a"))
+	cache.Put("b", []byte("b"))
+	cache.Get("a")
+	cache.Put("c", []byte("c"))
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("Expected the least recently used entry to be evicted")
+	}
+	if data, ok := cache.Get("a"); !ok || string(data) != "a" {
+		t.Error("Expected the recently read entry to be kept")
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Expected 2 entries, got %d", cache.Len())
+	}
+}
+
+func TestRenderCache_Disabled(t *testing.T) {
+	cache := render.NewCache(0)
+	cache.Put("a", []byte("a"))
+
+	if _, ok := cache.Get("a"); ok || cache.Len() != 0 {
+		t.Error("A cache without capacity should not keep images")
+	}
+}
+
+// ========================================
+// Render Service Tests
+// ========================================
+
+func TestRender_AnnotatesFromDatabase(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	frame := encodeJPEG(t, 64, 48)
+	if err := os.WriteFile(filepath.Join(imagesDir, "driveway.jpg"), frame, 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	imageRepo := sqlite.NewImageRepository(env.db)
+	detectionRepo := sqlite.NewDetectionRepository(env.db)
+	imageID, err := imageRepo.Insert(&model.Image{Filename: "driveway.jpg", Camera: "driveway", Timestamp: time.Now()})
+	if err != nil {
+		t.Fatalf("Failed to insert image: %v", err)
+	}
+	if err := detectionRepo.InsertBatch([]model.Detection{{ImageID: imageID, ObjectName: "car", X: 10, Y: 20,
+		Width: 30, Height: 15, Confidence: 0.87, Zone: "drive", TrackID: 7}}); err != nil {
+		t.Fatalf("Failed to insert detections: %v", err)
+	}
+
+	env.cfg.AnnotateCacheSize, env.cfg.MotionThresholdPct = 8, 1
+	zones := motion.NewZoneService(env.cfg, env.logger, sqlite.NewMotionZoneRepository(env.db))
+	zone, err := zones.Create(model.MotionZone{Camera: "driveway", Name: "drive", Type: model.ZoneDetect,
+		Points: rect(0, 0.5, 1, 1), Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create zone: %v", err)
+	}
+
+	var drawn []render.Overlay
+	draw := func(frame []byte, overlay render.Overlay) ([]byte, error) {
+		drawn = append(drawn, overlay)
+		return append([]byte("annotated:"), frame...), nil
+	}
+	renderer := render.NewRenderService(env.cfg, env.logger, env.buffer(),
+		imageRepo, detectionRepo, zones, draw)
+
+	rendered, err := renderer.Annotated("driveway.jpg")
+	if err != nil {
+		t.Fatalf("Failed to annotate: %v", err)
+	}
+	if string(rendered[:10]) != "annotated:" {
+		t.Error("Expected the drawn image to be returned")
+	}
+	if len(drawn) != 1 || len(drawn[0].Boxes) != 1 || len(drawn[0].Zones) != 1 {
+		t.Fatalf("Expected one box and one zone, got %+v", drawn)
+	}
+	if caption := drawn[0].Boxes[0].Caption; caption != "car (0.87) #7 @drive" {
+		t.Errorf("Unexpected caption %q", caption)
+	}
+
+	// A second request is served from the cache until the overlay changes
+	renderer.Annotated("driveway.jpg")
+	if len(drawn) != 1 {
+		t.Errorf("Expected the cached rendering to be reused, drew %d times", len(drawn))
+	}
+	zone.Enabled = false
+	if _, err := zones.Update(zone); err != nil {
+		t.Fatalf("Failed to update zone: %v", err)
+	}
+	renderer.Annotated("driveway.jpg")
+	if len(drawn) != 2 || len(drawn[1].Zones) != 0 {
+		t.Errorf("Expected a fresh rendering without the disabled zone, got %+v", drawn)
+	}
+}
+
+func TestRender_MissingImage(t *testing.T) {
+	cfg := &config.Config{ImageDirectory: t.TempDir(), SpoolDir: t.TempDir(), AnnotateCacheSize: 8}
+	draw := func(frame []byte, overlay render.Overlay) ([]byte, error) { return frame, nil }
+	renderer := render.NewRenderService(cfg, setupTestLogger(t), storage.NewBufferService(cfg, setupTestLogger(t), nil, nil, nil),
+		nil, nil, nil, draw)
+
+	for _, name := range []string{"missing.jpg", "../secret.jpg"} {
+		if _, err := renderer.Annotated(name); !errors.Is(err, fs.ErrNotExist) {
+			t.Errorf("Expected a not-exist error for %s, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+)
+
+// storedImage writes a small image file and records it with a detection of the label,
+// reporting the given size in megabytes.
+func storedImage(t *testing.T, db *sqlite.DB, dir, name, camera, label string, timestamp time.Time, megabytes int64) {
+	t.Helper()
+
+	if err := os.WriteFile(filepath.Join(dir, name), []byte("jpeg"), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	id, err := sqlite.NewImageRepository(db).Insert(&model.Image{Filename: name, Camera: camera, Timestamp: timestamp,
+		FilePath: filepath.Join(dir, name), FileSize: megabytes << 20})
+	if err != nil {
+		t.Fatalf("Failed to insert image: %v", err)
+	}
+	if err := sqlite.NewDetectionRepository(db).InsertBatch([]model.Detection{{ImageID: id, ObjectName: label}}); err != nil {
+		t.Fatalf("Failed to insert detection: %v", err)
+	}
+}
+
+// remaining returns which of the named images are still stored, on disk and in the database.
+func remaining(t *testing.T, db *sqlite.DB, dir string, names ...string) map[string]bool {
+	t.Helper()
+
+	repo := sqlite.NewImageRepository(db)
+	kept := make(map[string]bool)
+	for _, name := range names {
+		img, err := repo.GetByFilename(name)
+		if err != nil {
+			t.Fatalf("Failed to get image: %v", err)
+		}
+		_, statErr := os.Stat(filepath.Join(dir, name))
+		if (img != nil) != (statErr == nil) {
+			t.Errorf("Image %s should be removed from disk and database together", name)
+		}
+		kept[name] = img != nil
+	}
+	return kept
+}
+
+// ========================================
+// Retention Service Tests
+// ========================================
+
+func TestRetention_MaxAge(t *testing.T) {
+	env := newTestEnv(t)
+	dir := env.cfg.ImageDirectory
+	now := time.Now()
+	storedImage(t, env.db, dir, "gate-old.jpg", "gate", "person", now.Add(-40*24*time.Hour), 1)
+	storedImage(t, env.db, dir, "gate-new.jpg", "gate", "person", now.Add(-24*time.Hour), 1)
+	storedImage(t, env.db, dir, "yard-new.jpg", "yard", "cat", now.Add(-24*time.Hour), 1)
+
+	env.cfg.RetentionDays, env.cfg.RetentionCameraDays = 30, map[string]float64{"yard": 0.5}
+	service := env.retention()
+
+	if removed := service.Enforce(now); removed != 2 {
+		t.Errorf("Expected 2 images removed, got %d", removed)
+	}
+	kept := remaining(t, env.db, dir, "gate-old.jpg", "gate-new.jpg", "yard-new.jpg")
+	if kept["gate-old.jpg"] || !kept["gate-new.jpg"] || kept["yard-new.jpg"] {
+		t.Errorf("Expected only the recent gate image to be kept, got %v", kept)
+	}
+}
+
+func TestRetention_CameraQuotaKeepsPriorityLabels(t *testing.T) {
+	env := newTestEnv(t)
+	dir := env.cfg.ImageDirectory
+	now := time.Now()
+	storedImage(t, env.db, dir, "1-person.jpg", "gate", "person", now.Add(-3*time.Hour), 1)
+	storedImage(t, env.db, dir, "2-cat.jpg", "gate", "cat", now.Add(-2*time.Hour), 1)
+	storedImage(t, env.db, dir, "3-cat.jpg", "gate", "cat", now.Add(-time.Hour), 1)
+	storedImage(t, env.db, dir, "yard.jpg", "yard", "cat", now.Add(-4*time.Hour), 1)
+
+	env.cfg.CameraQuotasGB, env.cfg.RetentionPriority = map[string]float64{"gate": 1.5 / 1024}, []string{"person"}
+	service := env.retention()
+	service.Enforce(now)
+
+	kept := remaining(t, env.db, dir, "1-person.jpg", "2-cat.jpg", "3-cat.jpg", "yard.jpg")
+	if !kept["1-person.jpg"] || kept["2-cat.jpg"] || kept["3-cat.jpg"] || !kept["yard.jpg"] {
+		t.Errorf("Expected the gate cats to go before the older person, got %v", kept)
+	}
+}
+
+func TestRetention_TotalQuota(t *testing.T) {
+	env := newTestEnv(t)
+	dir := env.cfg.ImageDirectory
+	now := time.Now()
+	storedImage(t, env.db, dir, "gate-1.jpg", "gate", "car", now.Add(-3*time.Hour), 2)
+	storedImage(t, env.db, dir, "yard-1.jpg", "yard", "car", now.Add(-2*time.Hour), 2)
+	storedImage(t, env.db, dir, "gate-2.jpg", "gate", "car", now.Add(-time.Hour), 2)
+
+	env.cfg.StorageQuotaGB = 4.0 / 1024
+	service := env.retention()
+	if removed := service.Enforce(now); removed != 1 {
+		t.Errorf("Expected 1 image removed, got %d", removed)
+	}
+
+	kept := remaining(t, env.db, dir, "gate-1.jpg", "yard-1.jpg", "gate-2.jpg")
+	if kept["gate-1.jpg"] || !kept["yard-1.jpg"] || !kept["gate-2.jpg"] {
+		t.Errorf("Expected the oldest image across cameras to be removed, got %v", kept)
+	}
+
+	if removed := service.Enforce(now); removed != 0 {
+		t.Errorf("Expected nothing to remove within the quota, got %d", removed)
+	}
+}
+
+func TestRetention_QuotaCountsVariants(t *testing.T) {
+	env := newTestEnv(t)
+	dir := env.cfg.ImageDirectory
+	now := time.Now()
+	storedImage(t, env.db, dir, "gate-1.jpg", "gate", "car", now.Add(-2*time.Hour), 1)
+	storedImage(t, env.db, dir, "gate-2.jpg", "gate", "car", now.Add(-time.Hour), 1)
+
+	// The images alone fit the quota, their previews do not
+	for _, name := range []string{"gate-1.jpg", "gate-2.jpg"} {
+		img, _ := sqlite.NewImageRepository(env.db).GetByFilename(name)
+		sqlite.NewImageVariantRepository(env.db).Upsert(&model.ImageVariant{ImageID: img.ID, Size: "medium",
+			Filename: "medium/" + name, FileSize: 1 << 20})
+	}
+
+	env.cfg.StorageQuotaGB = 3.0 / 1024
+	service := env.retention()
+	if removed := service.Enforce(now); removed != 1 {
+		t.Errorf("Expected 1 image removed, got %d", removed)
+	}
+
+	kept := remaining(t, env.db, dir, "gate-1.jpg", "gate-2.jpg")
+	if kept["gate-1.jpg"] || !kept["gate-2.jpg"] {
+		t.Errorf("Expected the older image and its preview to be removed, got %v", kept)
+	}
+}
+
+func TestRetention_RemovedImageLeavesNoReferences(t *testing.T) {
+	env := newTestEnv(t)
+	dir := env.cfg.ImageDirectory
+	now := time.Now()
+	imageRepo := sqlite.NewImageRepository(env.db)
+	for name, confidence := range map[string]float64{"best.jpg": 0.9, "good.jpg": 0.7, "weak.jpg": 0.4} {
+		os.WriteFile(filepath.Join(dir, name), []byte("jpeg"), 0644)
+		imageRepo.InsertWithDetections(&model.Image{Filename: name, Camera: "gate", Timestamp: now,
+			FilePath: filepath.Join(dir, name)}, []model.Detection{{ObjectName: "car", Confidence: confidence}})
+	}
+
+	eventRepo := sqlite.NewEventRepository(env.db)
+	eventID, _ := eventRepo.Insert(&model.Event{Camera: "gate", StartTime: now, EndTime: now, Thumbnail: "best.jpg", ImageCount: 3})
+	for _, name := range []string{"best.jpg", "good.jpg", "weak.jpg"} {
+		eventRepo.AddImage(eventID, name)
+	}
+	clipRepo := sqlite.NewClipRepository(env.db)
+	clipID, _ := clipRepo.Insert(&model.Clip{Camera: "gate", Filename: "clip.avi", StartTime: now, EndTime: now})
+	clipRepo.AddImage(clipID, "best.jpg")
+	triggerRepo := sqlite.NewRuleTriggerRepository(env.db)
+	triggerRepo.Insert(&model.RuleTrigger{RuleID: 1, Type: "line", Camera: "gate", Image: "best.jpg", Timestamp: now})
+
+	buffer := env.buffer()
+	img, _ := imageRepo.GetByFilename("best.jpg")
+	if err := buffer.RemoveImage(*img); err != nil {
+		t.Fatalf("Failed to remove image: %v", err)
+	}
+
+	event, _ := eventRepo.GetByID(eventID)
+	if event == nil || event.Thumbnail != "good.jpg" || event.ImageCount != 2 {
+		t.Errorf("Expected the next best image as thumbnail of 2 images, got %+v", event)
+	}
+	if filenames, _ := eventRepo.GetImageFilenames(eventID); len(filenames) != 2 {
+		t.Errorf("Expected the removed image to be unlinked from the event, got %v", filenames)
+	}
+	if found, _ := clipRepo.GetByImage("best.jpg"); found != nil {
+		t.Errorf("Expected the removed image to be unlinked from its clip, got %+v", found)
+	}
+	if triggers, _ := triggerRepo.GetRecent("gate", 0, 10); len(triggers) != 1 || triggers[0].Image != "" {
+		t.Errorf("Expected the trigger to be kept without its image, got %+v", triggers)
+	}
+}
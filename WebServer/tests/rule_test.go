@@ -0,0 +1,181 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/rule"
+)
+
+// tracked returns a detection of a tracked object whose box centre is at (x, y) in a 100x100 frame.
+func tracked(trackID int64, label string, x, y int) []dto.DetectionResult {
+	return []dto.DetectionResult{{Label: label, TrackID: trackID, X: x - 5, Y: y - 5, Width: 10, Height: 10}}
+}
+
+// ========================================
+// Rule Evaluation Tests
+// ========================================
+
+func TestRules_LineCrossing(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.TrackMaxAgeS = 5
+	rules := env.rules()
+
+	// Horizontal line across the gate; moving down is to the right looking from A to B
+	if _, err := rules.Create(model.Rule{Camera: "gate", Name: "gate line", Type: model.RuleLineCrossing,
+		Labels: []string{"Person"}, Points: []model.Point{{X: 0.2, Y: 0.5}, {X: 0.8, Y: 0.5}},
+		Direction: model.DirectionRight, Enabled: true}); err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	now := time.Now()
+	steps := []struct {
+		detections []dto.DetectionResult
+		expected   int
+	}{
+		{tracked(1, "person", 50, 30), 0},
+		{tracked(1, "person", 50, 70), 1}, // crosses downwards
+		{tracked(1, "person", 50, 30), 0}, // crosses back upwards, wrong direction
+		{tracked(1, "person", 95, 30), 0},
+		{tracked(1, "person", 95, 70), 0}, // passes beside the end of the line
+		{tracked(2, "car", 50, 30), 0},
+		{tracked(2, "car", 50, 70), 0}, // other class
+	}
+	for i, step := range steps {
+		triggers := rules.Evaluate("gate", "img.jpg", step.detections, now.Add(time.Duration(i)*time.Second), 100, 100)
+		if len(triggers) != step.expected {
+			t.Fatalf("Step %d: expected %d triggers, got %+v", i, step.expected, triggers)
+		}
+		if len(triggers) == 1 && (triggers[0].Detail != model.DirectionRight || triggers[0].TrackID != 1 || triggers[0].ID == 0) {
+			t.Errorf("Step %d: unexpected trigger %+v", i, triggers[0])
+		}
+	}
+}
+
+func TestRules_ZoneEnterLeaveLoiter(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.TrackMaxAgeS = 5
+	rules, triggerRepo := env.rules(), sqlite.NewRuleTriggerRepository(env.db)
+
+	driveway := rect(0, 0.5, 1, 1)
+	for _, r := range []model.Rule{
+		{Camera: "gate", Name: "enter", Type: model.RuleZoneEnter, Points: driveway, Enabled: true},
+		{Camera: "gate", Name: "leave", Type: model.RuleZoneLeave, Points: driveway, Enabled: true},
+		{Camera: "gate", Name: "loiter", Type: model.RuleLoiter, Points: driveway, DwellSeconds: 10, Enabled: true},
+	} {
+		if _, err := rules.Create(r); err != nil {
+			t.Fatalf("Failed to create rule: %v", err)
+		}
+	}
+
+	now := time.Now()
+	steps := []struct {
+		offset     time.Duration
+		detections []dto.DetectionResult
+		expected   []string
+	}{
+		{0, tracked(1, "person", 50, 20), nil},
+		{time.Second, tracked(1, "person", 50, 60), []string{"enter"}},
+		{5 * time.Second, tracked(1, "person", 50, 70), nil},
+		{9 * time.Second, tracked(1, "person", 50, 75), nil},
+		{11 * time.Second, tracked(1, "person", 50, 80), []string{"loiter"}},
+		{12 * time.Second, tracked(1, "person", 50, 80), nil}, // loiter fires once
+		{13 * time.Second, tracked(1, "person", 50, 20), []string{"leave"}},
+		{14 * time.Second, tracked(1, "person", 50, 60), []string{"enter"}},
+		{30 * time.Second, nil, []string{"leave"}}, // lost inside the zone
+	}
+	for i, step := range steps {
+		triggers := rules.Evaluate("gate", "img.jpg", step.detections, now.Add(step.offset), 100, 100)
+		if len(triggers) != len(step.expected) {
+			t.Fatalf("Step %d: expected %v, got %+v", i, step.expected, triggers)
+		}
+		for j, name := range step.expected {
+			if triggers[j].RuleName != name {
+				t.Errorf("Step %d: expected rule %s, got %s", i, name, triggers[j].RuleName)
+			}
+		}
+	}
+
+	saved, err := triggerRepo.GetRecent("gate", 0, 0)
+	if err != nil {
+		t.Fatalf("Failed to get triggers: %v", err)
+	}
+	if len(saved) != 5 || saved[0].Type != model.RuleZoneLeave || saved[0].Detail != "lost" || saved[0].Image != "" {
+		t.Errorf("Expected 5 stored triggers, the newest for the lost object, got %+v", saved)
+	}
+	if loiter := saved[3]; loiter.Type != model.RuleLoiter || loiter.Detail != "10s" || loiter.Image != "img.jpg" {
+		t.Errorf("Unexpected loiter trigger: %+v", loiter)
+	}
+}
+
+// ========================================
+// Rule Management Tests
+// ========================================
+
+func TestRules_Validation(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.TrackMaxAgeS = 5
+	rules := env.rules()
+
+	line := []model.Point{{X: 0, Y: 0}, {X: 1, Y: 1}}
+	tests := []struct {
+		name string
+		rule model.Rule
+	}{
+		{"missing camera", model.Rule{Type: model.RuleLineCrossing, Points: line}},
+		{"unknown type", model.Rule{Camera: "gate", Type: "teleport", Points: line}},
+		{"line with 3 points", model.Rule{Camera: "gate", Type: model.RuleLineCrossing, Points: rect(0, 0, 1, 1)[:3]}},
+		{"bad direction", model.Rule{Camera: "gate", Type: model.RuleLineCrossing, Points: line, Direction: "up"}},
+		{"zone with 2 points", model.Rule{Camera: "gate", Type: model.RuleZoneEnter, Points: line}},
+		{"loiter without dwell", model.Rule{Camera: "gate", Type: model.RuleLoiter, Points: rect(0, 0, 1, 1)}},
+		{"point outside frame", model.Rule{Camera: "gate", Type: model.RuleZoneEnter, Points: rect(0, 0, 1, 1.5)}},
+	}
+
+	for _, tt := range tests {
+		if _, err := rules.Create(tt.rule); !errors.Is(err, rule.ErrInvalid) {
+			t.Errorf("%s: expected ErrInvalid, got %v", tt.name, err)
+		}
+	}
+}
+
+func TestRules_PersistAndRename(t *testing.T) {
+	env := newTestEnv(t)
+
+	rules := env.rules()
+	created, err := rules.Create(model.Rule{Camera: "gate", Name: " door ", Type: model.RuleZoneEnter,
+		Labels: []string{"person", " ", "dog"}, Points: rect(0, 0, 0.5, 0.5), Enabled: true})
+	if err != nil {
+		t.Fatalf("Failed to create rule: %v", err)
+	}
+
+	reloaded := env.rules()
+	saved, exists := reloaded.GetByID(created.ID)
+	if !exists || saved.Name != "door" || len(saved.Labels) != 2 || len(saved.Points) != 4 {
+		t.Fatalf("Expected the rule to be reloaded, got %+v", saved)
+	}
+
+	saved.Enabled = false
+	if _, err := reloaded.Update(saved); err != nil {
+		t.Fatalf("Failed to update rule: %v", err)
+	}
+	reloaded.RenameCamera("gate", "front")
+	if len(reloaded.GetAll("front")) != 1 || len(reloaded.GetAll("gate")) != 0 {
+		t.Error("Rules should move to the renamed camera")
+	}
+
+	if err := reloaded.Delete(created.ID); err != nil {
+		t.Fatalf("Failed to delete rule: %v", err)
+	}
+	if err := reloaded.Delete(created.ID); !errors.Is(err, rule.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	readOnly := rule.NewRuleService(env.cfg, env.logger, nil, nil)
+	if _, err := readOnly.Create(created); !errors.Is(err, rule.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly without a database, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/blob"
+	"webserver/internal/service/storage"
+)
+
+// spooled returns a buffered image with the given name and data.
+func spooled(name string, size int) dto.BufferedImage {
+	return dto.BufferedImage{Filename: name, Timestamp: "2024-05-01_10-00_00.000", Camera: "gate",
+		Detections: detected("car", 0.9), Data: make([]byte, size)}
+}
+
+// segmentFiles returns the number of spool segment files in dir.
+func segmentFiles(t *testing.T, dir string) int {
+	t.Helper()
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.spool"))
+	if err != nil {
+		t.Fatalf("Failed to list segments: %v", err)
+	}
+	return len(matches)
+}
+
+// ========================================
+// Spool Tests
+// ========================================
+
+func TestSpool_DrainsInOrderAcrossSegments(t *testing.T) {
+	dir := t.TempDir()
+	spool, err := storage.OpenSpool(dir, 1000, 0)
+	if err != nil {
+		t.Fatalf("Failed to open spool: %v", err)
+	}
+	defer spool.Close()
+
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		if err := spool.Append(spooled(name, 600)); err != nil {
+			t.Fatalf("Failed to append: %v", err)
+		}
+	}
+	if files := segmentFiles(t, dir); files != 3 {
+		t.Errorf("Expected a segment per record of this size, got %d", files)
+	}
+
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		image, err := spool.Peek()
+		if err != nil || image == nil || image.Filename != name {
+			t.Fatalf("Expected %s, got %+v: %v", name, image, err)
+		}
+		if again, _ := spool.Peek(); again.Filename != name {
+			t.Errorf("Peek should return the same image until acknowledged")
+		}
+		if len(image.Data) != 600 || image.Camera != "gate" || len(image.Detections) != 1 {
+			t.Errorf("Unexpected image %s: %d bytes from %s", name, len(image.Data), image.Camera)
+		}
+		if err := spool.Ack(); err != nil {
+			t.Fatalf("Failed to ack: %v", err)
+		}
+	}
+
+	if image, _ := spool.Peek(); image != nil {
+		t.Errorf("Expected the spool to be empty, got %s", image.Filename)
+	}
+	if records, size := spool.Backlog(); records != 0 || size != 0 {
+		t.Errorf("Expected no backlog, got %d records of %d bytes", records, size)
+	}
+	if files := segmentFiles(t, dir); files != 1 {
+		t.Errorf("Expected drained segments to be deleted, %d left", files)
+	}
+}
+
+func TestSpool_ReplaysAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	spool, _ := storage.OpenSpool(dir, 1<<20, 0)
+	spool.Append(spooled("a.jpg", 100))
+	spool.Append(spooled("b.jpg", 100))
+	spool.Close()
+
+	// A crash in the middle of writing a record leaves a torn tail
+	segment, _ := filepath.Glob(filepath.Join(dir, "*.spool"))
+	file, _ := os.OpenFile(segment[0], os.O_APPEND|os.O_WRONLY, 0644)
+	file.Write([]byte("SPL1\x00\x00\x00\x10"))
+	file.Close()
+
+	spool, err := storage.OpenSpool(dir, 1<<20, 0)
+	if err != nil {
+		t.Fatalf("Failed to reopen spool: %v", err)
+	}
+	defer spool.Close()
+
+	if records, _ := spool.Backlog(); records != 2 {
+		t.Errorf("Expected 2 records to replay, got %d", records)
+	}
+	if spool.Truncated() != 8 {
+		t.Errorf("Expected the torn record to be removed, truncated %d bytes", spool.Truncated())
+	}
+	if image, _ := spool.Peek(); image == nil || image.Filename != "a.jpg" {
+		t.Errorf("Expected to replay a.jpg first, got %+v", image)
+	}
+
+	// Appending after the cut-off keeps the journal readable
+	spool.Ack()
+	spool.Append(spooled("c.jpg", 100))
+	for _, name := range []string{"b.jpg", "c.jpg"} {
+		if image, err := spool.Peek(); err != nil || image == nil || image.Filename != name {
+			t.Fatalf("Expected %s, got %+v: %v", name, image, err)
+		}
+		spool.Ack()
+	}
+}
+
+func TestSpool_FullSpoolRejects(t *testing.T) {
+	spool, _ := storage.OpenSpool(t.TempDir(), 1<<20, 1000)
+	defer spool.Close()
+
+	if err := spool.Append(spooled("a.jpg", 600)); err != nil {
+		t.Fatalf("Failed to append: %v", err)
+	}
+	if err := spool.Append(spooled("b.jpg", 600)); !errors.Is(err, storage.ErrSpoolFull) {
+		t.Errorf("Expected the spool to be full, got %v", err)
+	}
+}
+
+func TestBuffer_SpoolSurvivesRestart(t *testing.T) {
+	env := newTestEnv(t)
+
+	imageRepo := sqlite.NewImageRepository(env.db)
+
+	// Accepted, but the process stops before draining
+	before := env.buffer()
+	first := before.AddImage(encodeJPEG(t, 32, 24), "gate", detected("car", 0.9))
+	second := before.AddImage(encodeJPEG(t, 32, 24), "gate", detected("car", 0.9))
+	if first == "" || second == "" {
+		t.Fatal("Expected the images to be accepted")
+	}
+
+	// The first image was stored just before the crash, but not removed from the spool
+	imageRepo.Insert(&model.Image{Filename: first, Camera: "gate", Timestamp: time.Now()})
+
+	after := env.buffer()
+	if stats := after.Stats(); stats.Backlog != 2 {
+		t.Errorf("Expected 2 images to replay, got %d", stats.Backlog)
+	}
+	after.FlushImages()
+
+	if img, _ := imageRepo.GetByFilename(second); img == nil {
+		t.Error("Expected the spooled image to be stored after the restart")
+	}
+	if count, _ := imageRepo.GetTotalCount(&dto.ImageFilters{}); count != 2 {
+		t.Errorf("Expected the replayed image not to be stored twice, got %d images", count)
+	}
+	if stats := after.Stats(); stats.Backlog != 0 || stats.Stored != 2 {
+		t.Errorf("Expected the spool to be drained, got %+v", stats)
+	}
+}
+
+func TestBuffer_ReportsDropsAndFailures(t *testing.T) {
+	// The spool fits one image, and the bucket is unreachable
+	s3, err := blob.NewS3Store(blob.S3Config{Endpoint: "http://127.0.0.1:1", Bucket: "cameras"})
+	if err != nil {
+		t.Fatalf("Failed to create S3 store: %v", err)
+	}
+	cfg := &config.Config{ImageDirectory: t.TempDir(), SpoolDir: t.TempDir(), SpoolMaxMB: 1}
+	buffer := storage.NewBufferService(cfg, setupTestLogger(t), blob.NewStores(s3), nil, nil)
+
+	if buffer.AddImage(make([]byte, 600<<10), "gate", nil) == "" {
+		t.Fatal("Expected the first image to be accepted")
+	}
+	if buffer.AddImage(make([]byte, 600<<10), "yard", nil) != "" {
+		t.Error("Expected the second image to be dropped")
+	}
+	buffer.FlushImages()
+
+	stats := buffer.Stats()
+	if stats.Accepted != 1 || stats.Dropped != 1 || stats.DroppedByCamera["yard"] != 1 {
+		t.Errorf("Unexpected counters %+v", stats)
+	}
+	if !stats.Failing || stats.Backlog != 1 || stats.Stored != 0 {
+		t.Errorf("Expected the image to stay spooled while storing fails, got %+v", stats)
+	}
+
+	missing := storage.NewBufferService(&config.Config{ImageDirectory: t.TempDir()}, setupTestLogger(t), nil, nil, nil)
+	if missing.AddImage([]byte("jpeg"), "gate", nil) != "" || missing.Stats().Dropped != 1 {
+		t.Error("Expected images to be dropped and counted without a spool")
+	}
+}
+
+func TestBuffer_DeadLettersImageThatKeepsFailing(t *testing.T) {
+	env := newTestEnv(t)
+	defer failDetections(t, env.db)()
+
+	imageRepo := sqlite.NewImageRepository(env.db)
+	buffer := env.buffer()
+	poison := buffer.AddImage(encodeJPEG(t, 32, 24), "gate", detected("boom", 0.9))
+	healthy := buffer.AddImage(encodeJPEG(t, 32, 24), "gate", detected("car", 0.9))
+
+	for i := 1; i < storage.SpoolMaxAttempts; i++ {
+		buffer.FlushImages()
+	}
+	if stats := buffer.Stats(); stats.Backlog != 2 || stats.DeadLetters != 0 {
+		t.Fatalf("Expected both images to wait while attempts remain, got %+v", stats)
+	}
+
+	buffer.FlushImages()
+	stats := buffer.Stats()
+	if stats.Backlog != 0 || stats.DeadLetters != 1 || stats.Stored != 1 || stats.Failing {
+		t.Errorf("Expected the failing image moved aside and the next one stored, got %+v", stats)
+	}
+	if img, _ := imageRepo.GetByFilename(poison); img != nil {
+		t.Error("Expected the failing image not to be stored")
+	}
+	if img, _ := imageRepo.GetByFilename(healthy); img == nil {
+		t.Error("Expected the image behind the failing one to be stored")
+	}
+
+	if stats := env.buffer().Stats(); stats.Backlog != 0 || stats.DeadLetters != 1 {
+		t.Errorf("Expected the dead letter to be kept but not replayed after a restart, got %+v", stats)
+	}
+}
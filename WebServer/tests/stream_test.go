@@ -0,0 +1,523 @@
+package tests
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/service/stream"
+)
+
+// ========================================
+// Test Setup Helpers
+// ========================================
+
+func testJPEG(size int) []byte {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	copy(data, []byte{0xFF, 0xD8})
+	copy(data[size-2:], []byte{0xFF, 0xD9})
+	return data
+}
+
+// splitFrame cuts a frame into framed packets with at most chunkSize payload bytes each.
+func splitFrame(frameID uint32, data []byte, chunkSize int) []*stream.Packet {
+	count := (len(data) + chunkSize - 1) / chunkSize
+	packets := make([]*stream.Packet, 0, count)
+	for i := 0; i < count; i++ {
+		end := (i + 1) * chunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		packets = append(packets, &stream.Packet{
+			Version:    stream.ProtocolVersion,
+			CameraID:   7,
+			FrameID:    frameID,
+			ChunkIndex: uint16(i),
+			ChunkCount: uint16(count),
+			Payload:    data[i*chunkSize : end],
+		})
+	}
+	return packets
+}
+
+// signed marks packets as authenticated within the given camera boot.
+func signed(boot uint32, packets []*stream.Packet) []*stream.Packet {
+	for _, pkt := range packets {
+		pkt.Flags |= stream.FlagAuthenticated
+		pkt.Boot = boot
+	}
+	return packets
+}
+
+func statsFor(t *testing.T, r *stream.ReassemblerService, camera string) (found bool, lost, incomplete, reordered uint64) {
+	t.Helper()
+	for _, s := range r.GetStats() {
+		if s.Camera == camera {
+			return true, s.Lost, s.Incomplete, s.Reordered
+		}
+	}
+	return false, 0, 0, 0
+}
+
+// ========================================
+// Packet Tests
+// ========================================
+
+func TestPacket_MarshalParseRoundTrip(t *testing.T) {
+	pkt := &stream.Packet{
+		Version:    stream.ProtocolVersion,
+		CameraID:   3,
+		FrameID:    123456,
+		ChunkIndex: 2,
+		ChunkCount: 5,
+		Payload:    []byte("chunk payload"),
+	}
+
+	parsed, err := stream.ParsePacket(pkt.Marshal())
+	if err != nil {
+		t.Fatalf("ParsePacket failed: %v", err)
+	}
+
+	if parsed.CameraID != 3 || parsed.FrameID != 123456 || parsed.ChunkIndex != 2 || parsed.ChunkCount != 5 {
+		t.Errorf("Header mismatch: %+v", parsed)
+	}
+	if !bytes.Equal(parsed.Payload, pkt.Payload) {
+		t.Errorf("Payload mismatch: %q", parsed.Payload)
+	}
+}
+
+func TestPacket_LegacyDataNotFramed(t *testing.T) {
+	if _, err := stream.ParsePacket(testJPEG(100)); !errors.Is(err, stream.ErrNotFramed) {
+		t.Errorf("Expected ErrNotFramed for raw JPEG, got %v", err)
+	}
+}
+
+func TestPacket_ChecksumMismatch(t *testing.T) {
+	pkt := &stream.Packet{Version: stream.ProtocolVersion, ChunkCount: 1, Payload: []byte("payload")}
+	data := pkt.Marshal()
+	data[len(data)-1] ^= 0xFF
+
+	if _, err := stream.ParsePacket(data); !errors.Is(err, stream.ErrChecksum) {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+}
+
+func TestPacket_SignedBootRoundTrip(t *testing.T) {
+	pkt := &stream.Packet{Version: stream.ProtocolVersion, ChunkCount: 1, Boot: 0xCAFE, Payload: []byte("payload")}
+	data := pkt.Sign([]byte("s3cret"))
+
+	parsed, err := stream.ParsePacket(data)
+	if err != nil {
+		t.Fatalf("ParsePacket failed: %v", err)
+	}
+	if parsed.Boot != 0xCAFE || !bytes.Equal(parsed.Payload, pkt.Payload) || !parsed.Verify([]byte("s3cret")) {
+		t.Errorf("Signed packet mismatch: %+v", parsed)
+	}
+
+	// Replaying the chunk under another boot counter breaks the tag
+	data[stream.HeaderSize+3] ^= 0x01
+	if parsed, _ := stream.ParsePacket(data); parsed.Verify([]byte("s3cret")) {
+		t.Error("Expected a changed boot counter to fail verification")
+	}
+}
+
+func TestPacket_InvalidChunkIndex(t *testing.T) {
+	pkt := &stream.Packet{Version: stream.ProtocolVersion, ChunkIndex: 3, ChunkCount: 3, Payload: []byte("x")}
+
+	if _, err := stream.ParsePacket(pkt.Marshal()); err == nil {
+		t.Error("Expected error for chunk index out of range")
+	}
+}
+
+// ========================================
+// Reassembler Tests
+// ========================================
+
+func TestReassembler_InOrderFrame(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+	frame := testJPEG(5000)
+
+	var result []byte
+	for _, pkt := range splitFrame(1, frame, 1400) {
+		if out := r.AddChunk("cam1", pkt); out != nil {
+			result = out
+		}
+	}
+
+	if !bytes.Equal(result, frame) {
+		t.Fatalf("Reassembled frame mismatch: got %d bytes, expected %d", len(result), len(frame))
+	}
+}
+
+func TestReassembler_ReorderedChunks(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+	frame := testJPEG(5000)
+	packets := splitFrame(1, frame, 1400)
+
+	// Deliver chunks in reverse order
+	var result []byte
+	for i := len(packets) - 1; i >= 0; i-- {
+		if out := r.AddChunk("cam1", packets[i]); out != nil {
+			result = out
+		}
+	}
+
+	if !bytes.Equal(result, frame) {
+		t.Fatal("Reordered chunks should still produce the original frame")
+	}
+
+	_, _, _, reordered := statsFor(t, r, "cam1")
+	if reordered != 1 {
+		t.Errorf("Expected 1 reordered frame, got %d", reordered)
+	}
+}
+
+func TestReassembler_InterleavedFrames(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+	first, second := testJPEG(3000), testJPEG(2500)
+	firstPackets, secondPackets := splitFrame(1, first, 1000), splitFrame(2, second, 1000)
+
+	var results [][]byte
+	for i := 0; i < 3; i++ {
+		if out := r.AddChunk("cam1", firstPackets[i]); out != nil {
+			results = append(results, out)
+		}
+		if out := r.AddChunk("cam1", secondPackets[i]); out != nil {
+			results = append(results, out)
+		}
+	}
+
+	if len(results) != 2 || !bytes.Equal(results[0], first) || !bytes.Equal(results[1], second) {
+		t.Fatalf("Expected both interleaved frames in order, got %d frames", len(results))
+	}
+}
+
+func TestReassembler_LostFrameCounted(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+
+	for _, id := range []uint32{1, 4} {
+		for _, pkt := range splitFrame(id, testJPEG(2000), 1000) {
+			r.AddChunk("cam1", pkt)
+		}
+	}
+
+	_, lost, _, _ := statsFor(t, r, "cam1")
+	if lost != 2 {
+		t.Errorf("Expected 2 lost frames, got %d", lost)
+	}
+}
+
+func TestReassembler_IncompleteFrameDroppedAfterTimeout(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 20
+	r := env.reassembler()
+	packets := splitFrame(1, testJPEG(3000), 1000)
+
+	r.AddChunk("cam1", packets[0])
+	r.AddChunk("cam1", packets[1])
+	time.Sleep(50 * time.Millisecond)
+
+	// The missing chunk arrives too late and must not complete the frame
+	if out := r.AddChunk("cam1", packets[2]); out != nil {
+		t.Fatal("Expired frame should not be delivered")
+	}
+
+	_, _, incomplete, _ := statsFor(t, r, "cam1")
+	if incomplete != 1 {
+		t.Errorf("Expected 1 incomplete frame, got %d", incomplete)
+	}
+}
+
+func TestReassembler_LateChunkAfterNewerFrame(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+	old := splitFrame(1, testJPEG(2000), 1000)
+
+	r.AddChunk("cam1", old[0])
+	for _, pkt := range splitFrame(2, testJPEG(2000), 1000) {
+		r.AddChunk("cam1", pkt)
+	}
+
+	if out := r.AddChunk("cam1", old[1]); out != nil {
+		t.Error("Frame older than the last delivered one should be dropped")
+	}
+
+	_, lost, incomplete, _ := statsFor(t, r, "cam1")
+	if incomplete != 1 || lost != 0 {
+		t.Errorf("Expected 1 incomplete and 0 lost, got %d incomplete and %d lost", incomplete, lost)
+	}
+}
+
+func TestReassembler_CameraRestart(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+
+	for _, pkt := range splitFrame(50000, testJPEG(1000), 1000) {
+		r.AddChunk("cam1", pkt)
+	}
+
+	var result []byte
+	for _, pkt := range splitFrame(0, testJPEG(1000), 1000) {
+		result = r.AddChunk("cam1", pkt)
+	}
+
+	if result == nil {
+		t.Error("Frame after camera restart should be delivered")
+	}
+}
+
+func TestReassembler_SignedStreamIgnoresOldFrames(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+
+	for _, pkt := range signed(1, splitFrame(50000, testJPEG(1000), 1000)) {
+		r.AddChunk("cam1", pkt)
+	}
+
+	// A recorded frame of the same boot is replayed far behind the current one
+	for _, pkt := range signed(1, splitFrame(10, testJPEG(1000), 1000)) {
+		if r.AddChunk("cam1", pkt) != nil {
+			t.Error("Expected an old frame of the same boot to be dropped")
+		}
+	}
+	if stats := r.GetStats()[0]; stats.Late != 1 {
+		t.Errorf("Expected the replayed chunk to count as late, got %+v", stats)
+	}
+}
+
+func TestReassembler_NewBootRestartsStream(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+
+	for _, pkt := range signed(1, splitFrame(50000, testJPEG(1000), 1000)) {
+		r.AddChunk("cam1", pkt)
+	}
+
+	var result []byte
+	for _, pkt := range signed(2, splitFrame(1, testJPEG(1000), 1000)) {
+		result = r.AddChunk("cam1", pkt)
+	}
+	if result == nil {
+		t.Fatal("Frame of a new boot should be delivered")
+	}
+
+	// Frames recorded in the earlier boot cannot restart the stream again
+	for _, pkt := range signed(1, splitFrame(50001, testJPEG(1000), 1000)) {
+		if r.AddChunk("cam1", pkt) != nil {
+			t.Error("Expected a frame of an earlier boot to be dropped")
+		}
+	}
+	if stats := r.GetStats()[0]; stats.Replayed != 1 {
+		t.Errorf("Expected 1 replayed chunk, got %+v", stats)
+	}
+}
+
+func TestReassembler_LegacyStream(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+	frame := testJPEG(3000)
+
+	var result []byte
+	for i := 0; i < len(frame); i += 1436 {
+		end := i + 1436
+		if end > len(frame) {
+			end = len(frame)
+		}
+		if out := r.AddLegacy("cam1", frame[i:end]); out != nil {
+			result = out
+		}
+	}
+
+	if !bytes.Equal(result, frame) {
+		t.Fatal("Legacy stream should reassemble the frame")
+	}
+
+	for _, s := range r.GetStats() {
+		if s.Camera == "cam1" && s.LegacyFrames != 1 {
+			t.Errorf("Expected 1 legacy frame, got %d", s.LegacyFrames)
+		}
+	}
+}
+
+func TestReassembler_LegacyOrphanChunkIgnored(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+
+	if out := r.AddLegacy("cam1", []byte{0x01, 0x02, 0xFF, 0xD9}); out != nil {
+		t.Error("Chunk without a frame start should not produce a frame")
+	}
+}
+
+func TestReassembler_StatsPerCamera(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.FrameTimeoutMs = 1000
+	r := env.reassembler()
+
+	r.AddChunk("cam1", splitFrame(1, testJPEG(500), 1000)[0])
+	r.AddLegacy("cam2", testJPEG(500))
+
+	stats := r.GetStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected stats for 2 cameras, got %d", len(stats))
+	}
+	if stats[0].Camera != "cam1" || stats[1].Camera != "cam2" {
+		t.Errorf("Expected stats sorted by camera, got %s, %s", stats[0].Camera, stats[1].Camera)
+	}
+}
+
+// ========================================
+// Identity Tests
+// ========================================
+
+// The cameras of the identity tests: "gate" signs its datagrams as device 7 and
+// "brama" is known by its address.
+var (
+	identityTokens = map[uint16]config.CameraToken{7: {Name: "gate", Secret: "s3cret"}}
+	identityNames  = map[string]string{"192.168.1.29": "brama"}
+)
+
+func TestIdentity_SignedPacketAccepted(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthToken
+	identity := env.identity()
+	pkt := splitFrame(1, testJPEG(500), 1000)[0]
+
+	parsed, err := stream.ParsePacket(pkt.Sign([]byte("s3cret")))
+	if err != nil {
+		t.Fatalf("ParsePacket failed: %v", err)
+	}
+
+	name, err := identity.Identify(parsed, "10.0.0.99")
+	if err != nil {
+		t.Fatalf("Expected signed packet to be accepted, got %v", err)
+	}
+	if name != "gate" {
+		t.Errorf("Expected camera 'gate', got %s", name)
+	}
+	if !bytes.Equal(parsed.Payload, pkt.Payload) {
+		t.Error("Payload should exclude the HMAC tag")
+	}
+}
+
+func TestIdentity_WrongSecretRejected(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthToken
+	identity := env.identity()
+	pkt := splitFrame(1, testJPEG(500), 1000)[0]
+
+	parsed, err := stream.ParsePacket(pkt.Sign([]byte("guess")))
+	if err != nil {
+		t.Fatalf("ParsePacket failed: %v", err)
+	}
+
+	if _, err := identity.Identify(parsed, "192.168.1.29"); !errors.Is(err, stream.ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature, got %v", err)
+	}
+}
+
+func TestIdentity_UnknownCameraRejected(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthToken
+	identity := env.identity()
+	pkt := splitFrame(1, testJPEG(500), 1000)[0]
+	pkt.CameraID = 99
+
+	parsed, _ := stream.ParsePacket(pkt.Sign([]byte("s3cret")))
+	if _, err := identity.Identify(parsed, "192.168.1.29"); !errors.Is(err, stream.ErrUnknownCamera) {
+		t.Errorf("Expected ErrUnknownCamera, got %v", err)
+	}
+}
+
+func TestIdentity_TokenModeRejectsUnsigned(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthToken
+	identity := env.identity()
+
+	parsed, _ := stream.ParsePacket(splitFrame(1, testJPEG(500), 1000)[0].Marshal())
+	if _, err := identity.Identify(parsed, "192.168.1.29"); !errors.Is(err, stream.ErrUnauthenticated) {
+		t.Errorf("Expected unsigned packet to be rejected, got %v", err)
+	}
+	if _, err := identity.IdentifyByIP("192.168.1.29"); !errors.Is(err, stream.ErrUnauthenticated) {
+		t.Errorf("Expected legacy traffic to be rejected, got %v", err)
+	}
+}
+
+func TestIdentity_IPFallbackMode(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthIP
+	identity := env.identity()
+
+	name, err := identity.IdentifyByIP("192.168.1.29")
+	if err != nil || name != "brama" {
+		t.Errorf("Expected known IP to map to 'brama', got %q (%v)", name, err)
+	}
+	if _, err := identity.IdentifyByIP("10.0.0.99"); err == nil {
+		t.Error("Unknown IP should be rejected even in IP fallback mode")
+	}
+}
+
+func TestIdentity_RejectionsTracked(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthToken
+	identity := env.identity()
+
+	identity.Reject("10.0.0.99", stream.ErrUnauthenticated)
+	identity.Reject("10.0.0.99", stream.ErrBadSignature)
+
+	rejections := identity.GetRejections()
+	if len(rejections) != 1 || rejections[0].Count != 2 {
+		t.Fatalf("Expected 2 rejections from one source, got %+v", rejections)
+	}
+	if rejections[0].LastReason != stream.ErrBadSignature.Error() {
+		t.Errorf("Expected last reason %q, got %q", stream.ErrBadSignature.Error(), rejections[0].LastReason)
+	}
+}
+
+func TestIdentity_EarlierBootRejectedAfterRestart(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.CameraTokens, env.cfg.CameraNames, env.cfg.CameraAuthMode = identityTokens, identityNames, config.CameraAuthToken
+	boot := func(counter uint32) *stream.Packet {
+		parsed, _ := stream.ParsePacket(signed(counter, splitFrame(1, testJPEG(500), 1000))[0].Sign([]byte("s3cret")))
+		return parsed
+	}
+
+	identity := env.identity()
+	recorded := boot(4)
+	if _, err := identity.Identify(recorded, "10.0.0.99"); err != nil {
+		t.Fatalf("Expected the first boot to be accepted, got %v", err)
+	}
+	if _, err := identity.Identify(boot(5), "10.0.0.99"); err != nil {
+		t.Fatalf("Expected a later boot to be accepted, got %v", err)
+	}
+
+	// After a server restart the recorded datagram must not take over the stream
+	restarted := env.identity()
+	if _, err := restarted.Identify(recorded, "10.0.0.99"); !errors.Is(err, stream.ErrReplayed) {
+		t.Errorf("Expected ErrReplayed for an earlier boot, got %v", err)
+	}
+	if _, err := restarted.Identify(boot(5), "10.0.0.99"); err != nil {
+		t.Errorf("Expected the current boot to be accepted, got %v", err)
+	}
+	if _, err := restarted.Identify(boot(6), "10.0.0.99"); err != nil {
+		t.Errorf("Expected a camera reboot to be accepted, got %v", err)
+	}
+}
@@ -0,0 +1,33 @@
+-- Database written by the first release, before migrations were tracked: images and
+-- detections only, without zones, track IDs or storage backends.
+CREATE TABLE images (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	filename TEXT NOT NULL UNIQUE,
+	camera TEXT NOT NULL,
+	timestamp DATETIME NOT NULL,
+	filepath TEXT NOT NULL,
+	filesize INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE detections (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	image_id INTEGER NOT NULL,
+	object_name TEXT NOT NULL,
+	x INTEGER DEFAULT 0,
+	y INTEGER DEFAULT 0,
+	width INTEGER DEFAULT 0,
+	height INTEGER DEFAULT 0,
+	confidence REAL DEFAULT 0,
+	FOREIGN KEY (image_id) REFERENCES images(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_images_camera ON images(camera);
+CREATE INDEX idx_images_timestamp ON images(timestamp);
+CREATE INDEX idx_detections_object_name ON detections(object_name);
+CREATE INDEX idx_detections_image_id ON detections(image_id);
+
+INSERT INTO images (filename, camera, timestamp, filepath, filesize)
+VALUES ('2024-05-01_10-00_00.000_gate_osoba_.jpg', 'gate', '2024-05-01 10:00:00', 'static/images/2024-05-01_10-00_00.000_gate_osoba_.jpg', 2048);
+INSERT INTO detections (image_id, object_name, x, y, width, height, confidence)
+VALUES (1, 'osoba', 10, 20, 30, 40, 0.9);
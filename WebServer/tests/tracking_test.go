@@ -0,0 +1,238 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"webserver/internal/config"
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/tracking"
+)
+
+// box returns a detection of the label with the given pixel box.
+func box(label string, x, y, width, height int) dto.DetectionResult {
+	return dto.DetectionResult{Label: label, Confidence: 0.9, X: x, Y: y, Width: width, Height: height}
+}
+
+// ========================================
+// Tracking Service Tests
+// ========================================
+
+func TestTracking_KeepsIDWhileObjectMoves(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	first := tracker.Update("gate", []dto.DetectionResult{box("person", 100, 100, 50, 100)}, now)
+	// Overlapping box one second later, then a jump no longer overlapping but within a box diagonal
+	second := tracker.Update("gate", []dto.DetectionResult{box("person", 110, 100, 50, 100)}, now.Add(time.Second))
+	third := tracker.Update("gate", []dto.DetectionResult{box("person", 170, 120, 50, 100)}, now.Add(2*time.Second))
+
+	if first[0].TrackID == 0 || first[0].TrackID != second[0].TrackID || second[0].TrackID != third[0].TrackID {
+		t.Fatalf("Expected one track, got IDs %d, %d, %d", first[0].TrackID, second[0].TrackID, third[0].TrackID)
+	}
+
+	active := tracker.Active("gate")
+	if len(active) != 1 || len(active[0].Path) != 3 || active[0].DwellSeconds != 2 {
+		t.Fatalf("Expected one 2s track with 3 points, got %+v", active)
+	}
+	if p := active[0].Path[2]; p.X != 195 || p.Y != 170 {
+		t.Errorf("Expected the path to end at the box centre (195, 170), got (%d, %d)", p.X, p.Y)
+	}
+}
+
+func TestTracking_FollowsRenamedCamera(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	first := tracker.Update("brama", []dto.DetectionResult{box("person", 100, 100, 50, 100)}, now)
+	tracker.RenameCamera("brama", "gate")
+	second := tracker.Update("gate", []dto.DetectionResult{box("person", 110, 100, 50, 100)}, now.Add(time.Second))
+
+	if first[0].TrackID != second[0].TrackID {
+		t.Errorf("Expected the track to continue under the new name, got IDs %d and %d", first[0].TrackID, second[0].TrackID)
+	}
+	if active := tracker.Active("gate"); len(active) != 1 || active[0].Camera != "gate" {
+		t.Errorf("Expected one track of camera gate, got %+v", active)
+	}
+}
+
+func TestTracking_SeparatesObjects(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	first := tracker.Update("gate", []dto.DetectionResult{
+		box("person", 0, 0, 50, 100),
+		box("person", 300, 0, 50, 100),
+	}, now)
+	if first[0].TrackID == first[1].TrackID {
+		t.Fatal("Two people in one frame should get different tracks")
+	}
+
+	// Both moved a little and are reported in the opposite order; a car appears at the first person
+	second := tracker.Update("gate", []dto.DetectionResult{
+		box("person", 305, 0, 50, 100),
+		box("car", 0, 0, 50, 100),
+		box("person", 5, 0, 50, 100),
+	}, now.Add(time.Second))
+
+	if second[0].TrackID != first[1].TrackID || second[2].TrackID != first[0].TrackID {
+		t.Errorf("Expected the people to keep their tracks, got %+v then %+v", first, second)
+	}
+	if second[1].TrackID == first[0].TrackID || second[1].TrackID == first[1].TrackID {
+		t.Error("An object with another label should start a new track")
+	}
+
+	other := tracker.Update("garden", []dto.DetectionResult{box("person", 0, 0, 50, 100)}, now.Add(time.Second))
+	if other[0].TrackID == first[0].TrackID {
+		t.Error("Tracks should not continue on another camera")
+	}
+}
+
+func TestTracking_ExpiresUnseenTracks(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{TrackMaxAgeS: 5}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	first := tracker.Update("gate", []dto.DetectionResult{box("dog", 0, 0, 40, 40)}, now)
+	within := tracker.Update("gate", []dto.DetectionResult{box("dog", 0, 0, 40, 40)}, now.Add(5*time.Second))
+	after := tracker.Update("gate", []dto.DetectionResult{box("dog", 0, 0, 40, 40)}, now.Add(11*time.Second))
+
+	if first[0].TrackID != within[0].TrackID {
+		t.Error("An object seen again within the maximum age should keep its track")
+	}
+	if after[0].TrackID == within[0].TrackID {
+		t.Error("An object unseen for longer than the maximum age should start a new track")
+	}
+	if active := tracker.Active("gate"); len(active) != 1 || active[0].ID != after[0].TrackID {
+		t.Errorf("Expected only the new track to be open, got %+v", active)
+	}
+}
+
+// ========================================
+// Track Persistence Tests
+// ========================================
+
+func TestTracking_PersistsTracks(t *testing.T) {
+	env := newTestEnv(t)
+	repo := sqlite.NewTrackRepository(env.db)
+	tracker := tracking.NewTrackingService(env.cfg, env.logger, repo)
+
+	start := time.Date(2025, 3, 10, 8, 0, 0, 0, time.Local)
+	var id int64
+	for i := 0; i < 3; i++ {
+		detections := tracker.Update("gate", []dto.DetectionResult{box("car", 10*i, 0, 100, 50)}, start.Add(time.Duration(i)*time.Second))
+		id = detections[0].TrackID
+	}
+	tracker.Update("garden", []dto.DetectionResult{box("person", 0, 0, 50, 100)}, start.Add(24*time.Hour))
+
+	saved, err := repo.GetByID(id)
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to get track: %v", err)
+	}
+	if saved.Camera != "gate" || saved.Label != "car" || saved.DwellSeconds != 2 || len(saved.Path) != 3 {
+		t.Errorf("Unexpected stored track: %+v", saved)
+	}
+	if !saved.StartTime.Equal(start) || saved.Path[1].X != 60 {
+		t.Errorf("Expected the path to start at %v with the second centre at x=60, got %+v", start, saved)
+	}
+
+	tests := []struct {
+		name     string
+		filter   dto.ImageFilters
+		expected int
+	}{
+		{"all", dto.ImageFilters{}, 2},
+		{"camera", dto.ImageFilters{Camera: "gate"}, 1},
+		{"label", dto.ImageFilters{Object: "person"}, 1},
+		{"date after", dto.ImageFilters{DateAfter: start.Add(24 * time.Hour)}, 1},
+	}
+	for _, tt := range tests {
+		filter := tt.filter
+		tracks, err := repo.GetAll(&filter)
+		if err != nil {
+			t.Fatalf("Failed to get tracks: %v", err)
+		}
+		count, _ := repo.GetTotalCount(&filter)
+		if len(tracks) != tt.expected || count != tt.expected {
+			t.Errorf("%s: expected %d tracks, got %d (count %d)", tt.name, tt.expected, len(tracks), count)
+		}
+	}
+}
+
+func TestTracking_DetectionTrackID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+	repo := sqlite.NewDetectionRepository(db)
+
+	if err := repo.InsertBatch([]model.Detection{{ImageID: 1, ObjectName: "person", TrackID: 7}}); err != nil {
+		t.Fatalf("Failed to insert detection: %v", err)
+	}
+	detections, err := repo.GetByImageID(1)
+	if err != nil || len(detections) != 1 || detections[0].TrackID != 7 {
+		t.Errorf("Expected track 7 to be stored with the detection, got %+v (%v)", detections, err)
+	}
+}
+
+// ========================================
+// Stationary Object Tests
+// ========================================
+
+func TestStationary_SuppressesUnchangedObjects(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{StationaryRefreshS: 600}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	car := box("car", 100, 100, 200, 100)
+	if tracker.Unchanged("driveway", []dto.DetectionResult{car}, now) {
+		t.Fatal("The first sighting of a car should be saved")
+	}
+	// The box jitters a little between detections
+	if !tracker.Unchanged("driveway", []dto.DetectionResult{box("car", 103, 98, 200, 100)}, now.Add(time.Minute)) {
+		t.Error("A parked car should not be saved again")
+	}
+	if tracker.Unchanged("yard", []dto.DetectionResult{car}, now.Add(time.Minute)) {
+		t.Error("Stationary objects are remembered per camera")
+	}
+
+	// A person walking past is new, so the frame is saved together with the car
+	withPerson := []dto.DetectionResult{car, box("person", 400, 100, 50, 100)}
+	if tracker.Unchanged("driveway", withPerson, now.Add(2*time.Minute)) {
+		t.Error("A frame with a new object should be saved")
+	}
+	if !tracker.Unchanged("driveway", withPerson, now.Add(3*time.Minute)) {
+		t.Error("The person standing still should be suppressed as well")
+	}
+
+	// The car drives off
+	if tracker.Unchanged("driveway", []dto.DetectionResult{box("car", 250, 100, 200, 100)}, now.Add(4*time.Minute)) {
+		t.Error("A car that moved should be saved")
+	}
+}
+
+func TestStationary_RefreshInterval(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{StationaryRefreshS: 600}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	car := []dto.DetectionResult{box("car", 100, 100, 200, 100)}
+	tracker.Unchanged("driveway", car, now)
+	if !tracker.Unchanged("driveway", car, now.Add(9*time.Minute)) {
+		t.Error("Expected the car to be suppressed within the refresh interval")
+	}
+	if tracker.Unchanged("driveway", car, now.Add(10*time.Minute)) {
+		t.Error("Expected the car to be saved again after the refresh interval")
+	}
+	if !tracker.Unchanged("driveway", car, now.Add(11*time.Minute)) {
+		t.Error("Expected the refresh to restart the interval")
+	}
+}
+
+func TestStationary_Disabled(t *testing.T) {
+	tracker := tracking.NewTrackingService(&config.Config{}, setupTestLogger(t), nil)
+
+	now := time.Now()
+	car := []dto.DetectionResult{box("car", 100, 100, 200, 100)}
+	tracker.Unchanged("driveway", car, now)
+	if tracker.Unchanged("driveway", car, now.Add(time.Second)) {
+		t.Error("Suppression should be off without STATIONARY_REFRESH")
+	}
+}
@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"webserver/internal/dto"
+	"webserver/internal/model"
+	"webserver/internal/repository/sqlite"
+	"webserver/internal/service/storage"
+)
+
+// ========================================
+// Image Variant Tests
+// ========================================
+
+func TestVariants_DownscaleAveragesPixels(t *testing.T) {
+	src := image.NewGray(image.Rect(0, 0, 4, 2))
+	// Left half black, right half white
+	for y := 0; y < 2; y++ {
+		for x := 2; x < 4; x++ {
+			src.SetGray(x, y, color.Gray{Y: 255})
+		}
+	}
+
+	scaled := storage.Downscale(src, 2)
+	if b := scaled.Bounds(); b.Dx() != 2 || b.Dy() != 1 {
+		t.Fatalf("Expected a 2x1 image, got %dx%d", b.Dx(), b.Dy())
+	}
+	if r, _, _, _ := scaled.At(0, 0).RGBA(); r != 0 {
+		t.Errorf("Expected the left pixel to stay black, got %d", r>>8)
+	}
+	if r, _, _, _ := scaled.At(1, 0).RGBA(); r>>8 != 255 {
+		t.Errorf("Expected the right pixel to stay white, got %d", r>>8)
+	}
+
+	if storage.Downscale(src, 10) != image.Image(src) {
+		t.Error("Narrower images should not be scaled up")
+	}
+}
+
+func TestVariants_CreatedOnFlush(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	variantRepo := sqlite.NewImageVariantRepository(env.db)
+	env.cfg.ThumbnailWidth, env.cfg.PreviewWidth = 32, 64
+	buffer := env.buffer()
+
+	filename := buffer.AddImage(encodeJPEG(t, 128, 96), "gate", []dto.DetectionResult{box("person", 0, 0, 10, 10)})
+	buffer.FlushImages()
+
+	img, err := imageRepo.GetByFilename(filename)
+	if err != nil || img == nil {
+		t.Fatalf("Expected the image to be stored, got %v", err)
+	}
+	variants, err := variantRepo.GetByImageID(img.ID)
+	if err != nil {
+		t.Fatalf("Failed to get variants: %v", err)
+	}
+	if len(variants) != 2 {
+		t.Fatalf("Expected 2 variants, got %d", len(variants))
+	}
+	if v := variants[0]; v.Size != storage.VariantThumb || v.Width != 32 || v.Height != 24 {
+		t.Errorf("Unexpected thumbnail %+v", v)
+	}
+	if v := variants[1]; v.Size != storage.VariantMedium || v.Width != 64 || v.Height != 48 {
+		t.Errorf("Unexpected preview %+v", v)
+	}
+	for _, v := range variants {
+		if _, err := os.Stat(filepath.Join(imagesDir, v.Filename)); err != nil {
+			t.Errorf("Expected the %s file on disk: %v", v.Size, err)
+		}
+	}
+
+	size, _ := imageRepo.GetDirectorySize()
+	if size != img.FileSize+variants[0].FileSize+variants[1].FileSize {
+		t.Errorf("Expected the directory size to include the variants, got %d", size)
+	}
+
+	if err := imageRepo.DeleteByFilename(filename); err != nil {
+		t.Fatalf("Failed to delete image: %v", err)
+	}
+	if variants, _ := variantRepo.GetByImageID(img.ID); len(variants) != 0 {
+		t.Errorf("Expected the variants to be deleted with the image, got %d", len(variants))
+	}
+}
+
+func TestVariants_Backfill(t *testing.T) {
+	env := newTestEnv(t)
+
+	imagesDir := env.cfg.ImageDirectory
+	imageRepo := sqlite.NewImageRepository(env.db)
+	variantRepo := sqlite.NewImageVariantRepository(env.db)
+
+	// Stored before variants existed, and one row whose file is gone
+	if err := os.WriteFile(filepath.Join(imagesDir, "old.jpg"), encodeJPEG(t, 640, 480), 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+	oldID, _ := imageRepo.Insert(&model.Image{Filename: "old.jpg", Camera: "gate", Timestamp: time.Now()})
+	imageRepo.Insert(&model.Image{Filename: "gone.jpg", Camera: "gate", Timestamp: time.Now()})
+
+	buffer := env.buffer()
+	buffer.BackfillVariants()
+
+	variants, _ := variantRepo.GetByImageID(oldID)
+	if len(variants) != 2 || variants[0].Width != storage.DefaultThumbnailWidth || variants[1].Width != 640 {
+		t.Errorf("Expected a default-width thumbnail and a full-width preview, got %+v", variants)
+	}
+
+	missing, err := variantRepo.GetMissing(0, len(storage.VariantSizes), 10)
+	if err != nil {
+		t.Fatalf("Failed to list images without variants: %v", err)
+	}
+	if len(missing) != 1 || missing[0].Filename != "gone.jpg" {
+		t.Errorf("Expected only the missing file to lack variants, got %+v", missing)
+	}
+}
+
+func TestVariants_NotSavedForDeletedImage(t *testing.T) {
+	env := newTestEnv(t)
+	imageRepo := sqlite.NewImageRepository(env.db)
+	variantRepo := sqlite.NewImageVariantRepository(env.db)
+
+	id, _ := imageRepo.Insert(&model.Image{Filename: "gone.jpg", Camera: "gate", Timestamp: time.Now()})
+	if err := imageRepo.DeleteByFilename("gone.jpg"); err != nil {
+		t.Fatalf("Failed to delete image: %v", err)
+	}
+
+	saved, err := variantRepo.Upsert(&model.ImageVariant{ImageID: id, Size: storage.VariantThumb, Filename: "thumb/gone.jpg"})
+	if err != nil || saved {
+		t.Errorf("Expected no variant for a deleted image, got %v: %v", saved, err)
+	}
+	if variants, _ := variantRepo.GetByImageID(id); len(variants) != 0 {
+		t.Errorf("Expected no variant rows left behind, got %+v", variants)
+	}
+}
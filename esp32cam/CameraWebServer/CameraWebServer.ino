@@ -2,6 +2,8 @@
 #include "time.h"
 #include <WiFi.h>
 #include <WiFiUDP.h>
+#include "mbedtls/md.h"
+#include <Preferences.h>
 
 //data for time synchronization
 const char* ntpServer = "pool.ntp.org";
@@ -18,6 +20,19 @@ IPAddress serverIp(192, 168, 1, 13);
 const int maxUdpPacketSize = 1436; 
 WiFiUDP udp;
 
+//framed protocol settings (see WebServer/internal/service/stream/packet.go)
+const uint16_t cameraId = 1;
+const char* cameraSecret = "change-me";  // must match CAMERA_TOKENS on the server
+const uint8_t protocolVersion = 1;
+const uint8_t flagAuthenticated = 0x01;
+const size_t headerSize = 18;
+const size_t bootSize = 4;
+const size_t tagSize = 16;
+const size_t maxChunkPayload = maxUdpPacketSize - headerSize - bootSize - tagSize;
+uint32_t frameId = 0;
+uint32_t bootCounter = 0; // kept in flash and raised every boot, so recorded packets cannot be replayed
+uint8_t packetBuffer[maxUdpPacketSize];
+
 sensor_t * sensor;
 
 
@@ -107,6 +122,13 @@ void setup() {
   Serial.print("Adres IP: ");
   Serial.println(WiFi.localIP());
 
+  // The server only accepts boot counters higher than the last one it has seen
+  Preferences preferences;
+  preferences.begin("stream", false);
+  bootCounter = preferences.getUInt("boot", 0) + 1;
+  preferences.putUInt("boot", bootCounter);
+  preferences.end();
+
   sensor = esp_camera_sensor_get();
   //sensor->set_vflip(sensor, 1);
   //sensor->set_hmirror(sensor, 1);
@@ -144,6 +166,43 @@ void CheckWifiConnection(){
     lastWiFiCheck = millis();
   }
 }
+// Standard CRC-32 (IEEE 802.3), same as Go's crc32.ChecksumIEEE
+uint32_t Crc32(const uint8_t *data, size_t length) {
+  uint32_t crc = 0xFFFFFFFF;
+  for (size_t i = 0; i < length; i++) {
+    crc ^= data[i];
+    for (int bit = 0; bit < 8; bit++) {
+      crc = (crc >> 1) ^ (0xEDB88320 & (-(int32_t)(crc & 1)));
+    }
+  }
+  return ~crc;
+}
+
+// Appends the first 16 bytes of HMAC-SHA256(secret, data) at data + length
+void SignPacket(uint8_t *data, size_t length) {
+  uint8_t digest[32];
+  mbedtls_md_context_t ctx;
+  mbedtls_md_init(&ctx);
+  mbedtls_md_setup(&ctx, mbedtls_md_info_from_type(MBEDTLS_MD_SHA256), 1);
+  mbedtls_md_hmac_starts(&ctx, (const unsigned char*)cameraSecret, strlen(cameraSecret));
+  mbedtls_md_hmac_update(&ctx, data, length);
+  mbedtls_md_hmac_finish(&ctx, digest);
+  mbedtls_md_free(&ctx);
+  memcpy(data + length, digest, tagSize);
+}
+
+void PutUint16(uint8_t *buf, uint16_t value) {
+  buf[0] = value >> 8;
+  buf[1] = value & 0xFF;
+}
+
+void PutUint32(uint8_t *buf, uint32_t value) {
+  buf[0] = value >> 24;
+  buf[1] = (value >> 16) & 0xFF;
+  buf[2] = (value >> 8) & 0xFF;
+  buf[3] = value & 0xFF;
+}
+
 void SendImage(){
   // Sending image every 'timerSend' ms
   if (millis() - lastImageSend > timerSend) {
@@ -160,14 +219,31 @@ void SendImage(){
     uint8_t *buffer = fb->buf;
     size_t length = fb->len;
     size_t offset = 0;
+    uint16_t chunkCount = (length + maxChunkPayload - 1) / maxChunkPayload;
+    uint16_t chunkIndex = 0;
+    frameId++;
 
     while(offset < length){ 
-      size_t chunkSize = min((size_t)maxUdpPacketSize, length - offset);
+      size_t chunkSize = min(maxChunkPayload, length - offset);
+
+      // Header: magic, version, flags, camera id, frame id, chunk index, chunk count, crc32, boot counter
+      packetBuffer[0] = 'S';
+      packetBuffer[1] = 'C';
+      packetBuffer[2] = protocolVersion;
+      packetBuffer[3] = flagAuthenticated;
+      PutUint16(packetBuffer + 4, cameraId);
+      PutUint32(packetBuffer + 6, frameId);
+      PutUint16(packetBuffer + 10, chunkIndex);
+      PutUint16(packetBuffer + 12, chunkCount);
+      PutUint32(packetBuffer + 14, Crc32(buffer + offset, chunkSize));
+      PutUint32(packetBuffer + headerSize, bootCounter);
+      memcpy(packetBuffer + headerSize + bootSize, buffer + offset, chunkSize);
+      SignPacket(packetBuffer, headerSize + bootSize + chunkSize);
       
       int beginResult = udp.beginPacket(serverIp, udpPort);
       
       if (beginResult == 1) {
-          udp.write(buffer + offset, chunkSize);
+          udp.write(packetBuffer, headerSize + bootSize + chunkSize + tagSize);
           int udpStatus = udp.endPacket();
           if (udpStatus == 0) {
              Serial.println("Error while sending package");
@@ -183,6 +259,7 @@ void SendImage(){
       }
 
       offset += chunkSize;
+      chunkIndex++;
       
     }
     esp_camera_fb_return(fb);